@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// createAccessList asks the node for the access list of msg with eth_createAccessList
+func createAccessList(client *ethclient.Client, msg ethereum.CallMsg) (types.AccessList, error) {
+	call := map[string]interface{}{"from": msg.From}
+	if msg.To != nil {
+		call["to"] = msg.To
+	}
+	if msg.Value != nil {
+		call["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if len(msg.Data) > 0 {
+		call["data"] = hexutil.Bytes(msg.Data)
+	}
+	var result struct {
+		AccessList types.AccessList `json:"accessList"`
+		GasUsed    hexutil.Uint64   `json:"gasUsed"`
+		Error      string           `json:"error"`
+	}
+	if err := client.Client().CallContext(opCtx, &result, "eth_createAccessList", call, "pending"); err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("the call fails: %s", result.Error)
+	}
+	return result.AccessList, nil
+}
+
+// accessListFor returns the access list of msg and the gas estimated with it, if it saves gas
+// over gas, the estimate without it. It returns a nil list when the list does not pay for the
+// 2400 gas per address and 1900 per storage key it costs, or cannot be generated
+func accessListFor(client *ethclient.Client, msg ethereum.CallMsg, gas uint64, nf numberFormat) (types.AccessList, uint64) {
+	list, err := createAccessList(client, msg)
+	if err != nil {
+		warnf("Failed to create an access list, sending without one: %v", err)
+		return nil, gas
+	}
+	if len(list) == 0 {
+		infof("Access list: empty, sending without one")
+		return nil, gas
+	}
+	msg.AccessList = list
+	withList, err := client.EstimateGas(opCtx, msg)
+	if err != nil {
+		warnf("Failed to estimate gas with the access list, sending without one: %s", describeCallError(err))
+		return nil, gas
+	}
+	if withList >= gas {
+		infof("Access list of %d address(es), %d storage key(s) does not save gas (%s with it, %s without), sending without one", len(list), list.StorageKeys(), nf.format(fmt.Sprint(withList)), nf.format(fmt.Sprint(gas)))
+		return nil, gas
+	}
+	infof("Access list of %d address(es), %d storage key(s) saves %s gas (%s with it, %s without)", len(list), list.StorageKeys(), nf.format(fmt.Sprint(gas-withList)), nf.format(fmt.Sprint(withList)), nf.format(fmt.Sprint(gas)))
+	return list, withList
+}
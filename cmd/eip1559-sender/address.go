@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// parseAddress parses a hex address entered by the user, rejecting mixed-case addresses with a
+// wrong EIP-55 checksum and, unless -noChecksum is set, single-case addresses that carry none
+func parseAddress(value string) (common.Address, error) {
+	if !common.IsHexAddress(value) {
+		return common.Address{}, fmt.Errorf("invalid address %q", value)
+	}
+	address := common.HexToAddress(value)
+	digits := strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	if digits == address.Hex()[2:] {
+		// also covers addresses without any letters, such as the zero address
+		return address, nil
+	}
+	if digits == strings.ToLower(digits) || digits == strings.ToUpper(digits) {
+		if !*noChecksumFlag {
+			return common.Address{}, fmt.Errorf("address %s has no EIP-55 checksum, use %s or pass -noChecksum", value, address.Hex())
+		}
+		return address, nil
+	}
+	return common.Address{}, fmt.Errorf("address %s has an invalid EIP-55 checksum, it may contain a typo", value)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+// addressBookFile is the address book's path below the user's config directory
+var addressBookFile = filepath.Join("eip1559-sender", "addressbook.yaml")
+
+// addressBookName matches the names of entries. They cannot contain dots, which mark ENS
+// names, and start with a letter, unlike addresses
+var addressBookName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// addressBookActions lists the actions of the addressbook subcommand
+var addressBookActions = []string{"add", "remove", "list"}
+
+// addressBookEntry is a named recipient. Chains, if set, restricts the chain IDs it may be
+// used on, for addresses that only exist on some chains such as a contract or an exchange
+type addressBookEntry struct {
+	Address string   `yaml:"address"`
+	Chains  []uint64 `yaml:"chains,omitempty"`
+}
+
+// addressBook maps names to entries
+type addressBook map[string]addressBookEntry
+
+// addressBookPath returns the path of the address book in the user's config directory
+func addressBookPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, addressBookFile), nil
+}
+
+// loadAddressBook reads the address book, which is empty if the file does not exist yet
+func loadAddressBook() (addressBook, string, error) {
+	path, err := addressBookPath()
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return addressBook{}, path, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	book := addressBook{}
+	if err := yaml.Unmarshal(data, &book); err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return book, path, nil
+}
+
+// save writes the address book to path, readable by the user only
+func (b addressBook) save(path string) error {
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// isAddressBookName reports whether value is meant as an address book name rather than an
+// address or ENS name
+func isAddressBookName(value string) bool {
+	return addressBookName.MatchString(value) && !common.IsHexAddress(value)
+}
+
+// lookupAddressBook returns the address of the entry name, refusing it on chains it is not
+// meant for
+func lookupAddressBook(name string, chainID *big.Int) (common.Address, error) {
+	book, path, err := loadAddressBook()
+	if err != nil {
+		return common.Address{}, err
+	}
+	entry, ok := book[name]
+	if !ok {
+		return common.Address{}, fmt.Errorf("%q is neither an address nor an entry of the address book %s", name, path)
+	}
+	if len(entry.Chains) > 0 {
+		allowed := false
+		for _, chain := range entry.Chains {
+			allowed = allowed || (chainID.IsUint64() && chain == chainID.Uint64())
+		}
+		if !allowed {
+			return common.Address{}, fmt.Errorf("address book entry %s is restricted to chain IDs %s, not %s", name, formatChains(entry.Chains), chainID)
+		}
+	}
+	if !common.IsHexAddress(entry.Address) {
+		return common.Address{}, fmt.Errorf("address book entry %s holds the invalid address %q", name, entry.Address)
+	}
+	return common.HexToAddress(entry.Address), nil
+}
+
+// resolveAddressBook replaces an address book name given for -receiver with its address
+func resolveAddressBook(chainID *big.Int) error {
+	if !isAddressBookName(*receiverFlag) {
+		return nil
+	}
+	address, err := lookupAddressBook(*receiverFlag, chainID)
+	if err != nil {
+		return err
+	}
+	infof("Resolved receiver %s to %s from the address book", *receiverFlag, address.Hex())
+	*receiverFlag = address.Hex()
+	return nil
+}
+
+// addressBookNames returns the sorted names of the address book, for shell completion
+func addressBookNames() []string {
+	book, _, err := loadAddressBook()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(book))
+	for name := range book {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatChains renders a list of chain IDs as "1, 8453"
+func formatChains(chains []uint64) string {
+	ids := make([]string, len(chains))
+	for i, chain := range chains {
+		ids[i] = strconv.FormatUint(chain, 10)
+	}
+	return strings.Join(ids, ", ")
+}
+
+// addressBookOptions holds the flags of the addressbook subcommand
+type addressBookOptions struct {
+	chains string
+}
+
+func newAddressBookFlagSet(action string, opts *addressBookOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("addressbook "+action, flag.ExitOnError)
+	if action == "add" {
+		fs.StringVar(&opts.chains, "chains", "", "Comma-separated chain IDs the entry may be used on (default: all)")
+		addRootFlags(fs, "noChecksum")
+	}
+	addRootFlags(fs, "v", "quiet", "logFormat")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s addressbook add <name> <address> [-chains 1,8453]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s addressbook remove <name>\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s addressbook list\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nNames stand for their address in -receiver. The address book is kept in the user's config directory.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runAddressBook implements the "addressbook add|remove|list" subcommand. The names and
+// addresses come before the flags
+func runAddressBook(args []string) {
+	positional := map[string]int{"add": 2, "remove": 1, "list": 0}
+	if len(args) == 0 {
+		newAddressBookFlagSet("add", &addressBookOptions{}).Usage()
+		exit(exitInvalid)
+	}
+	action := args[0]
+	count, ok := positional[action]
+	if !ok || len(args) < 1+count {
+		newAddressBookFlagSet("add", &addressBookOptions{}).Usage()
+		exit(exitInvalid)
+	}
+	var opts addressBookOptions
+	fs := newAddressBookFlagSet(action, &opts)
+	fs.Parse(args[1+count:])
+	if err := setupLogging(); err != nil {
+		exitf(exitInvalid, "Invalid logging options: %v", err)
+	}
+	if fs.NArg() > 0 {
+		fmt.Printf("Error: Unexpected arguments %s\n", strings.Join(fs.Args(), " "))
+		fs.Usage()
+		exit(exitInvalid)
+	}
+
+	book, path, err := loadAddressBook()
+	if err != nil {
+		fatalf("Failed to read the address book: %v", err)
+	}
+	switch action {
+	case "add":
+		name := args[1]
+		if !isAddressBookName(name) {
+			exitf(exitInvalid, "Invalid name %q: use letters, digits, - and _, starting with a letter", name)
+		}
+		if existing, ok := book[name]; ok {
+			exitf(exitInvalid, "%s is already in the address book as %s, remove it first", name, existing.Address)
+		}
+		address, err := parseAddress(args[2])
+		if err != nil {
+			exitf(exitInvalid, "Invalid address: %v", err)
+		}
+		entry := addressBookEntry{Address: address.Hex()}
+		if opts.chains != "" {
+			for _, field := range strings.Split(opts.chains, ",") {
+				chain, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+				if err != nil || chain == 0 {
+					exitf(exitInvalid, "Invalid chain ID %q in -chains", field)
+				}
+				entry.Chains = append(entry.Chains, chain)
+			}
+		}
+		book[name] = entry
+		if err := book.save(path); err != nil {
+			fatalf("Failed to write the address book: %v", err)
+		}
+		resultf([]interface{}{"name", name, "address", entry.Address}, "Added %s: %s", name, entry.Address)
+	case "remove":
+		name := args[1]
+		if _, ok := book[name]; !ok {
+			exitf(exitInvalid, "%s is not in the address book", name)
+		}
+		delete(book, name)
+		if err := book.save(path); err != nil {
+			fatalf("Failed to write the address book: %v", err)
+		}
+		resultf([]interface{}{"name", name}, "Removed %s", name)
+	case "list":
+		for _, name := range addressBookNames() {
+			entry := book[name]
+			chains := "all chains"
+			if len(entry.Chains) > 0 {
+				chains = "chain IDs " + formatChains(entry.Chains)
+			}
+			resultf([]interface{}{"name", name, "address", entry.Address, "chains", entry.Chains}, "%s: %s (%s)", name, entry.Address, chains)
+		}
+	}
+}
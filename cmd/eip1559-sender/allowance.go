@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// allowanceOptions holds the flags of the allowance subcommand
+type allowanceOptions struct {
+	owner   string
+	spender string
+}
+
+func newAllowanceFlagSet(opts *allowanceOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("allowance", flag.ExitOnError)
+	fs.StringVar(&opts.owner, "owner", "", "Address or address book name of the token holder (default: the account of the key source)")
+	fs.StringVar(&opts.spender, "spender", "", "Address or address book name of the spender")
+	addRootFlags(fs, "tokenContract", "token", "tokenList", "tokenABI", "decimals")
+	addRootFlags(fs, readFlags...)
+	addRootFlags(fs, keyFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s allowance -tokenContract 0x... -spender 0x... [-owner 0x...] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runAllowance implements the "allowance" subcommand
+func runAllowance(args []string) {
+	var opts allowanceOptions
+	fs := newAllowanceFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" || *tokenContract == "" || opts.spender == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	spender, err := resolveAllowed(opts.spender, chainID)
+	if err != nil {
+		exitf(exitInvalid, "Invalid spender: %v", err)
+	}
+	var owner common.Address
+	if opts.owner != "" {
+		if owner, err = resolveAllowed(opts.owner, chainID); err != nil {
+			exitf(exitInvalid, "Invalid owner: %v", err)
+		}
+	} else {
+		signer, err := loadSigner()
+		if err != nil {
+			exitf(keyExitCode(err), "Failed to load signing key (or give -owner): %v", err)
+		}
+		owner = signer.Address()
+	}
+
+	token, err := loadToken(client, *tokenContract, *tokenABIFlag)
+	if err != nil {
+		fatalf("Failed to load token contract: %v", err)
+	}
+	allowance, err := token.allowance(owner, spender)
+	if err != nil {
+		exitf(exitRPC, "Failed to get allowance: %v", err)
+	}
+	decimals, err := token.decimals()
+	if err != nil {
+		exitf(exitRPC, "Failed to get token decimals: %v", err)
+	}
+	symbol := token.symbol()
+	infof("Owner: %s", owner.Hex())
+	infof("Spender: %s", spender.Hex())
+	resultf([]interface{}{"owner", owner.Hex(), "spender", spender.Hex(), "token", token.address.Hex(), "allowance", allowance.String(), "decimals", decimals, "symbol", symbol, "unlimited", isUnlimited(allowance)},
+		"%s", formatAllowance(allowance, decimals, symbol, nf))
+}
+
+// isUnlimited tells whether allowance is the maximum an approve(spender, type(uint256).max) sets
+func isUnlimited(allowance *big.Int) bool {
+	return allowance.Cmp(math.MaxBig256) == 0
+}
+
+// formatAllowance renders an allowance in whole tokens, or as unlimited
+func formatAllowance(allowance *big.Int, decimals int, symbol string, nf numberFormat) string {
+	if isUnlimited(allowance) {
+		return "unlimited " + symbol
+	}
+	return nf.format(formatUnits(allowance, decimals)) + " " + symbol
+}
+
+// approveRefused tells whether owner's approve(spender, amount) fails without changing the
+// allowance, reverting or returning false
+func (t *erc20Token) approveRefused(owner, spender common.Address, amount *big.Int) bool {
+	data, err := t.abi.Pack("approve", spender, amount)
+	if err != nil {
+		return true
+	}
+	output, err := t.client.CallContract(opCtx, ethereum.CallMsg{From: owner, To: &t.address, Data: data}, nil)
+	// tokens such as USDT return nothing, the others a bool
+	return err != nil || (len(output) == 32 && bytes.Equal(output, make([]byte, 32)))
+}
+
+// needsReset tells whether the token only lets owner change its non-zero allowance for spender
+// to amount by setting it to 0 first, as USDT does against the approve front-running race
+func (t *erc20Token) needsReset(owner, spender common.Address, current, amount *big.Int) bool {
+	if current.Sign() == 0 || amount.Sign() == 0 {
+		return false
+	}
+	return t.approveRefused(owner, spender, amount) && !t.approveRefused(owner, spender, new(big.Int))
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// defaultApprovalDir is where transfers wait for approval below the user's config directory
+var defaultApprovalDir = filepath.Join("eip1559-sender", "approvals")
+
+// requestIDPattern matches the IDs of staged transfers, the start of their transaction hash
+var requestIDPattern = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+// stagedTx is a transfer above -approvalAbove, signed and waiting in -approvalDir for
+// "approve -requestID" to broadcast it
+type stagedTx struct {
+	signedTxFile
+	ID      string `json:"id"`
+	Summary string `json:"summary"`
+	// Approvers are the addresses one of which must sign the approval, empty for anyone
+	Approvers []common.Address `json:"approvers,omitempty"`
+}
+
+// approvalDir returns the path of -approvalDir
+func approvalDir() (string, error) {
+	if *approvalDirFlag != "" {
+		return *approvalDirFlag, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, defaultApprovalDir), nil
+}
+
+// checkApproval rejects -approvalAbove and -approvers where they cannot apply
+func checkApproval() error {
+	if *approvalAboveFlag == "" {
+		if *approversFlag != "" {
+			return errors.New("-approvers requires -approvalAbove")
+		}
+		return nil
+	}
+	switch {
+	case *batchFlag != "" || *stdinFlag:
+		return errors.New("-approvalAbove cannot be combined with -batch or -stdin")
+	case *exportFlag != "" || *signToFlag != "" || *offlineFlag:
+		return errors.New("-approvalAbove cannot be combined with -exportUnsigned, -signTo or -offline")
+	}
+	return nil
+}
+
+// stagedTransfer is what a transaction transfers: the tokens of an ERC-20 transfer call, the
+// native coin otherwise
+type stagedTransfer struct {
+	receiver *common.Address // nil for a contract deployment
+	amount   *big.Int
+	decimals int
+	symbol   string
+}
+
+// describeTransfer works out what tx transfers
+func describeTransfer(client *ethclient.Client, chainID *big.Int, tx *types.Transaction) (*stagedTransfer, error) {
+	chain := lookupChain(chainID)
+	t := &stagedTransfer{receiver: tx.To(), amount: tx.Value(), decimals: chain.decimals, symbol: chain.symbol}
+	data := tx.Data()
+	if tx.To() == nil || len(data) < 4 {
+		return t, nil
+	}
+	erc20 := mustLoadABI(erc20ABIJSON)
+	method, err := erc20.MethodById(data[:4])
+	if err != nil || (method.Name != "transfer" && method.Name != "transferFrom") {
+		return t, nil
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return t, nil
+	}
+	token, err := loadToken(client, tx.To().Hex(), *tokenABIFlag)
+	if err != nil {
+		return nil, err
+	}
+	if t.decimals, err = token.decimals(); err != nil {
+		return nil, fmt.Errorf("failed to get token decimals: %v", err)
+	}
+	receiver := args[len(args)-2].(common.Address)
+	t.receiver, t.amount, t.symbol = &receiver, args[len(args)-1].(*big.Int), token.symbol()
+	return t, nil
+}
+
+// stageIfLarge writes signedTx to -approvalDir instead of broadcasting it when it transfers more
+// than -approvalAbove, and tells whether it did
+func stageIfLarge(client *ethclient.Client, chainID *big.Int, from common.Address, signedTx *types.Transaction, nf numberFormat) (bool, error) {
+	if *approvalAboveFlag == "" {
+		return false, nil
+	}
+	t, err := describeTransfer(client, chainID, signedTx)
+	if err != nil {
+		return false, err
+	}
+	threshold, err := parseUnits(*approvalAboveFlag, t.decimals)
+	if err != nil {
+		return false, fmt.Errorf("invalid -approvalAbove: %v", err)
+	}
+	if t.amount.Cmp(threshold) <= 0 {
+		return false, nil
+	}
+	var approvers []common.Address
+	for _, entry := range strings.Split(*approversFlag, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		address, err := resolveAllowed(entry, chainID)
+		if err != nil {
+			return false, fmt.Errorf("invalid -approvers: %v", err)
+		}
+		approvers = append(approvers, address)
+	}
+	file, err := newSignedTxFile(chainID, from, signedTx)
+	if err != nil {
+		return false, err
+	}
+	summary := fmt.Sprintf("%s %s in a contract deployment", formatUnits(t.amount, t.decimals), t.symbol)
+	if t.receiver != nil {
+		summary = fmt.Sprintf("%s %s to %s", formatUnits(t.amount, t.decimals), t.symbol, t.receiver.Hex())
+	}
+	staged := stagedTx{
+		signedTxFile: file,
+		ID:           strings.TrimPrefix(signedTx.Hash().Hex(), "0x")[:12],
+		Summary:      summary,
+		Approvers:    approvers,
+	}
+	dir, err := approvalDir()
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return false, err
+	}
+	data, err := json.MarshalIndent(staged, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, staged.ID+".json"), append(data, '\n'), 0o600); err != nil {
+		return false, err
+	}
+	resultf([]interface{}{"requestId", staged.ID, "hash", signedTx.Hash().Hex()}, "%s %s is above -approvalAbove of %s %s, staged as request %s: approve it with \"approve -requestID %s\"",
+		nf.format(formatUnits(t.amount, t.decimals)), t.symbol, nf.format(*approvalAboveFlag), t.symbol, staged.ID, staged.ID)
+	if len(approvers) > 0 {
+		infof("The approval must be signed by one of %s", describeAddresses(approvers))
+	}
+	warnf("Nonce %d is kept for it: approve it before %s sends anything else, or the next transaction takes that nonce and the request has to be staged again", signedTx.Nonce(), from.Hex())
+	return true, nil
+}
+
+// describeAddresses lists addresses for the log
+func describeAddresses(addresses []common.Address) string {
+	hexes := make([]string, len(addresses))
+	for i, address := range addresses {
+		hexes[i] = address.Hex()
+	}
+	return strings.Join(hexes, ", ")
+}
+
+// runApproveRequest implements "approve -requestID": it broadcasts a staged transfer once its
+// approval is signed by one of its approvers, if it names any, and confirmed
+func runApproveRequest(id string) {
+	if !requestIDPattern.MatchString(id) {
+		exitf(exitInvalid, "Invalid -requestID %q, expected the 12 hex digits printed when the transfer was staged", id)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	dir, err := approvalDir()
+	if err != nil {
+		fatalf("Failed to find the approval directory: %v", err)
+	}
+	path := filepath.Join(dir, id+".json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		exitf(exitInvalid, "No request %s waits for approval in %s", id, dir)
+	}
+	if err != nil {
+		fatalf("Failed to read request %s: %v", id, err)
+	}
+	var staged stagedTx
+	if err := json.Unmarshal(data, &staged); err != nil {
+		exitf(exitInvalid, "Invalid request %s: %v", path, err)
+	}
+	tx, err := parseSignedTx(data)
+	if err != nil {
+		exitf(exitInvalid, "Invalid request %s: %v", path, err)
+	}
+	if staged.ID != id || !strings.HasPrefix(tx.Hash().Hex(), "0x"+id) {
+		exitf(exitInvalid, "Invalid request %s: it holds transaction %s", path, tx.Hash().Hex())
+	}
+	infof("Request %s: %s, staged by %s", id, staged.Summary, staged.From.Hex())
+
+	// without approvers anyone may approve, signing the approval only if a key is given
+	keyGiven := false
+	for _, given := range keySources() {
+		keyGiven = keyGiven || given
+	}
+	if len(staged.Approvers) > 0 || keyGiven {
+		if err := signApproval(&staged, tx); err != nil {
+			fatalf("Not approved: %v", err)
+		}
+	}
+	client, chainID := dialRPC()
+	if err := checkStagedNonce(client, staged.From, tx); err != nil {
+		exitf(exitInvalid, "Refusing to broadcast request %s: %v", id, err)
+	}
+	broadcastCheckedTx(client, chainID, tx, nf)
+	if *dryRunFlag {
+		return
+	}
+	// the transaction is on its way, a second approval would only be refused for its nonce
+	if err := os.Remove(path); err != nil {
+		warnf("Failed to remove request %s: %v", path, err)
+	}
+}
+
+// checkStagedNonce refuses tx, staged by from, if another transaction has taken its nonce since.
+// One that is only pending would be refused by the node as an underpriced replacement
+func checkStagedNonce(client *ethclient.Client, from common.Address, tx *types.Transaction) error {
+	pending, err := client.PendingNonceAt(opCtx, from)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %v", err)
+	}
+	if pending <= tx.Nonce() {
+		return nil
+	}
+	if _, _, err := client.TransactionByHash(opCtx, tx.Hash()); err == nil {
+		return fmt.Errorf("transaction %s was already broadcast", tx.Hash().Hex())
+	}
+	return fmt.Errorf("nonce %d was taken by another transaction of %s since the transfer was staged, stage it again", tx.Nonce(), from.Hex())
+}
+
+// signApproval has the signer of the key flags sign the approval of staged, which must not be its
+// own transfer and must come from one of its approvers if it names any
+func signApproval(staged *stagedTx, tx *types.Transaction) error {
+	signer, err := loadSigner()
+	if err != nil {
+		return fmt.Errorf("the request needs the signature of one of %s: %w", describeAddresses(staged.Approvers), err)
+	}
+	approver := signer.Address()
+	if approver == staged.From {
+		return fmt.Errorf("%s staged the transfer, another key must approve it", approver.Hex())
+	}
+	if len(staged.Approvers) > 0 && !slices.Contains(staged.Approvers, approver) {
+		return fmt.Errorf("%s is not one of the approvers %s", approver.Hex(), describeAddresses(staged.Approvers))
+	}
+	text, ok := signer.(textSigner)
+	if !ok {
+		return errors.New("the selected key source cannot sign messages")
+	}
+	message := fmt.Sprintf("Approve request %s: transaction %s on chain %d", staged.ID, tx.Hash().Hex(), staged.ChainID)
+	signature, err := text.SignText([]byte(message))
+	if err != nil {
+		return fmt.Errorf("failed to sign the approval: %v", err)
+	}
+	if signature[64] < 27 {
+		signature[64] += 27
+	}
+	resultf([]interface{}{"approver", approver.Hex(), "message", message, "signature", hexutil.Encode(signature)}, "Approved by %s at %s: %s",
+		approver.Hex(), time.Now().Format(time.RFC3339), hexutil.Encode(signature))
+	return nil
+}
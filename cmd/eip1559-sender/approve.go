@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// approveOptions holds the flags of the approve subcommand
+type approveOptions struct {
+	spender   string
+	amount    string
+	unlimited bool
+	requestID string
+}
+
+func newApproveFlagSet(opts *approveOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("approve", flag.ExitOnError)
+	fs.StringVar(&opts.spender, "spender", "", "Address allowed to spend the tokens")
+	fs.StringVar(&opts.amount, "amount", "", "Allowance in whole tokens (0 revokes the approval)")
+	fs.BoolVar(&opts.unlimited, "unlimited", false, "Approve the maximum amount instead of -amount")
+	fs.StringVar(&opts.requestID, "requestID", "", "Broadcast the transfer staged by -approvalAbove under this ID instead of approving a spender")
+	addSharedFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s approve -tokenContract 0x... -spender 0x... -amount 100|-unlimited [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s approve -requestID 0123456789ab -rpcURL https://... [-privateKeyEnv APPROVER_KEY] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runApprove implements the "approve" subcommand
+func runApprove(args []string) {
+	var opts approveOptions
+	fs := newApproveFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if opts.requestID != "" {
+		if *rpcURLFlag == "" || opts.spender != "" || opts.amount != "" || opts.unlimited {
+			fmt.Println("Error: Missing required parameters (-requestID needs -rpcURL, and takes no -spender, -amount or -unlimited)")
+			fs.Usage()
+			exit(exitInvalid)
+		}
+		runApproveRequest(opts.requestID)
+		return
+	}
+	if *rpcURLFlag == "" || *tokenContract == "" || opts.spender == "" || (opts.amount == "") == !opts.unlimited {
+		fmt.Println("Error: Missing required parameters (exactly one of -amount and -unlimited is required)")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	spender, err := parseAddress(opts.spender)
+	if err != nil {
+		exitf(exitInvalid, "Invalid spender: %v", err)
+	}
+
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	token, err := loadToken(client, *tokenContract, *tokenABIFlag)
+	if err == nil {
+		err = token.verify()
+	}
+	if err != nil {
+		fatalf("Failed to load token contract: %v", err)
+	}
+	symbol := token.symbol()
+	owner := signer.Address()
+	infof("Owner's address: %s", owner.Hex())
+	infof("Spender address: %s", spender.Hex())
+	decimals, err := token.decimals()
+	if err != nil {
+		exitf(exitRPC, "Failed to get token decimals: %v", err)
+	}
+
+	var amount *big.Int
+	switch {
+	case opts.unlimited:
+		amount = math.MaxBig256
+		warnf("An unlimited approval lets %s spend all of your %s, now and in the future", spender.Hex(), symbol)
+	case strings.Trim(opts.amount, "0.") == "":
+		amount = new(big.Int)
+	default:
+		if amount, err = parseUnits(opts.amount, decimals); err != nil {
+			exitf(exitInvalid, "Invalid amount: %v", err)
+		}
+	}
+
+	current, err := token.allowance(owner, spender)
+	if err != nil {
+		exitf(exitRPC, "Failed to get allowance: %v", err)
+	}
+	infof("Current allowance: %s", formatAllowance(current, decimals, symbol, nf))
+	delta := ""
+	switch diff := new(big.Int).Sub(amount, current); {
+	case diff.Sign() == 0:
+		delta = " (unchanged)"
+	case isUnlimited(amount) || isUnlimited(current):
+	case diff.Sign() > 0:
+		delta = fmt.Sprintf(" (+%s)", nf.format(formatUnits(diff, decimals)))
+	default:
+		delta = fmt.Sprintf(" (-%s)", nf.format(formatUnits(diff.Neg(diff), decimals)))
+	}
+	infof("New allowance: %s%s", formatAllowance(amount, decimals, symbol, nf), delta)
+
+	if token.needsReset(owner, spender, current, amount) {
+		resetAllowance(client, signer, chainID, token, spender, nf)
+	}
+	data, err := token.abi.Pack("approve", spender, amount)
+	if err != nil {
+		fatalf("Failed to pack approve call: %v", err)
+	}
+	tx := newTransaction(client, signer.Address(), chainID, &token.address, new(big.Int), data, nf)
+	sendAndFollow(client, signer, chainID, tx, nf)
+}
+
+// resetAllowance sets the allowance of spender to 0, for tokens such as USDT that only change a
+// non-zero allowance through 0. It waits for the reset to be mined, as the new allowance can only
+// be estimated after it; a dry run only simulates the reset and exits
+func resetAllowance(client *ethclient.Client, signer sender.Signer, chainID *big.Int, token *erc20Token, spender common.Address, nf numberFormat) {
+	symbol := token.symbol()
+	if *exportFlag != "" || *signToFlag != "" {
+		exitf(exitInvalid, "%s only changes a non-zero allowance through 0: approve -amount 0 first, and the new allowance once that is mined", symbol)
+	}
+	infof("%s only changes a non-zero allowance through 0, sending two transactions: approve 0, then the new allowance once that is mined", symbol)
+	data, err := token.abi.Pack("approve", spender, new(big.Int))
+	if err != nil {
+		fatalf("Failed to pack approve call: %v", err)
+	}
+	tx := newTransaction(client, signer.Address(), chainID, &token.address, new(big.Int), data, nf)
+	if *dryRunFlag {
+		sendAndFollow(client, signer, chainID, tx, nf)
+		infof("Dry run: the new allowance would be approved once the reset to 0 is mined")
+		exit(0)
+	}
+	wait := *waitFlag
+	*waitFlag = true
+	sendAndFollow(client, signer, chainID, tx, nf)
+	*waitFlag = wait
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// balanceOptions holds the flags of the balance subcommand
+type balanceOptions struct {
+	address string
+}
+
+func newBalanceFlagSet(opts *balanceOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("balance", flag.ExitOnError)
+	fs.StringVar(&opts.address, "address", "", "Address or ENS name to look up (default: the account of the key source)")
+	addRootFlags(fs, "tokenContract", "token", "tokenList", "tokenABI", "decimals")
+	addRootFlags(fs, readFlags...)
+	addRootFlags(fs, keyFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s balance -address 0x... [-tokenContract 0x...] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runBalance implements the "balance" subcommand
+func runBalance(args []string) {
+	var opts balanceOptions
+	fs := newBalanceFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+
+	var owner common.Address
+	switch {
+	case isENSName(opts.address):
+		if owner, err = resolveENS(client, opts.address); err != nil {
+			fatalf("Failed to resolve ENS name: %v", err)
+		}
+		infof("Resolved %s to %s", opts.address, owner.Hex())
+	case opts.address != "":
+		if owner, err = parseAddress(opts.address); err != nil {
+			exitf(exitInvalid, "Invalid address: %v", err)
+		}
+	default:
+		signer, err := loadSigner()
+		if err != nil {
+			exitf(keyExitCode(err), "Failed to load signing key (or give -address): %v", err)
+		}
+		owner = signer.Address()
+	}
+	infof("Address: %s", owner.Hex())
+
+	chain := lookupChain(chainID)
+	balance, err := client.BalanceAt(opCtx, owner, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get balance: %v", err)
+	}
+	amount := formatUnits(balance, chain.decimals)
+	resultf([]interface{}{"address", owner.Hex(), "balance", balance.String(), "decimals", chain.decimals, "symbol", chain.symbol}, "%s %s", nf.format(amount), chain.symbol)
+
+	if *tokenContract == "" {
+		return
+	}
+	token, err := loadToken(client, *tokenContract, *tokenABIFlag)
+	if err != nil {
+		fatalf("Failed to load token contract: %v", err)
+	}
+	decimals, err := token.decimals()
+	if err != nil {
+		exitf(exitRPC, "Failed to get token decimals: %v", err)
+	}
+	tokens, err := token.balanceOf(owner)
+	if err != nil {
+		exitf(exitRPC, "Failed to get token balance: %v", err)
+	}
+	symbol := token.symbol()
+	resultf([]interface{}{"address", owner.Hex(), "token", token.address.Hex(), "balance", tokens.String(), "decimals", decimals, "symbol", symbol}, "%s %s", nf.format(formatUnits(tokens, decimals)), symbol)
+}
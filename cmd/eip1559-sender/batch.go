@@ -0,0 +1,629 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// decimalAmount matches the plain decimal amounts accepted in batch files
+var decimalAmount = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// batchTransfer is one row of a batch file
+type batchTransfer struct {
+	Receiver string      `json:"receiver"`
+	Amount   json.Number `json:"amount"`
+	Token    string      `json:"token,omitempty"`
+	Memo     string      `json:"memo,omitempty"`
+
+	row    int
+	from   common.Address // the sender of hash, which differs between rows with a sender pool
+	hash   common.Hash
+	nonce  uint64
+	status string
+}
+
+// validate checks the receiver, amount and token contract of t
+func (t *batchTransfer) validate() error {
+	if _, err := parseAddress(t.Receiver); err != nil {
+		return fmt.Errorf("invalid receiver: %v", err)
+	}
+	if *splitFlag == "equal" {
+		// the amount is the share of -amount, worked out once the decimals are known
+		if t.Amount != "" {
+			return fmt.Errorf("amount %s given, but -split equal sets every amount", t.Amount)
+		}
+	} else if !decimalAmount.MatchString(t.Amount.String()) {
+		return fmt.Errorf("invalid amount %q", t.Amount)
+	}
+	if t.Token != "" && !common.IsHexAddress(t.Token) {
+		return fmt.Errorf("invalid token contract %q", t.Token)
+	}
+	return nil
+}
+
+// tokenDecimals caches the decimals() of the tokens in a batch, shared by concurrent rows
+type tokenDecimals struct {
+	mu      sync.Mutex
+	byToken map[string]int
+}
+
+func (d *tokenDecimals) get(token *erc20Token) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := token.address.Hex()
+	if decimals, ok := d.byToken[key]; ok {
+		return decimals, nil
+	}
+	if err := token.verify(); err != nil {
+		return 0, err
+	}
+	decimals, err := token.decimals()
+	if err != nil {
+		return 0, err
+	}
+	d.byToken[key] = decimals
+	return decimals, nil
+}
+
+// loadBatch reads transfers from a CSV (receiver,amount[,token[,memo]]) or JSON file
+func loadBatch(path string) ([]*batchTransfer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var transfers []*batchTransfer
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(f)
+		dec.UseNumber()
+		if err := dec.Decode(&transfers); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+	} else {
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		r.Comment = '#'
+		r.TrimLeadingSpace = true
+		for line := 1; ; line++ {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+			}
+			// skip an optional header row
+			if line == 1 && !common.IsHexAddress(record[0]) {
+				continue
+			}
+			if *splitFlag == "equal" {
+				if len(record) != 1 {
+					return nil, fmt.Errorf("%s:%d: expected only the receiver with -split equal", path, line)
+				}
+				record = append(record, "")
+			}
+			if len(record) < 2 || len(record) > 4 {
+				return nil, fmt.Errorf("%s:%d: expected receiver,amount[,token[,memo]]", path, line)
+			}
+			t := &batchTransfer{Receiver: record[0], Amount: json.Number(record[1])}
+			if len(record) >= 3 {
+				t.Token = record[2]
+			}
+			if len(record) == 4 {
+				t.Memo = record[3]
+			}
+			transfers = append(transfers, t)
+		}
+	}
+
+	for i, t := range transfers {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("row %d: %v", i+1, err)
+		}
+		t.row = i + 1
+	}
+	if len(transfers) == 0 {
+		return nil, fmt.Errorf("%s contains no transfers", path)
+	}
+	return transfers, nil
+}
+
+// runBatch sends every transfer of the -batch file with sequential nonces and prints a summary
+func runBatch(client *ethclient.Client, pool *senderPool, chainID *big.Int, nf numberFormat) {
+	transfers, err := loadBatch(*batchFlag)
+	if err != nil {
+		fatalf("Failed to load batch file: %v", err)
+	}
+	if *splitFlag != "" {
+		if err := splitBatch(client, chainID, transfers); err != nil {
+			exitf(exitInvalid, "Failed to split -amount: %v", err)
+		}
+	}
+	if *disperseFlag && len(pool.accounts) > 1 {
+		exitf(exitInvalid, "-disperse sends the whole batch in one transaction, it cannot be spread over a sender pool")
+	}
+	ctx := opCtx
+	signer := pool.accounts[0].signer
+	from := signer.Address()
+	infof("Sender's address: %s", pool.describe())
+	for _, account := range pool.accounts {
+		fundDevAccount(client, account.signer.Address())
+	}
+
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get header: %v", err)
+	}
+	legacy, err := legacyTx(header)
+	if err != nil {
+		exitf(exitInvalid, "Invalid transaction type: %v", err)
+	}
+	baseFee := header.BaseFee
+	if legacy {
+		baseFee = nil
+	}
+	tip, feeCap, err := suggestFees(ctx, client, baseFee)
+	if err != nil {
+		exitf(exitRPC, "Failed to determine fees: %v", err)
+	}
+	if *dryRunFlag {
+		infof("Dry run, the transactions will not be broadcast")
+	}
+	var tokens []string
+	for _, t := range transfers {
+		if t.Token != "" && !slices.Contains(tokens, t.Token) {
+			tokens = append(tokens, t.Token)
+		}
+	}
+	for _, account := range pool.accounts {
+		runPreflight(client, chainID, account.signer.Address(), tokens, nf)
+		if nonce, err := nextNonce(ctx, client, account.signer.Address()); err == nil {
+			checkPendingAhead(client, account.signer, chainID, nonce)
+		}
+	}
+	// a dry run sends nothing, so it neither resumes nor records progress
+	var progress *batchProgress
+	pending := transfers
+	if !*dryRunFlag {
+		if progress, err = openBatchProgress(transfers); err != nil {
+			fatalf("Failed to read batch progress: %v", err)
+		}
+		if *resumeFlag {
+			if pending, err = progress.resume(client, from, transfers); err != nil {
+				fatalf("Failed to resume batch: %v", err)
+			}
+		}
+	}
+	var problems []string
+	for _, t := range pending {
+		for _, problem := range receiverProblems(client, chainID, from, common.HexToAddress(t.Receiver), t.Token, t.Token == "") {
+			problems = append(problems, fmt.Sprintf("row %d: %s", t.row, problem))
+		}
+	}
+	checkReceiver(problems)
+	if len(pending) > 0 {
+		infof("Sending %d transfers (maxPriorityFeePerGas %s, maxFeePerGas %s)", len(pending), nf.format(tip.String()), nf.format(feeCap.String()))
+	}
+	if !*dryRunFlag && len(pending) > 0 {
+		var summary strings.Builder
+		fmt.Fprintf(&summary, "\nChain: %s (chain ID %s)\n", lookupChain(chainID).name, chainID)
+		tokens := map[string]string{}
+		for _, t := range pending {
+			token := lookupChain(chainID).symbol
+			if t.Token != "" {
+				if _, ok := tokens[t.Token]; !ok {
+					tokens[t.Token] = "of token " + common.HexToAddress(t.Token).Hex()
+					if erc20, err := loadToken(client, t.Token, *tokenABIFlag); err == nil {
+						tokens[t.Token] = erc20.describe()
+					}
+				}
+				token = tokens[t.Token]
+			}
+			fmt.Fprintf(&summary, "%d. %s %s to %s\n", t.row, nf.format(t.Amount.String()), token, t.Receiver)
+		}
+		if err := confirm(summary.String()); err != nil {
+			fatalf("Not sending: %v", err)
+		}
+	}
+
+	// a first Ctrl+C or SIGTERM stops sending rows: those being sent finish and are recorded, so
+	// that -resume picks up from there
+	interrupted := notifyShutdown()
+	context.AfterFunc(interrupted, func() {
+		infof("Interrupted, finishing the rows being sent within %s. Interrupt again to exit at once", *shutdownTimeoutFlag)
+		time.AfterFunc(*shutdownTimeoutFlag, func() {
+			abortf(exitFailure, "Rows were still being sent after -shutdownTimeout of %s, check the sender's transactions before -resume", *shutdownTimeoutFlag)
+		})
+	})
+
+	decimals := &tokenDecimals{byToken: map[string]int{}}
+	if len(pending) == 0 {
+		// nothing to send, only the transactions of the earlier run to wait for
+	} else if len(pool.accounts) > 1 {
+		sendBatchPool(interrupted, client, pool, chainID, legacy, tip, feeCap, pending, decimals, progress)
+	} else if *disperseFlag {
+		sendBatchDisperse(client, signer, chainID, legacy, tip, feeCap, pending, decimals, progress)
+	} else if *concurrency > 1 {
+		sendBatchParallel(interrupted, client, signer, chainID, legacy, tip, feeCap, pending, decimals, progress)
+	} else {
+		for _, t := range pending {
+			if interrupted.Err() != nil {
+				t.status = statusInterrupted
+				continue
+			}
+			nonce, err := nonces.Reserve(ctx, client, from)
+			if err != nil {
+				exitf(exitRPC, "Failed to get nonce: %v", err)
+			}
+			debugf("Row to %s at nonce %d", t.Receiver, nonce)
+			if _, err := sendBatchTransfer(opCtx, client, signer, chainID, nonce, legacy, tip, feeCap, t, decimals); err != nil {
+				// the next row takes over an unused nonce, so no gap holds up the rest
+				nonces.Fail(from, nonce, err)
+				t.status = "failed: " + err.Error()
+				progress.record(t)
+				continue
+			}
+			t.status = "sent"
+			if *dryRunFlag {
+				t.status = "simulated"
+			}
+			progress.record(t)
+		}
+	}
+
+	var costs *feeReport
+	if *costReport && !*dryRunFlag {
+		costs = newFeeReport()
+	}
+	if *waitFlag || costs != nil {
+		// an interruption stops the wait, the rows sent are left to -resume
+		waitCtx, cancelWait := context.WithCancel(ctx)
+		defer cancelWait()
+		context.AfterFunc(interrupted, cancelWait)
+		// with -disperse, rows share their transaction
+		waited := map[common.Hash]string{}
+		for _, t := range transfers {
+			if t.status != "sent" || interrupted.Err() != nil {
+				continue
+			}
+			if status, ok := waited[t.hash]; ok {
+				t.status = status
+				progress.record(t)
+				continue
+			}
+			receipt, err := sender.WaitReceipt(waitCtx, client, t.hash, *confirmations, pollInterval)
+			if err != nil && interrupted.Err() != nil {
+				break
+			}
+			if err == nil {
+				recordReceipt(client, receipt)
+				if costs != nil {
+					costs.addReceipt(client, receipt)
+				}
+			}
+			switch {
+			case err != nil:
+				t.status = "unknown: " + err.Error()
+			case receipt.Status == types.ReceiptStatusSuccessful:
+				t.status = fmt.Sprintf("success (block %d)", receipt.BlockNumber)
+			default:
+				t.status = revertedStatus(client, receipt)
+			}
+			waited[t.hash] = t.status
+			progress.record(t)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nROW\tRECEIVER\tAMOUNT\tTOKEN\tHASH\tSTATUS")
+	failed := false
+	for i, t := range transfers {
+		token, hash := "native", "-"
+		if t.Token != "" {
+			token = common.HexToAddress(t.Token).Hex()
+		}
+		if t.hash != (common.Hash{}) {
+			hash = t.hash.Hex()
+		}
+		if t.status != "sent" && t.status != "simulated" && !strings.HasPrefix(t.status, "success") {
+			failed = true
+		}
+		receiver := common.HexToAddress(t.Receiver).Hex()
+		if *logFormatFlag == "json" {
+			resultf([]interface{}{"row", i + 1, "receiver", receiver, "amount", t.Amount.String(), "token", token, "memo", t.Memo, "hash", hash, "status", t.status}, "Batch row %d: %s", i+1, t.status)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, receiver, nf.format(t.Amount.String()), token, hash, t.status)
+	}
+	w.Flush()
+	if costs != nil {
+		costs.print(client, chainID, nf)
+	}
+	progress.finish(transfers)
+	if failed || interrupted.Err() != nil {
+		exit(exitFailure)
+	}
+}
+
+// statusInterrupted is the status of the rows not sent as the batch was interrupted
+const statusInterrupted = "not sent: interrupted"
+
+// sendBatchParallel sends the transfers with up to -concurrency rows signed and broadcast at once.
+// Nonces are reserved up front in file order, so a row that fails leaves a gap holding up every
+// later row: it is retried once, and otherwise the nonce is filled with a 0-value self-transfer
+func sendBatchParallel(interrupted context.Context, client *ethclient.Client, signer sender.Signer, chainID *big.Int, legacy bool, tip, feeCap *big.Int, transfers []*batchTransfer, decimals *tokenDecimals, progress *batchProgress) {
+	ctx := opCtx
+	from := signer.Address()
+	rowNonces := make([]uint64, len(transfers))
+	for i := range transfers {
+		nonce, err := nonces.Reserve(ctx, client, from)
+		if err != nil {
+			exitf(exitRPC, "Failed to get nonce: %v", err)
+		}
+		rowNonces[i] = nonce
+	}
+	infof("Sending with up to %d transfers in flight, nonces %d to %d", *concurrency, rowNonces[0], rowNonces[len(rowNonces)-1])
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, *concurrency)
+	for i, t := range transfers {
+		slots <- struct{}{}
+		// the rows left have the highest nonces, so stopping leaves no gap
+		if interrupted.Err() != nil {
+			<-slots
+			t.status = statusInterrupted
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if _, err := sendBatchTransfer(opCtx, client, signer, chainID, rowNonces[i], legacy, tip, feeCap, t, decimals); err != nil {
+				t.status = "failed: " + err.Error()
+				progress.record(t)
+				return
+			}
+			t.status = "sent"
+			if *dryRunFlag {
+				t.status = "simulated"
+			}
+			progress.record(t)
+		}()
+	}
+	wg.Wait()
+
+	for i, t := range transfers {
+		if !strings.HasPrefix(t.status, "failed") || *dryRunFlag {
+			continue
+		}
+		if _, err := sendBatchTransfer(opCtx, client, signer, chainID, rowNonces[i], legacy, tip, feeCap, t, decimals); err == nil {
+			infof("Row %d sent on retry", t.row)
+			t.status = "sent"
+			progress.record(t)
+			continue
+		}
+		hash, err := fillNonce(client, signer, chainID, rowNonces[i], legacy, tip, feeCap)
+		if err != nil {
+			t.status += fmt.Sprintf("; nonce %d is left open: %v", rowNonces[i], err)
+			progress.record(t)
+			continue
+		}
+		t.status += fmt.Sprintf("; nonce %d filled by self-transfer %s", rowNonces[i], hash.Hex())
+		progress.record(t)
+	}
+}
+
+// sendBatchPool spreads transfers over the accounts of pool, each row going to the account with
+// the fewest rows in flight. An account has one row in flight at a time, so a failed row gives
+// its nonce back to the account's next row without leaving a gap
+func sendBatchPool(interrupted context.Context, client *ethclient.Client, pool *senderPool, chainID *big.Int, legacy bool, tip, feeCap *big.Int, transfers []*batchTransfer, decimals *tokenDecimals, progress *batchProgress) {
+	ctx := opCtx
+	infof("Sending from %d accounts, one transfer in flight on each", len(pool.accounts))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, len(pool.accounts))
+	for _, t := range transfers {
+		slots <- struct{}{}
+		if interrupted.Err() != nil {
+			<-slots
+			t.status = statusInterrupted
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			account := pool.acquire()
+			defer pool.release(account)
+			from := account.signer.Address()
+			nonce, err := account.nonces.Reserve(ctx, client, from)
+			if err != nil {
+				t.status = "failed: " + err.Error()
+				progress.record(t)
+				return
+			}
+			debugf("Row to %s from %s at nonce %d", t.Receiver, from.Hex(), nonce)
+			if _, err := sendBatchTransfer(opCtx, client, account.signer, chainID, nonce, legacy, tip, feeCap, t, decimals); err != nil {
+				account.nonces.Fail(from, nonce, err)
+				t.status = "failed: " + err.Error()
+				progress.record(t)
+				return
+			}
+			t.status = "sent"
+			if *dryRunFlag {
+				t.status = "simulated"
+			}
+			progress.record(t)
+		}()
+	}
+	wg.Wait()
+}
+
+// fillNonce sends a 0-value self-transfer at nonce, releasing the transactions queued behind it
+func fillNonce(client *ethclient.Client, signer sender.Signer, chainID *big.Int, nonce uint64, legacy bool, tip, feeCap *big.Int) (common.Hash, error) {
+	from := signer.Address()
+	tx := sender.MakeTx(legacy, &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       21000,
+		To:        &from,
+		Value:     new(big.Int),
+	})
+	if err := checkFeeCap(client, chainID, tx); err != nil {
+		return common.Hash{}, err
+	}
+	signed, err := signer.SignTx(tx, chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := sendTransaction(opCtx, client, signed); err != nil {
+		return common.Hash{}, err
+	}
+	return signed.Hash(), nil
+}
+
+// sendBatchTransfer builds, signs and broadcasts a single batch row at nonce, returning the
+// signed transaction, nil with -dryRun
+func sendBatchTransfer(ctx context.Context, client *ethclient.Client, signer sender.Signer, chainID *big.Int, nonce uint64, legacy bool, tip, feeCap *big.Int, t *batchTransfer, decimals *tokenDecimals) (*types.Transaction, error) {
+	receiver := common.HexToAddress(t.Receiver)
+	ctx = withMemo(ctx, t.Memo)
+
+	to, value, data := receiver, new(big.Int), []byte(nil)
+	if t.Token == "" {
+		amount, err := parseUnits(t.Amount.String(), lookupChain(chainID).decimals)
+		if err != nil {
+			return nil, err
+		}
+		value = amount
+		if memo := sendMemo(ctx); *memoCalldata && memo != "" {
+			if err := checkMemoReceiver(client, receiver); err != nil {
+				return nil, err
+			}
+			data = []byte(memo)
+		}
+	} else {
+		token, err := loadToken(client, t.Token, *tokenABIFlag)
+		if err != nil {
+			return nil, err
+		}
+		tokenDecimals, err := decimals.get(token)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := parseUnits(t.Amount.String(), tokenDecimals)
+		if err != nil {
+			return nil, err
+		}
+		to = token.address
+		if data, err = token.abi.Pack("transfer", receiver, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := sendBatchTx(ctx, client, signer, chainID, nonce, legacy, tip, feeCap, to, value, data)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	t.from, t.hash, t.nonce = signer.Address(), tx.Hash(), tx.Nonce()
+	return tx, nil
+}
+
+// sendBatchTx estimates the gas of a batch transaction unless -gasLimit is given, then signs and
+// broadcasts it at nonce. It returns the signed transaction, nil with -dryRun
+func sendBatchTx(ctx context.Context, client *ethclient.Client, signer sender.Signer, chainID *big.Int, nonce uint64, legacy bool, tip, feeCap *big.Int, to common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	from := signer.Address()
+	// only the daemon has a -policy, it holds configMu
+	refund, err := activePolicy.admit(&to, value, data)
+	if err != nil {
+		return nil, err
+	}
+	gas := *gasLimitFlag
+	if gas == 0 {
+		if gas, err = client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &to, Value: value, Data: data}); err != nil {
+			refund()
+			return nil, fmt.Errorf("failed to estimate gas: %s", describeCallError(err))
+		}
+		if gas, err = withGasMargin(gas); err != nil {
+			refund()
+			return nil, err
+		}
+	}
+	tx := sender.MakeTx(legacy, &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	})
+	if err := checkFeeCap(client, chainID, tx); err != nil {
+		refund()
+		return nil, err
+	}
+	if *dryRunFlag {
+		refund()
+		return nil, nil
+	}
+	signed, err := sender.SignTx(ctx, signer, tx, chainID)
+	if err == nil {
+		err = sendTransaction(ctx, client, signed)
+	}
+	if err != nil {
+		refund()
+		return nil, err
+	}
+	return signed, nil
+}
+
+// parseUnits converts a decimal amount such as "1.5" to an integer number of base units
+func parseUnits(amount string, decimals int) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(amount), ".")
+	if len(frac) > decimals {
+		return nil, fmt.Errorf("amount %s has more than %d decimals", amount, decimals)
+	}
+	value, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", decimals-len(frac)), 10)
+	if !ok || value.Sign() <= 0 {
+		return nil, errors.New("invalid amount " + amount)
+	}
+	return value, nil
+}
+
+// formatUnits converts an integer number of base units to a decimal amount, the inverse of parseUnits
+func formatUnits(value *big.Int, decimals int) string {
+	digits := value.String()
+	if decimals == 0 {
+		return digits
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
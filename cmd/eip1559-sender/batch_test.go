@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestParseUnits(t *testing.T) {
+	for _, tc := range []struct {
+		amount   string
+		decimals int
+		want     string // base units, empty if the amount is refused
+	}{
+		{"1.5", 18, "1500000000000000000"},
+		{"0.000000000000000001", 18, "1"},
+		{"123456789.123456", 6, "123456789123456"},
+		{" 2 ", 6, "2000000"},
+		{"1", 0, "1"},
+		{"100000000000000000000000000", 18, "100000000000000000000000000000000000000000000"},
+		{"0.0000001", 6, ""},
+		{"1.5", 0, ""},
+		{"0", 18, ""},
+		{"0.0", 18, ""},
+		{"-1", 18, ""},
+		{"1e18", 18, ""},
+		{"1.2.3", 18, ""},
+		{"", 18, ""},
+	} {
+		got, err := parseUnits(tc.amount, tc.decimals)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("parseUnits(%q, %d) = %s, want an error", tc.amount, tc.decimals, got)
+			}
+			continue
+		}
+		want, _ := new(big.Int).SetString(tc.want, 10)
+		if err != nil || got.Cmp(want) != 0 {
+			t.Errorf("parseUnits(%q, %d) = %v, %v, want %s", tc.amount, tc.decimals, got, err, tc.want)
+		}
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		decimals int
+		want     string
+	}{
+		{"1500000000000000000", 18, "1.5"},
+		{"1", 18, "0.000000000000000001"},
+		{"1000000", 6, "1"},
+		{"0", 6, "0"},
+		{"123", 0, "123"},
+		{"100", 3, "0.1"},
+	} {
+		value, _ := new(big.Int).SetString(tc.value, 10)
+		if got := formatUnits(value, tc.decimals); got != tc.want {
+			t.Errorf("formatUnits(%s, %d) = %q, want %q", tc.value, tc.decimals, got, tc.want)
+		}
+		if value.Sign() == 0 {
+			continue
+		}
+		if back, err := parseUnits(tc.want, tc.decimals); err != nil || back.Cmp(value) != 0 {
+			t.Errorf("parseUnits(%q, %d) = %v, %v, want %s back", tc.want, tc.decimals, back, err, tc.value)
+		}
+	}
+}
+
+func TestParseRawAmount(t *testing.T) {
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	for _, tc := range []struct {
+		amount string
+		ok     bool
+	}{
+		{"1", true},
+		{maxUint256.String(), true},
+		{new(big.Int).Add(maxUint256, big.NewInt(1)).String(), false},
+		{"0", false},
+		{"-5", false},
+		{"1.5", false},
+		{"0x10", false},
+	} {
+		got, err := parseRawAmount(tc.amount)
+		if (err == nil) != tc.ok {
+			t.Errorf("parseRawAmount(%q) = %v, %v, want ok %v", tc.amount, got, err, tc.ok)
+		}
+	}
+}
+
+func TestBatchTransferValidate(t *testing.T) {
+	const receiver = "0x00000000000000000000000000000000000000AA"
+	for _, tc := range []struct {
+		amount string
+		ok     bool
+	}{
+		{"1", true},
+		{"0.25", true},
+		{"1e3", false},
+		{".5", false},
+		{"5.", false},
+		{"-1", false},
+		{"", false},
+	} {
+		row := batchTransfer{Receiver: receiver, Amount: json.Number(tc.amount)}
+		if err := row.validate(); (err == nil) != tc.ok {
+			t.Errorf("validate with amount %q = %v, want ok %v", tc.amount, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// batchProgressSuffix is appended to the -batch path to name its progress file
+const batchProgressSuffix = ".progress"
+
+// batchRowState is a line of the progress file: the state of a row after a change
+type batchRowState struct {
+	Row      int     `json:"row"`
+	Receiver string  `json:"receiver"`
+	Amount   string  `json:"amount"`
+	Token    string  `json:"token,omitempty"`
+	Status   string  `json:"status"`
+	Hash     string  `json:"hash,omitempty"`
+	From     string  `json:"from,omitempty"`
+	Nonce    *uint64 `json:"nonce,omitempty"`
+}
+
+// batchProgress records the progress of a batch, so an interrupted run can be resumed with
+// -resume without paying anyone twice. Its methods do nothing on a nil batchProgress
+type batchProgress struct {
+	mu   sync.Mutex
+	path string
+	rows map[int]batchRowState
+}
+
+// openBatchProgress reads the progress file of the -batch file. A previous run's progress is
+// only picked up with -resume, and without it the batch is refused rather than sent again
+func openBatchProgress(transfers []*batchTransfer) (*batchProgress, error) {
+	p := &batchProgress{path: *batchFlag + batchProgressSuffix, rows: map[int]batchRowState{}}
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		// the last line was cut short by a crash, the next record must not be appended to it
+		if err := appendLine(p.path, nil); err != nil {
+			return nil, err
+		}
+	}
+	for i, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var state batchRowState
+		if err := json.Unmarshal(line, &state); err != nil {
+			warnf("Ignoring line %d of %s: %v", i+1, p.path, err)
+			continue
+		}
+		if state.Row < 1 || state.Row > len(transfers) {
+			return nil, fmt.Errorf("%s has row %d, but %s only has %d rows", p.path, state.Row, *batchFlag, len(transfers))
+		}
+		if t := transfers[state.Row-1]; !strings.EqualFold(t.Receiver, state.Receiver) || t.Amount.String() != state.Amount || !strings.EqualFold(t.Token, state.Token) {
+			return nil, fmt.Errorf("row %d of %s changed since the run recorded in %s", state.Row, *batchFlag, p.path)
+		}
+		p.rows[state.Row] = state
+	}
+	if len(p.rows) > 0 && !*resumeFlag {
+		return nil, fmt.Errorf("%s holds the progress of an earlier run, pass -resume to continue it or delete the file to send the batch again", p.path)
+	}
+	return p, nil
+}
+
+// resume sets the status of the rows an earlier run got to, checking with the node what became
+// of the transactions it sent. It returns the rows that are left to send: those never sent, those
+// that failed or reverted, and those whose transaction was dropped without using its nonce. from is
+// taken as the sender of the rows recorded without one
+func (p *batchProgress) resume(client *ethclient.Client, from common.Address, transfers []*batchTransfer) ([]*batchTransfer, error) {
+	ctx := opCtx
+	var pending []*batchTransfer
+	done := 0
+	for _, t := range transfers {
+		state, ok := p.rows[t.row]
+		if !ok || state.Hash == "" || state.Status == "failed" || state.Status == "reverted" {
+			pending = append(pending, t)
+			continue
+		}
+		t.from, t.hash = from, common.HexToHash(state.Hash)
+		if state.From != "" {
+			t.from = common.HexToAddress(state.From)
+		}
+		if state.Nonce != nil {
+			t.nonce = *state.Nonce
+		}
+		receipt, err := client.TransactionReceipt(ctx, t.hash)
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				infof("Row %d reverted in block %s, sending it again", t.row, receipt.BlockNumber)
+				t.hash = common.Hash{}
+				pending = append(pending, t)
+				continue
+			}
+			t.status = fmt.Sprintf("success (block %d)", receipt.BlockNumber)
+			p.record(t)
+			done++
+			continue
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		if _, _, err := client.TransactionByHash(ctx, t.hash); err == nil {
+			// still pending, it is waited for rather than sent again
+			t.status = "sent"
+			done++
+			continue
+		} else if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		mined, err := client.NonceAt(ctx, t.from, nil)
+		if err != nil {
+			return nil, err
+		}
+		if state.Nonce == nil || mined > *state.Nonce {
+			// another transaction took the nonce, which may be a replacement paying the same row
+			t.status = fmt.Sprintf("unknown: %s is gone and its nonce was used, check the account before sending the row again", t.hash.Hex())
+			done++
+			continue
+		}
+		infof("Row %d was dropped by the node before it was mined, sending it again", t.row)
+		t.hash = common.Hash{}
+		pending = append(pending, t)
+	}
+	infof("Resuming %s: %d rows sent by an earlier run, %d left to send", *batchFlag, done, len(pending))
+	return pending, nil
+}
+
+// record appends the state of row t to the progress file. A failure to write it stops the
+// batch, as the rows sent from then on could be paid twice on a resume
+func (p *batchProgress) record(t *batchTransfer) {
+	if p == nil {
+		return
+	}
+	status, _, _ := strings.Cut(t.status, ":")
+	status, _, _ = strings.Cut(status, " ")
+	state := batchRowState{Row: t.row, Receiver: t.Receiver, Amount: t.Amount.String(), Token: t.Token, Status: status}
+	if t.hash != (common.Hash{}) {
+		nonce := t.nonce
+		state.Hash, state.From, state.Nonce = t.hash.Hex(), t.from.Hex(), &nonce
+	}
+	line, _ := json.Marshal(state)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := appendLine(p.path, line); err != nil {
+		// rows are recorded from the goroutines sending them
+		abortf(exitFailure, "Failed to record the progress of row %d in %s: %v", t.row, p.path, err)
+	}
+	p.rows[t.row] = state
+}
+
+// finish removes the progress file once every row succeeded, so the batch can be sent again
+func (p *batchProgress) finish(transfers []*batchTransfer) {
+	if p == nil {
+		return
+	}
+	for _, t := range transfers {
+		if !strings.HasPrefix(t.status, "success") {
+			infof("Progress kept in %s, rerun with -resume to complete the batch", p.path)
+			return
+		}
+	}
+	if err := os.Remove(p.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		warnf("Failed to remove %s: %v", p.path, err)
+	}
+}
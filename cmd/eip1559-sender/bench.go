@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// benchResult accumulates the measurements for one RPC provider
+type benchResult struct {
+	url       string
+	chainID   *big.Int
+	latencies []time.Duration
+	errors    int
+	calls     int
+	blockLag  []uint64  // blocks behind the highest head seen in the same round
+	tipDev    []float64 // relative deviation of the suggested tip from the round's median
+}
+
+// benchOptions holds the flags of the bench subcommand
+type benchOptions struct {
+	rpcURLs  string
+	samples  int
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func newBenchFlagSet(opts *benchOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	fs.StringVar(&opts.rpcURLs, "rpcURL", "", "Comma-separated list of RPC URLs to compare")
+	fs.IntVar(&opts.samples, "samples", 10, "Number of sampling rounds")
+	fs.DurationVar(&opts.interval, "interval", 2*time.Second, "Delay between sampling rounds")
+	fs.DurationVar(&opts.timeout, "timeout", 10*time.Second, "Timeout for a single RPC call")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bench -rpcURL https://a,https://b [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runBench implements the "bench" subcommand
+func runBench(args []string) {
+	var opts benchOptions
+	fs := newBenchFlagSet(&opts)
+	fs.Parse(args)
+
+	var urls []string
+	for _, url := range strings.Split(opts.rpcURLs, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 || opts.samples <= 0 {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+
+	clients := make([]*ethclient.Client, len(urls))
+	results := make([]*benchResult, len(urls))
+	for i, url := range urls {
+		results[i] = &benchResult{url: url}
+		client, err := ethclient.Dial(url)
+		if err != nil {
+			exitf(exitRPC, "Failed to connect to %s: %v", url, err)
+		}
+		defer client.Close()
+		clients[i] = client
+	}
+
+	fmt.Printf("Benchmarking %d RPC providers over %d samples...\n", len(urls), opts.samples)
+	for round := 0; round < opts.samples; round++ {
+		if round > 0 {
+			time.Sleep(opts.interval)
+		}
+		heads := make([]uint64, len(urls))
+		tips := make([]*big.Int, len(urls))
+
+		var wg sync.WaitGroup
+		for i := range urls {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				heads[i], tips[i] = benchSample(clients[i], results[i], opts.timeout)
+			}(i)
+		}
+		wg.Wait()
+
+		// compare every provider against the best head and the median tip of this round
+		var best uint64
+		var okTips []*big.Int
+		for i := range urls {
+			if heads[i] > best {
+				best = heads[i]
+			}
+			if tips[i] != nil {
+				okTips = append(okTips, tips[i])
+			}
+		}
+		median := medianBig(okTips)
+		for i, r := range results {
+			if heads[i] != 0 {
+				r.blockLag = append(r.blockLag, best-heads[i])
+			}
+			if tips[i] != nil && median != nil && median.Sign() > 0 {
+				dev, _ := new(big.Float).Quo(
+					new(big.Float).SetInt(new(big.Int).Abs(new(big.Int).Sub(tips[i], median))),
+					new(big.Float).SetInt(median),
+				).Float64()
+				r.tipDev = append(r.tipDev, dev)
+			}
+		}
+	}
+
+	// warn when providers disagree about the network they serve
+	for _, r := range results[1:] {
+		if r.chainID != nil && results[0].chainID != nil && r.chainID.Cmp(results[0].chainID) != 0 {
+			fmt.Printf("Warning: %s reports chain ID %s but %s reports %s\n", r.url, r.chainID, results[0].url, results[0].chainID)
+		}
+	}
+
+	ranked := make([]*benchResult, len(results))
+	copy(ranked, results)
+	sort.SliceStable(ranked, func(a, b int) bool {
+		ra, rb := ranked[a], ranked[b]
+		if ra.errorRate() != rb.errorRate() {
+			return ra.errorRate() < rb.errorRate()
+		}
+		if ra.avgLag() != rb.avgLag() {
+			return ra.avgLag() < rb.avgLag()
+		}
+		return ra.medianLatency() < rb.medianLatency()
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nRANK\tRPC URL\tCHAIN ID\tP50 LATENCY\tP95 LATENCY\tERRORS\tAVG BLOCK LAG\tTIP DEVIATION")
+	for i, r := range ranked {
+		chainID := "-"
+		if r.chainID != nil {
+			chainID = r.chainID.String()
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%.1f%%\t%.2f\t%.1f%%\n",
+			i+1, r.url, chainID,
+			r.medianLatency().Round(time.Millisecond), r.percentileLatency(0.95).Round(time.Millisecond),
+			r.errorRate()*100, r.avgLag(), r.avgTipDev()*100)
+	}
+	w.Flush()
+
+	var order []string
+	for _, r := range ranked {
+		order = append(order, r.url)
+	}
+	fmt.Printf("\nRecommended failover order:\n  -rpcURL %s\n", strings.Join(order, ","))
+}
+
+// benchSample runs one round of calls against a provider and returns its head and suggested tip
+func benchSample(client *ethclient.Client, r *benchResult, timeout time.Duration) (uint64, *big.Int) {
+	call := func(fn func(ctx context.Context) error) bool {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		start := time.Now()
+		err := fn(ctx)
+		r.calls++
+		if err != nil {
+			r.errors++
+			return false
+		}
+		r.latencies = append(r.latencies, time.Since(start))
+		return true
+	}
+
+	if r.chainID == nil {
+		call(func(ctx context.Context) error {
+			chainID, err := client.ChainID(ctx)
+			r.chainID = chainID
+			return err
+		})
+	}
+
+	var head uint64
+	call(func(ctx context.Context) error {
+		var err error
+		head, err = client.BlockNumber(ctx)
+		return err
+	})
+
+	var tip *big.Int
+	call(func(ctx context.Context) error {
+		var err error
+		tip, err = client.SuggestGasTipCap(ctx)
+		return err
+	})
+	return head, tip
+}
+
+func (r *benchResult) errorRate() float64 {
+	if r.calls == 0 {
+		return 0
+	}
+	return float64(r.errors) / float64(r.calls)
+}
+
+func (r *benchResult) medianLatency() time.Duration {
+	return r.percentileLatency(0.5)
+}
+
+func (r *benchResult) percentileLatency(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(r.latencies))
+	copy(sorted, r.latencies)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+func (r *benchResult) avgLag() float64 {
+	if len(r.blockLag) == 0 {
+		return 0
+	}
+	var sum uint64
+	for _, lag := range r.blockLag {
+		sum += lag
+	}
+	return float64(sum) / float64(len(r.blockLag))
+}
+
+func (r *benchResult) avgTipDev() float64 {
+	if len(r.tipDev) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, dev := range r.tipDev {
+		sum += dev
+	}
+	return sum / float64(len(r.tipDev))
+}
+
+// medianBig returns the median of values, or nil if there are none
+func medianBig(values []*big.Int) *big.Int {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := make([]*big.Int, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Cmp(sorted[b]) < 0 })
+	return sorted[len(sorted)/2]
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+	"github.com/holiman/uint256"
+)
+
+// blobDataSize is the payload a blob carries: 4096 field elements of 31 bytes each, the high
+// byte of every 32-byte element is left zero to keep it below the BLS modulus
+const blobDataSize = params.BlobTxFieldElementsPerBlob * 31
+
+// encodeBlob packs data into a blob, 31 bytes per field element
+func encodeBlob(data []byte) (*kzg4844.Blob, error) {
+	if len(data) > blobDataSize {
+		return nil, fmt.Errorf("%d bytes exceed the %d a blob can hold", len(data), blobDataSize)
+	}
+	var blob kzg4844.Blob
+	for i := 0; len(data) > 0; i++ {
+		n := copy(blob[i*32+1:(i+1)*32], data)
+		data = data[n:]
+	}
+	return &blob, nil
+}
+
+// blobSidecar reads the comma-separated files, one blob each, and computes their KZG commitments
+// and proofs: cell proofs, or one proof per blob with -blobProofs blob for chains before Osaka
+func blobSidecar(files string) (*types.BlobTxSidecar, error) {
+	// the C library is only available in builds with -tags ckzg
+	if err := kzg4844.UseCKZG(true); err != nil {
+		debugf("Using the Go KZG library: %v", err)
+	}
+	var version byte
+	switch *blobProofsFlag {
+	case "cell":
+		version = types.BlobSidecarVersion1
+	case "blob":
+		version = types.BlobSidecarVersion0
+	default:
+		return nil, fmt.Errorf("unknown -blobProofs %q, expected cell or blob", *blobProofsFlag)
+	}
+
+	var (
+		blobs       []kzg4844.Blob
+		commitments []kzg4844.Commitment
+		proofs      []kzg4844.Proof
+	)
+	paths := strings.Split(files, ",")
+	if len(paths) > params.BlobTxMaxBlobs {
+		return nil, fmt.Errorf("%d blobs exceed the limit of %d per transaction", len(paths), params.BlobTxMaxBlobs)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(strings.TrimSpace(path))
+		if err != nil {
+			return nil, err
+		}
+		blob, err := encodeBlob(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		commitment, err := kzg4844.BlobToCommitment(blob)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to compute commitment: %v", path, err)
+		}
+		if version == types.BlobSidecarVersion1 {
+			cellProofs, err := kzg4844.ComputeCellProofs(blob)
+			if err != nil {
+				return nil, fmt.Errorf("%s: failed to compute cell proofs: %v", path, err)
+			}
+			proofs = append(proofs, cellProofs...)
+		} else {
+			proof, err := kzg4844.ComputeBlobProof(blob, commitment)
+			if err != nil {
+				return nil, fmt.Errorf("%s: failed to compute proof: %v", path, err)
+			}
+			proofs = append(proofs, proof)
+		}
+		blobs = append(blobs, *blob)
+		commitments = append(commitments, commitment)
+	}
+	return types.NewBlobTxSidecar(version, blobs, commitments, proofs), nil
+}
+
+// blobTx turns tx into an EIP-4844 transaction carrying the blobs of -blob, with a blob fee cap
+// of -maxFeePerBlobGas or twice the current blob base fee
+func blobTx(client *ethclient.Client, from common.Address, chainID *big.Int, tx *types.Transaction, nf numberFormat) *types.Transaction {
+	if tx.Type() == types.LegacyTxType {
+		exitf(exitInvalid, "Blob transactions cannot be sent as legacy transactions")
+	}
+	sidecar, err := blobSidecar(*blobFlag)
+	if err != nil {
+		fatalf("Failed to build blobs: %v", err)
+	}
+	hashes := make([]common.Hash, len(sidecar.Commitments))
+	for i := range sidecar.Commitments {
+		hashes[i] = kzg4844.CalcBlobHashV1(sha256.New(), &sidecar.Commitments[i])
+		debugf("Blob %d versioned hash: %s", i, hashes[i].Hex())
+	}
+	infof("Blobs: %d", len(hashes))
+
+	blobFeeCap, err := parseGwei(*maxBlobFeeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -maxFeePerBlobGas: %v", err)
+	}
+	if blobFeeCap == nil {
+		blobBaseFee, err := client.BlobBaseFee(opCtx)
+		if err != nil {
+			exitf(exitRPC, "Failed to get blob base fee: %v", err)
+		}
+		infof("Blob base fee: %s", nf.format(blobBaseFee.String()))
+		blobFeeCap = new(big.Int).Mul(blobBaseFee, big.NewInt(2))
+		if blobFeeCap.Sign() == 0 {
+			blobFeeCap.SetInt64(1)
+		}
+	}
+	infof("Max fee per blob gas: %s", nf.format(blobFeeCap.String()))
+
+	// the blob fee is charged on top of the execution gas
+	balance, err := client.PendingBalanceAt(opCtx, from)
+	if err != nil {
+		exitf(exitRPC, "Failed to get balance: %v", err)
+	}
+	blobCost := new(big.Int).Mul(new(big.Int).SetUint64(uint64(len(hashes))*params.BlobTxBlobGasPerBlob), blobFeeCap)
+	if err := sender.CheckFunds(balance, new(big.Int).Add(tx.Value(), blobCost), tx.Gas(), tx.GasFeeCap()); err != nil {
+		exitf(exitFunds, "Insufficient funds: %v", err)
+	}
+
+	return types.NewTx(&types.BlobTx{
+		ChainID:    uint256.MustFromBig(chainID),
+		Nonce:      tx.Nonce(),
+		GasTipCap:  uint256.MustFromBig(tx.GasTipCap()),
+		GasFeeCap:  uint256.MustFromBig(tx.GasFeeCap()),
+		Gas:        tx.Gas(),
+		To:         *tx.To(),
+		Value:      uint256.MustFromBig(tx.Value()),
+		Data:       tx.Data(),
+		BlobFeeCap: uint256.MustFromBig(blobFeeCap),
+		BlobHashes: hashes,
+		Sidecar:    sidecar,
+	})
+}
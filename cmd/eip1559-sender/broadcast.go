@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// broadcastOptions holds the flags of the broadcast subcommand
+type broadcastOptions struct {
+	rawTx      string
+	unsignedTx string
+	signature  string
+}
+
+func newBroadcastFlagSet(opts *broadcastOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	fs.StringVar(&opts.rawTx, "rawTx", "", "Signed raw transaction as 0x-prefixed hex, or path to a file containing it or written by -signTo")
+	fs.StringVar(&opts.unsignedTx, "unsignedTx", "", "Transaction written by -exportUnsigned, to be sent with -signature")
+	fs.StringVar(&opts.signature, "signature", "", "65 byte [R || S || V] signature of the signingHash of -unsignedTx, as hex")
+	addSharedFlags(fs)
+	addRootFlags(fs, "force")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s broadcast -rawTx 0x02f8...|tx.json [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s broadcast -unsignedTx tx.json -signature 0x... [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runBroadcast implements the "broadcast" subcommand
+func runBroadcast(args []string) {
+	var opts broadcastOptions
+	fs := newBroadcastFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" || (opts.rawTx == "") == (opts.unsignedTx == "") || (opts.unsignedTx == "") != (opts.signature == "") {
+		fmt.Println("Error: Missing required parameters (either -rawTx, or -unsignedTx with -signature)")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	if *bumpAfter > 0 || *validForFlag > 0 {
+		exitf(exitInvalid, "-bumpAfter and -validFor cannot be used with broadcast, replacing the transaction needs the signing key")
+	}
+	if *signToFlag != "" {
+		exitf(exitInvalid, "-signTo cannot be used with broadcast, the transaction is already signed")
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	var tx *types.Transaction
+	if opts.unsignedTx != "" {
+		if tx, err = importSigned(opts.unsignedTx, opts.signature); err != nil {
+			fatalf("Failed to attach signature: %v", err)
+		}
+	} else if tx, err = decodeRawTx(opts.rawTx); err != nil {
+		exitf(exitInvalid, "Invalid raw transaction: %v", err)
+	}
+
+	broadcastRawTx(tx, nf)
+}
+
+// sendSignedFile implements -sendFrom: the broadcast of a transaction signed earlier with -signTo
+func sendSignedFile(path string) {
+	if *bumpAfter > 0 || *validForFlag > 0 {
+		exitf(exitInvalid, "-bumpAfter and -validFor cannot be used with -sendFrom, replacing the transaction needs the signing key")
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fatalf("Failed to read signed transaction: %v", err)
+	}
+	tx, err := parseSignedTx(data)
+	if err != nil {
+		exitf(exitInvalid, "Invalid signed transaction %s: %v", path, err)
+	}
+	broadcastRawTx(tx, nf)
+}
+
+// broadcastRawTx checks, confirms and broadcasts a transaction signed elsewhere
+func broadcastRawTx(tx *types.Transaction, nf numberFormat) {
+	client, chainID := dialRPC()
+	broadcastCheckedTx(client, chainID, tx, nf)
+}
+
+// broadcastCheckedTx is broadcastRawTx on an RPC connection already made
+func broadcastCheckedTx(client *ethclient.Client, chainID *big.Int, tx *types.Transaction, nf numberFormat) {
+	from, err := checkRawTx(client, chainID, tx, nf)
+	if err != nil {
+		exitf(exitInvalid, "Refusing to broadcast: %v", err)
+	}
+	if err := checkFeeCap(client, chainID, tx); err != nil {
+		fatalf("Fee cap exceeded: %v", err)
+	}
+	if *simulateFlag != "" {
+		tenderlySimulate(chainID, from, tx)
+	}
+	if *traceFlag {
+		traceTx(client, from, tx)
+	}
+	if *dryRunFlag {
+		simulateTx(client, from, tx, nf)
+		return
+	}
+	if err := confirmTx(client, chainID, from, tx, nf); err != nil {
+		fatalf("Not sending: %v", err)
+	}
+	broadcastAndFollow(client, nil, chainID, tx, nf)
+}
+
+// decodeRawTx parses a signed transaction given as hex, inline or in a file
+func decodeRawTx(value string) (*types.Transaction, error) {
+	text := strings.TrimSpace(value)
+	if !strings.HasPrefix(text, "0x") {
+		data, err := os.ReadFile(text)
+		if err != nil {
+			return nil, err
+		}
+		text = strings.TrimSpace(string(data))
+		if strings.HasPrefix(text, "{") {
+			return parseSignedTx(data)
+		}
+	}
+	raw, err := hexutil.Decode(text)
+	if err != nil {
+		return nil, err
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// checkRawTx verifies that tx belongs to chainID, recovers its sender and checks the nonce and
+// balance of the sender before anything is sent
+func checkRawTx(client *ethclient.Client, chainID *big.Int, tx *types.Transaction, nf numberFormat) (common.Address, error) {
+	if !tx.Protected() {
+		return common.Address{}, errors.New("the transaction is not replay protected (no EIP-155 chain ID)")
+	}
+	if tx.ChainId().Cmp(chainID) != 0 {
+		return common.Address{}, fmt.Errorf("the transaction is signed for chain ID %s, the RPC serves %s", tx.ChainId(), chainID)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover the sender: %v", err)
+	}
+	infof("Sender's address: %s", from.Hex())
+	infof("Transaction hash: %s", tx.Hash().Hex())
+
+	ctx := opCtx
+	if mined, err := client.NonceAt(ctx, from, nil); err != nil {
+		return common.Address{}, fmt.Errorf("failed to get nonce: %v", err)
+	} else if tx.Nonce() < mined {
+		return common.Address{}, fmt.Errorf("nonce %d was already used, the account's next nonce is %d", tx.Nonce(), mined)
+	}
+	if header, err := client.HeaderByNumber(ctx, nil); err != nil {
+		return common.Address{}, fmt.Errorf("failed to get header: %v", err)
+	} else if header.BaseFee != nil && tx.GasFeeCap().Cmp(header.BaseFee) < 0 {
+		warnf("maxFeePerGas %s Wei is below the current base fee of %s Wei, the transaction waits until the base fee drops", nf.format(tx.GasFeeCap().String()), nf.format(header.BaseFee.String()))
+	}
+	balance, err := client.PendingBalanceAt(ctx, from)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to get balance: %v", err)
+	}
+	if err := sender.CheckFunds(balance, tx.Value(), tx.Gas(), tx.GasFeeCap()); err != nil {
+		return common.Address{}, fmt.Errorf("insufficient funds: %v", err)
+	}
+	return from, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// waitWithBumps waits for tx, the latest version followed by tracker, to be mined, re-sending it
+// with the same nonce and fees raised by percent whenever it stays pending for longer than after.
+// Every version but the first counts as one of maxBumps. It returns the receipt of whichever
+// version was mined, and the latest version sent. A replacement being broadcast when ctx is done
+// is still sent and added to tracker
+func waitWithBumps(ctx context.Context, client *ethclient.Client, signer sender.Signer, chainID *big.Int, tracker *sender.Tracker, tx *types.Transaction, after time.Duration, percent, maxBumps int) (*types.Receipt, *types.Transaction, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks := sender.WatchBlocks(ctx, client, pollInterval)
+	deadline := time.Now().Add(after)
+	for bumps := len(tracker.Hashes()) - 1; ; {
+		receipt, err := tracker.Check(ctx)
+		if errors.Is(err, sender.ErrNonceUsed) {
+			txReplaced.Inc()
+			notifyWebhook(replacedEvent(chainID, signer.Address(), tx, common.Hash{}))
+			recordReplaced(tx, common.Hash{})
+		}
+		if receipt != nil || err != nil {
+			return receipt, tx, err
+		}
+
+		if bumps < maxBumps && time.Now().After(deadline) {
+			bumps++
+			replacement, err := bumpTx(context.WithoutCancel(ctx), client, signer, chainID, tx, percent)
+			if errors.Is(err, errFeeLimit) {
+				// keep waiting for the versions already sent
+				warnf("Not bumping any further: %v", err)
+				bumps = maxBumps
+				continue
+			}
+			if err != nil {
+				// the node refuses the replacement once a version got mined, which the check at the
+				// top of the loop finds. Otherwise the versions already sent are still pending
+				warnf("Bump %d/%d failed, waiting for the versions already sent: %v", bumps, maxBumps, err)
+				deadline = time.Now().Add(after)
+				continue
+			}
+			tx = replacement
+			tracker.Add(tx.Hash())
+			deadline = time.Now().Add(after)
+			infof("Not mined after %s, bump %d/%d: maxPriorityFeePerGas %s, maxFeePerGas %s, hash %s", after, bumps, maxBumps, tx.GasTipCap(), tx.GasFeeCap(), tx.Hash().Hex())
+		}
+		// look again once there is a new block, or when the next bump is due
+		var bumpDue <-chan time.Time
+		if bumps < maxBumps {
+			bumpDue = time.After(time.Until(deadline))
+		}
+		select {
+		case <-blocks:
+		case <-bumpDue:
+		}
+	}
+}
+
+// errFeeLimit marks a fee bump refused by -maxFeeGwei or -maxFeeEth
+var errFeeLimit = errors.New("fee limit reached")
+
+// bumpTx re-sends tx with the same nonce and fees raised by percent, or by enough to outbid a base
+// fee that rose in the meantime, and returns the replacement
+func bumpTx(ctx context.Context, client *ethclient.Client, signer sender.Signer, chainID *big.Int, tx *types.Transaction, percent int) (*types.Transaction, error) {
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Type() == types.BlobTxType && percent < 100 {
+		// the blob pool requires every fee of a replacement to be doubled
+		percent = 100
+	}
+	tip := bumpFee(tx.GasTipCap(), percent)
+	feeCap := bumpFee(tx.GasFeeCap(), percent)
+	// keep up with a base fee that rose while the transaction was pending
+	if header.BaseFee != nil {
+		if minFeeCap := new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), tip); feeCap.Cmp(minFeeCap) < 0 {
+			feeCap = minFeeCap
+		}
+	}
+	// blob transactions must double their blob fee cap as well
+	var blobFeeCap *big.Int
+	if tx.Type() == types.BlobTxType {
+		blobFeeCap = new(big.Int).Mul(tx.BlobGasFeeCap(), big.NewInt(2))
+	}
+	unsigned := sender.WithFees(tx, chainID, tip, feeCap, blobFeeCap)
+	// the fee limits may be reloaded while a daemon waits
+	configMu.RLock()
+	err = checkFeeCap(client, chainID, unsigned)
+	configMu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errFeeLimit, err)
+	}
+	replacement, err := sender.SignTx(ctx, signer, unsigned, chainID)
+	if err != nil {
+		return nil, err
+	}
+	if err := sendTransaction(ctx, client, replacement); err != nil {
+		return nil, fmt.Errorf("failed to send replacement: %v", err)
+	}
+	txSent.Inc()
+	txReplaced.Inc()
+	notifyWebhook(replacedEvent(chainID, signer.Address(), tx, replacement.Hash()))
+	recordReplaced(tx, replacement.Hash())
+	return replacement, nil
+}
+
+// bumpFee raises fee by percent, and always by at least 1 wei. It rounds up, as nodes refuse a
+// replacement even a wei short of their minimum bump
+func bumpFee(fee *big.Int, percent int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(int64(100+percent)))
+	bumped.Add(bumped, big.NewInt(99))
+	bumped.Div(bumped, big.NewInt(100))
+	if bumped.Cmp(fee) <= 0 {
+		bumped.Add(fee, big.NewInt(1))
+	}
+	return bumped
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBumpFee(t *testing.T) {
+	for _, tc := range []struct {
+		fee     int64
+		percent int
+		want    int64
+	}{
+		{1000000007, 10, 1100000008}, // 1100000007.7 rounded up
+		{1000000000, 10, 1100000000},
+		{15, 10, 17}, // 16.5 rounded up
+		{1, 10, 2},
+		{0, 10, 1},
+	} {
+		if got := bumpFee(big.NewInt(tc.fee), tc.percent); got.Int64() != tc.want {
+			t.Errorf("bumpFee(%d, %d) = %s, want %d", tc.fee, tc.percent, got, tc.want)
+		}
+	}
+}
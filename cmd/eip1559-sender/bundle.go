@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// bundleOptions holds the flags of the bundle subcommand
+type bundleOptions struct {
+	txs    string
+	blocks uint64
+}
+
+func newBundleFlagSet(opts *bundleOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	fs.StringVar(&opts.txs, "txs", "", "JSON file listing the transactions of the bundle, in order")
+	fs.Uint64Var(&opts.blocks, "blocks", 5, "Number of blocks, starting with the next one, the bundle is submitted for")
+	addRootFlags(fs, "relayURL", "tokenABI", "maxFeePerGas", "maxPriorityFeePerGas", "maxFeeEth", "maxFeeGwei",
+		"priority", "feeBlocks", "feePercentile", "feeHeadroom", "feeSource", "txType", "nonce", "nonceSource", "dryRun", "yes", "y")
+	addRootFlags(fs, readFlags...)
+	addRootFlags(fs, keyFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bundle -txs bundle.json -rpcURL https://... -privateKeyEnv SENDER_KEY [-blocks 5] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nSends the transactions of -txs as a Flashbots bundle: all of them are included in one block, in order, or none is.\n")
+		fmt.Fprintf(fs.Output(), "Each entry is either {\"rawTx\": \"0x02f8...\"} signed elsewhere, or a call signed with the key source:\n")
+		fmt.Fprintf(fs.Output(), "{\"to\": \"0x...\", \"value\": \"0.1\", \"data\": \"0x...\"} or {\"to\": \"0x...\", \"method\": \"approve(address,uint256)\", \"args\": \"0x...,100\"}, with an optional \"gas\" limit.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// bundleEntry is one transaction of a bundle file
+type bundleEntry struct {
+	RawTx  string `json:"rawTx,omitempty"`
+	To     string `json:"to,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Data   string `json:"data,omitempty"`
+	Method string `json:"method,omitempty"`
+	Args   string `json:"args,omitempty"`
+	Gas    uint64 `json:"gas,omitempty"`
+}
+
+// bundleSimulation is the result of eth_callBundle
+type bundleSimulation struct {
+	BundleHash   string `json:"bundleHash"`
+	TotalGasUsed uint64 `json:"totalGasUsed"`
+	Results      []struct {
+		TxHash  string `json:"txHash"`
+		GasUsed uint64 `json:"gasUsed"`
+		Error   string `json:"error"`
+		Revert  string `json:"revert"`
+	} `json:"results"`
+}
+
+// runBundle implements the "bundle" subcommand
+func runBundle(args []string) {
+	var opts bundleOptions
+	fs := newBundleFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" || opts.txs == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	if opts.blocks < 1 {
+		exitf(exitInvalid, "-blocks must be at least 1")
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	entries, err := loadBundle(opts.txs)
+	if err != nil {
+		exitf(exitInvalid, "Invalid bundle: %v", err)
+	}
+	client, chainID := dialRPC()
+	ctx := opCtx
+	relay, err := relayURL(ctx, client)
+	if err != nil {
+		fatalf("Cannot submit a bundle: %v", err)
+	}
+	txs, err := buildBundle(ctx, client, chainID, entries)
+	if err != nil {
+		fatalf("Failed to build bundle: %v", err)
+	}
+	raws := make([]string, len(txs))
+	for i, tx := range txs {
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			fatalf("Failed to encode transaction %d: %v", i+1, err)
+		}
+		raws[i] = hexutil.Encode(raw)
+	}
+
+	// simulate on top of the latest block before anything is submitted
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		exitf(exitRPC, "Failed to get block number: %v", err)
+	}
+	var sim bundleSimulation
+	err = relayCall(ctx, relay, "eth_callBundle", map[string]interface{}{
+		"txs":              raws,
+		"blockNumber":      hexutil.EncodeUint64(head + 1),
+		"stateBlockNumber": "latest",
+	}, &sim)
+	if err != nil {
+		fatalf("Failed to simulate bundle: %v", err)
+	}
+	failed := false
+	for i, result := range sim.Results {
+		switch {
+		case result.Error != "":
+			failed = true
+			warnf("Transaction %d (%s) fails: %s %s", i+1, result.TxHash, result.Error, result.Revert)
+		default:
+			infof("Transaction %d (%s): %s gas", i+1, result.TxHash, nf.format(fmt.Sprint(result.GasUsed)))
+		}
+	}
+	if failed {
+		fatalf("Bundle simulation failed, not sending")
+	}
+	resultf([]interface{}{"bundleHash", sim.BundleHash, "gasUsed", sim.TotalGasUsed}, "Simulation succeeded: %s gas in total", nf.format(fmt.Sprint(sim.TotalGasUsed)))
+	if *dryRunFlag {
+		return
+	}
+
+	var summary strings.Builder
+	chain := lookupChain(chainID)
+	fmt.Fprintf(&summary, "\nChain:    %s (chain ID %s)\n", chain.name, chainID)
+	fmt.Fprintf(&summary, "Relay:    %s\n", relay)
+	fmt.Fprintf(&summary, "Blocks:   %d to %d\n", head+1, head+opts.blocks)
+	for i, tx := range txs {
+		from, _ := types.Sender(types.LatestSignerForChainID(chainID), tx)
+		to := "new contract"
+		if tx.To() != nil {
+			to = tx.To().Hex()
+		}
+		fmt.Fprintf(&summary, "%-9s %s -> %s: %s\n", fmt.Sprintf("Tx %d:", i+1), from.Hex(), to, describeBundleTx(client, chain, tx, nf))
+	}
+	if err := confirm(summary.String()); err != nil {
+		fatalf("Not sending: %v", err)
+	}
+
+	// a bundle targets a single block, so it is submitted for each of the next ones
+	for block := head + 1; block <= head+opts.blocks; block++ {
+		var sent struct {
+			BundleHash string `json:"bundleHash"`
+		}
+		err := relayCall(ctx, relay, "eth_sendBundle", map[string]interface{}{
+			"txs":         raws,
+			"blockNumber": hexutil.EncodeUint64(block),
+		}, &sent)
+		if err != nil {
+			exitf(exitRejected, "Failed to submit bundle for block %d: %v", block, err)
+		}
+		debugf("Submitted bundle %s for block %d", sent.BundleHash, block)
+	}
+	infof("Bundle submitted to %s for blocks %d to %d", relay, head+1, head+opts.blocks)
+
+	receipts, err := waitBundle(ctx, client, txs, head+opts.blocks)
+	if err != nil {
+		fatalf("Bundle not mined: %v", err)
+	}
+	block := receipts[0].BlockNumber.Uint64()
+	reverted := false
+	for i, receipt := range receipts {
+		status := "success"
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			status, reverted = "reverted", true
+		}
+		resultf([]interface{}{"hash", receipt.TxHash.Hex(), "block", block, "gasUsed", receipt.GasUsed, "status", status}, "Transaction %d: %s %s", i+1, receipt.TxHash.Hex(), status)
+		if url := explorerTxURL(chainID, receipt.TxHash.Hex()); url != "" {
+			infof("Explorer: %s", url)
+		}
+	}
+	resultf([]interface{}{"block", block}, "Bundle included in block %d", block)
+	if reverted {
+		exit(exitReverted)
+	}
+}
+
+// loadBundle reads and checks the entries of a bundle file
+func loadBundle(path string) ([]bundleEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []bundleEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no transactions")
+	}
+	for i, e := range entries {
+		switch {
+		case e.RawTx != "" && (e.To != "" || e.Value != "" || e.Data != "" || e.Method != "" || e.Gas != 0):
+			return nil, fmt.Errorf("transaction %d: rawTx cannot be combined with other fields", i+1)
+		case e.RawTx == "" && e.To == "":
+			return nil, fmt.Errorf("transaction %d: either rawTx or to is required", i+1)
+		case e.Data != "" && e.Method != "":
+			return nil, fmt.Errorf("transaction %d: data and method cannot be combined", i+1)
+		}
+	}
+	return entries, nil
+}
+
+// buildBundle decodes the signed entries and builds and signs the others with the key source,
+// at consecutive nonces and the fees of the fee flags
+func buildBundle(ctx context.Context, client *ethclient.Client, chainID *big.Int, entries []bundleEntry) ([]*types.Transaction, error) {
+	var signer sender.Signer
+	var legacy bool
+	var tip, feeCap *big.Int
+	txs := make([]*types.Transaction, len(entries))
+	for i, e := range entries {
+		if e.RawTx != "" {
+			tx, err := decodeRawTx(e.RawTx)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %d: %v", i+1, err)
+			}
+			if tx.ChainId().Cmp(chainID) != 0 {
+				return nil, fmt.Errorf("transaction %d is for chain %s, not %s", i+1, tx.ChainId(), chainID)
+			}
+			if tx.Type() == types.BlobTxType {
+				return nil, fmt.Errorf("transaction %d: blob transactions cannot be bundled", i+1)
+			}
+			txs[i] = tx
+			continue
+		}
+
+		// the key and fees are only needed once there is something to sign
+		if signer == nil {
+			var err error
+			if signer, err = loadSigner(); err != nil {
+				return nil, fmt.Errorf("failed to load signing key: %w", err)
+			}
+			infof("Sender's address: %s", signer.Address().Hex())
+			header, err := client.HeaderByNumber(ctx, nil)
+			if err != nil {
+				return nil, err
+			}
+			if legacy, err = legacyTx(header); err != nil {
+				return nil, err
+			}
+			baseFee := header.BaseFee
+			if legacy {
+				baseFee = nil
+			}
+			if tip, feeCap, err = suggestFees(ctx, client, baseFee); err != nil {
+				return nil, fmt.Errorf("failed to determine fees: %v", err)
+			}
+		}
+		tx, err := buildBundleTx(ctx, client, signer.Address(), chainID, e, legacy, tip, feeCap)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i+1, err)
+		}
+		if err := checkFeeCap(client, chainID, tx); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i+1, err)
+		}
+		if txs[i], err = signer.SignTx(tx, chainID); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i+1, err)
+		}
+	}
+	return txs, nil
+}
+
+// buildBundleTx builds the unsigned transaction of a call entry
+func buildBundleTx(ctx context.Context, client *ethclient.Client, from common.Address, chainID *big.Int, e bundleEntry, legacy bool, tip, feeCap *big.Int) (*types.Transaction, error) {
+	to, err := parseAddress(e.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to: %v", err)
+	}
+	value := new(big.Int)
+	if e.Value != "" {
+		if value, err = parseUnits(e.Value, lookupChain(chainID).decimals); err != nil {
+			return nil, fmt.Errorf("invalid value: %v", err)
+		}
+	}
+	var data []byte
+	switch {
+	case e.Data != "":
+		if data, err = hexutil.Decode(e.Data); err != nil {
+			return nil, fmt.Errorf("invalid data: %v", err)
+		}
+	case e.Method != "":
+		method, err := parseMethod(e.Method, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid method: %v", err)
+		}
+		if data, err = encodeCall(method, e.Args); err != nil {
+			return nil, err
+		}
+	}
+	gas := e.Gas
+	if gas == 0 {
+		// each estimate runs on its own, without the transactions before it in the bundle
+		if gas, err = client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &to, Value: value, Data: data}); err != nil {
+			return nil, fmt.Errorf("failed to estimate gas: %s (if it depends on an earlier transaction of the bundle, set \"gas\")", describeCallError(err))
+		}
+	}
+	nonce, err := nonces.Reserve(ctx, client, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce: %v", err)
+	}
+	return sender.MakeTx(legacy, &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	}), nil
+}
+
+// describeBundleTx summarizes the value and call of tx on one line
+func describeBundleTx(client *ethclient.Client, chain network, tx *types.Transaction, nf numberFormat) string {
+	var parts []string
+	if tx.Value().Sign() > 0 || len(tx.Data()) == 0 {
+		parts = append(parts, fmt.Sprintf("%s %s", nf.format(formatUnits(tx.Value(), chain.decimals)), chain.symbol))
+	}
+	switch {
+	case tx.To() == nil:
+		parts = append(parts, fmt.Sprintf("%s bytes of init code", nf.format(fmt.Sprint(len(tx.Data())))))
+	case len(tx.Data()) > 0:
+		parts = append(parts, describeCall(client, tx, nf))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// waitBundle waits until the transactions of a bundle are mined, or block last has passed
+// without them
+func waitBundle(ctx context.Context, client *ethclient.Client, txs []*types.Transaction, last uint64) ([]*types.Receipt, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks := sender.WatchBlocks(ctx, client, pollInterval)
+	for range blocks {
+		// the head is read first, so a receipt missing after it is missing from that block too
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			warnf("Failed to get block number: %v", err)
+			continue
+		}
+		receipt, err := client.TransactionReceipt(ctx, txs[0].Hash())
+		if err == nil {
+			receipts := []*types.Receipt{receipt}
+			for _, tx := range txs[1:] {
+				r, err := client.TransactionReceipt(ctx, tx.Hash())
+				if err != nil {
+					return nil, fmt.Errorf("bundle was split: %s was mined in block %s, %s: %v", receipt.TxHash.Hex(), receipt.BlockNumber, tx.Hash().Hex(), err)
+				}
+				receipts = append(receipts, r)
+			}
+			return receipts, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			warnf("Failed to get receipt: %v", err)
+			continue
+		}
+		if head >= last {
+			return nil, fmt.Errorf("bundle was not included by block %d", last)
+		}
+	}
+	return nil, ctx.Err()
+}
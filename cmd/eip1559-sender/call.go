@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// callOptions holds the flags of the call subcommand
+type callOptions struct {
+	to     string
+	method string
+	abi    string
+	args   string
+	value  string
+	send   bool
+}
+
+func newCallFlagSet(opts *callOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("call", flag.ExitOnError)
+	fs.StringVar(&opts.to, "to", "", "Contract address or ENS name")
+	fs.StringVar(&opts.method, "method", "", `Method signature such as "transfer(address,uint256)", optionally followed by its return types, e.g. "balanceOf(address)(uint256)", or a method name of -abi`)
+	fs.StringVar(&opts.abi, "abi", "", "Contract ABI JSON (or path to a file containing it) to look -method up in")
+	fs.StringVar(&opts.args, "args", "", "Comma-separated method arguments, arrays in brackets such as [1,2,3]")
+	fs.StringVar(&opts.value, "value", "", "Native coin amount sent along with the call")
+	fs.BoolVar(&opts.send, "send", false, "Send a transaction instead of reading with eth_call")
+	addSharedFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s call -to 0x... -method \"balanceOf(address)(uint256)\" -args 0x... [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s call -send -to 0x... -method \"transfer(address,uint256)\" -args 0x...,1000000 [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runCall implements the "call" subcommand
+func runCall(args []string) {
+	var opts callOptions
+	fs := newCallFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" || opts.to == "" || opts.method == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	if *errorABIFlag == "" {
+		// the contract's ABI also declares its custom errors
+		*errorABIFlag = opts.abi
+	}
+	method, err := parseMethod(opts.method, opts.abi)
+	if err != nil {
+		exitf(exitInvalid, "Invalid method: %v", err)
+	}
+	data, err := encodeCall(method, opts.args)
+	if err != nil {
+		fatalf("Failed to encode the call: %v", err)
+	}
+	debugf("Calldata: %s", hexutil.Encode(data))
+
+	client, chainID := dialRPC()
+	to := opts.to
+	if isENSName(to) {
+		address, err := resolveENS(client, to)
+		if err != nil {
+			fatalf("Failed to resolve ENS name: %v", err)
+		}
+		infof("Resolved contract %s to %s", to, address.Hex())
+		to = address.Hex()
+	}
+	contract, err := parseAddress(to)
+	if err != nil {
+		exitf(exitInvalid, "Invalid contract: %v", err)
+	}
+	value := new(big.Int)
+	if opts.value != "" {
+		if value, err = parseUnits(opts.value, lookupChain(chainID).decimals); err != nil {
+			exitf(exitInvalid, "Invalid value: %v", err)
+		}
+	}
+
+	if !opts.send {
+		output, err := client.CallContract(opCtx, ethereum.CallMsg{To: &contract, Value: value, Data: data}, nil)
+		if err != nil {
+			fatalf("Call failed: %s", describeCallError(err))
+		}
+		printOutputs(method, output)
+		return
+	}
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	infof("Sender's address: %s", signer.Address().Hex())
+	infof("Calling %s on %s", method.Sig, contract.Hex())
+	tx := newTransaction(client, signer.Address(), chainID, &contract, value, data, nf)
+	sendAndFollow(client, signer, chainID, tx, nf)
+}
+
+// encodeCall returns the calldata of method with the comma-separated arguments args
+func encodeCall(method abi.Method, args string) ([]byte, error) {
+	values, err := parseArgs(method.Inputs, args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments for %s: %v", method.Sig, err)
+	}
+	data, err := method.Inputs.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode arguments: %v", err)
+	}
+	return append(append([]byte{}, method.ID...), data...), nil
+}
+
+// parseMethod looks the method up by name or signature in the ABI of abiSpec or, without one,
+// parses a human-readable signature with optional return types
+func parseMethod(signature, abiSpec string) (abi.Method, error) {
+	if abiSpec != "" {
+		parsed, err := loadABI(abiSpec)
+		if err != nil {
+			return abi.Method{}, err
+		}
+		if method, ok := parsed.Methods[signature]; ok {
+			return method, nil
+		}
+		for _, method := range parsed.Methods {
+			if method.Sig == signature {
+				return method, nil
+			}
+		}
+		return abi.Method{}, fmt.Errorf("the ABI has no method %s", signature)
+	}
+
+	// split "name(inputs)(outputs)" after the parenthesis closing the inputs
+	open := strings.Index(signature, "(")
+	if open < 0 {
+		return abi.Method{}, fmt.Errorf("%q is not a method signature such as transfer(address,uint256)", signature)
+	}
+	depth, end := 0, -1
+	for i := open; i < len(signature) && end < 0; i++ {
+		switch signature[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				end = i + 1
+			}
+		}
+	}
+	if end < 0 {
+		return abi.Method{}, fmt.Errorf("unbalanced parentheses in %q", signature)
+	}
+	inputs, err := selectorArguments(strings.ReplaceAll(signature[:end], " ", ""))
+	if err != nil {
+		return abi.Method{}, err
+	}
+	var outputs abi.Arguments
+	if rest := strings.ReplaceAll(signature[end:], " ", ""); rest != "" {
+		if outputs, err = selectorArguments("returns" + rest); err != nil {
+			return abi.Method{}, err
+		}
+	}
+	name := signature[:open]
+	return abi.NewMethod(name, name, abi.Function, "", false, false, inputs, outputs), nil
+}
+
+// selectorArguments parses the argument types of a selector such as transfer(address,uint256)
+func selectorArguments(selector string) (abi.Arguments, error) {
+	parsed, err := abi.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	var args abi.Arguments
+	for _, input := range parsed.Inputs {
+		typ, err := abi.NewType(input.Type, "", input.Components)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, abi.Argument{Name: input.Name, Type: typ})
+	}
+	return args, nil
+}
+
+// parseArgs converts the comma-separated text into values of the types of args
+func parseArgs(args abi.Arguments, text string) ([]interface{}, error) {
+	var fields []string
+	if strings.TrimSpace(text) != "" {
+		fields = splitTopLevel(text)
+	}
+	if len(fields) != len(args) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", len(args), len(fields))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		value, err := parseArg(arg.Type, strings.TrimSpace(fields[i]))
+		if err != nil {
+			return nil, fmt.Errorf("argument %d (%s): %v", i+1, arg.Type, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// splitTopLevel splits text at the commas outside of brackets
+func splitTopLevel(text string) []string {
+	var fields []string
+	depth, start := 0, 0
+	for i, c := range text {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				fields = append(fields, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, text[start:])
+}
+
+// parseArg converts text into the Go value the ABI encoder expects for typ
+func parseArg(typ abi.Type, text string) (interface{}, error) {
+	switch typ.T {
+	case abi.AddressTy:
+		return parseAddress(text)
+	case abi.BoolTy:
+		return strconv.ParseBool(text)
+	case abi.StringTy:
+		return text, nil
+	case abi.BytesTy:
+		return hexutil.Decode(text)
+	case abi.FixedBytesTy:
+		data, err := hexutil.Decode(text)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != typ.Size {
+			return nil, fmt.Errorf("expected %d bytes, got %d", typ.Size, len(data))
+		}
+		value := reflect.New(typ.GetType()).Elem()
+		reflect.Copy(value, reflect.ValueOf(data))
+		return value.Interface(), nil
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(text, 0)
+		if !ok {
+			return nil, fmt.Errorf("%q is not an integer", text)
+		}
+		if typ.T == abi.UintTy && n.Sign() < 0 {
+			return nil, errors.New("negative value for an unsigned integer")
+		}
+		// signed values need a sign bit, -x fits where x-1 does
+		bits := n.BitLen()
+		if typ.T == abi.IntTy {
+			bits = new(big.Int).Not(n).BitLen() + 1
+			if n.Sign() >= 0 {
+				bits = n.BitLen() + 1
+			}
+		}
+		if bits > typ.Size {
+			return nil, fmt.Errorf("%s does not fit into %s", text, typ)
+		}
+		if typ.Size > 64 {
+			return n, nil
+		}
+		if typ.T == abi.UintTy {
+			return reflect.ValueOf(n.Uint64()).Convert(typ.GetType()).Interface(), nil
+		}
+		return reflect.ValueOf(n.Int64()).Convert(typ.GetType()).Interface(), nil
+	case abi.SliceTy, abi.ArrayTy:
+		inner := strings.TrimSpace(text)
+		if !strings.HasPrefix(inner, "[") || !strings.HasSuffix(inner, "]") {
+			return nil, fmt.Errorf("arrays are written in brackets, such as [1,2,3]")
+		}
+		var fields []string
+		if inner = strings.TrimSpace(inner[1 : len(inner)-1]); inner != "" {
+			fields = splitTopLevel(inner)
+		}
+		if typ.T == abi.ArrayTy && len(fields) != typ.Size {
+			return nil, fmt.Errorf("expected %d elements, got %d", typ.Size, len(fields))
+		}
+		value := reflect.MakeSlice(reflect.SliceOf(typ.Elem.GetType()), len(fields), len(fields))
+		if typ.T == abi.ArrayTy {
+			value = reflect.New(typ.GetType()).Elem()
+		}
+		for i, field := range fields {
+			elem, err := parseArg(*typ.Elem, strings.TrimSpace(field))
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %v", i+1, err)
+			}
+			value.Index(i).Set(reflect.ValueOf(elem))
+		}
+		return value.Interface(), nil
+	}
+	return nil, fmt.Errorf("%s arguments are not supported", typ)
+}
+
+// printOutputs decodes the return data of method, or prints it raw without declared outputs
+func printOutputs(method abi.Method, output []byte) {
+	if len(method.Outputs) == 0 {
+		resultf([]interface{}{"output", hexutil.Encode(output)}, "%s", hexutil.Encode(output))
+		return
+	}
+	values, err := method.Outputs.Unpack(output)
+	if err != nil {
+		fatalf("Failed to decode the return data %s: %v", hexutil.Encode(output), err)
+	}
+	for i, value := range values {
+		text := formatValue(value)
+		resultf([]interface{}{"index", i, "type", method.Outputs[i].Type.String(), "value", text}, "%s", text)
+	}
+}
+
+// formatValue prints a decoded ABI value, addresses checksummed and bytes as hex
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case common.Address:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8:
+		// bytesN
+		data := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(data), rv)
+		return hexutil.Encode(data)
+	case rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array:
+		elems := make([]string, rv.Len())
+		for i := range elems {
+			elems[i] = formatValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(elems, ",") + "]"
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func newCancelFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	// -nonce names the transaction to cancel here, the shared -nonce does not apply
+	fs.Int64Var(cancelNonce, "nonce", -1, "Nonce of the pending transaction to cancel")
+	for _, name := range sharedFlags {
+		if name != "nonce" {
+			addRootFlags(fs, name)
+		}
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s cancel -nonce 7 [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runCancel implements the "cancel" subcommand, -cancelNonce of the root flags
+func runCancel(args []string) {
+	fs := newCancelFlagSet()
+	fs.Parse(args)
+	configure(fs)
+
+	if *cancelNonce < 0 {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	runTransfer(fs.Usage)
+}
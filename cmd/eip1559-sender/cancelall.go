@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+func newCancelAllFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("cancel-all", flag.ExitOnError)
+	// every pending nonce is cancelled, and the replacements are not bumped further
+	skipped := []string{"nonce", "nonceSource", "rescue", "exportUnsigned", "qr", "signTo", "expiresIn", "bumpAfter", "maxBumps", "validFor", "preflight", "preflightTxs", "explorerAPI", "explorerAPIKey", "tokenContract", "token", "tokenList", "tokenABI", "decimals", "memo"}
+	for _, name := range sharedFlags {
+		if !slices.Contains(skipped, name) {
+			addRootFlags(fs, name)
+		}
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s cancel-all [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nCancels every pending transaction of the account, from its mined nonce to its pending nonce, with 0-value self-transfers at higher fees.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runCancelAll implements the "cancel-all" subcommand: -cancelNonce for each nonce between the
+// account's mined and pending nonce, confirmed once
+func runCancelAll(args []string) {
+	fs := newCancelAllFlagSet()
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, chainID := dialRPC()
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	ctx := opCtx
+	from := signer.Address()
+	mined, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get the mined nonce: %v", err)
+	}
+	pending, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		exitf(exitRPC, "Failed to get the pending nonce: %v", err)
+	}
+	if pending <= mined {
+		resultf([]interface{}{"address", from.Hex(), "cancelled", 0}, "No pending transactions for %s", from.Hex())
+		return
+	}
+	infof("Cancelling nonces %d to %d of %s", mined, pending-1, from.Hex())
+
+	pool := poolContentFrom(client, from)
+	originals := map[uint64]*types.Transaction{}
+	var cancels []*types.Transaction
+	for nonce := mined; nonce < pending; nonce++ {
+		originals[nonce] = pool["pending"][nonce]
+		*cancelNonce = int64(nonce)
+		tx, err := buildReplacement(client, from, chainID, nf)
+		if err != nil {
+			// a blob transaction holds up the nonces after it, but the others can still be cancelled
+			warnf("Not cancelling nonce %d: %v", nonce, err)
+			continue
+		}
+		if err := checkFeeCap(client, chainID, tx); err != nil {
+			fatalf("Fee cap exceeded: %v", err)
+		}
+		cancels = append(cancels, tx)
+	}
+	if len(cancels) == 0 {
+		fatalf("None of the pending transactions can be cancelled")
+	}
+	if *dryRunFlag {
+		for _, tx := range cancels {
+			simulateTx(client, from, tx, nf)
+		}
+		return
+	}
+
+	chain := lookupChain(chainID)
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "\nChain: %s (chain ID %s)\n", chain.name, chainID)
+	fmt.Fprintf(&summary, "From:  %s\n", from.Hex())
+	for _, tx := range cancels {
+		original := "not in the txpool"
+		if tx := originals[tx.Nonce()]; tx != nil {
+			original = tx.Hash().Hex()
+		}
+		fmt.Fprintf(&summary, "Nonce %d: cancel %s, maxFeePerGas %s gwei\n", tx.Nonce(), original, formatUnits(tx.GasFeeCap(), 9))
+	}
+	if err := confirm(summary.String()); err != nil {
+		fatalf("Not sending: %v", err)
+	}
+
+	var sent []*types.Transaction
+	for _, tx := range cancels {
+		signedTx, err := signer.SignTx(tx, chainID)
+		if err != nil {
+			fatalf("Failed to sign transaction: %v", err)
+		}
+		if err := sendTransaction(ctx, client, signedTx); err != nil {
+			warnf("Failed to cancel nonce %d: %v", tx.Nonce(), err)
+			continue
+		}
+		txSent.Inc()
+		if original := originals[tx.Nonce()]; original != nil {
+			txReplaced.Inc()
+			notifyWebhook(replacedEvent(chainID, from, original, signedTx.Hash()))
+			recordReplaced(original, signedTx.Hash())
+		}
+		resultf([]interface{}{"nonce", tx.Nonce(), "hash", signedTx.Hash().Hex()}, "Nonce %d: cancellation sent, transaction hash: %s", tx.Nonce(), signedTx.Hash().Hex())
+		sent = append(sent, signedTx)
+	}
+
+	if *waitFlag {
+		for _, tx := range sent {
+			// the original may still be mined first, which ends the wait as well
+			tracker := sender.NewTracker(client, from, tx)
+			if original := originals[tx.Nonce()]; original != nil {
+				tracker.Add(original.Hash())
+			}
+			receipt, err := tracker.Wait(ctx, *confirmations, pollInterval)
+			if err != nil {
+				warnf("Nonce %d: %v", tx.Nonce(), err)
+				continue
+			}
+			recordReceipt(client, receipt)
+			notifyWebhook(receiptEvent(chainID, from, tx.Nonce(), receipt))
+			attrs := []interface{}{"nonce", tx.Nonce(), "hash", receipt.TxHash.Hex(), "block", receipt.BlockNumber.Uint64()}
+			if receipt.TxHash != tx.Hash() {
+				resultf(append(attrs, "status", "original"), "Nonce %d: the original transaction %s was mined first, in block %s", tx.Nonce(), receipt.TxHash.Hex(), nf.format(receipt.BlockNumber.String()))
+				continue
+			}
+			resultf(append(attrs, "status", "cancelled"), "Nonce %d: cancelled in block %s", tx.Nonce(), nf.format(receipt.BlockNumber.String()))
+		}
+	}
+	if len(sent) < len(cancels) || len(cancels) < int(pending-mined) {
+		exit(exitFailure)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// parseBaseFeeBelow reads -sendWhenBaseFeeBelow and -maxWait. It returns nil if the transfer is
+// sent whatever the base fee
+func parseBaseFeeBelow() (*big.Int, error) {
+	if *baseFeeBelow == "" {
+		if *maxWaitFlag != 0 {
+			return nil, errors.New("-maxWait requires -sendWhenBaseFeeBelow")
+		}
+		return nil, nil
+	}
+	limit, err := parseGwei(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(*baseFeeBelow)), "gwei"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid -sendWhenBaseFeeBelow: %v", err)
+	}
+	if limit.Sign() == 0 {
+		return nil, errors.New("-sendWhenBaseFeeBelow must be positive")
+	}
+	if *maxWaitFlag < 0 {
+		return nil, errors.New("-maxWait must be positive")
+	}
+	// nobody may be around to answer the confirmation prompt when the base fee drops
+	if !*yesFlag && !*dryRunFlag {
+		return nil, errors.New("-sendWhenBaseFeeBelow sends unattended and requires -yes")
+	}
+	return limit, nil
+}
+
+// waitForBaseFee watches new blocks until the base fee of the latest one is below limit, or
+// -maxWait passed, after which the transfer is sent anyway. -deadline bounds the send that
+// follows rather than the wait
+func waitForBaseFee(client *ethclient.Client, limit *big.Int) {
+	stopDeadline()
+	defer startDeadline()
+	ctx, cancel := context.WithCancel(context.Background())
+	if *maxWaitFlag > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), *maxWaitFlag)
+	}
+	defer cancel()
+	blocks := sender.WatchBlocks(ctx, client, pollInterval)
+	var baseFee *big.Int
+	for waiting := false; ; {
+		header, err := client.HeaderByNumber(ctx, nil)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			// a long wait should outlast a node that is briefly unavailable
+			warnf("Failed to get header: %v", err)
+		case err != nil:
+		case header.BaseFee == nil:
+			exitf(exitInvalid, "-sendWhenBaseFeeBelow requires blocks with a base fee, which this chain does not have")
+		case header.BaseFee.Cmp(limit) < 0:
+			infof("Base fee of %s gwei in block %d is below %s gwei, sending", formatUnits(header.BaseFee, 9), header.Number, formatUnits(limit, 9))
+			return
+		case !waiting:
+			infof("Base fee of %s gwei is not below %s gwei, waiting for it to drop", formatUnits(header.BaseFee, 9), formatUnits(limit, 9))
+			waiting, baseFee = true, header.BaseFee
+		default:
+			debugf("Base fee of %s gwei in block %d", formatUnits(header.BaseFee, 9), header.Number)
+			baseFee = header.BaseFee
+		}
+		if _, ok := <-blocks; !ok {
+			break
+		}
+	}
+	if baseFee != nil {
+		warnf("-maxWait of %s passed with the base fee at %s gwei, sending anyway", *maxWaitFlag, formatUnits(baseFee, 9))
+	} else {
+		warnf("-maxWait of %s passed, sending anyway", *maxWaitFlag)
+	}
+}
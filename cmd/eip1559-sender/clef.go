@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// clefSigner signs through go-ethereum's Clef, which applies its rules or asks its operator
+// before every signature. Clef takes typed data only as the full JSON document, not as the
+// hashes the typed data commands produce, so it signs transactions and EIP-191 messages
+type clefSigner struct {
+	clef    *external.ExternalSigner
+	account accounts.Account
+}
+
+// loadClef connects to Clef at endpoint, an IPC path or an HTTP URL, and selects account, or the
+// only account Clef lists if account is empty
+func loadClef(endpoint, account string) (sender.Signer, error) {
+	clef, err := external.NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Clef at %s: %v", endpoint, err)
+	}
+	// Clef asks its operator whether to reveal the accounts
+	promptf("Please approve the account listing in Clef")
+	listed := clef.Accounts()
+	var selected *accounts.Account
+	if account != "" {
+		address, err := parseAddress(account)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -clefAccount: %v", err)
+		}
+		for i := range listed {
+			if listed[i].Address == address {
+				selected = &listed[i]
+			}
+		}
+		if selected == nil {
+			return nil, fmt.Errorf("Clef does not list %s", address.Hex())
+		}
+	} else {
+		switch len(listed) {
+		case 0:
+			return nil, errors.New("Clef lists no accounts, or the listing was denied")
+		case 1:
+			selected = &listed[0]
+		default:
+			addresses := make([]string, len(listed))
+			for i, a := range listed {
+				addresses[i] = a.Address.Hex()
+			}
+			return nil, fmt.Errorf("Clef lists %d accounts, choose one with -clefAccount: %s", len(listed), strings.Join(addresses, ", "))
+		}
+	}
+	infof("Using Clef account %s", selected.Address.Hex())
+	return &clefSigner{clef: clef, account: *selected}, nil
+}
+
+func (s *clefSigner) Address() common.Address {
+	return s.account.Address
+}
+
+func (s *clefSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if tx.Type() == types.SetCodeTxType {
+		// account_signTransaction takes no authorization list
+		return nil, errors.New("Clef cannot sign EIP-7702 transactions")
+	}
+	promptf("Please review and approve the transaction in Clef")
+	signed, err := s.clef.SignTx(s.account, tx, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("Clef: %v", err)
+	}
+	// the operator may edit the transaction before approving it, which would send something else
+	// than what was confirmed here
+	signer := types.LatestSignerForChainID(chainID)
+	if signer.Hash(signed) != signer.Hash(tx) {
+		return nil, errors.New("Clef signed a modified transaction, not sending it")
+	}
+	if from, err := types.Sender(signer, signed); err != nil || from != s.account.Address {
+		return nil, errors.New("Clef signed with another account")
+	}
+	return signed, nil
+}
+
+func (s *clefSigner) SignText(text []byte) ([]byte, error) {
+	promptf("Please review and approve the message in Clef")
+	return s.clef.SignText(s.account, text)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// completionShells lists the shells a completion script can be generated for
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// flagValueCompletions returns candidate values for flags that take a known set of values
+var flagValueCompletions = map[string]func() []string{
+	"network":       networkKeys,
+	"priority":      priorityNames,
+	"receiver":      addressBookNames,
+	"tokenContract": tokenSymbols,
+	"token":         tokenSymbols,
+	"status": func() []string {
+		return historyStatuses
+	},
+	"simulate": func() []string {
+		return []string{"tenderly"}
+	},
+	"unit": func() []string {
+		return amountUnits
+	},
+	"feeSource": func() []string {
+		return append([]string{"rpc"}, feeSources...)
+	},
+	"nonceSource": func() []string {
+		return []string{"pending", "latest"}
+	},
+	"blobProofs": func() []string {
+		return []string{"cell", "blob"}
+	},
+	"locale": func() []string {
+		var locales []string
+		for name := range localeFormats {
+			locales = append(locales, name)
+		}
+		return locales
+	},
+}
+
+// runCompletion implements the "completion bash|zsh|fish|powershell" subcommand
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Usage: %s completion %s\n", os.Args[0], strings.Join(completionShells, "|"))
+		exit(exitInvalid)
+	}
+
+	prog := filepath.Base(os.Args[0])
+	// shell function names cannot contain dashes or dots
+	fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(prog)
+
+	switch args[0] {
+	case "bash":
+		fmt.Printf(`%[2]s() {
+    local IFS=$'\n'
+    COMPREPLY=($(%[1]s __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F %[2]s %[1]s
+`, prog, fn)
+	case "zsh":
+		fmt.Printf(`#compdef %[1]s
+%[2]s() {
+    local -a candidates
+    candidates=("${(@f)$(%[1]s __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if (( ${#candidates[@]} )) && [[ -n "${candidates[1]}" ]]; then
+        compadd -- "${candidates[@]}"
+    else
+        _files
+    fi
+}
+compdef %[2]s %[1]s
+`, prog, fn)
+	case "fish":
+		fmt.Printf(`function _%[2]s
+    set -l tokens (commandline -opc) (commandline -ct)
+    %[1]s __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c %[1]s -a '(_%[2]s)'
+`, prog, fn)
+	case "powershell":
+		fmt.Printf(`Register-ArgumentCompleter -Native -CommandName '%[1]s' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '""' }
+    & '%[1]s' __complete @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`, prog)
+	default:
+		fmt.Printf("Error: Unsupported shell %q (supported: %s)\n", args[0], strings.Join(completionShells, ", "))
+		exit(exitInvalid)
+	}
+}
+
+// completeArgs returns the candidates for the last word in args, given the words before it
+func completeArgs(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	current := args[len(args)-1]
+	previous := args[:len(args)-1]
+
+	var candidates []string
+	switch {
+	case len(previous) == 0:
+		candidates = append(candidates, subcommands...)
+		candidates = append(candidates, completeFlags(flag.CommandLine, previous, current)...)
+	case previous[0] == "service":
+		if len(previous) == 1 {
+			candidates = serviceActions
+		} else {
+			candidates = completeFlags(newServiceFlagSet(previous[1], &serviceOptions{}), previous, current)
+		}
+	case previous[0] == "bench":
+		candidates = completeFlags(newBenchFlagSet(&benchOptions{}), previous, current)
+	case previous[0] == "devnet":
+		if len(previous) == 1 {
+			candidates = []string{"up"}
+		} else {
+			candidates = completeFlags(newDevnetFlagSet(&devnetOptions{}), previous, current)
+		}
+	case previous[0] == "approve":
+		candidates = completeFlags(newApproveFlagSet(&approveOptions{}), previous, current)
+	case previous[0] == "permit":
+		if len(previous) == 1 {
+			candidates = permitActions
+		} else {
+			candidates = completeFlags(newPermitFlagSet(previous[1], &permitOptions{}), previous, current)
+		}
+	case previous[0] == "permit2":
+		if len(previous) == 1 {
+			candidates = permitActions
+		} else {
+			candidates = completeFlags(newPermit2FlagSet(previous[1], &permit2Options{}), previous, current)
+		}
+	case previous[0] == "transfer-auth":
+		if len(previous) == 1 {
+			candidates = permitActions
+		} else {
+			candidates = completeFlags(newTransferAuthFlagSet(previous[1], &transferAuthOptions{}), previous, current)
+		}
+	case previous[0] == "wrap" || previous[0] == "unwrap":
+		candidates = completeFlags(newWrapFlagSet(previous[0], &wrapOptions{}), previous, current)
+	case previous[0] == "addressbook":
+		if len(previous) == 1 {
+			candidates = addressBookActions
+		} else if len(previous) == 2 && previous[1] == "remove" {
+			candidates = addressBookNames()
+		} else {
+			candidates = completeFlags(newAddressBookFlagSet(previous[1], &addressBookOptions{}), previous, current)
+		}
+	case previous[0] == "history":
+		candidates = completeFlags(newHistoryFlagSet(&historyOptions{}), previous, current)
+	case previous[0] == "keygen":
+		candidates = completeFlags(newKeygenFlagSet(&keygenOptions{}), previous, current)
+	case previous[0] == "broadcast":
+		candidates = completeFlags(newBroadcastFlagSet(&broadcastOptions{}), previous, current)
+	case previous[0] == "call":
+		candidates = completeFlags(newCallFlagSet(&callOptions{}), previous, current)
+	case previous[0] == "deploy":
+		candidates = completeFlags(newDeployFlagSet(&deployOptions{}), previous, current)
+	case previous[0] == "send":
+		switch {
+		case len(previous) == 1 && !strings.HasPrefix(current, "-"):
+			candidates = sendKinds
+		case len(previous) == 1 || strings.HasPrefix(previous[1], "-"):
+			candidates = completeFlags(newSendFlagSet(""), previous, current)
+		default:
+			candidates = completeFlags(newSendFlagSet(previous[1]), previous, current)
+		}
+	case previous[0] == "cancel":
+		candidates = completeFlags(newCancelFlagSet(), previous, current)
+	case previous[0] == "cancel-all":
+		candidates = completeFlags(newCancelAllFlagSet(), previous, current)
+	case previous[0] == "sweep":
+		candidates = completeFlags(newSweepFlagSet(&sweepOptions{}), previous, current)
+	case previous[0] == "watch-forward":
+		candidates = completeFlags(newWatchForwardFlagSet(&forwardOptions{}), previous, current)
+	case previous[0] == "balance":
+		candidates = completeFlags(newBalanceFlagSet(&balanceOptions{}), previous, current)
+	case previous[0] == "allowance":
+		candidates = completeFlags(newAllowanceFlagSet(&allowanceOptions{}), previous, current)
+	case previous[0] == "estimate":
+		candidates = completeFlags(newEstimateFlagSet(&estimateOptions{}), previous, current)
+	case previous[0] == "fees":
+		if len(previous) > 1 && previous[1] == "history" {
+			candidates = completeFlags(newFeeHistoryFlagSet(&feeHistoryOptions{}), previous, current)
+		} else {
+			if len(previous) == 1 {
+				candidates = []string{"history"}
+			}
+			candidates = append(candidates, completeFlags(newFeesFlagSet(), previous, current)...)
+		}
+	case previous[0] == "decode":
+		candidates = completeFlags(newDecodeFlagSet(&decodeOptions{}), previous, current)
+	case previous[0] == "server":
+		candidates = completeFlags(newServerFlagSet(&serverOptions{}), previous, current)
+	case previous[0] == "daemon":
+		candidates = completeFlags(newDaemonFlagSet(&daemonOptions{}), previous, current)
+	case previous[0] == "bundle":
+		candidates = completeFlags(newBundleFlagSet(&bundleOptions{}), previous, current)
+	case previous[0] == "dispatch":
+		candidates = completeFlags(newDispatchFlagSet(&dispatchOptions{}), previous, current)
+	case previous[0] == "request":
+		candidates = completeFlags(newRequestFlagSet(), previous, current)
+	case previous[0] == "tui":
+		candidates = completeFlags(newTUIFlagSet(), previous, current)
+	case previous[0] == "userop":
+		candidates = completeFlags(newUserOpFlagSet(&userOpOptions{}), previous, current)
+	case previous[0] == "sign-typed":
+		candidates = completeFlags(newSignTypedFlagSet(&signTypedOptions{}), previous, current)
+	case previous[0] == "verify":
+		candidates = completeFlags(newVerifyFlagSet(&verifyOptions{}), previous, current)
+	case previous[0] == "sign-message" || previous[0] == "verify-message":
+		candidates = completeFlags(newMessageFlagSet(previous[0], &messageOptions{}), previous, current)
+	case previous[0] == "completion":
+		if len(previous) == 1 {
+			candidates = completionShells
+		}
+	default:
+		candidates = completeFlags(flag.CommandLine, previous, current)
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// completeFlags returns either the values for a flag that is waiting for one, or the flag names of fs
+func completeFlags(fs *flag.FlagSet, previous []string, current string) []string {
+	if len(previous) > 0 {
+		if f := lookupFlagArg(fs, previous[len(previous)-1]); f != nil && !isBoolFlag(f) {
+			if values, ok := flagValueCompletions[f.Name]; ok {
+				return values()
+			}
+			// free-form value, let the shell fall back to file names
+			return nil
+		}
+	}
+	if name, _, ok := strings.Cut(current, "="); ok {
+		if f := lookupFlagArg(fs, name); f != nil {
+			var values []string
+			if complete, ok := flagValueCompletions[f.Name]; ok {
+				for _, value := range complete() {
+					values = append(values, name+"="+value)
+				}
+			}
+			return values
+		}
+	}
+
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	return names
+}
+
+// lookupFlagArg resolves a command line word such as "-locale" or "--locale" to its flag
+func lookupFlagArg(fs *flag.FlagSet, arg string) *flag.Flag {
+	if !strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+		return nil
+	}
+	return fs.Lookup(strings.TrimLeft(arg, "-"))
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is looked up in the home directory when -config is not given
+const defaultConfigFile = ".eip1559-sender.yaml"
+
+// configFile is the layout of the config file: named profiles of flag values
+type configFile struct {
+	Default  string                            `yaml:"default"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// profileSource is the profile applied at startup, kept to reload it
+type profileSource struct {
+	path        string
+	name        string
+	values      map[string]interface{}
+	commandLine map[string]bool // flags given on the command line, which the profile does not override
+}
+
+// loadedProfile is set by applyProfile, nil without a profile
+var loadedProfile *profileSource
+
+// configure applies the selected config profile and -network to fs and sets up logging, exiting on invalid settings
+func configure(fs *flag.FlagSet) {
+	profile, err := applyProfile(fs)
+	if err != nil {
+		exitf(exitInvalid, "Invalid config: %v", err)
+	}
+	if err := applyNetwork(); err != nil {
+		exitf(exitInvalid, "Invalid network: %v", err)
+	}
+	if err := applyDev(); err != nil {
+		exitf(exitInvalid, "Invalid dev mode: %v", err)
+	}
+	if err := applyPriority(fs); err != nil {
+		exitf(exitInvalid, "Invalid priority: %v", err)
+	}
+	if err := setupLogging(); err != nil {
+		exitf(exitInvalid, "Invalid logging options: %v", err)
+	}
+	if err := applyURI(); err != nil {
+		exitf(exitInvalid, "Invalid -uri: %v", err)
+	}
+	if err := applyAmount(); err != nil {
+		exitf(exitInvalid, "Invalid amount: %v", err)
+	}
+	if err := checkSignTo(); err != nil {
+		exitf(exitInvalid, "Invalid -signTo: %v", err)
+	}
+	if err := checkApproval(); err != nil {
+		exitf(exitInvalid, "Invalid -approvalAbove: %v", err)
+	}
+	if err := checkGasMargin(); err != nil {
+		exitf(exitInvalid, "Invalid gas limit policy: %v", err)
+	}
+	startDeadline()
+	if profile != "" {
+		infof("Using %s", profile)
+	}
+}
+
+// applyProfile sets the flags of fs from the selected profile of the config file, leaving
+// flags given on the command line untouched. It returns a description of the profile used
+func applyProfile(fs *flag.FlagSet) (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && *configFlag == "" && *profileFlag == "" {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var config configFile
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	name := *profileFlag
+	if name == "" {
+		name = config.Default
+	}
+	if name == "" {
+		return "", nil
+	}
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return "", fmt.Errorf("profile %q not found in %s", name, path)
+	}
+
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	loadedProfile = &profileSource{path: path, name: name, values: profile, commandLine: given}
+	for key, value := range profile {
+		if key == "config" || key == "profile" {
+			return "", fmt.Errorf("profile %q cannot set -%s", name, key)
+		}
+		if fs.Lookup(key) == nil {
+			// root-only flags such as -receiver do not apply to subcommands
+			if flag.CommandLine.Lookup(key) != nil {
+				continue
+			}
+			return "", fmt.Errorf("profile %q sets unknown flag -%s", name, key)
+		}
+		if given[key] {
+			continue
+		}
+		text := profileValue(value)
+		if err := fs.Set(key, text); err != nil {
+			return "", fmt.Errorf("profile %q: invalid value %q for -%s: %v", name, text, key, err)
+		}
+	}
+	return fmt.Sprintf("profile %s from %s", name, path), nil
+}
+
+// configPath returns the path of the config file: -config, else the default file in the home
+// directory
+func configPath() (string, error) {
+	if *configFlag != "" {
+		return *configFlag, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, defaultConfigFile), nil
+}
+
+// profileValue returns a value of a profile as a flag value, with a leading ~/ expanded to the
+// home directory
+func profileValue(value interface{}) string {
+	text := fmt.Sprint(value)
+	if strings.HasPrefix(text, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			text = filepath.Join(home, text[2:])
+		}
+	}
+	return text
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/term"
+)
+
+// confirmTx prints a summary of tx and asks the user to confirm it unless -yes is set
+func confirmTx(client *ethclient.Client, chainID *big.Int, from common.Address, tx *types.Transaction, nf numberFormat) error {
+	chain := lookupChain(chainID)
+	maxFee := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasFeeCap())
+	fee := new(big.Int).Set(maxFee) // with the blob and L1 fees, for the projected balance
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "\nChain:    %s (chain ID %s)\n", chain.name, chainID)
+	fmt.Fprintf(&summary, "From:     %s\n", from.Hex())
+	if tx.To() == nil {
+		fmt.Fprintf(&summary, "Deploy:   %s bytes of init code\n", nf.format(fmt.Sprint(len(tx.Data()))))
+	} else {
+		fmt.Fprintf(&summary, "To:       %s\n", tx.To().Hex())
+	}
+	if tx.Value().Sign() > 0 || len(tx.Data()) == 0 {
+		fmt.Fprintf(&summary, "Amount:   %s %s%s\n", nf.format(formatUnits(tx.Value(), chain.decimals)), chain.symbol, fiatAmount(client, chainID, tx.Value(), chain.decimals, nf))
+	}
+	if len(tx.Data()) > 0 && tx.To() != nil {
+		fmt.Fprintf(&summary, "Call:     %s\n", describeCall(client, tx, nf))
+	}
+	if blobs := len(tx.BlobHashes()); blobs > 0 {
+		blobFee := new(big.Int).Mul(new(big.Int).SetUint64(tx.BlobGas()), tx.BlobGasFeeCap())
+		fmt.Fprintf(&summary, "Blobs:    %d (max blob fee %s %s)\n", blobs, nf.format(formatUnits(blobFee, chain.decimals)), chain.symbol)
+		fee.Add(fee, blobFee)
+	}
+	for _, auth := range tx.SetCodeAuthorizations() {
+		authority, err := auth.Authority()
+		if err != nil {
+			fmt.Fprintf(&summary, "Delegate: invalid authorization: %v\n", err)
+			continue
+		}
+		fmt.Fprintf(&summary, "Delegate: %s to %s\n", authority.Hex(), auth.Address.Hex())
+	}
+	fmt.Fprintf(&summary, "Nonce:    %d\n", tx.Nonce())
+	fmt.Fprintf(&summary, "Max fee:  %s %s (%s gas at %s Wei)%s\n", nf.format(formatUnits(maxFee, chain.decimals)), chain.symbol, nf.format(fmt.Sprint(tx.Gas())), nf.format(tx.GasFeeCap().String()), fiatAmount(client, chainID, maxFee, chain.decimals, nf))
+	if l1Fee, err := l1DataFee(client, tx); err != nil {
+		warnf("%v", err)
+	} else if l1Fee != nil {
+		fmt.Fprintf(&summary, "L1 fee:   %s %s for posting the transaction to L1%s\n", nf.format(formatUnits(l1Fee, chain.decimals)), chain.symbol, fiatAmount(client, chainID, l1Fee, chain.decimals, nf))
+		fee.Add(fee, l1Fee)
+	}
+	if client != nil {
+		for _, line := range describeProjection(client, chainID, from, tx, fee, nf) {
+			fmt.Fprintf(&summary, "%s\n", line)
+		}
+	}
+	return confirm(summary.String())
+}
+
+// describeCall decodes the token call of tx for the confirmation summary
+func describeCall(client *ethclient.Client, tx *types.Transaction, nf numberFormat) string {
+	data := tx.Data()
+	if len(data) < 4 {
+		return fmt.Sprintf("%d bytes of data", len(data))
+	}
+	if method, err := erc1155ABI.MethodById(data[:4]); err == nil {
+		if args, err := method.Inputs.Unpack(data[4:]); err == nil && len(args) >= 4 {
+			return fmt.Sprintf("ERC-1155 %s to %s, ids %v, amounts %v", method.Name, args[1].(common.Address).Hex(), args[2], args[3])
+		}
+	}
+	if client == nil {
+		// signing offline, the token cannot be looked up
+		return fmt.Sprintf("%d bytes of data", len(data))
+	}
+	token, err := loadToken(client, tx.To().Hex(), *tokenABIFlag)
+	if err != nil {
+		return fmt.Sprintf("%d bytes of data", len(data))
+	}
+	method, err := token.abi.MethodById(data[:4])
+	if err != nil {
+		if method, err := erc2612ABI.MethodById(data[:4]); err == nil {
+			return fmt.Sprintf("%s on %s", method.Name, token.symbol())
+		}
+		return fmt.Sprintf("%d bytes of data", len(data))
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil || len(args) < 2 {
+		return method.Name
+	}
+	amount, ok := args[len(args)-1].(*big.Int)
+	decimals, err := token.decimals()
+	if !ok || err != nil {
+		return method.Name
+	}
+	target, _ := args[len(args)-2].(common.Address)
+	return fmt.Sprintf("%s %s %s to %s", method.Name, nf.format(formatUnits(amount, decimals)), token.describe(), target.Hex())
+}
+
+// confirm prints summary and requires the user to type "yes", unless -yes is set
+func confirm(summary string) error {
+	if *yesFlag {
+		infof("%s", strings.TrimRight(summary, "\n"))
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("stdin is not a terminal, pass -yes to send without confirmation")
+	}
+	fmt.Fprint(os.Stderr, summary+"Type yes to send: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(answer) != "yes" {
+		return errors.New("aborted")
+	}
+	return nil
+}
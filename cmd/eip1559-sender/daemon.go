@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// daemonOptions holds the flags of the daemon subcommand
+type daemonOptions struct {
+	queue string
+}
+
+func newDaemonFlagSet(opts *daemonOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	fs.StringVar(&opts.queue, "queue", "", "Job queue: a directory, redis://host:6379/0?list=name or nats://host:4222?subject=name")
+	addRootFlags(fs, "concurrency", "gasLimit", "gasMargin", "gasFloor", "gasCeiling", "bumpAfter", "bumpPercent", "maxBumps", "webhook", "webhookRetries", "shutdownTimeout", "pendingDir")
+	addRootFlags(fs, serverFlags...)
+	addRootFlags(fs, readFlags...)
+	addRootFlags(fs, keyFlags...)
+	addRootFlags(fs, "privateKeysEnv", "keystoreDir")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s daemon -queue /var/spool/payouts -rpcURL https://... -privateKeyEnv SENDER_KEY [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nSends every job of the queue, a JSON object {\"id\": \"...\", \"receiver\": \"0x...\", \"amount\": \"0.1\", \"token\": \"0x...\"}, and writes its result back.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// daemonJob is a transfer read from the queue, in the format of a JSON batch row
+type daemonJob struct {
+	ID string `json:"id,omitempty"`
+	batchTransfer
+}
+
+// daemonResult is written back to the queue once a job is mined or has failed
+type daemonResult struct {
+	ID string `json:"id,omitempty"`
+	// Job numbers the jobs read with -stdin from 1, as their results come in the order they finish
+	Job      int    `json:"job,omitempty"`
+	Receiver string `json:"receiver,omitempty"`
+	Amount   string `json:"amount,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Memo     string `json:"memo,omitempty"`
+	// Status is success, reverted, failed (not sent) or unknown (sent, but not seen mined); jobs
+	// read with -stdin may also be sent (not waited for) or simulated (-dryRun)
+	Status string   `json:"status"`
+	Hash   string   `json:"hash,omitempty"`
+	Hashes []string `json:"hashes,omitempty"`
+	Block  uint64   `json:"block,omitempty"`
+	// Error is why the job failed or, when it reverted, the revert reason
+	Error string `json:"error,omitempty"`
+}
+
+// runDaemon implements the "daemon" subcommand, a worker sending the jobs of a queue until stopped
+func runDaemon(args []string) {
+	var opts daemonOptions
+	fs := newDaemonFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if opts.queue == "" || *rpcURLFlag == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	if *concurrency < 1 {
+		exitf(exitInvalid, "-concurrency must be at least 1")
+	}
+	stopTracing, err := setupTracing()
+	if err != nil {
+		fatalf("Failed to set up tracing: %v", err)
+	}
+	defer stopTracing()
+	client, chainID := dialRPC()
+	if err := applySendPolicy(client, chainID); err != nil {
+		exitf(exitInvalid, "Invalid send policy: %v", err)
+	}
+	pool, err := loadSenderPool()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	infof("Sender's address: %s", pool.describe())
+	seedPolicySpend(chainID, pool.addresses())
+	watchConfig(fs, client, chainID)
+	serveMetrics()
+	queue, err := openQueue(opts.queue)
+	if err != nil {
+		fatalf("Failed to open queue: %v", err)
+	}
+	defer queue.close()
+
+	ctx := notifyShutdown()
+	handoff := &jobHandoff{stopping: ctx, queue: opts.queue}
+	finish := func(job *queuedJob, result daemonResult) {
+		if result.Status == statusHandedOff {
+			return
+		}
+		data, _ := json.Marshal(result)
+		if err := queue.finish(job, data, result.Status != "success"); err != nil {
+			warnf("Failed to write the result of job %s: %v", job.name, err)
+		}
+		resultf([]interface{}{"job", result.ID, "status", result.Status, "hash", result.Hash, "error", result.Error}, "Job %s: %s", jobLabel(job, result), result.Status)
+	}
+	var wg sync.WaitGroup
+	resumeHandedOff(client, pool, chainID, handoff, &wg, finish)
+
+	infof("Waiting for jobs on %s with up to %d in flight", opts.queue, *concurrency)
+	decimals := &tokenDecimals{byToken: map[string]int{}}
+	slots := make(chan struct{}, *concurrency)
+	for {
+		// a job is only taken once there is room for it and -rateLimit allows it, the rest stay in the queue
+		slots <- struct{}{}
+		if err := sendLimiter.Wait(ctx); err != nil {
+			<-slots
+			break
+		}
+		job, err := queue.next(ctx)
+		if err != nil {
+			<-slots
+			if ctx.Err() != nil {
+				break
+			}
+			warnf("Failed to read from the queue: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			account := pool.acquire()
+			defer pool.release(account)
+			finish(job, runJob(client, account, chainID, job, decimals, true, handoff))
+		}()
+	}
+	// jobs being sent finish sending, and those sent are handed off rather than waited for
+	infof("Stopping, waiting up to %s for the jobs being sent. Interrupt again to exit at once", *shutdownTimeoutFlag)
+	if !waitShutdown(&wg) {
+		warnf("Jobs were still being sent after -shutdownTimeout of %s, they may or may not have been sent", *shutdownTimeoutFlag)
+	}
+}
+
+// jobHandoff hands off the jobs of queue still unconfirmed once stopping is done, for the next
+// start of the daemon to follow
+type jobHandoff struct {
+	stopping context.Context
+	queue    string
+}
+
+// resumeHandedOff follows again the jobs the last run of the daemon handed off, in the background
+// with wg, and passes their results to finish
+func resumeHandedOff(client *ethclient.Client, pool *senderPool, chainID *big.Int, handoff *jobHandoff, wg *sync.WaitGroup, finish func(*queuedJob, daemonResult)) {
+	pending, err := loadPendingJobs(handoff.queue, chainID)
+	if err != nil {
+		warnf("Failed to read the jobs handed off by the last run: %v", err)
+		return
+	}
+	resumed := 0
+	for path, p := range pending {
+		account := pool.account(p.From)
+		if account == nil {
+			warnf("Not following %s: its sender %s is not one of the keys", path, p.From.Hex())
+			continue
+		}
+		job, tracker, tx, err := p.resume(client)
+		if err != nil {
+			warnf("Not following %s: %v", path, err)
+			continue
+		}
+		resumed++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := resumeJob(client, account.signer, chainID, job, tracker, tx, p.Sent, handoff)
+			// a job handed off again has replaced its file
+			if result.Status != statusHandedOff {
+				if err := os.Remove(path); err != nil {
+					warnf("Failed to remove %s: %v", path, err)
+				}
+			}
+			finish(job, result)
+		}()
+	}
+	if resumed > 0 {
+		infof("Following %d job(s) handed off by the last run", resumed)
+	}
+}
+
+// sendJob builds, signs and broadcasts the transfer of j from account, or returns a nil
+// transaction if it was only simulated. A job that is not sent hands its nonce to the next one
+func sendJob(ctx context.Context, client *ethclient.Client, account *senderAccount, chainID *big.Int, j *daemonJob, decimals *tokenDecimals) (*types.Transaction, uint64, error) {
+	if err := checkAllowed(common.HexToAddress(j.Receiver)); err != nil {
+		return nil, 0, err
+	}
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get header: %v", err)
+	}
+	legacy, err := legacyTx(header)
+	if err != nil {
+		return nil, 0, err
+	}
+	baseFee := header.BaseFee
+	if legacy {
+		baseFee = nil
+	}
+	tip, feeCap, err := suggestFees(ctx, client, baseFee)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to determine fees: %v", err)
+	}
+	signer, from := account.signer, account.signer.Address()
+	nonce, err := account.nonces.Reserve(ctx, client, from)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get nonce: %v", err)
+	}
+	tx, err := sendBatchTransfer(ctx, client, signer, chainID, nonce, legacy, tip, feeCap, &j.batchTransfer, decimals)
+	if err != nil {
+		// the next job takes over an unused nonce, so no gap holds up the rest
+		account.nonces.Fail(from, nonce, err)
+		nonceGaps.Inc()
+		txFailed.WithLabelValues("send").Inc()
+		return nil, nonce, err
+	}
+	if tx == nil {
+		account.nonces.Release(from, nonce)
+	}
+	return tx, nonce, nil
+}
+
+// jobLabel names a job in the log by its ID, or by where it came from
+func jobLabel(job *queuedJob, result daemonResult) string {
+	if result.ID != "" {
+		return result.ID
+	}
+	return job.name
+}
+
+// decodeJob reads the transfer of job, and the result it starts from
+func decodeJob(job *queuedJob) (daemonJob, daemonResult, error) {
+	var j daemonJob
+	dec := json.NewDecoder(bytes.NewReader(job.data))
+	dec.UseNumber()
+	if err := dec.Decode(&j); err != nil {
+		return j, daemonResult{Status: "failed"}, fmt.Errorf("invalid job: %v", err)
+	}
+	return j, daemonResult{ID: j.ID, Receiver: j.Receiver, Amount: j.Amount.String(), Token: j.Token, Memo: j.Memo, Status: "failed"}, nil
+}
+
+// startJobSpan starts the span of a job, which endJobSpan ends with its result
+func startJobSpan(name string, j *daemonJob) (context.Context, trace.Span) {
+	// the memo also goes with the fee bumps of the job
+	return tracer.Start(withMemo(opCtx, j.Memo), name, trace.WithAttributes(attribute.String("job.id", j.ID), attribute.String("job.receiver", j.Receiver)))
+}
+
+func endJobSpan(span trace.Span, result daemonResult) {
+	span.SetAttributes(attribute.String("job.status", result.Status))
+	if result.Error != "" {
+		span.SetStatus(codes.Error, result.Error)
+	}
+	span.End()
+}
+
+// runJob sends the transfer of job from account and, if wait is set, follows it with fee bumps
+// until it is mined. With handoff, a job still unconfirmed when the daemon stops is handed off
+func runJob(client *ethclient.Client, account *senderAccount, chainID *big.Int, job *queuedJob, decimals *tokenDecimals, wait bool, handoff *jobHandoff) daemonResult {
+	j, result, err := decodeJob(job)
+	if err == nil {
+		err = j.validate()
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	ctx, span := startJobSpan("job", &j)
+	defer func() { endJobSpan(span, result) }()
+	// the policy stays the same from the fees to the broadcast, a reload waits for the send
+	configMu.RLock()
+	tx, nonce, err := sendJob(ctx, client, account, chainID, &j, decimals)
+	configMu.RUnlock()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if tx == nil {
+		result.Status = "simulated"
+		return result
+	}
+	sent := time.Now()
+	txSent.Inc()
+	infof("Job %s sent with nonce %d: %s", jobLabel(job, result), nonce, tx.Hash().Hex())
+	if !wait {
+		result.Status, result.Hash = "sent", tx.Hash().Hex()
+		return result
+	}
+	tracker := sender.NewTracker(client, account.signer.Address(), tx)
+	result = followJob(ctx, client, account.signer, chainID, job, &j, result, tracker, tx, sent, handoff)
+	return result
+}
+
+// resumeJob follows the transaction of a job handed off by the last run of the daemon, tracker
+// following its versions and tx being the latest
+func resumeJob(client *ethclient.Client, signer sender.Signer, chainID *big.Int, job *queuedJob, tracker *sender.Tracker, tx *types.Transaction, sent time.Time, handoff *jobHandoff) daemonResult {
+	j, result, err := decodeJob(job)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	ctx, span := startJobSpan("resumed job", &j)
+	defer func() { endJobSpan(span, result) }()
+	result = followJob(ctx, client, signer, chainID, job, &j, result, tracker, tx, sent, handoff)
+	return result
+}
+
+// followJob waits for the transaction of job, whose versions tracker follows with tx the latest,
+// with fee bumps until it is mined, and returns result completed with its receipt. With handoff, a
+// job still unconfirmed when the daemon stops is written to -pendingDir instead
+func followJob(ctx context.Context, client *ethclient.Client, signer sender.Signer, chainID *big.Int, job *queuedJob, j *daemonJob, result daemonResult, tracker *sender.Tracker, tx *types.Transaction, sent time.Time, handoff *jobHandoff) daemonResult {
+	configMu.RLock()
+	after, percent, bumps := *bumpAfter, *bumpPercent, *maxBumps
+	configMu.RUnlock()
+	if after == 0 {
+		bumps = 0
+	}
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if handoff != nil {
+		defer context.AfterFunc(handoff.stopping, cancel)()
+	}
+	from, nonce := signer.Address(), tx.Nonce()
+	result.Status, result.Hash = "unknown", tx.Hash().Hex()
+	receipt, latest, err := waitWithBumps(waitCtx, client, signer, chainID, tracker, tx, after, percent, bumps)
+	if hashes := tracker.Hashes(); len(hashes) > 1 {
+		for _, hash := range hashes {
+			result.Hashes = append(result.Hashes, hash.Hex())
+		}
+	}
+	if err == nil && *confirmations > 1 {
+		// the version mined may still change with a reorg, the result has the final one
+		receipt, err = tracker.Wait(waitCtx, *confirmations, pollInterval)
+	}
+	if err != nil && !errors.Is(err, sender.ErrNonceUsed) && handoff != nil && handoff.stopping.Err() != nil {
+		if err := handOff(handoff.queue, job, chainID, tracker, latest, sent); err != nil {
+			warnf("Failed to hand off job %s: %v", jobLabel(job, result), err)
+		} else {
+			infof("Job %s is not confirmed yet, handed off to the next start", jobLabel(job, result))
+			result.Status = statusHandedOff
+			return result
+		}
+	}
+	if err != nil {
+		txFailed.WithLabelValues("unknown").Inc()
+		result.Error = err.Error()
+		return result
+	}
+	observeReceipt(receipt, sent)
+	recordReceipt(client, receipt)
+	event := receiptEvent(chainID, from, nonce, receipt)
+	event.Job = j.ID
+	notifyWebhook(event)
+	result.Hash, result.Block = receipt.TxHash.Hex(), receipt.BlockNumber.Uint64()
+	result.Status = "success"
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		result.Status = "reverted"
+		result.Error = minedRevertReason(client, receipt)
+	}
+	return result
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// deadlineGrace is how long work that cannot be cancelled, such as a hardware wallet waiting
+// for a button press, may overrun -deadline before the process exits
+const deadlineGrace = 5 * time.Second
+
+var (
+	// opCtx is the context of the operation a command performs, done once -deadline has passed
+	opCtx = context.Background()
+	// stopDeadline stops the -deadline timer of opCtx
+	stopDeadline context.CancelFunc = func() {}
+)
+
+// startDeadline bounds opCtx by -deadline, if given
+func startDeadline() {
+	if *deadlineFlag <= 0 {
+		return
+	}
+	cause := fmt.Errorf("-deadline of %s exceeded", *deadlineFlag)
+	ctx, cancel := context.WithTimeoutCause(context.Background(), *deadlineFlag, cause)
+	opCtx = ctx
+	// a send stuck past the grace period cannot be ended alone, so the whole process exits
+	timer := time.AfterFunc(*deadlineFlag+deadlineGrace, func() {
+		abortf(exitTimeout, "Giving up (%v)", context.Cause(ctx))
+	})
+	stopDeadline = func() {
+		timer.Stop()
+		cancel()
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// decodeOptions holds the flags of the decode subcommand
+type decodeOptions struct {
+	rawTx string
+	data  string
+	abi   string
+}
+
+func newDecodeFlagSet(opts *decodeOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	fs.StringVar(&opts.rawTx, "rawTx", "", "Signed raw transaction as 0x-prefixed hex, or path to a file containing it")
+	fs.StringVar(&opts.data, "data", "", "Calldata to decode as 0x-prefixed hex")
+	fs.StringVar(&opts.abi, "abi", "", "Contract ABI JSON (or path to a file containing it) to decode the calldata with (default: ERC-20, ERC-1155, EIP-2612, EIP-3009, Permit2, WETH and Disperse)")
+	addRootFlags(fs, "locale", "v", "quiet", "logFormat")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s decode -rawTx 0x02f8...|-data 0xa9059cbb... [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runDecode implements the "decode" subcommand, which needs no RPC
+func runDecode(args []string) {
+	var opts decodeOptions
+	fs := newDecodeFlagSet(&opts)
+	fs.Parse(args)
+	if err := setupLogging(); err != nil {
+		exitf(exitInvalid, "Invalid logging options: %v", err)
+	}
+
+	if (opts.rawTx == "") == (opts.data == "") {
+		fmt.Println("Error: Missing required parameters (either -rawTx or -data)")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	// the contracts the other subcommands call, so their transactions can be audited
+	abis := []abi.ABI{mustLoadABI(erc20ABIJSON), erc1155ABI, erc2612ABI, eip3009ABI, permit2ABI, wethABI, disperseABI}
+	if opts.abi != "" {
+		custom, err := loadABI(opts.abi)
+		if err != nil {
+			exitf(exitInvalid, "Invalid ABI: %v", err)
+		}
+		abis = append([]abi.ABI{custom}, abis...)
+	}
+
+	if opts.data != "" {
+		data, err := hexutil.Decode(opts.data)
+		if err != nil {
+			exitf(exitInvalid, "Invalid -data: %v", err)
+		}
+		call, err := decodeCalldata(data, abis)
+		if err != nil {
+			fatalf("Failed to decode calldata: %v", err)
+		}
+		resultf([]interface{}{"call", call}, "%s", call)
+		return
+	}
+
+	tx, err := decodeRawTx(opts.rawTx)
+	if err != nil {
+		exitf(exitInvalid, "Invalid raw transaction: %v", err)
+	}
+	signer := types.LatestSignerForChainID(tx.ChainId())
+	if !tx.Protected() {
+		signer = types.HomesteadSigner{}
+	}
+	field := func(name, format string, args ...interface{}) {
+		value := fmt.Sprintf(format, args...)
+		resultf([]interface{}{"field", name, "value", value}, "%-10s %s", name+":", value)
+	}
+	field("Hash", "%s", tx.Hash().Hex())
+	field("Type", "%s", txTypeName(tx.Type()))
+	if tx.Protected() {
+		field("Chain ID", "%s", tx.ChainId())
+	} else {
+		field("Chain ID", "none, the transaction is not replay protected")
+	}
+	if from, err := types.Sender(signer, tx); err != nil {
+		field("From", "invalid signature: %v", err)
+	} else {
+		field("From", "%s", from.Hex())
+	}
+	if tx.To() == nil {
+		field("To", "contract creation")
+	} else {
+		field("To", "%s", tx.To().Hex())
+	}
+	field("Nonce", "%d", tx.Nonce())
+	field("Value", "%s Wei", nf.format(tx.Value().String()))
+	field("Gas limit", "%s", nf.format(fmt.Sprint(tx.Gas())))
+	if tx.Type() == types.LegacyTxType || tx.Type() == types.AccessListTxType {
+		field("Gas price", "%s Wei", nf.format(tx.GasPrice().String()))
+	} else {
+		field("Tip", "%s Wei", nf.format(tx.GasTipCap().String()))
+		field("Max fee", "%s Wei", nf.format(tx.GasFeeCap().String()))
+	}
+	if hashes := tx.BlobHashes(); len(hashes) > 0 {
+		field("Blobs", "%d at up to %s Wei per blob gas", len(hashes), nf.format(tx.BlobGasFeeCap().String()))
+	}
+	for _, tuple := range tx.AccessList() {
+		keys := make([]string, len(tuple.StorageKeys))
+		for i, key := range tuple.StorageKeys {
+			keys[i] = key.Hex()
+		}
+		field("Access", "%s %s", tuple.Address.Hex(), strings.Join(keys, " "))
+	}
+	for _, auth := range tx.SetCodeAuthorizations() {
+		if authority, err := auth.Authority(); err != nil {
+			field("Delegate", "invalid authorization: %v", err)
+		} else {
+			field("Delegate", "%s to %s", authority.Hex(), auth.Address.Hex())
+		}
+	}
+	if len(tx.Data()) == 0 {
+		return
+	}
+	if tx.To() == nil {
+		field("Data", "%d bytes of init code", len(tx.Data()))
+		return
+	}
+	if call, err := decodeCalldata(tx.Data(), abis); err == nil {
+		field("Call", "%s", call)
+	} else {
+		field("Data", "%s", hexutil.Encode(tx.Data()))
+	}
+}
+
+// decodeCalldata renders data as a call of the first method of abis matching its selector
+func decodeCalldata(data []byte, abis []abi.ABI) (string, error) {
+	if len(data) < 4 {
+		return "", fmt.Errorf("%d bytes are too short for a method selector", len(data))
+	}
+	for _, contract := range abis {
+		method, err := contract.MethodById(data[:4])
+		if err != nil {
+			continue
+		}
+		values, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			return "", fmt.Errorf("arguments of %s: %v", method.Sig, err)
+		}
+		args := make([]string, len(values))
+		for i, value := range values {
+			args[i] = formatValue(value)
+		}
+		return method.Name + "(" + strings.Join(args, ", ") + ")", nil
+	}
+	return "", errors.New("unknown method selector " + hexutil.Encode(data[:4]) + ", pass the contract's ABI with -abi")
+}
+
+// mustLoadABI parses one of the embedded ABIs
+func mustLoadABI(spec string) abi.ABI {
+	parsed, err := loadABI(spec)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// txTypeName describes a transaction type
+func txTypeName(typ uint8) string {
+	switch typ {
+	case types.LegacyTxType:
+		return "legacy (0)"
+	case types.AccessListTxType:
+		return "access list, EIP-2930 (1)"
+	case types.DynamicFeeTxType:
+		return "dynamic fee, EIP-1559 (2)"
+	case types.BlobTxType:
+		return "blob, EIP-4844 (3)"
+	case types.SetCodeTxType:
+		return "set code, EIP-7702 (4)"
+	}
+	return fmt.Sprintf("unknown (%d)", typ)
+}
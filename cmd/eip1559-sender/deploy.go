@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// deployOptions holds the flags of the deploy subcommand
+type deployOptions struct {
+	bytecode string
+	abi      string
+	args     string
+	value    string
+}
+
+func newDeployFlagSet(opts *deployOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("deploy", flag.ExitOnError)
+	fs.StringVar(&opts.bytecode, "bytecode", "", "Contract creation bytecode as hex, or path to a file containing it such as solc's .bin output")
+	fs.StringVar(&opts.abi, "abi", "", "Contract ABI JSON (or path to a file containing it) declaring the constructor")
+	fs.StringVar(&opts.args, "args", "", "Comma-separated constructor arguments, arrays in brackets such as [1,2,3]")
+	fs.StringVar(&opts.value, "value", "", "Native coin amount sent to a payable constructor")
+	addSharedFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s deploy -bytecode Token.bin [-abi Token.abi -args ...] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runDeploy implements the "deploy" subcommand
+func runDeploy(args []string) {
+	var opts deployOptions
+	fs := newDeployFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" || opts.bytecode == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	if opts.args != "" && opts.abi == "" {
+		exitf(exitInvalid, "-args needs -abi to encode the constructor arguments")
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	data, err := loadBytecode(opts.bytecode)
+	if err != nil {
+		exitf(exitInvalid, "Invalid bytecode: %v", err)
+	}
+	if opts.abi != "" {
+		parsed, err := loadABI(opts.abi)
+		if err != nil {
+			exitf(exitInvalid, "Invalid ABI: %v", err)
+		}
+		values, err := parseArgs(parsed.Constructor.Inputs, opts.args)
+		if err != nil {
+			exitf(exitInvalid, "Invalid constructor arguments: %v", err)
+		}
+		encoded, err := parsed.Constructor.Inputs.Pack(values...)
+		if err != nil {
+			fatalf("Failed to encode constructor arguments: %v", err)
+		}
+		data = append(data, encoded...)
+	}
+
+	client, chainID := dialRPC()
+	value := new(big.Int)
+	if opts.value != "" {
+		if value, err = parseUnits(opts.value, lookupChain(chainID).decimals); err != nil {
+			exitf(exitInvalid, "Invalid value: %v", err)
+		}
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	infof("Sender's address: %s", signer.Address().Hex())
+	tx := newTransaction(client, signer.Address(), chainID, nil, value, data, nf)
+	infof("Contract will be deployed at %s", crypto.CreateAddress(signer.Address(), tx.Nonce()).Hex())
+
+	// the contract address is only final once the receipt is in
+	*waitFlag = true
+	sendAndFollow(client, signer, chainID, tx, nf)
+}
+
+// loadBytecode reads hex bytecode, inline or from a file, with or without the 0x prefix
+func loadBytecode(value string) ([]byte, error) {
+	text := strings.TrimSpace(value)
+	if !strings.HasPrefix(text, "0x") {
+		data, err := os.ReadFile(text)
+		if err != nil {
+			return nil, err
+		}
+		text = strings.TrimSpace(string(data))
+	}
+	if !strings.HasPrefix(text, "0x") {
+		text = "0x" + text
+	}
+	code, err := hexutil.Decode(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("the bytecode is empty")
+	}
+	return code, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// pollInterval is how often receipts and new blocks are polled for, shorter with -dev as local
+// chains mine a block as soon as a transaction arrives
+var pollInterval = 2 * time.Second
+
+// devChainIDs are the chain IDs of local test chains: go-ethereum's developer chain and the
+// anvil, hardhat and ganache defaults
+var devChainIDs = []int64{1337, 31337}
+
+// devClients are the web3_clientVersion prefixes of local test chains started with another chain ID
+var devClients = []string{"anvil", "hardhatnetwork", "ganache"}
+
+// devFunded makes sure -devFund tops up the sender once per process
+var devFunded sync.Once
+
+// applyDev sets up -dev: the local RPC URL, an account of the well-known test mnemonic unless
+// another key source is given, quick polling, and receipts counted as final once mined, since
+// local chains only mine blocks on demand
+func applyDev() error {
+	if !*devFlag {
+		if *devAccount != 0 || *devFund != "" {
+			return errors.New("-devAccount and -devFund require -dev")
+		}
+		return nil
+	}
+	if *rpcURLFlag == "" {
+		*rpcURLFlag = "http://127.0.0.1:8545"
+	}
+	if *devAccount < 0 {
+		return errors.New("-devAccount must not be negative")
+	}
+	given := false
+	for _, set := range keySources() {
+		given = given || set
+	}
+	switch {
+	case !given:
+		*mnemonicFlag = devMnemonic
+		*hdPathFlag = fmt.Sprintf("m/44'/60'/0'/0/%d", *devAccount)
+	case *devAccount != 0:
+		return errors.New("-devAccount selects a test account and cannot be combined with another key source")
+	}
+	if *devFund != "" {
+		if _, err := parseUnits(*devFund, 18); err != nil {
+			return fmt.Errorf("invalid -devFund: %v", err)
+		}
+	}
+	if *confirmations > 1 {
+		warnf("-dev waits for 1 confirmation instead of %d, local chains only mine blocks on demand", *confirmations)
+		*confirmations = 1
+	}
+	pollInterval = 200 * time.Millisecond
+	return nil
+}
+
+// checkDevChain refuses -dev on anything but a local test chain, where the test mnemonic's keys
+// are public and whatever they hold is taken within seconds
+func checkDevChain(client *ethclient.Client, chainID *big.Int) error {
+	for _, id := range devChainIDs {
+		if chainID.Cmp(big.NewInt(id)) == 0 {
+			return nil
+		}
+	}
+	var version string
+	if err := client.Client().CallContext(opCtx, &version, "web3_clientVersion"); err == nil {
+		for _, prefix := range devClients {
+			if strings.HasPrefix(strings.ToLower(version), prefix) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("chain ID %s is not a local test chain such as anvil, hardhat (31337) or devnet (1337)", chainID)
+}
+
+// fundDevAccount sets the balance of account to -devFund through anvil_setBalance or
+// hardhat_setBalance, so tests start from a known balance without a funding transfer
+func fundDevAccount(client *ethclient.Client, account common.Address) {
+	if !*devFlag || *devFund == "" {
+		return
+	}
+	devFunded.Do(func() {
+		balance, _ := parseUnits(*devFund, 18)
+		var err error
+		for _, method := range []string{"anvil_setBalance", "hardhat_setBalance"} {
+			if err = client.Client().CallContext(opCtx, nil, method, account, (*hexutil.Big)(balance)); err == nil {
+				infof("Set the balance of %s to %s ETH with %s", account.Hex(), *devFund, method)
+				return
+			}
+		}
+		fatalf("Failed to fund %s, -devFund needs anvil or hardhat: %v", account.Hex(), err)
+	})
+}
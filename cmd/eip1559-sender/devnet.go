@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/catalyst"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/ethclient"
+	gethlog "github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// mockERC20Bytecode is the creation code of a minimal hand-assembled ERC-20 ("Mock Token", MOCK, 18 decimals).
+// Besides the standard ERC-20 methods it exposes an unrestricted mint(address,uint256) for funding test accounts.
+const mockERC20Bytecode = "0x61029e8061000d6000396000f360003560e01c806306fdde031461007957806395d89b41146100ad578063313ce567146100e157806318160ddd146100e857806370a08231146100f0578063dd62ed3e14610106578063a9059cbb1461013e578063095ea7b31461014d57806323b872dd146101a357806340c10f19146101e2575b600080fd5b6020600052600a6020527f4d6f636b20546f6b656e0000000000000000000000000000000000000000000060405260606000f35b602060005260046020527f4d4f434b0000000000000000000000000000000000000000000000000000000060405260606000f35b601261012a565b60025461012a565b600435600052600060205260406000205461012a565b6004356000526001602052604060002060243560005260205260406000205461012a565b60005260206000f35b600160005260206000f35b6101336024356004353361023a565b336000526001602052604060002060043560005260205260406000206024359055602435600052600435337f8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b92560206000a3610133565b6004356000526001602052604060002033600052602052604060002080546044358181116100745781038255505061013360443560243560043561023a565b6004356000526000602052604060002080546024350190556002546024350160025560243560005260043560007fddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef60206000a3610133565b8060005260006020526040600020805480851161007457808590038255505081600052600060205260406000208054840190558260005281817fddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef60206000a350505056"
+
+// devnetChainID is the chain ID of the go-ethereum developer chain
+const devnetChainID = 1337
+
+// devnetOptions holds the flags of the devnet subcommand
+type devnetOptions struct {
+	listen    string
+	accounts  int
+	balance   string
+	tokens    string
+	blockTime uint64
+	mnemonic  string
+	verbose   bool
+}
+
+func newDevnetFlagSet(opts *devnetOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("devnet up", flag.ExitOnError)
+	fs.StringVar(&opts.listen, "listen", "127.0.0.1:8545", "Address the HTTP and WebSocket RPC server listens on")
+	fs.IntVar(&opts.accounts, "accounts", 10, "Number of pre-funded test accounts")
+	fs.StringVar(&opts.balance, "balance", "10000", "Native balance of every test account")
+	fs.StringVar(&opts.tokens, "tokens", "1000000", "Mock ERC-20 balance minted to every test account")
+	fs.Uint64Var(&opts.blockTime, "blockTime", 0, "Seconds between blocks (if 0, a block is mined as soon as a transaction arrives)")
+	fs.StringVar(&opts.mnemonic, "mnemonic", devMnemonic, "Mnemonic the test accounts are derived from")
+	fs.BoolVar(&opts.verbose, "verbose", false, "Show the embedded node's logs")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s devnet up [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runDevnet implements the "devnet up" subcommand
+func runDevnet(args []string) {
+	var opts devnetOptions
+	fs := newDevnetFlagSet(&opts)
+	if len(args) == 0 || args[0] != "up" {
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	fs.Parse(args[1:])
+
+	host, portString, err := net.SplitHostPort(opts.listen)
+	if err != nil {
+		exitf(exitInvalid, "Invalid listen address: %v", err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		exitf(exitInvalid, "Invalid listen port: %v", err)
+	}
+	balance, err := parseDevnetBalance(opts.balance)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -balance: %v", err)
+	}
+	amount, err := parseDevnetBalance(opts.tokens)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -tokens: %v", err)
+	}
+	if !opts.verbose {
+		gethlog.SetDefault(gethlog.NewLogger(gethlog.NewTerminalHandlerWithLevel(os.Stderr, gethlog.LevelCrit, true)))
+	}
+
+	// derive the test accounts and fund them in the genesis block
+	genesis := core.DeveloperGenesisBlock(ethconfig.Defaults.Miner.GasCeil, nil)
+	keys := make([]*ecdsa.PrivateKey, opts.accounts)
+	for i := range keys {
+		path := make(accounts.DerivationPath, len(accounts.DefaultBaseDerivationPath))
+		copy(path, accounts.DefaultBaseDerivationPath)
+		path[len(path)-1] = uint32(i)
+		keys[i], err = mnemonicToKey(opts.mnemonic, "", path)
+		if err != nil {
+			exitf(exitInvalid, "Failed to derive test account %d: %v", i, err)
+		}
+		genesis.Alloc[crypto.PubkeyToAddress(keys[i].PublicKey)] = types.Account{Balance: balance}
+	}
+
+	stack, backend, beacon, err := startDevnetNode(host, port, genesis, opts.blockTime)
+	if err != nil {
+		fatalf("Failed to start devnet: %v", err)
+	}
+	defer stack.Close()
+	client := ethclient.NewClient(stack.Attach())
+	if opts.blockTime == 0 {
+		mineOnDemand(backend, beacon)
+	}
+
+	// deploy the mock token and mint the test balances
+	token := common.Address{}
+	if len(keys) > 0 {
+		receipt, err := sendDevnetTx(client, keys[0], nil, hexutil.MustDecode(mockERC20Bytecode))
+		if err != nil {
+			fatalf("Failed to deploy mock ERC-20: %v", err)
+		}
+		token = receipt.ContractAddress
+		for _, key := range keys {
+			data := append(common.FromHex("0x40c10f19"), common.LeftPadBytes(crypto.PubkeyToAddress(key.PublicKey).Bytes(), 32)...)
+			data = append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+			if _, err := sendDevnetTx(client, keys[0], &token, data); err != nil {
+				fatalf("Failed to mint mock ERC-20: %v", err)
+			}
+		}
+	}
+
+	rpcURL := fmt.Sprintf("http://%s", opts.listen)
+	fmt.Printf("Devnet running with chain ID %d\n", devnetChainID)
+	fmt.Printf("RPC URL: %s (WebSocket: ws://%s)\n", rpcURL, opts.listen)
+	if len(keys) > 0 {
+		fmt.Printf("Mock ERC-20 (MOCK, 18 decimals): %s\n", token.Hex())
+	}
+	fmt.Printf("\nAccounts (%s native tokens and %s MOCK each):\n", opts.balance, opts.tokens)
+	for i, key := range keys {
+		fmt.Printf("  (%d) %s  private key: %x\n", i, crypto.PubkeyToAddress(key.PublicKey).Hex(), crypto.FromECDSA(key))
+	}
+	if len(keys) > 1 {
+		fmt.Printf("\nExample:\n")
+		fmt.Printf("  %s -rpcURL %s -privateKey %x -receiver %s -amount 1\n",
+			filepath.Base(os.Args[0]), rpcURL, crypto.FromECDSA(keys[0]), crypto.PubkeyToAddress(keys[1].PublicKey).Hex())
+	}
+	fmt.Printf("\nPress Ctrl+C to stop\n")
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+	fmt.Println("Shutting down devnet")
+}
+
+// parseDevnetBalance converts a balance of the test accounts to base units of 18 decimals exactly,
+// allowing 0 to fund none
+func parseDevnetBalance(amount string) (*big.Int, error) {
+	if decimalAmount.MatchString(amount) && strings.Trim(amount, "0.") == "" {
+		return new(big.Int), nil
+	}
+	return parseUnits(amount, 18)
+}
+
+// startDevnetNode starts an in-memory developer chain serving HTTP and WebSocket RPC on host:port
+func startDevnetNode(host string, port int, genesis *core.Genesis, blockTime uint64) (*node.Node, *eth.Ethereum, *catalyst.SimulatedBeacon, error) {
+	modules := []string{"eth", "net", "web3", "txpool"}
+	nodeConf := node.DefaultConfig
+	nodeConf.DataDir = ""
+	nodeConf.P2P = p2p.Config{NoDiscovery: true, ListenAddr: ""}
+	nodeConf.HTTPHost, nodeConf.HTTPPort = host, port
+	nodeConf.HTTPModules = modules
+	nodeConf.HTTPVirtualHosts = []string{"*"}
+	nodeConf.HTTPCors = []string{"*"}
+	nodeConf.WSHost, nodeConf.WSPort = host, port
+	nodeConf.WSModules = modules
+	nodeConf.WSOrigins = []string{"*"}
+
+	stack, err := node.New(&nodeConf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ethConf := ethconfig.Defaults
+	ethConf.NetworkId = devnetChainID
+	ethConf.Genesis = genesis
+	ethConf.SyncMode = downloader.FullSync
+	backend, err := eth.New(stack, &ethConf)
+	if err != nil {
+		stack.Close()
+		return nil, nil, nil, err
+	}
+	filterSystem := filters.NewFilterSystem(backend.APIBackend, filters.Config{})
+	stack.RegisterAPIs([]rpc.API{{
+		Namespace: "eth",
+		Service:   filters.NewFilterAPI(filterSystem),
+	}})
+
+	beacon, err := catalyst.NewSimulatedBeacon(blockTime, common.Address{}, backend)
+	if err != nil {
+		stack.Close()
+		return nil, nil, nil, err
+	}
+	catalyst.RegisterSimulatedBeaconAPIs(stack, beacon)
+	stack.RegisterLifecycle(beacon)
+	if err := stack.Start(); err != nil {
+		stack.Close()
+		return nil, nil, nil, err
+	}
+	return stack, backend, beacon, nil
+}
+
+// mineOnDemand seals a new block whenever transactions enter the pool
+func mineOnDemand(backend *eth.Ethereum, beacon *catalyst.SimulatedBeacon) {
+	txs := make(chan core.NewTxsEvent, 64)
+	backend.TxPool().SubscribeTransactions(txs, true)
+	go func() {
+		for range txs {
+			beacon.Commit()
+		}
+	}()
+}
+
+// sendDevnetTx sends a transaction from key and waits for it to be mined successfully
+func sendDevnetTx(client *ethclient.Client, key *ecdsa.PrivateKey, to *common.Address, data []byte) (*types.Receipt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gas, err := client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: to, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(devnetChainID)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(devnetChainID),
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), tip),
+		Gas:       gas,
+		To:        to,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	receipt, err := sender.WaitReceipt(ctx, client, tx.Hash(), 1, 100*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, errors.New("transaction reverted")
+	}
+	return receipt, nil
+}
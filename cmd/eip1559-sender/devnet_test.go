@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseDevnetBalance(t *testing.T) {
+	for _, tc := range []struct {
+		amount string
+		want   string // base units, empty if the amount is refused
+	}{
+		{"1000000", "1000000000000000000000000"},
+		{"10000", "10000000000000000000000"},
+		{"0.1", "100000000000000000"},
+		{"0", "0"},
+		{"0.0", "0"},
+		{"1.0000000000000000001", ""},
+		{"-1", ""},
+		{"1e6", ""},
+	} {
+		got, err := parseDevnetBalance(tc.amount)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("parseDevnetBalance(%q) = %s, want an error", tc.amount, got)
+			}
+			continue
+		}
+		want, _ := new(big.Int).SetString(tc.want, 10)
+		if err != nil || got.Cmp(want) != 0 {
+			t.Errorf("parseDevnetBalance(%q) = %v, %v, want %s", tc.amount, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// dispatchOptions holds the flags of the dispatch subcommand
+type dispatchOptions struct {
+	jobs string
+}
+
+func newDispatchFlagSet(opts *dispatchOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("dispatch", flag.ExitOnError)
+	fs.StringVar(&opts.jobs, "jobs", "", "JSON file listing the transfers to send, each with the RPC URL of its chain")
+	addRootFlags(fs, "proxy", "header", "rpcRetries", "rpcTimeout", "deadline", "locale", "tokenABI", "errorABI", "maxFeeEth", "maxFeeGwei",
+		"priority", "feeBlocks", "feePercentile", "feeHeadroom", "feeSource", "txType", "nonceSource",
+		"wait", "confirmations", "dryRun", "force", "yes", "y", "historyFile", "v", "quiet", "logFormat", "config", "profile")
+	addRootFlags(fs, keyFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s dispatch -jobs jobs.json -privateKeyEnv SENDER_KEY [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nSends transfers on several chains in one run, with one connection, fee estimate and nonce sequence per chain.\n")
+		fmt.Fprintf(fs.Output(), "Each entry of -jobs is {\"rpcURL\": \"https://...\", \"receiver\": \"0x...\", \"amount\": \"0.1\"}, with an optional\n")
+		fmt.Fprintf(fs.Output(), "\"token\" contract and an optional \"chainID\" the node has to report.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// dispatchJob is one transfer of a jobs file
+type dispatchJob struct {
+	RPCURL  string `json:"rpcURL"`
+	ChainID int64  `json:"chainID,omitempty"`
+	batchTransfer
+}
+
+// dispatchChain is the connection and transfers of one chain of a jobs file
+type dispatchChain struct {
+	rpcURL  string
+	client  *ethclient.Client
+	chainID *big.Int
+	legacy  bool
+	tip     *big.Int
+	feeCap  *big.Int
+	nonces  *sender.NonceManager
+	jobs    []*dispatchJob
+	err     error
+}
+
+// loadJobs reads and validates the transfers of a jobs file
+func loadJobs(path string) ([]*dispatchJob, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var jobs []*dispatchJob
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	if err := dec.Decode(&jobs); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	for i, job := range jobs {
+		if job.RPCURL == "" {
+			return nil, fmt.Errorf("job %d: missing rpcURL", i+1)
+		}
+		if job.ChainID < 0 {
+			return nil, fmt.Errorf("job %d: invalid chainID %d", i+1, job.ChainID)
+		}
+		if err := job.validate(); err != nil {
+			return nil, fmt.Errorf("job %d: %v", i+1, err)
+		}
+		job.row = i + 1
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("%s contains no transfers", path)
+	}
+	return jobs, nil
+}
+
+// runDispatch implements the "dispatch" subcommand: the transfers of -jobs, grouped by chain,
+// confirmed once and reported in one table
+func runDispatch(args []string) {
+	var opts dispatchOptions
+	fs := newDispatchFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if opts.jobs == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	jobs, err := loadJobs(opts.jobs)
+	if err != nil {
+		exitf(exitInvalid, "Invalid jobs file: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	from := signer.Address()
+	infof("Sender's address: %s", from.Hex())
+
+	var chains []*dispatchChain
+	byURL := map[string]*dispatchChain{}
+	for _, job := range jobs {
+		chain := byURL[job.RPCURL]
+		if chain == nil {
+			chain = &dispatchChain{rpcURL: job.RPCURL}
+			byURL[job.RPCURL] = chain
+			chains = append(chains, chain)
+		}
+		chain.jobs = append(chain.jobs, job)
+	}
+	// the same account has a nonce sequence of its own on every chain, shared by the RPC URLs
+	// of one chain
+	nonceManagers := map[string]*sender.NonceManager{}
+	var problems []string
+	for _, chain := range chains {
+		chain.connect()
+		if chain.err != nil {
+			warnf("Not sending the %d transfer(s) on %s: %v", len(chain.jobs), chain.rpcURL, chain.err)
+			continue
+		}
+		if nonceManagers[chain.chainID.String()] == nil {
+			nonceManagers[chain.chainID.String()] = &sender.NonceManager{Next: sourceNonce}
+		}
+		chain.nonces = nonceManagers[chain.chainID.String()]
+		infof("%s: %s (chain ID %s), %d transfer(s)", chain.rpcURL, lookupChain(chain.chainID).name, chain.chainID, len(chain.jobs))
+		for _, job := range chain.jobs {
+			for _, problem := range receiverProblems(chain.client, chain.chainID, from, common.HexToAddress(job.Receiver), job.Token, job.Token == "") {
+				problems = append(problems, fmt.Sprintf("job %d: %s", job.row, problem))
+			}
+		}
+	}
+	checkReceiver(problems)
+
+	if *dryRunFlag {
+		infof("Dry run, the transactions will not be broadcast")
+	} else {
+		var summary strings.Builder
+		fmt.Fprintf(&summary, "\nFrom: %s\n", from.Hex())
+		for _, chain := range chains {
+			if chain.err != nil {
+				continue
+			}
+			info := lookupChain(chain.chainID)
+			fmt.Fprintf(&summary, "%s (chain ID %s), maxFeePerGas %s gwei:\n", info.name, chain.chainID, formatUnits(chain.feeCap, 9))
+			for _, job := range chain.jobs {
+				token := info.symbol
+				if job.Token != "" {
+					token = "of token " + common.HexToAddress(job.Token).Hex()
+					if erc20, err := loadToken(chain.client, job.Token, *tokenABIFlag); err == nil {
+						token = erc20.describe()
+					}
+				}
+				fmt.Fprintf(&summary, "  %d. %s %s to %s\n", job.row, nf.format(job.Amount.String()), token, job.Receiver)
+			}
+		}
+		if err := confirm(summary.String()); err != nil {
+			fatalf("Not sending: %v", err)
+		}
+	}
+
+	for _, chain := range chains {
+		chain.send(signer)
+	}
+	if *waitFlag {
+		for _, chain := range chains {
+			chain.wait()
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nJOB\tCHAIN\tRECEIVER\tAMOUNT\tTOKEN\tHASH\tSTATUS")
+	failed := false
+	for _, job := range jobs {
+		chain := byURL[job.RPCURL]
+		chainName := job.RPCURL
+		if chain.chainID != nil {
+			chainName = lookupChain(chain.chainID).name
+		}
+		token, hash := "native", "-"
+		if job.Token != "" {
+			token = common.HexToAddress(job.Token).Hex()
+		}
+		if job.hash != (common.Hash{}) {
+			hash = job.hash.Hex()
+		}
+		if job.status != "sent" && job.status != "simulated" && !strings.HasPrefix(job.status, "success") {
+			failed = true
+		}
+		receiver := common.HexToAddress(job.Receiver).Hex()
+		if *logFormatFlag == "json" {
+			chainID := ""
+			if chain.chainID != nil {
+				chainID = chain.chainID.String()
+			}
+			resultf([]interface{}{"job", job.row, "chainID", chainID, "receiver", receiver, "amount", job.Amount.String(), "token", token, "hash", hash, "status", job.status}, "Job %d: %s", job.row, job.status)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", job.row, chainName, receiver, nf.format(job.Amount.String()), token, hash, job.status)
+	}
+	w.Flush()
+	if failed {
+		exit(exitFailure)
+	}
+}
+
+// connect dials the chain, checks the chain ID the jobs expect and estimates the fees its
+// transfers are sent with. A failure is kept in c.err and fails the chain's jobs
+func (c *dispatchChain) connect() {
+	c.err = func() error {
+		client, chainID, err := dialEndpoint(c.rpcURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %v", err)
+		}
+		c.client, c.chainID = client, chainID
+		for _, job := range c.jobs {
+			if job.ChainID != 0 && job.ChainID != chainID.Int64() {
+				return fmt.Errorf("job %d expects chain ID %d, but the node reports %s", job.row, job.ChainID, chainID)
+			}
+		}
+		header, err := client.HeaderByNumber(opCtx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get header: %v", err)
+		}
+		if c.legacy, err = legacyTx(header); err != nil {
+			return fmt.Errorf("invalid transaction type: %v", err)
+		}
+		baseFee := header.BaseFee
+		if c.legacy {
+			baseFee = nil
+		}
+		if c.tip, c.feeCap, err = suggestFees(opCtx, client, baseFee); err != nil {
+			return fmt.Errorf("failed to determine fees: %v", err)
+		}
+		return nil
+	}()
+	if c.err != nil {
+		for _, job := range c.jobs {
+			job.status = "failed: " + c.err.Error()
+		}
+	}
+}
+
+// send signs and broadcasts the transfers of the chain at consecutive nonces
+func (c *dispatchChain) send(signer sender.Signer) {
+	if c.err != nil {
+		return
+	}
+	from := signer.Address()
+	decimals := &tokenDecimals{byToken: map[string]int{}}
+	for _, job := range c.jobs {
+		nonce, err := c.nonces.Reserve(opCtx, c.client, from)
+		if err != nil {
+			job.status = "failed: " + err.Error()
+			continue
+		}
+		if _, err := sendBatchTransfer(opCtx, c.client, signer, c.chainID, nonce, c.legacy, c.tip, c.feeCap, &job.batchTransfer, decimals); err != nil {
+			// the next job takes over an unused nonce, so no gap holds up the rest
+			c.nonces.Fail(from, nonce, err)
+			job.status = "failed: " + err.Error()
+			continue
+		}
+		job.status = "sent"
+		if *dryRunFlag {
+			job.status = "simulated"
+			continue
+		}
+		infof("Job %d: sent on chain %s, transaction hash: %s", job.row, c.chainID, job.hash.Hex())
+	}
+}
+
+// wait waits for the receipts of the transfers sent on the chain
+func (c *dispatchChain) wait() {
+	for _, job := range c.jobs {
+		if job.status != "sent" {
+			continue
+		}
+		receipt, err := sender.WaitReceipt(opCtx, c.client, job.hash, *confirmations, pollInterval)
+		switch {
+		case err != nil:
+			job.status = "unknown: " + err.Error()
+		case receipt.Status == types.ReceiptStatusSuccessful:
+			recordReceipt(c.client, receipt)
+			job.status = fmt.Sprintf("success (block %d)", receipt.BlockNumber)
+		default:
+			recordReceipt(c.client, receipt)
+			job.status = revertedStatus(c.client, receipt)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// defaultDisperse is the Disperse contract of disperse.app, deployed at the same address on
+// mainnet and most other chains
+const defaultDisperse = "0xD152f549545093347A162Dce210e7293f1452150"
+
+// disperseABI holds the Disperse methods sending the native coin and tokens to many receivers
+var disperseABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(`[
+		{"type":"function","name":"disperseEther","stateMutability":"payable","inputs":[{"name":"recipients","type":"address[]"},{"name":"values","type":"uint256[]"}],"outputs":[]},
+		{"type":"function","name":"disperseToken","stateMutability":"nonpayable","inputs":[{"name":"token","type":"address"},{"name":"recipients","type":"address[]"},{"name":"values","type":"uint256[]"}],"outputs":[]}
+	]`))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// sendBatchDisperse sends the transfers of a batch as one Disperse call per token, the native
+// coin counting as one, in the order the tokens first appear in the file. The rows of a call
+// share its hash and status
+func sendBatchDisperse(client *ethclient.Client, signer sender.Signer, chainID *big.Int, legacy bool, tip, feeCap *big.Int, transfers []*batchTransfer, decimals *tokenDecimals, progress *batchProgress) {
+	if !common.IsHexAddress(*disperseAddr) {
+		exitf(exitInvalid, "Invalid -disperseContract address %q", *disperseAddr)
+	}
+	contract := common.HexToAddress(*disperseAddr)
+	if code, err := client.CodeAt(opCtx, contract, nil); err != nil {
+		exitf(exitRPC, "Failed to get the code of the Disperse contract: %v", err)
+	} else if len(code) == 0 {
+		fatalf("No Disperse contract at %s on this chain, deploy one and pass -disperseContract", contract.Hex())
+	}
+
+	var tokens []string
+	groups := map[string][]*batchTransfer{}
+	for _, t := range transfers {
+		key := ""
+		if t.Token != "" {
+			key = common.HexToAddress(t.Token).Hex()
+		}
+		if _, ok := groups[key]; !ok {
+			tokens = append(tokens, key)
+		}
+		groups[key] = append(groups[key], t)
+	}
+
+	from := signer.Address()
+	for _, token := range tokens {
+		rows := groups[token]
+		status := "sent"
+		if *dryRunFlag {
+			status = "simulated"
+		}
+		nonce, err := nonces.Reserve(opCtx, client, from)
+		if err != nil {
+			exitf(exitRPC, "Failed to get nonce: %v", err)
+		}
+		tx, err := sendDisperse(client, signer, chainID, contract, nonce, legacy, tip, feeCap, token, rows, decimals)
+		if err != nil {
+			nonces.Fail(from, nonce, err)
+			status = "failed: " + err.Error()
+		} else if tx != nil {
+			infof("Dispersed %d transfers in %s (%d gas)", len(rows), tx.Hash().Hex(), tx.Gas())
+		}
+		for _, t := range rows {
+			t.status = status
+			if tx != nil {
+				t.from, t.hash, t.nonce = from, tx.Hash(), tx.Nonce()
+			}
+			progress.record(t)
+		}
+	}
+}
+
+// sendDisperse sends rows, which all move the native coin or all the same token, with one
+// Disperse call at nonce. Tokens are pulled by the contract, so it needs an allowance covering
+// the total
+func sendDisperse(client *ethclient.Client, signer sender.Signer, chainID *big.Int, contract common.Address, nonce uint64, legacy bool, tip, feeCap *big.Int, token string, rows []*batchTransfer, decimals *tokenDecimals) (*types.Transaction, error) {
+	unit := lookupChain(chainID).decimals
+	var erc20 *erc20Token
+	if token != "" {
+		var err error
+		if erc20, err = loadToken(client, token, *tokenABIFlag); err != nil {
+			return nil, err
+		}
+		if unit, err = decimals.get(erc20); err != nil {
+			return nil, err
+		}
+	}
+	recipients := make([]common.Address, len(rows))
+	values := make([]*big.Int, len(rows))
+	total := new(big.Int)
+	for i, t := range rows {
+		amount, err := parseUnits(t.Amount.String(), unit)
+		if err != nil {
+			return nil, err
+		}
+		recipients[i], values[i] = common.HexToAddress(t.Receiver), amount
+		total.Add(total, amount)
+	}
+
+	if erc20 == nil {
+		data, err := disperseABI.Pack("disperseEther", recipients, values)
+		if err != nil {
+			return nil, err
+		}
+		return sendBatchTx(opCtx, client, signer, chainID, nonce, legacy, tip, feeCap, contract, total, data)
+	}
+	results, err := erc20.call("allowance", signer.Address(), contract)
+	if err != nil {
+		return nil, err
+	}
+	if allowance, ok := results[0].(*big.Int); !ok || allowance.Cmp(total) < 0 {
+		return nil, fmt.Errorf("the Disperse contract may only spend %v of the %s base units to send, approve it first: approve -tokenContract %s -spender %s -amount %s",
+			results[0], total, erc20.address.Hex(), contract.Hex(), formatUnits(total, unit))
+	}
+	data, err := disperseABI.Pack("disperseToken", erc20.address, recipients, values)
+	if err != nil {
+		return nil, err
+	}
+	return sendBatchTx(opCtx, client, signer, chainID, nonce, legacy, tip, feeCap, contract, new(big.Int), data)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// defaultENSRegistry is the ENS registry deployed on mainnet, Sepolia and Holesky
+const defaultENSRegistry = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+
+// ensABI holds the registry's resolver() and the resolver's addr() lookups
+var ensABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(`[
+		{"type":"function","name":"resolver","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+		{"type":"function","name":"addr","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]}
+	]`))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// isENSName reports whether value looks like an ENS name rather than a hex address
+func isENSName(value string) bool {
+	return strings.Contains(value, ".") && !common.IsHexAddress(value)
+}
+
+// namehash computes the EIP-137 node of an ENS name
+func namehash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node[:], crypto.Keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// resolveENS looks up the address of name through the registry at -ensRegistry
+func resolveENS(client *ethclient.Client, name string) (common.Address, error) {
+	if !common.IsHexAddress(*ensRegistry) {
+		return common.Address{}, fmt.Errorf("invalid ENS registry address %q", *ensRegistry)
+	}
+	registry := common.HexToAddress(*ensRegistry)
+	if code, err := client.CodeAt(opCtx, registry, nil); err != nil {
+		return common.Address{}, err
+	} else if len(code) == 0 {
+		return common.Address{}, fmt.Errorf("no ENS registry at %s on this chain, set -ensRegistry", registry.Hex())
+	}
+	node := namehash(strings.ToLower(name))
+	results, err := callABI(client, ensABI, registry, "resolver", node)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to look up the resolver of %s: %v", name, err)
+	}
+	resolver := results[0].(common.Address)
+	if resolver == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%s has no resolver set", name)
+	}
+	results, err = callABI(client, ensABI, resolver, "addr", node)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to resolve %s: %v", name, err)
+	}
+	address := results[0].(common.Address)
+	if address == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("%s resolves to the zero address", name)
+	}
+	return address, nil
+}
+
+// resolveAddressFlags replaces ENS names given for -receiver and -tokenContract, address book
+// names given for -receiver and token list symbols given for -tokenContract with their addresses
+func resolveAddressFlags(client *ethclient.Client, chainID *big.Int) error {
+	if err := resolveAddressBook(chainID); err != nil {
+		return err
+	}
+	if err := resolveTokenList(chainID); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{
+		{"receiver", receiverFlag},
+		{"token contract", tokenContract},
+	} {
+		if !isENSName(*f.value) {
+			continue
+		}
+		address, err := resolveENS(client, *f.value)
+		if err != nil {
+			return err
+		}
+		infof("Resolved %s %s to %s", f.name, *f.value, address.Hex())
+		*f.value = address.Hex()
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// erc1155ABIJSON is the ERC-1155 multi token ABI
+//
+//go:embed erc1155.abi.json
+var erc1155ABIJSON string
+
+var erc1155ABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(erc1155ABIJSON))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// erc1155TransferData checks the sender's balance of every id and packs safeTransferFrom, or
+// safeBatchTransferFrom when several ids are given
+func erc1155TransferData(client *ethclient.Client, contract, from, to common.Address, ids, amounts string, nf numberFormat) ([]byte, error) {
+	tokenIDs, err := parseBigList(ids)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token id: %v", err)
+	}
+	values, err := parseBigList(amounts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount: %v", err)
+	}
+	if len(tokenIDs) == 0 {
+		return nil, errors.New("no token ids given")
+	}
+	if len(values) != len(tokenIDs) {
+		return nil, fmt.Errorf("got %d token ids but %d amounts", len(tokenIDs), len(values))
+	}
+
+	infof("Token contract: %s (ERC-1155)", contract.Hex())
+	for i, id := range tokenIDs {
+		data, err := erc1155ABI.Pack("balanceOf", from, id)
+		if err != nil {
+			return nil, err
+		}
+		output, err := client.CallContract(opCtx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("balanceOf() failed: %s", describeCallError(err))
+		}
+		results, err := erc1155ABI.Unpack("balanceOf", output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode balanceOf(): %v", err)
+		}
+		balance := results[0].(*big.Int)
+		infof("Token id %s: transfer %s of %s", id, nf.format(values[i].String()), nf.format(balance.String()))
+		if balance.Cmp(values[i]) < 0 {
+			return nil, fmt.Errorf("insufficient balance of token id %s: have %s, want %s", id, balance, values[i])
+		}
+	}
+
+	if len(tokenIDs) == 1 {
+		return erc1155ABI.Pack("safeTransferFrom", from, to, tokenIDs[0], values[0], []byte{})
+	}
+	return erc1155ABI.Pack("safeBatchTransferFrom", from, to, tokenIDs, values, []byte{})
+}
+
+// parseBigList parses a comma-separated list of decimal or 0x-prefixed hex integers
+func parseBigList(list string) ([]*big.Int, error) {
+	var values []*big.Int
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		value, ok := new(big.Int).SetString(item, 0)
+		if !ok || value.Sign() < 0 {
+			return nil, fmt.Errorf("%q is not a non-negative integer", item)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// erc20ABIJSON is the canonical ERC-20 ABI, used unless -tokenABI is given
+//
+//go:embed erc20.abi.json
+var erc20ABIJSON string
+
+// maxTokenDecimals is the most decimals a token can have while one whole token still fits in a
+// uint256
+const maxTokenDecimals = 77
+
+// verifiedTokens holds the addresses of the token contracts verify found sound
+var verifiedTokens sync.Map
+
+// erc20Token is an ERC-20 contract called through its ABI
+type erc20Token struct {
+	client  *ethclient.Client
+	address common.Address
+	abi     abi.ABI
+}
+
+// loadToken binds the token contract at address. abiSpec is empty for the embedded ERC-20 ABI,
+// inline ABI JSON, or the path of a file containing it
+func loadToken(client *ethclient.Client, address, abiSpec string) (*erc20Token, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid token contract address %q", address)
+	}
+	if abiSpec == "" {
+		abiSpec = erc20ABIJSON
+	}
+	parsed, err := loadABI(abiSpec)
+	if err != nil {
+		return nil, err
+	}
+	for _, method := range []string{"transfer", "balanceOf", "decimals"} {
+		if _, ok := parsed.Methods[method]; !ok && (method != "decimals" || *decimalsFlag < 0) {
+			return nil, fmt.Errorf("token ABI has no %s method", method)
+		}
+	}
+	return &erc20Token{client: client, address: common.HexToAddress(address), abi: parsed}, nil
+}
+
+// loadABI parses inline ABI JSON, or the file it names
+func loadABI(spec string) (abi.ABI, error) {
+	abiJSON := strings.TrimSpace(spec)
+	if !strings.HasPrefix(abiJSON, "[") {
+		data, err := os.ReadFile(abiJSON)
+		if err != nil {
+			return abi.ABI{}, err
+		}
+		abiJSON = string(data)
+	}
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("failed to parse ABI: %v", err)
+	}
+	return parsed, nil
+}
+
+// call runs a read-only contract method and returns its unpacked outputs
+func (t *erc20Token) call(method string, args ...interface{}) ([]interface{}, error) {
+	data, err := t.abi.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	output, err := t.client.CallContract(opCtx, ethereum.CallMsg{To: &t.address, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s() failed: %s", method, describeCallError(err))
+	}
+	results, err := t.abi.Unpack(method, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s(): %v", method, err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%s() returned nothing", method)
+	}
+	return results, nil
+}
+
+// decimals returns -decimals for the -tokenContract token, or else the token's decimals(). Its
+// result is read as a full 32-byte word, so that a value past uint8 is refused instead of being
+// cut to its last byte
+func (t *erc20Token) decimals() (int, error) {
+	if *decimalsFlag >= 0 && common.IsHexAddress(*tokenContract) && common.HexToAddress(*tokenContract) == t.address {
+		return *decimalsFlag, nil
+	}
+	listed, fromList := listedTokens[t.address]
+	output, err := t.rawCall("decimals")
+	if err != nil && fromList {
+		debugf("Using the %d decimals of the token list, decimals() failed: %v", listed.Decimals, err)
+		return listed.Decimals, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%v; pass -decimals if the token does not implement it", err)
+	}
+	if len(output) < 32 {
+		return 0, fmt.Errorf("decimals() of %s returned %d bytes instead of a 32-byte word; pass -decimals", t.address.Hex(), len(output))
+	}
+	decimals := new(big.Int).SetBytes(output[:32])
+	if decimals.Cmp(big.NewInt(maxTokenDecimals)) > 0 {
+		return 0, fmt.Errorf("decimals() of %s returned %s, which cannot be right; pass -decimals, or -amountRaw to give the amount in base units", t.address.Hex(), decimals)
+	}
+	// a list that disagrees with the token may name another token, or scale the amount wrongly
+	if fromList && int(decimals.Int64()) != listed.Decimals {
+		return 0, fmt.Errorf("decimals() of %s returned %s, but the token list gives %s %d decimals; pass -decimals if the token is right", t.address.Hex(), decimals, listed.Symbol, listed.Decimals)
+	}
+	return int(decimals.Int64()), nil
+}
+
+// rawCall runs a read-only call of a metadata method without decoding its output, for tokens whose
+// return types differ from the ABI. The selector comes from the token's ABI if it has the method,
+// otherwise from the standard ERC-20 one
+func (t *erc20Token) rawCall(method string) ([]byte, error) {
+	m, ok := t.abi.Methods[method]
+	if !ok {
+		if m, ok = mustLoadABI(erc20ABIJSON).Methods[method]; !ok {
+			return nil, fmt.Errorf("no %s method", method)
+		}
+	}
+	output, err := t.client.CallContract(opCtx, ethereum.CallMsg{To: &t.address, Data: m.ID}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s() failed: %s", method, describeCallError(err))
+	}
+	return output, nil
+}
+
+// metadataString reads the string returned by the name() or symbol() of a token. Some early
+// tokens, such as MKR, return a bytes32 instead, which is read up to its first zero byte. Empty
+// if the call reverts or returns something else
+func (t *erc20Token) metadataString(method string) string {
+	output, err := t.rawCall(method)
+	if err != nil {
+		return ""
+	}
+	if len(output) == 32 {
+		value, _, _ := bytes.Cut(output, []byte{0})
+		if utf8.Valid(value) {
+			return strings.TrimSpace(string(value))
+		}
+		return ""
+	}
+	stringType, _ := abi.NewType("string", "", nil)
+	values, err := abi.Arguments{{Type: stringType}}.Unpack(output)
+	if err != nil {
+		return ""
+	}
+	value, _ := values[0].(string)
+	if !utf8.ValidString(value) {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// verify checks that the token address holds a contract that answers balanceOf(), so that a
+// mistyped address or the wrong network is caught before a transfer is signed. Calls to an
+// address without code succeed with empty output, which would otherwise decode as garbage
+func (t *erc20Token) verify() error {
+	if _, ok := verifiedTokens.Load(t.address); ok {
+		return nil
+	}
+	code, err := t.client.CodeAt(opCtx, t.address, nil)
+	if err != nil {
+		return err
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("%s has no contract code on this chain, check the token address and the network", t.address.Hex())
+	}
+	if _, err := t.balanceOf(common.Address{}); err != nil {
+		return fmt.Errorf("%s does not look like an ERC-20 token: %v", t.address.Hex(), err)
+	}
+	verifiedTokens.Store(t.address, true)
+	return nil
+}
+
+// symbol returns the token symbol, or "tokens" if the contract does not report a readable one
+func (t *erc20Token) symbol() string {
+	if symbol := t.metadataString("symbol"); symbol != "" {
+		return symbol
+	}
+	return "tokens"
+}
+
+// name returns the token name, empty if the contract does not report a readable one
+func (t *erc20Token) name() string {
+	return t.metadataString("name")
+}
+
+// describe names the token for summaries by symbol, name and address, so that a mistaken
+// contract address stands out before anything is sent
+func (t *erc20Token) describe() string {
+	if name := t.name(); name != "" {
+		return fmt.Sprintf("%s (%s, %s)", t.symbol(), name, t.address.Hex())
+	}
+	return fmt.Sprintf("%s (%s)", t.symbol(), t.address.Hex())
+}
+
+func (t *erc20Token) balanceOf(owner common.Address) (*big.Int, error) {
+	results, err := t.call("balanceOf", owner)
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := results[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected balanceOf() type %T", results[0])
+	}
+	return balance, nil
+}
+
+// allowance returns how many base units spender may still move out of owner's balance
+func (t *erc20Token) allowance(owner, spender common.Address) (*big.Int, error) {
+	results, err := t.call("allowance", owner, spender)
+	if err != nil {
+		return nil, err
+	}
+	allowance, ok := results[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected allowance() type %T", results[0])
+	}
+	return allowance, nil
+}
+
+// balanceAmount returns the balance of owner in whole tokens
+func (t *erc20Token) balanceAmount(owner common.Address) (string, error) {
+	decimals, err := t.decimals()
+	if err != nil {
+		return "", err
+	}
+	balance, err := t.balanceOf(owner)
+	if err != nil {
+		return "", err
+	}
+	if balance.Sign() == 0 {
+		return "", fmt.Errorf("%s holds no tokens", owner.Hex())
+	}
+	return formatUnits(balance, decimals), nil
+}
+
+// transferUnits converts amount whole tokens to base units and logs the transfer. With
+// -amountRaw the amount is already in base units and the token's decimals are not asked for.
+// The returned format renders base units in the same way
+func (t *erc20Token) transferUnits(amount string, nf numberFormat) (*big.Int, func(*big.Int) string, error) {
+	if err := t.verify(); err != nil {
+		return nil, nil, err
+	}
+	symbol := t.symbol()
+	if *amountRawFlag != "" {
+		units, err := parseRawAmount(*amountRawFlag)
+		if err != nil {
+			return nil, nil, err
+		}
+		infof("Token contract: %s (%s)", t.address.Hex(), symbol)
+		infof("Transfer amount: %s base units", nf.format(units.String()))
+		return units, func(v *big.Int) string { return v.String() + " base units of " + symbol }, nil
+	}
+	decimals, err := t.decimals()
+	if err != nil {
+		return nil, nil, err
+	}
+	units, err := parseUnits(amount, decimals)
+	if err != nil {
+		return nil, nil, err
+	}
+	infof("Token contract: %s (%s, %d decimals)", t.address.Hex(), symbol, decimals)
+	infof("Transfer amount: %s %s (equivalent to %s base units)", nf.format(amount), symbol, nf.format(units.String()))
+	return units, func(v *big.Int) string { return formatUnits(v, decimals) + " " + symbol }, nil
+}
+
+// transferData checks the sender's balance and the amount the receiver would get, and packs the
+// transfer call for amount whole tokens
+func (t *erc20Token) transferData(from, to common.Address, amount string, nf numberFormat) ([]byte, error) {
+	units, format, err := t.transferUnits(amount, nf)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := t.balanceOf(from)
+	if err != nil {
+		return nil, err
+	}
+	infof("Token balance: %s base units", nf.format(balance.String()))
+	if balance.Cmp(units) < 0 {
+		return nil, fmt.Errorf("insufficient balance: need another %s (have %s, want %s base units)", format(new(big.Int).Sub(units, balance)), balance, units)
+	}
+	t.checkTransferFee(from, to, units, format)
+	return t.abi.Pack("transfer", to, units)
+}
+
+// transferFromData checks that spender may move amount whole tokens out of owner's balance and
+// packs the transferFrom call
+func (t *erc20Token) transferFromData(owner, spender, to common.Address, amount string, nf numberFormat) ([]byte, error) {
+	units, format, err := t.transferUnits(amount, nf)
+	if err != nil {
+		return nil, err
+	}
+	infof("Token owner: %s", owner.Hex())
+
+	allowance, err := t.allowance(owner, spender)
+	if err != nil {
+		return nil, err
+	}
+	infof("Allowance: %s base units", nf.format(allowance.String()))
+	if allowance.Cmp(units) < 0 {
+		return nil, fmt.Errorf("%s may only spend %s of %s's balance, want %s base units; the owner has to approve it first", spender.Hex(), format(allowance), owner.Hex(), units)
+	}
+	balance, err := t.balanceOf(owner)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Cmp(units) < 0 {
+		return nil, fmt.Errorf("insufficient balance of %s: need another %s (have %s, want %s base units)", owner.Hex(), format(new(big.Int).Sub(units, balance)), balance, units)
+	}
+	return t.abi.Pack("transferFrom", owner, to, units)
+}
+
+// formatAmount renders a float amount flag as a plain decimal string
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+// amountUnits lists the units of -unit, also accepted as a suffix of -amount
+var amountUnits = []string{"wei", "gwei", "ether"}
+
+// amountWithUnit matches an -amount with a unit suffix such as 1500gwei
+var amountWithUnit = regexp.MustCompile(`^(.*?)\s*(wei|gwei|ether)$`)
+
+// applyAmount checks -amount and carries the deprecated -tokenValue over to it. A float64
+// cannot hold every decimal, 0.1 of an 18-decimal token included, so -amount is kept as typed.
+// A unit suffix of -amount is moved to -unit
+func applyAmount() error {
+	if *tokenValueFlag != 0 {
+		if *amountFlag != "" {
+			return errors.New("-amount and -tokenValue are mutually exclusive")
+		}
+		*amountFlag = formatAmount(*tokenValueFlag)
+		warnf("-tokenValue is deprecated as floating point loses precision, use -amount %s", *amountFlag)
+	}
+	if m := amountWithUnit.FindStringSubmatch(strings.ToLower(*amountFlag)); m != nil {
+		if *unitFlag != "" && *unitFlag != m[2] {
+			return fmt.Errorf("-amount %s contradicts -unit %s", *amountFlag, *unitFlag)
+		}
+		*amountFlag, *unitFlag = m[1], m[2]
+	}
+	if *amountFlag != "" && !decimalAmount.MatchString(*amountFlag) {
+		return fmt.Errorf("-amount %q is not a decimal amount such as 0.1", *amountFlag)
+	}
+	if *unitFlag != "" && !slices.Contains(amountUnits, *unitFlag) {
+		return fmt.Errorf("invalid -unit %q, expected one of %s", *unitFlag, strings.Join(amountUnits, ", "))
+	}
+	if *unitFlag != "" && *tokenContract != "" {
+		return errors.New("-unit only applies to native coin amounts, token amounts are in whole tokens")
+	}
+	if *decimalsFlag != -1 {
+		if *decimalsFlag < 0 || *decimalsFlag > maxTokenDecimals {
+			return fmt.Errorf("-decimals %d is outside 0 to %d", *decimalsFlag, maxTokenDecimals)
+		}
+		if *tokenContract == "" {
+			return errors.New("-decimals only applies to the ERC-20 token of -tokenContract")
+		}
+	}
+	if *amountRawFlag != "" {
+		if *amountFlag != "" {
+			return errors.New("-amountRaw and -amount are mutually exclusive")
+		}
+		if *tokenContract == "" || *tokenIDFlag != "" || *tokenIDsFlag != "" {
+			return errors.New("-amountRaw only applies to ERC-20 transfers, use -unit wei for native coin amounts")
+		}
+		if _, err := parseRawAmount(*amountRawFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRawAmount parses -amountRaw, a uint256 number of base units
+func parseRawAmount(amount string) (*big.Int, error) {
+	units, ok := new(big.Int).SetString(amount, 10)
+	if !ok || units.Sign() <= 0 || units.BitLen() > 256 {
+		return nil, fmt.Errorf("-amountRaw %q is not a positive integer number of base units", amount)
+	}
+	return units, nil
+}
+
+// unitDecimals returns the number of decimals of an -amount in -unit of the native coin of chain
+func unitDecimals(chain network) int {
+	switch *unitFlag {
+	case "wei":
+		return 0
+	case "gwei":
+		return 9
+	}
+	return chain.decimals
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// estimateOptions holds the flags of the estimate subcommand
+type estimateOptions struct {
+	from string
+}
+
+func newEstimateFlagSet(opts *estimateOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("estimate", flag.ExitOnError)
+	fs.StringVar(&opts.from, "from", "", "Sender address the gas limit is estimated for (default: the zero address)")
+	addRootFlags(fs, "receiver", "amount", "amountRaw", "unit", "tokenValue", "data", "tokenContract", "token", "tokenList", "tokenABI", "errorABI", "decimals")
+	addRootFlags(fs, "maxFeePerGas", "maxPriorityFeePerGas", "priority", "feeBlocks", "feePercentile", "feeHeadroom", "feeSource", "txType", "gasLimit", "gasMargin", "gasFloor", "gasCeiling")
+	addRootFlags(fs, readFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s estimate [-receiver 0x... -amount 0.1 [-tokenContract 0x...]] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runEstimate implements the "estimate" subcommand: the fees of every priority and, given a
+// receiver, the gas and cost of the transfer, without any key
+func runEstimate(args []string) {
+	var opts estimateOptions
+	fs := newEstimateFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	ctx := opCtx
+	chain := lookupChain(chainID)
+
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get header: %v", err)
+	}
+	legacy, err := legacyTx(header)
+	if err != nil {
+		exitf(exitInvalid, "Invalid transaction type: %v", err)
+	}
+	baseFee := header.BaseFee
+	if legacy {
+		baseFee = nil
+		infof("Legacy transactions pay a single gas price")
+	} else {
+		infof("Base fee: %s gwei", nf.format(formatUnits(baseFee, 9)))
+	}
+
+	// -maxPriorityFeePerGas and -maxFeePerGas fix the fees of every priority alike
+	tip, err := parseGwei(*maxTipFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -maxPriorityFeePerGas: %v", err)
+	}
+	feeCap, err := parseGwei(*maxFeeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -maxFeePerGas: %v", err)
+	}
+	if err := checkFeeSource(); err != nil {
+		exitf(exitInvalid, "Invalid -feeSource: %v", err)
+	}
+	for _, preset := range feePresets {
+		presetTip, presetCap, err := presetFees(ctx, client, baseFee, legacy, preset, tip, feeCap)
+		if err != nil {
+			exitf(exitRPC, "Failed to determine fees: %v", err)
+		}
+		if legacy {
+			// the node's gas price does not depend on the priority
+			resultf([]interface{}{"gasPrice", presetCap.String()}, "Gas price %s gwei", nf.format(formatUnits(presetCap, 9)))
+			break
+		}
+		attrs := []interface{}{"priority", preset.name, "maxPriorityFeePerGas", presetTip.String(), "maxFeePerGas", presetCap.String()}
+		inclusion := fmt.Sprintf("%d block(s)", preset.blocks)
+		if chain.block > 0 {
+			inclusion += fmt.Sprintf(", about %s", time.Duration(preset.blocks)*chain.block)
+		}
+		resultf(attrs, "%-8s tip %s gwei, max fee %s gwei (usually within %s)", preset.name, nf.format(formatUnits(presetTip, 9)), nf.format(formatUnits(presetCap, 9)), inclusion)
+	}
+	if *receiverFlag == "" {
+		return
+	}
+
+	// the gas limit of the transfer and its cost at -priority
+	from := common.Address{}
+	if opts.from != "" {
+		if from, err = parseAddress(opts.from); err != nil {
+			exitf(exitInvalid, "Invalid -from: %v", err)
+		}
+	}
+	to, err := parseAddress(*receiverFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid receiver: %v", err)
+	}
+	value, data := new(big.Int), []byte(nil)
+	if *tokenContract != "" {
+		token, err := loadToken(client, *tokenContract, *tokenABIFlag)
+		if err != nil {
+			fatalf("Failed to load token contract: %v", err)
+		}
+		amount := new(big.Int)
+		if *amountRawFlag != "" {
+			amount, _ = parseRawAmount(*amountRawFlag)
+		} else if *amountFlag != "" {
+			decimals, err := token.decimals()
+			if err != nil {
+				exitf(exitRPC, "Failed to get token decimals: %v", err)
+			}
+			if amount, err = parseUnits(*amountFlag, decimals); err != nil {
+				exitf(exitInvalid, "Invalid -amount: %v", err)
+			}
+		}
+		if data, err = token.abi.Pack("transfer", to, amount); err != nil {
+			fatalf("Failed to encode transfer: %v", err)
+		}
+		to = token.address
+	} else {
+		if *amountFlag != "" {
+			if value, err = parseUnits(*amountFlag, unitDecimals(chain)); err != nil {
+				exitf(exitInvalid, "Invalid -amount: %v", err)
+			}
+		}
+		if data, err = callData(); err != nil {
+			exitf(exitInvalid, "Invalid -data: %v", err)
+		}
+	}
+	gas := *gasLimitFlag
+	if gas == 0 {
+		if gas, err = quoteGas(client, ethereum.CallMsg{From: from, To: &to, Value: value, Data: data}); err != nil {
+			if *tokenContract != "" && opts.from == "" {
+				fatalf("Failed to estimate gas: %s, pass -from with an address holding the tokens", describeCallError(err))
+			}
+			fatalf("Failed to estimate gas: %s", describeCallError(err))
+		}
+		if gas, err = withGasMargin(gas); err != nil {
+			fatalf("Invalid gas limit: %v", err)
+		}
+	}
+	tip, feeCap, err = suggestFees(ctx, client, baseFee)
+	if err != nil {
+		exitf(exitRPC, "Failed to determine fees: %v", err)
+	}
+	// the fee actually paid per gas is capped by maxFeePerGas
+	price := feeCap
+	if baseFee != nil {
+		if price = new(big.Int).Add(baseFee, tip); price.Cmp(feeCap) > 0 {
+			price = feeCap
+		}
+	}
+	expected := new(big.Int).Mul(price, new(big.Int).SetUint64(gas))
+	worst := new(big.Int).Mul(feeCap, new(big.Int).SetUint64(gas))
+	attrs := []interface{}{"gas", gas, "expectedFee", expected.String(), "maxFee", worst.String()}
+	// OP-stack chains charge for the L1 data on top, priced by the size of the transaction
+	l1Fee, err := l1DataFee(client, sender.MakeTx(legacy, &types.DynamicFeeTx{
+		ChainID:   chainID,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	}))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if l1Fee != nil {
+		expected.Add(expected, l1Fee)
+		worst.Add(worst, l1Fee)
+		attrs = append(attrs, "l1Fee", l1Fee.String())
+		infof("L1 data fee: %s %s", nf.format(formatUnits(l1Fee, chain.decimals)), chain.symbol)
+	}
+	if value.Sign() > 0 {
+		// the worst case the sender needs to hold, to quote a transfer in full
+		total := new(big.Int).Add(worst, value)
+		attrs = append(attrs, "value", value.String(), "maxTotal", total.String())
+		infof("Total cost: at most %s %s including the transferred value", nf.format(formatUnits(total, chain.decimals)), chain.symbol)
+	}
+	resultf(attrs, "Gas limit %s, fee %s %s at the current base fee, at most %s %s (priority %s)",
+		nf.format(fmt.Sprint(gas)), nf.format(formatUnits(expected, chain.decimals)), chain.symbol, nf.format(formatUnits(worst, chain.decimals)), chain.symbol, *priorityFlag)
+}
+
+// presetFees suggests the tip and fee cap of preset, unless tip and feeCap fix them
+func presetFees(ctx context.Context, client *ethclient.Client, baseFee *big.Int, legacy bool, preset feePreset, tip, feeCap *big.Int) (*big.Int, *big.Int, error) {
+	opts := sender.FeeOptions{
+		Tip:        tip,
+		FeeCap:     feeCap,
+		Blocks:     *feeBlocksFlag,
+		Percentile: preset.percentile,
+		Headroom:   preset.headroom,
+	}
+	if !legacy {
+		opts = withTipFallback(client, preset, withFeeSource(ctx, client, preset, opts))
+	}
+	return sender.SuggestFees(ctx, client, baseFee, opts)
+}
+
+// quoteGas estimates the gas of msg. Should the sender not hold the value, the estimate is
+// repeated with its balance overridden, as a quote does not depend on who asks for it
+func quoteGas(client *ethclient.Client, msg ethereum.CallMsg) (uint64, error) {
+	gas, err := client.EstimateGas(opCtx, msg)
+	if err == nil || msg.Value.Sign() == 0 || !strings.Contains(err.Error(), "insufficient funds") {
+		return gas, err
+	}
+	call := map[string]interface{}{"from": msg.From, "to": msg.To, "value": (*hexutil.Big)(msg.Value)}
+	if len(msg.Data) > 0 {
+		call["data"] = hexutil.Bytes(msg.Data)
+	}
+	balance := new(big.Int).Add(msg.Value, new(big.Int).Lsh(big.NewInt(1), 128))
+	overrides := map[common.Address]map[string]interface{}{msg.From: {"balance": (*hexutil.Big)(balance)}}
+	var quoted hexutil.Uint64
+	if overrideErr := client.Client().CallContext(opCtx, &quoted, "eth_estimateGas", call, "latest", overrides); overrideErr != nil {
+		debugf("Failed to estimate gas with the balance overridden: %v", overrideErr)
+		return 0, err
+	}
+	infof("%s does not hold the amount, the gas limit is estimated as if it did", msg.From.Hex())
+	return uint64(quoted), nil
+}
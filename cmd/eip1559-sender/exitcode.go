@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Exit codes of the sending commands, for scripts that branch on the kind of failure. They are
+// listed in the README and must not be renumbered
+const (
+	exitFailure  = 1 // any other error, such as a declined confirmation or a failed batch row
+	exitInvalid  = 2 // invalid or missing flags and parameters, as for flag parse errors
+	exitRPC      = 3 // the RPC, bundler or paymaster could not be reached or failed a request
+	exitFunds    = 4 // the sender cannot pay for the transfer and its gas
+	exitRejected = 5 // the node, relay or bundler refused the transaction
+	exitReverted = 6 // the transaction was mined, but reverted, or reverted when simulated or traced
+	exitTimeout  = 7 // -deadline passed before the command was done
+	exitExpired  = 8 // -validFor passed and the transaction was cancelled
+)
+
+// invalidInput marks an error in the flags or input given, which exits with exitInvalid
+type invalidInput struct{ error }
+
+func (e invalidInput) Unwrap() error { return e.error }
+
+// invalidf returns an error marked as invalid input
+func invalidf(format string, args ...interface{}) error {
+	return invalidInput{fmt.Errorf(format, args...)}
+}
+
+// keyExitCode tells apart a key source given wrong, such as an unset -privateKeyEnv variable,
+// from one that failed to load, such as an unreachable KMS
+func keyExitCode(err error) int {
+	var invalid invalidInput
+	if errors.As(err, &invalid) {
+		return exitInvalid
+	}
+	return exitFailure
+}
+
+// broadcastExitCode tells apart why a transaction could not be broadcast: the node refusing it
+// for the balance of the sender, refusing it for another reason, or not answering at all
+func broadcastExitCode(err error) int {
+	var rpcErr rpc.Error
+	switch {
+	case strings.Contains(err.Error(), "insufficient funds"):
+		return exitFunds
+	case errors.As(err, &rpcErr):
+		return exitRejected
+	}
+	return exitRPC
+}
+
+// callExitCode tells apart a simulated call that reverted from one that failed for another
+// reason, such as the node not answering
+func callExitCode(err error) int {
+	if len(callRevertData(err)) > 0 || strings.Contains(err.Error(), "execution reverted") {
+		return exitReverted
+	}
+	return exitFailure
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// revertError is the error of a call that reverted with data, as the node returns it
+type revertError struct{}
+
+func (revertError) Error() string          { return "execution reverted" }
+func (revertError) ErrorCode() int         { return 3 }
+func (revertError) ErrorData() interface{} { return "0x08c379a0" }
+
+var _ rpc.DataError = revertError{}
+
+func TestExitCodesDistinct(t *testing.T) {
+	seen := map[int]string{0: "success"}
+	for name, code := range map[string]int{
+		"exitFailure": exitFailure, "exitInvalid": exitInvalid, "exitRPC": exitRPC, "exitFunds": exitFunds,
+		"exitRejected": exitRejected, "exitReverted": exitReverted, "exitTimeout": exitTimeout, "exitExpired": exitExpired,
+	} {
+		if other, ok := seen[code]; ok {
+			t.Errorf("%s and %s share exit code %d", name, other, code)
+		}
+		seen[code] = name
+	}
+}
+
+func TestCallExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{revertError{}, exitReverted},
+		{errors.New("execution reverted: ERC20: transfer amount exceeds balance"), exitReverted},
+		{errors.New("connection refused"), exitFailure},
+		{errors.New("insufficient funds for gas * price + value"), exitFailure},
+	} {
+		if got := callExitCode(tc.err); got != tc.want {
+			t.Errorf("callExitCode(%q) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestBroadcastExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{errors.New("insufficient funds for gas * price + value"), exitFunds},
+		{revertError{}, exitRejected},
+		{errors.New("dial tcp: connection refused"), exitRPC},
+	} {
+		if got := broadcastExitCode(tc.err); got != tc.want {
+			t.Errorf("broadcastExitCode(%q) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestKeyExitCode(t *testing.T) {
+	defer func(name string) { *privateKeyEnv = name }(*privateKeyEnv)
+	*privateKeyEnv = "EIP1559_SENDER_TEST_UNSET_KEY"
+	_, err := loadSigner()
+	if err == nil {
+		t.Fatal("loadSigner with an unset -privateKeyEnv variable succeeded")
+	}
+	if code := keyExitCode(err); code != exitInvalid {
+		t.Errorf("keyExitCode(%v) = %d, want %d", err, code, exitInvalid)
+	}
+	if code := keyExitCode(errors.New("KMS unreachable")); code != exitFailure {
+		t.Errorf("keyExitCode of a failing key source = %d, want %d", code, exitFailure)
+	}
+	wrapped := fmt.Errorf("failed to load signing key: %w", invalidf("invalid -from"))
+	if code := keyExitCode(wrapped); code != exitInvalid {
+		t.Errorf("keyExitCode of a wrapped invalid input = %d, want %d", code, exitInvalid)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// statusExpired is the status of a transaction cancelled by -validFor, whose cancel took the nonce
+const statusExpired = "expired"
+
+// waitMined waits for a version of tx, which tracker follows, to be mined, bumping its fees with
+// -bumpAfter. Once -validFor has passed without any, it cancels the nonce and waits for either
+// the transaction or the cancel. cancel is the cancel sent, nil if none was
+func waitMined(client *ethclient.Client, signer sender.Signer, chainID *big.Int, tracker *sender.Tracker, tx *types.Transaction) (receipt *types.Receipt, cancel *types.Transaction, err error) {
+	ctx := opCtx
+	if *validForFlag > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(opCtx, *validForFlag)
+		defer stop()
+	}
+	follow := func(ctx context.Context, tx *types.Transaction) (*types.Receipt, *types.Transaction, error) {
+		if *bumpAfter > 0 {
+			return waitWithBumps(ctx, client, signer, chainID, tracker, tx, *bumpAfter, *bumpPercent, *maxBumps)
+		}
+		receipt, err := tracker.Wait(ctx, 1, pollInterval)
+		return receipt, tx, err
+	}
+	receipt, latest, err := follow(ctx, tx)
+	// only the end of -validFor is handled here, not that of -deadline
+	if err == nil || ctx.Err() == nil || opCtx.Err() != nil {
+		return receipt, nil, err
+	}
+	infof("Not mined within %s, cancelling nonce %d", *validForFlag, tx.Nonce())
+	if cancel, err = cancelNonceOf(client, signer, chainID, tracker, latest); err != nil {
+		return nil, nil, fmt.Errorf("failed to cancel: %v", err)
+	}
+	if cancel == nil {
+		// a version was mined while the cancel was being sent
+		receipt, err = tracker.Wait(opCtx, 1, pollInterval)
+		return receipt, nil, err
+	}
+	receipt, _, err = follow(opCtx, cancel)
+	return receipt, cancel, err
+}
+
+// cancelNonceOf replaces tx, the latest version tracker follows, with a 0-value self-transfer at
+// the same nonce. It outbids tx by -bumpPercent, so miners take the cancel unless a version of tx
+// makes it into a block first. It returns nil if one already has
+func cancelNonceOf(client *ethclient.Client, signer sender.Signer, chainID *big.Int, tracker *sender.Tracker, tx *types.Transaction) (*types.Transaction, error) {
+	ctx := opCtx
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	tip := bumpFee(tx.GasTipCap(), *bumpPercent)
+	feeCap := bumpFee(tx.GasFeeCap(), *bumpPercent)
+	// keep up with a base fee that rose while the transaction was pending
+	if header.BaseFee != nil {
+		if minFeeCap := new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), tip); feeCap.Cmp(minFeeCap) < 0 {
+			feeCap = minFeeCap
+		}
+	}
+	from := signer.Address()
+	unsigned := sender.MakeTx(tx.Type() == types.LegacyTxType, &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     tx.Nonce(),
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       21000,
+		To:        &from,
+		Value:     new(big.Int),
+	})
+	if err := checkFeeCap(client, chainID, unsigned); err != nil {
+		return nil, err
+	}
+	cancel, err := sender.SignTx(ctx, signer, unsigned, chainID)
+	if err != nil {
+		return nil, err
+	}
+	if err := sendTransaction(ctx, client, cancel); err != nil {
+		if receipt, _ := tracker.Check(ctx); receipt != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	txSent.Inc()
+	txReplaced.Inc()
+	notifyWebhook(replacedEvent(chainID, from, tx, cancel.Hash()))
+	recordReplaced(tx, cancel.Hash())
+	tracker.Add(cancel.Hash())
+	infof("Cancel sent: maxPriorityFeePerGas %s, maxFeePerGas %s, hash %s", tip, feeCap, cancel.Hash().Hex())
+	return cancel, nil
+}
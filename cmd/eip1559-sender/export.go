@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// unsignedTx is a fully populated transaction awaiting its signature, as exchanged with
+// external signers through -exportUnsigned and "broadcast -unsignedTx"
+type unsignedTx struct {
+	Type                 uint8            `json:"type"`
+	ChainID              uint64           `json:"chainId"`
+	From                 common.Address   `json:"from"`
+	Nonce                uint64           `json:"nonce"`
+	GasPrice             string           `json:"gasPrice,omitempty"`
+	MaxPriorityFeePerGas string           `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerGas         string           `json:"maxFeePerGas,omitempty"`
+	Gas                  uint64           `json:"gas"`
+	To                   *common.Address  `json:"to"`
+	Value                string           `json:"value"`
+	Data                 hexutil.Bytes    `json:"data"`
+	AccessList           types.AccessList `json:"accessList,omitempty"`
+	SigningHash          common.Hash      `json:"signingHash"`
+}
+
+// addressSigner stands in for the key of an external signer: it knows the account, but cannot sign
+type addressSigner struct {
+	address common.Address
+}
+
+func (s *addressSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *addressSigner) SignTx(*types.Transaction, *big.Int) (*types.Transaction, error) {
+	return nil, errors.New("-from has no key, export the transaction with -exportUnsigned instead")
+}
+
+// exportUnsigned writes tx, to be signed by from, to path
+func exportUnsigned(path string, chainID *big.Int, from common.Address, tx *types.Transaction) error {
+	if tx.Type() != types.DynamicFeeTxType && tx.Type() != types.LegacyTxType {
+		return errors.New("only EIP-1559 and legacy transactions can be exported")
+	}
+	out := unsignedTx{
+		Type:        tx.Type(),
+		ChainID:     chainID.Uint64(),
+		From:        from,
+		Nonce:       tx.Nonce(),
+		Gas:         tx.Gas(),
+		To:          tx.To(),
+		Value:       tx.Value().String(),
+		Data:        tx.Data(),
+		AccessList:  tx.AccessList(),
+		SigningHash: types.LatestSignerForChainID(chainID).Hash(tx),
+	}
+	if tx.Type() == types.LegacyTxType {
+		out.GasPrice = tx.GasPrice().String()
+	} else {
+		out.MaxPriorityFeePerGas = tx.GasTipCap().String()
+		out.MaxFeePerGas = tx.GasFeeCap().String()
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// importSigned reads a transaction exported with -exportUnsigned and attaches signature, a
+// 65 byte [R || S || V] signature of its signing hash with V either 0/1 or 27/28
+func importSigned(path, signature string) (*types.Transaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var in unsignedTx
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	chainID := new(big.Int).SetUint64(in.ChainID)
+	value, ok := new(big.Int).SetString(in.Value, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid value %q", in.Value)
+	}
+	fees := map[string]*big.Int{}
+	for name, text := range map[string]string{"gasPrice": in.GasPrice, "maxPriorityFeePerGas": in.MaxPriorityFeePerGas, "maxFeePerGas": in.MaxFeePerGas} {
+		if text == "" {
+			continue
+		}
+		if fees[name], ok = new(big.Int).SetString(text, 10); !ok {
+			return nil, fmt.Errorf("invalid %s %q", name, text)
+		}
+	}
+	inner := &types.DynamicFeeTx{
+		ChainID:    chainID,
+		Nonce:      in.Nonce,
+		GasTipCap:  fees["maxPriorityFeePerGas"],
+		GasFeeCap:  fees["maxFeePerGas"],
+		Gas:        in.Gas,
+		To:         in.To,
+		Value:      value,
+		Data:       in.Data,
+		AccessList: in.AccessList,
+	}
+	switch in.Type {
+	case types.LegacyTxType:
+		inner.GasFeeCap = fees["gasPrice"]
+	case types.DynamicFeeTxType:
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", in.Type)
+	}
+	if inner.GasFeeCap == nil || (in.Type == types.DynamicFeeTxType && inner.GasTipCap == nil) {
+		return nil, errors.New("missing fees")
+	}
+	tx := sender.MakeTx(in.Type == types.LegacyTxType, inner)
+
+	// the signing hash guards against fields edited after the export
+	signer := types.LatestSignerForChainID(chainID)
+	if hash := signer.Hash(tx); hash != in.SigningHash {
+		return nil, fmt.Errorf("the fields do not match signingHash %s (they hash to %s)", in.SigningHash.Hex(), hash.Hex())
+	}
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("the signature has %d bytes, expected 65", len(sig))
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	signed, err := tx.WithSignature(signer, sig)
+	if err != nil {
+		return nil, err
+	}
+	if sender, err := types.Sender(signer, signed); err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	} else if sender != in.From {
+		return nil, fmt.Errorf("the signature is from %s, not from %s", sender.Hex(), in.From.Hex())
+	}
+	return signed, nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// failoverTimeout bounds a single attempt against one of several endpoints
+	failoverTimeout = 15 * time.Second
+	// failoverCooldown is how long an endpoint that failed is only tried after the others
+	failoverCooldown = 30 * time.Second
+	// maxHeadLag is how many blocks an endpoint may trail the others and still be preferred
+	maxHeadLag = 2
+)
+
+// rpcEndpoint is one of the URLs of a comma-separated -rpcURL
+type rpcEndpoint struct {
+	raw      string
+	url      *url.URL
+	head     uint64
+	latency  time.Duration
+	failedAt time.Time
+}
+
+// failoverTransport sends each RPC request to the healthiest of several endpoints, moving on to
+// the next one on errors, timeouts, 429s and 5xx responses
+type failoverTransport struct {
+	next         http.RoundTripper
+	broadcastAll bool
+
+	mu        sync.Mutex
+	endpoints []*rpcEndpoint // in order of preference
+}
+
+// newFailoverTransport probes the endpoints of urls through next, requiring them to serve the
+// same chain, and orders them by head block and latency
+func newFailoverTransport(ctx context.Context, urls []string, next http.RoundTripper, broadcastAll bool) (*failoverTransport, error) {
+	endpoints := make([]*rpcEndpoint, len(urls))
+	chainIDs := make([]*big.Int, len(urls))
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	for i, raw := range urls {
+		raw = strings.TrimSpace(raw)
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid endpoint %q, a list of RPC URLs takes http(s) URLs only", raw)
+		}
+		endpoints[i] = &rpcEndpoint{raw: raw, url: u}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chainIDs[i], errs[i] = probeEndpoint(ctx, endpoints[i], next)
+		}()
+	}
+	wg.Wait()
+
+	var healthy []*rpcEndpoint
+	var chainID *big.Int
+	var chainEndpoint string
+	for i, ep := range endpoints {
+		if errs[i] != nil {
+			warnf("Skipping RPC endpoint %s: %v", ep.raw, errs[i])
+			continue
+		}
+		if chainID == nil {
+			chainID, chainEndpoint = chainIDs[i], ep.raw
+		} else if chainIDs[i].Cmp(chainID) != 0 {
+			return nil, fmt.Errorf("endpoints serve different chains: %s reports chain ID %s, %s reports %s", chainEndpoint, chainID, ep.raw, chainIDs[i])
+		}
+		healthy = append(healthy, ep)
+	}
+	if len(healthy) == 0 {
+		return nil, errors.New("no RPC endpoint is reachable")
+	}
+
+	var maxHead uint64
+	for _, ep := range healthy {
+		maxHead = max(maxHead, ep.head)
+	}
+	sort.SliceStable(healthy, func(i, j int) bool {
+		iSynced, jSynced := healthy[i].head+maxHeadLag >= maxHead, healthy[j].head+maxHeadLag >= maxHead
+		if iSynced != jSynced {
+			return iSynced
+		}
+		return healthy[i].latency < healthy[j].latency
+	})
+	for _, ep := range healthy {
+		debugf("RPC endpoint %s: block %d, %s", ep.raw, ep.head, ep.latency.Round(time.Millisecond))
+	}
+	return &failoverTransport{next: next, broadcastAll: broadcastAll, endpoints: healthy}, nil
+}
+
+// probeEndpoint measures the head block and latency of ep, returning its chain ID
+func probeEndpoint(ctx context.Context, ep *rpcEndpoint, next http.RoundTripper) (*big.Int, error) {
+	ctx, cancel := context.WithTimeout(ctx, failoverTimeout)
+	defer cancel()
+	conn, err := rpc.DialOptions(ctx, ep.raw, rpc.WithHTTPClient(&http.Client{Transport: next}))
+	if err != nil {
+		return nil, err
+	}
+	client := ethclient.NewClient(conn)
+	defer client.Close()
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	if ep.head, err = client.BlockNumber(ctx); err != nil {
+		return nil, err
+	}
+	ep.latency = time.Since(start)
+	return chainID, nil
+}
+
+// primary returns the URL of the preferred endpoint
+func (t *failoverTransport) primary() string {
+	return t.endpoints[0].raw
+}
+
+// order returns the endpoints to try, those that failed recently last
+func (t *failoverTransport) order() []*rpcEndpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ready, cooling []*rpcEndpoint
+	for _, ep := range t.endpoints {
+		if time.Since(ep.failedAt) < failoverCooldown {
+			cooling = append(cooling, ep)
+		} else {
+			ready = append(ready, ep)
+		}
+	}
+	return append(ready, cooling...)
+}
+
+// markFailed moves ep behind the other endpoints for a while
+func (t *failoverTransport) markFailed(ep *rpcEndpoint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ep.failedAt = time.Now()
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	if t.broadcastAll && rpcMethod(body) == "eth_sendRawTransaction" {
+		return t.broadcast(req, body)
+	}
+
+	var resp *http.Response
+	var err error
+	for _, ep := range t.order() {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		resp, err = t.attempt(req, body, ep)
+		if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		t.markFailed(ep)
+		reason := fmt.Sprint(err)
+		if err == nil {
+			reason = resp.Status
+		}
+		warnf("RPC endpoint %s failed (%s), failing over", ep.raw, reason)
+		if req.Context().Err() != nil {
+			break
+		}
+	}
+	return resp, err
+}
+
+// attempt sends the request to ep, giving up after failoverTimeout
+func (t *failoverTransport) attempt(req *http.Request, body []byte, ep *rpcEndpoint) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), failoverTimeout)
+	out := req.Clone(ctx)
+	out.URL, out.Host = ep.url, ep.url.Host
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the timeout covers reading the response as well
+	resp.Body = cancelOnClose{resp.Body, cancel}
+	return resp, nil
+}
+
+// broadcast sends a transaction to every endpoint at once, answering with the first acceptance
+func (t *failoverTransport) broadcast(req *http.Request, body []byte) (*http.Response, error) {
+	type answer struct {
+		resp *http.Response
+		data []byte
+		err  error
+	}
+	endpoints := t.order()
+	answers := make([]answer, len(endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := t.attempt(req, body, ep)
+			if err != nil {
+				answers[i].err = err
+				return
+			}
+			defer resp.Body.Close()
+			answers[i].resp = resp
+			answers[i].data, answers[i].err = io.ReadAll(resp.Body)
+		}()
+	}
+	wg.Wait()
+
+	// prefer an endpoint that accepted the transaction over ones reporting it as already known
+	best := -1
+	var failure error
+	for i, a := range answers {
+		if a.err == nil && a.resp.StatusCode != http.StatusOK {
+			a.err = errors.New(a.resp.Status)
+		}
+		if a.err != nil {
+			debugf("Broadcast to %s failed: %v", endpoints[i].raw, a.err)
+			failure = a.err
+			continue
+		}
+		var reply struct {
+			Error json.RawMessage `json:"error"`
+		}
+		if json.Unmarshal(a.data, &reply) == nil && reply.Error == nil {
+			best = i
+			break
+		}
+		if best < 0 {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, fmt.Errorf("broadcast to all %d endpoints failed: %v", len(endpoints), failure)
+	}
+	debugf("Broadcast to %d endpoints, answering with %s", len(endpoints), endpoints[best].raw)
+	resp := answers[best].resp
+	resp.Body = io.NopCloser(bytes.NewReader(answers[best].data))
+	return resp, nil
+}
+
+// rpcMethod returns the method of a single JSON-RPC request, empty for batches
+func rpcMethod(body []byte) string {
+	var call struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(body, &call) != nil {
+		return ""
+	}
+	return call.Method
+}
+
+// cancelOnClose releases the context of a request once its response is read
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// feeHistoryPage is the most blocks asked for in one eth_feeHistory call, the limit of geth and
+// of most providers. Nodes that allow fewer return fewer, and the rest is asked for in turn
+const feeHistoryPage = 1024
+
+// blockTimeBatch is how many block headers are asked for in one batch for their timestamps
+const blockTimeBatch = 100
+
+// feeHistoryOptions holds the flags of the fees history subcommand
+type feeHistoryOptions struct {
+	blocks      uint64
+	toBlock     string
+	percentiles string
+	out         string
+	file        string
+}
+
+// feeHistoryRow is the fee market of one block
+type feeHistoryRow struct {
+	block        uint64
+	time         time.Time // zero if the node did not tell
+	baseFee      *big.Int
+	gasUsedRatio float64
+	tips         []*big.Int // the tip paid at each percentile of gas, zero in empty blocks
+}
+
+func newFeeHistoryFlagSet(opts *feeHistoryOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("fees history", flag.ExitOnError)
+	fs.Uint64Var(&opts.blocks, "blocks", 1000, "Number of blocks to analyse")
+	fs.StringVar(&opts.toBlock, "toBlock", "latest", "Newest block to analyse")
+	fs.StringVar(&opts.percentiles, "percentiles", "10,25,50,75,90", "Comma-separated percentiles of the tips paid in each block, weighted by gas")
+	fs.StringVar(&opts.out, "out", "csv", "Output format: csv, or json for one JSON object per block")
+	fs.StringVar(&opts.file, "file", "", "File to write the blocks to, which also prints a summary (default: stdout)")
+	addRootFlags(fs, readFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s fees history -rpcURL https://... [-blocks 1000] [-out csv|json] [-file fees.csv] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nLists the base fee, the gas used and the tips paid by percentile of every block of a range, from eth_feeHistory. CSV fees are in gwei, JSON fees in Wei.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runFeeHistory implements the "fees history" subcommand
+func runFeeHistory(args []string) {
+	var opts feeHistoryOptions
+	fs := newFeeHistoryFlagSet(&opts)
+	fs.Parse(args)
+	if opts.file == "" && !*verboseFlag {
+		// the rows go to stdout, which progress lines would break up
+		*quietFlag = true
+	}
+	configure(fs)
+
+	if *rpcURLFlag == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	if opts.out != "csv" && opts.out != "json" {
+		exitf(exitInvalid, "Invalid -out %q, expected csv or json", opts.out)
+	}
+	if opts.blocks == 0 {
+		exitf(exitInvalid, "Invalid -blocks: must be at least 1")
+	}
+	percentiles, err := parsePercentiles(opts.percentiles)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -percentiles: %v", err)
+	}
+	var newest *big.Int
+	if opts.toBlock != "latest" {
+		n, err := strconv.ParseUint(opts.toBlock, 10, 64)
+		if err != nil {
+			exitf(exitInvalid, "Invalid -toBlock %q, expected a block number or latest", opts.toBlock)
+		}
+		newest = new(big.Int).SetUint64(n)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, _ := dialRPC()
+
+	rows, err := fetchFeeHistory(client, newest, opts.blocks, percentiles)
+	if err != nil {
+		exitf(exitRPC, "Failed to get the fee history: %v", err)
+	}
+	if len(rows) == 0 {
+		exitf(exitRPC, "The node returned no fee history")
+	}
+	if err := fillBlockTimes(client, rows); err != nil {
+		warnf("Failed to get the block times, leaving them out: %v", err)
+		for i := range rows {
+			rows[i].time = time.Time{}
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if opts.file != "" {
+		f, err := os.Create(opts.file)
+		if err != nil {
+			fatalf("Failed to create %s: %v", opts.file, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if opts.out == "csv" {
+		err = writeFeeHistoryCSV(w, rows, percentiles)
+	} else {
+		err = writeFeeHistoryJSON(w, rows, percentiles)
+	}
+	if err != nil {
+		fatalf("Failed to write the fee history: %v", err)
+	}
+	if opts.file != "" {
+		// on stdout the summary would mix with the rows
+		infof("Wrote %d blocks to %s", len(rows), opts.file)
+		summarizeFeeHistory(rows, percentiles, nf)
+	}
+}
+
+// parsePercentiles parses a comma-separated list of percentiles, which eth_feeHistory takes
+// in ascending order
+func parsePercentiles(list string) ([]float64, error) {
+	var percentiles []float64
+	for _, field := range strings.Split(list, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("%q is not a percentile between 0 and 100", field)
+		}
+		if len(percentiles) > 0 && p <= percentiles[len(percentiles)-1] {
+			return nil, fmt.Errorf("percentiles must be in ascending order")
+		}
+		percentiles = append(percentiles, p)
+	}
+	return percentiles, nil
+}
+
+// fetchFeeHistory returns the fee market of the count blocks up to newest, nil for the latest,
+// oldest first. The range is asked for a page at a time, newest first, and may end early at
+// the genesis block
+func fetchFeeHistory(client *ethclient.Client, newest *big.Int, count uint64, percentiles []float64) ([]feeHistoryRow, error) {
+	var pages [][]feeHistoryRow
+	total := uint64(0)
+	for total < count {
+		history, err := client.FeeHistory(opCtx, min(count-total, feeHistoryPage), newest, percentiles)
+		if err != nil {
+			return nil, err
+		}
+		n := len(history.GasUsedRatio)
+		if n == 0 {
+			break
+		}
+		oldest := history.OldestBlock.Uint64()
+		page := make([]feeHistoryRow, n)
+		for i := range page {
+			page[i] = feeHistoryRow{block: oldest + uint64(i), gasUsedRatio: history.GasUsedRatio[i], baseFee: new(big.Int)}
+			if i < len(history.BaseFee) && history.BaseFee[i] != nil {
+				page[i].baseFee = history.BaseFee[i]
+			}
+			if i < len(history.Reward) {
+				page[i].tips = history.Reward[i]
+			}
+		}
+		pages = append(pages, page)
+		total += uint64(n)
+		debugf("Got the fee history of blocks %d to %d", oldest, oldest+uint64(n)-1)
+		if oldest == 0 {
+			break
+		}
+		newest = new(big.Int).SetUint64(oldest - 1)
+	}
+	var rows []feeHistoryRow
+	for i := len(pages) - 1; i >= 0; i-- {
+		rows = append(rows, pages[i]...)
+	}
+	return rows, nil
+}
+
+// fillBlockTimes sets the time of rows from their headers, which eth_feeHistory leaves out,
+// asking for them in batches
+func fillBlockTimes(client *ethclient.Client, rows []feeHistoryRow) error {
+	for start := 0; start < len(rows); start += blockTimeBatch {
+		end := min(start+blockTimeBatch, len(rows))
+		headers := make([]struct {
+			Time hexutil.Uint64 `json:"timestamp"`
+		}, end-start)
+		batch := make([]rpc.BatchElem, end-start)
+		for i := range batch {
+			batch[i] = rpc.BatchElem{
+				Method: "eth_getBlockByNumber",
+				Args:   []interface{}{hexutil.EncodeUint64(rows[start+i].block), false},
+				Result: &headers[i],
+			}
+		}
+		if err := client.Client().BatchCallContext(opCtx, batch); err != nil {
+			return err
+		}
+		for i, elem := range batch {
+			if elem.Error != nil {
+				return elem.Error
+			}
+			rows[start+i].time = time.Unix(int64(headers[i].Time), 0)
+		}
+	}
+	return nil
+}
+
+// percentileHeader renders a percentile for the names of columns and fields, as "p50"
+func percentileHeader(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// formatBlockTime renders the time of a block in UTC, "" if it is unknown
+func formatBlockTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeFeeHistoryCSV(w io.Writer, rows []feeHistoryRow, percentiles []float64) error {
+	out := csv.NewWriter(w)
+	header := []string{"block", "time", "base_fee_gwei", "gas_used_ratio"}
+	for _, p := range percentiles {
+		header = append(header, "tip_"+percentileHeader(p)+"_gwei")
+	}
+	out.Write(header)
+	for _, row := range rows {
+		record := []string{strconv.FormatUint(row.block, 10), formatBlockTime(row.time), formatUnits(row.baseFee, 9), strconv.FormatFloat(row.gasUsedRatio, 'f', 4, 64)}
+		for i := range percentiles {
+			tip := ""
+			if i < len(row.tips) {
+				tip = formatUnits(row.tips[i], 9)
+			}
+			record = append(record, tip)
+		}
+		out.Write(record)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func writeFeeHistoryJSON(w io.Writer, rows []feeHistoryRow, percentiles []float64) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		tips := make(map[string]string, len(row.tips))
+		for i, tip := range row.tips {
+			if i < len(percentiles) {
+				tips[percentileHeader(percentiles[i])] = tip.String()
+			}
+		}
+		record := struct {
+			Block        uint64            `json:"block"`
+			Time         string            `json:"time,omitempty"`
+			BaseFee      string            `json:"baseFeePerGas"`
+			GasUsedRatio float64           `json:"gasUsedRatio"`
+			Tips         map[string]string `json:"tips"`
+		}{row.block, formatBlockTime(row.time), row.baseFee.String(), row.gasUsedRatio, tips}
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summarizeFeeHistory prints how high the base fee and the tips ran over rows, to set fee caps
+// by, and the median base fee per hour of the day, to schedule transfers by
+func summarizeFeeHistory(rows []feeHistoryRow, percentiles []float64, nf numberFormat) {
+	gwei := func(v *big.Int) string { return nf.format(formatUnits(v, 9)) }
+	baseFees := make([]*big.Int, len(rows))
+	for i, row := range rows {
+		baseFees[i] = row.baseFee
+	}
+	sortBig(baseFees)
+	first, last := rows[0], rows[len(rows)-1]
+	span := ""
+	if !first.time.IsZero() {
+		span = fmt.Sprintf(", %s", last.time.Sub(first.time).Round(time.Minute))
+	}
+	resultf([]interface{}{"fromBlock", first.block, "toBlock", last.block, "baseFeeMedian", nearestRank(baseFees, 50).String(), "baseFeeP90", nearestRank(baseFees, 90).String(),
+		"baseFeeP99", nearestRank(baseFees, 99).String(), "baseFeeMax", baseFees[len(baseFees)-1].String()},
+		"Base fee over blocks %d to %d%s: median %s gwei, p90 %s gwei, p99 %s gwei, max %s gwei", first.block, last.block, span,
+		gwei(nearestRank(baseFees, 50)), gwei(nearestRank(baseFees, 90)), gwei(nearestRank(baseFees, 99)), gwei(baseFees[len(baseFees)-1]))
+
+	// empty blocks report a zero tip that says nothing about the market
+	for i, p := range percentiles {
+		var tips []*big.Int
+		for _, row := range rows {
+			if row.gasUsedRatio > 0 && i < len(row.tips) {
+				tips = append(tips, row.tips[i])
+			}
+		}
+		if len(tips) == 0 {
+			continue
+		}
+		sortBig(tips)
+		resultf([]interface{}{"percentile", p, "tipMedian", nearestRank(tips, 50).String(), "tipP90", nearestRank(tips, 90).String(), "blocks", len(tips)},
+			"Tip %s: median %s gwei, p90 %s gwei over %d non-empty block(s)", percentileHeader(p), gwei(nearestRank(tips, 50)), gwei(nearestRank(tips, 90)), len(tips))
+	}
+
+	if first.time.IsZero() {
+		return
+	}
+	byHour := map[int][]*big.Int{}
+	for _, row := range rows {
+		hour := row.time.UTC().Hour()
+		byHour[hour] = append(byHour[hour], row.baseFee)
+	}
+	if len(byHour) < 2 {
+		return
+	}
+	hours := make([]int, 0, len(byHour))
+	for hour := range byHour {
+		hours = append(hours, hour)
+	}
+	sort.Ints(hours)
+	for _, hour := range hours {
+		fees := byHour[hour]
+		sortBig(fees)
+		resultf([]interface{}{"hourUTC", hour, "baseFeeMedian", nearestRank(fees, 50).String(), "blocks", len(fees)},
+			"%02d:00 UTC: median base fee %s gwei over %d block(s)", hour, gwei(nearestRank(fees, 50)), len(fees))
+	}
+}
+
+// sortBig sorts values in ascending order
+func sortBig(values []*big.Int) {
+	sort.Slice(values, func(i, j int) bool { return values[i].Cmp(values[j]) < 0 })
+}
+
+// nearestRank returns the p-th percentile of sorted, which is not empty, by the nearest-rank method
+func nearestRank(sorted []*big.Int, p float64) *big.Int {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// tipPercentiles are the percentiles of the recent tips the fees subcommand lists
+var tipPercentiles = []float64{10, 25, 50, 75, 90}
+
+// Gas of the transfers the fees subcommand prices. An ERC-20 transfer varies by token and is
+// dearer when the receiver holds none yet, so the figure is a typical one rather than a bound
+const (
+	nativeTransferGas = 21000
+	erc20TransferGas  = 65000
+)
+
+func newFeesFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("fees", flag.ExitOnError)
+	addRootFlags(fs, "feeBlocks", "feeSource", "txType", "fiat", "priceFeed", "priceURL")
+	addRootFlags(fs, readFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s fees -rpcURL https://... [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s fees history -rpcURL https://... [-blocks 1000] [-out csv|json] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nPrints the base fee, recent tips by percentile, the fees of every priority and what a transfer costs at each, without building a transaction.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runFees implements the "fees" subcommand, a fee quote that needs neither a key nor a receiver
+func runFees(args []string) {
+	if len(args) > 0 && args[0] == "history" {
+		runFeeHistory(args[1:])
+		return
+	}
+	fs := newFeesFlagSet()
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	if err := checkFeeSource(); err != nil {
+		exitf(exitInvalid, "Invalid -feeSource: %v", err)
+	}
+	client, chainID := dialRPC()
+	ctx := opCtx
+	chain := lookupChain(chainID)
+
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get header: %v", err)
+	}
+	legacy, err := legacyTx(header)
+	if err != nil {
+		exitf(exitInvalid, "Invalid transaction type: %v", err)
+	}
+	baseFee := header.BaseFee
+	if legacy {
+		baseFee = nil
+		infof("Legacy transactions pay a single gas price")
+	} else {
+		resultf([]interface{}{"baseFee", baseFee.String()}, "Base fee: %s gwei", nf.format(formatUnits(baseFee, 9)))
+		tips, blocks, err := recentTips(ctx, client)
+		if err != nil {
+			warnf("No recent tips: %v", err)
+		}
+		for i, tip := range tips {
+			resultf([]interface{}{"percentile", tipPercentiles[i], "tip", tip.String(), "blocks", blocks},
+				"Tip p%-3v %s gwei (median of %d block(s))", tipPercentiles[i], nf.format(formatUnits(tip, 9)), blocks)
+		}
+	}
+
+	cost := func(gas uint64, price *big.Int) string {
+		amount := new(big.Int).Mul(price, new(big.Int).SetUint64(gas))
+		return nf.format(formatUnits(amount, chain.decimals)) + " " + chain.symbol + fiatAmount(client, chainID, amount, chain.decimals, nf)
+	}
+	for _, preset := range feePresets {
+		tip, feeCap, err := presetFees(ctx, client, baseFee, legacy, preset, nil, nil)
+		if err != nil {
+			exitf(exitRPC, "Failed to determine fees: %v", err)
+		}
+		// the fee actually paid per gas is the base fee plus the tip, capped by maxFeePerGas
+		price := feeCap
+		if baseFee != nil {
+			if price = new(big.Int).Add(baseFee, tip); price.Cmp(feeCap) > 0 {
+				price = feeCap
+			}
+		}
+		attrs := []interface{}{"priority", preset.name, "maxPriorityFeePerGas", tip.String(), "maxFeePerGas", feeCap.String(),
+			"transferFee", new(big.Int).Mul(price, big.NewInt(nativeTransferGas)).String(), "transferMaxFee", new(big.Int).Mul(feeCap, big.NewInt(nativeTransferGas)).String(),
+			"erc20TransferFee", new(big.Int).Mul(price, big.NewInt(erc20TransferGas)).String(), "erc20TransferMaxFee", new(big.Int).Mul(feeCap, big.NewInt(erc20TransferGas)).String()}
+		fees := fmt.Sprintf("%-8s tip %s gwei, max fee %s gwei", preset.name, nf.format(formatUnits(tip, 9)), nf.format(formatUnits(feeCap, 9)))
+		if legacy {
+			// the node's gas price does not depend on the priority
+			fees = fmt.Sprintf("Gas price %s gwei", nf.format(formatUnits(feeCap, 9)))
+		}
+		resultf(attrs, "%s: transfer %s (at most %s), ERC-20 transfer %s (at most %s)", fees,
+			cost(nativeTransferGas, price), cost(nativeTransferGas, feeCap), cost(erc20TransferGas, price), cost(erc20TransferGas, feeCap))
+		if legacy {
+			break
+		}
+	}
+}
+
+// recentTips returns, for each of tipPercentiles, the median over the last -feeBlocks non-empty
+// blocks of the tip paid at that percentile, with the number of blocks it is taken over
+func recentTips(ctx context.Context, client *ethclient.Client) ([]*big.Int, int, error) {
+	if *feeBlocksFlag == 0 {
+		return nil, 0, errors.New("-feeBlocks is 0")
+	}
+	history, err := client.FeeHistory(ctx, *feeBlocksFlag, nil, tipPercentiles)
+	if err != nil {
+		return nil, 0, err
+	}
+	byPercentile := make([][]*big.Int, len(tipPercentiles))
+	for i, rewards := range history.Reward {
+		// empty blocks report a zero tip that says nothing about the market
+		if i >= len(history.GasUsedRatio) || history.GasUsedRatio[i] == 0 || len(rewards) != len(tipPercentiles) {
+			continue
+		}
+		for p, reward := range rewards {
+			byPercentile[p] = append(byPercentile[p], reward)
+		}
+	}
+	blocks := len(byPercentile[0])
+	if blocks == 0 {
+		return nil, 0, fmt.Errorf("no transactions in the last %d blocks", *feeBlocksFlag)
+	}
+	tips := make([]*big.Int, len(tipPercentiles))
+	for p, rewards := range byPercentile {
+		sort.Slice(rewards, func(i, j int) bool { return rewards[i].Cmp(rewards[j]) < 0 })
+		tips[p] = rewards[len(rewards)/2]
+	}
+	return tips, blocks, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// paidFees is what a mined transaction paid per gas against what it was signed to pay at most
+type paidFees struct {
+	gasUsed uint64
+	price   *big.Int // effective gas price
+	feeCap  *big.Int // maxFeePerGas, or the gas price of a legacy transaction
+	baseFee *big.Int // of the block, nil for legacy transactions or if it could not be read
+	tip     *big.Int // the price less the base fee, nil with baseFee
+}
+
+// receiptFees works out the fees tx paid from its receipt and the base fee of its block, or
+// returns nil if the node reports no effective gas price
+func receiptFees(client *ethclient.Client, tx *types.Transaction, receipt *types.Receipt) *paidFees {
+	if receipt.EffectiveGasPrice == nil {
+		return nil
+	}
+	f := &paidFees{gasUsed: receipt.GasUsed, price: receipt.EffectiveGasPrice, feeCap: tx.GasFeeCap()}
+	if tx.Type() == types.LegacyTxType || tx.Type() == types.AccessListTxType {
+		return f
+	}
+	if header, err := client.HeaderByNumber(opCtx, receipt.BlockNumber); err != nil || header.BaseFee == nil {
+		debugf("Failed to get the base fee of block %s: %v", receipt.BlockNumber, err)
+	} else {
+		f.baseFee, f.tip = header.BaseFee, new(big.Int).Sub(f.price, header.BaseFee)
+	}
+	return f
+}
+
+// fee returns the gas used times price
+func (f *paidFees) fee(price *big.Int) *big.Int {
+	return new(big.Int).Mul(price, new(big.Int).SetUint64(f.gasUsed))
+}
+
+// feeReport adds up the fees of the transactions of a -batch for -costReport. Each sum is the
+// gas used times a price per gas, so that dividing it by gasUsed gives the gas-weighted average
+type feeReport struct {
+	count   int
+	gasUsed uint64
+	paid    *big.Int // at the effective gas price
+	capped  *big.Int // at maxFeePerGas
+	baseFee *big.Int // at the base fee, over based transactions only
+	tip     *big.Int
+	based   uint64 // gas used by the transactions whose base fee is known
+}
+
+func newFeeReport() *feeReport {
+	return &feeReport{paid: new(big.Int), capped: new(big.Int), baseFee: new(big.Int), tip: new(big.Int)}
+}
+
+func (r *feeReport) add(f *paidFees) {
+	r.count++
+	r.gasUsed += f.gasUsed
+	r.paid.Add(r.paid, f.fee(f.price))
+	r.capped.Add(r.capped, f.fee(f.feeCap))
+	if f.baseFee != nil {
+		r.baseFee.Add(r.baseFee, f.fee(f.baseFee))
+		r.tip.Add(r.tip, f.fee(f.tip))
+		r.based += f.gasUsed
+	}
+}
+
+// addReceipt adds the transaction of receipt, looking up the fee cap it was signed with
+func (r *feeReport) addReceipt(client *ethclient.Client, receipt *types.Receipt) {
+	tx, _, err := client.TransactionByHash(opCtx, receipt.TxHash)
+	if err != nil {
+		warnf("Failed to get transaction %s, it is left out of the cost report: %v", receipt.TxHash.Hex(), err)
+		return
+	}
+	if f := receiptFees(client, tx, receipt); f != nil {
+		r.add(f)
+	}
+}
+
+// print logs the totals and the gas-weighted average prices in gwei
+func (r *feeReport) print(client *ethclient.Client, chainID *big.Int, nf numberFormat) {
+	if r.count == 0 || r.gasUsed == 0 {
+		infof("Cost report: no confirmed transactions")
+		return
+	}
+	chain := lookupChain(chainID)
+	average := func(sum *big.Int, gas uint64) string {
+		return nf.format(formatUnits(new(big.Int).Div(sum, new(big.Int).SetUint64(gas)), 9))
+	}
+	saved := new(big.Int).Sub(r.capped, r.paid)
+	percent := new(big.Int).Div(new(big.Int).Mul(saved, big.NewInt(100)), r.capped)
+	attrs := []interface{}{"transactions", r.count, "gasUsed", r.gasUsed, "fees", r.paid.String(),
+		"averageGasPrice", new(big.Int).Div(r.paid, new(big.Int).SetUint64(r.gasUsed)).String()}
+	resultf(attrs, "Cost report: %d transactions used %s gas and paid %s %s%s, at an average of %s gwei per gas", r.count, nf.format(formatUnits(new(big.Int).SetUint64(r.gasUsed), 0)),
+		nf.format(formatUnits(r.paid, chain.decimals)), chain.symbol, fiatAmount(client, chainID, r.paid, chain.decimals, nf), average(r.paid, r.gasUsed))
+	if r.based > 0 {
+		resultf([]interface{}{"averageBaseFee", new(big.Int).Div(r.baseFee, new(big.Int).SetUint64(r.based)).String(), "averageTip", new(big.Int).Div(r.tip, new(big.Int).SetUint64(r.based)).String()},
+			"Cost report: average base fee %s gwei, average tip %s gwei", average(r.baseFee, r.based), average(r.tip, r.based))
+	}
+	resultf([]interface{}{"maxFees", r.capped.String(), "saved", saved.String()}, "Cost report: the fee caps allowed %s %s, of which %s %s (%s%%) was saved",
+		nf.format(formatUnits(r.capped, chain.decimals)), chain.symbol, nf.format(formatUnits(saved, chain.decimals)), chain.symbol, percent)
+}
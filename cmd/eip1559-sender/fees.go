@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// feePreset is a -priority level, mapped onto the fee estimator's settings
+type feePreset struct {
+	name       string
+	percentile float64
+	headroom   uint
+	blocks     int // blocks until inclusion in most cases
+}
+
+var feePresets = []feePreset{
+	{"slow", 10, 3, 10},
+	{"standard", 50, 6, 3},
+	{"fast", 75, 8, 2},
+	{"urgent", 95, 10, 1},
+}
+
+// priorityNames returns the values accepted by -priority
+func priorityNames() []string {
+	var names []string
+	for _, p := range feePresets {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// lookupPriority returns the preset selected with -priority
+func lookupPriority() (feePreset, error) {
+	for _, p := range feePresets {
+		if p.name == *priorityFlag {
+			return p, nil
+		}
+	}
+	return feePreset{}, fmt.Errorf("unknown priority %q, expected one of %s", *priorityFlag, strings.Join(priorityNames(), ", "))
+}
+
+// applyPriority sets -feePercentile and -feeHeadroom from -priority unless they were given on fs
+func applyPriority(fs *flag.FlagSet) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	return applyPriorityPreset(given)
+}
+
+// applyPriorityPreset sets -feePercentile and -feeHeadroom from -priority unless they are given
+func applyPriorityPreset(given map[string]bool) error {
+	preset, err := lookupPriority()
+	if err != nil {
+		return err
+	}
+	if !given["feePercentile"] {
+		*feePercentile = preset.percentile
+	}
+	if !given["feeHeadroom"] {
+		*feeHeadroom = preset.headroom
+	}
+	return nil
+}
+
+// inclusionEstimate describes when a transaction at -priority is expected to be mined
+func inclusionEstimate(chainID *big.Int) string {
+	preset, err := lookupPriority()
+	if err != nil {
+		return ""
+	}
+	estimate := fmt.Sprintf("usually included within %d block(s)", preset.blocks)
+	if block := lookupChain(chainID).block; block > 0 {
+		estimate += fmt.Sprintf(" (about %s)", time.Duration(preset.blocks)*block)
+	}
+	return estimate
+}
+
+// suggestFees returns the tip and fee cap for a new transaction from the fee flags, see
+// sender.SuggestFees. Without a base fee both are the gas price of a legacy transaction
+func suggestFees(ctx context.Context, client *ethclient.Client, baseFee *big.Int) (*big.Int, *big.Int, error) {
+	opts, err := feeOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	if baseFee == nil && opts.Tip != nil {
+		return nil, nil, errors.New("-maxPriorityFeePerGas does not apply to legacy transactions, use -maxFeePerGas for the gas price")
+	}
+	if baseFee != nil {
+		preset, err := lookupPriority()
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = withTipFallback(client, preset, withFeeSource(ctx, client, preset, opts))
+	}
+	return sender.SuggestFees(ctx, client, baseFee, opts)
+}
+
+// feeOptions returns the settings of the fee estimator selected by the fee flags
+func feeOptions() (sender.FeeOptions, error) {
+	if err := checkFeeSource(); err != nil {
+		return sender.FeeOptions{}, err
+	}
+	tip, err := parseGwei(*maxTipFlag)
+	if err != nil {
+		return sender.FeeOptions{}, fmt.Errorf("invalid -maxPriorityFeePerGas: %v", err)
+	}
+	feeCap, err := parseGwei(*maxFeeFlag)
+	if err != nil {
+		return sender.FeeOptions{}, fmt.Errorf("invalid -maxFeePerGas: %v", err)
+	}
+	return sender.FeeOptions{
+		Tip:        tip,
+		FeeCap:     feeCap,
+		Blocks:     *feeBlocksFlag,
+		Percentile: *feePercentile,
+		Headroom:   *feeHeadroom,
+	}, nil
+}
+
+// legacyTx reports whether to build legacy (type 0) transactions: on chains whose blocks carry
+// no base fee, or when forced with -txType legacy
+func legacyTx(header *types.Header) (bool, error) {
+	legacy, err := sender.UseLegacy(header, sender.TxType(*txTypeFlag))
+	if err != nil && *txTypeFlag == string(sender.TxDynamic) {
+		return false, fmt.Errorf("%v, use -txType legacy", err)
+	}
+	return legacy, err
+}
+
+// checkFeeCap refuses transactions whose fee cap or worst-case fee exceeds -maxFeeGwei or -maxFeeEth,
+// the latter in the native coin of chainID. Unsigned legacy transactions do not carry their chain
+// ID, so it is passed in. On OP-stack chains the worst-case fee includes the L1 data fee
+func checkFeeCap(client *ethclient.Client, chainID *big.Int, tx *types.Transaction) error {
+	if limit, err := parseGwei(*maxFeeGweiFlag); err != nil {
+		return fmt.Errorf("invalid -maxFeeGwei: %v", err)
+	} else if limit != nil && tx.GasFeeCap().Cmp(limit) > 0 {
+		return fmt.Errorf("maxFeePerGas %s Wei exceeds -maxFeeGwei %s", tx.GasFeeCap(), *maxFeeGweiFlag)
+	}
+	if *maxFeeEthFlag == "" {
+		return nil
+	}
+	chain := lookupChain(chainID)
+	limit, err := parseUnits(*maxFeeEthFlag, chain.decimals)
+	if err != nil {
+		return fmt.Errorf("invalid -maxFeeEth: %v", err)
+	}
+	worst := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasFeeCap())
+	l1Fee, err := l1DataFee(client, tx)
+	if err != nil {
+		return err
+	}
+	if l1Fee != nil {
+		if worst.Add(worst, l1Fee); worst.Cmp(limit) > 0 {
+			return fmt.Errorf("worst-case fee of %s %s (%d gas at %s Wei plus an L1 data fee of %s Wei) exceeds -maxFeeEth %s", formatUnits(worst, chain.decimals), chain.symbol, tx.Gas(), tx.GasFeeCap(), l1Fee, *maxFeeEthFlag)
+		}
+	}
+	if worst.Cmp(limit) > 0 {
+		return fmt.Errorf("worst-case fee of %s %s (%d gas at %s Wei) exceeds -maxFeeEth %s", formatUnits(worst, chain.decimals), chain.symbol, tx.Gas(), tx.GasFeeCap(), *maxFeeEthFlag)
+	}
+	return nil
+}
+
+// parseGwei converts a decimal gwei amount to Wei, returning nil for an empty string
+func parseGwei(amount string) (*big.Int, error) {
+	if amount == "" {
+		return nil, nil
+	}
+	if !decimalAmount.MatchString(amount) {
+		return nil, fmt.Errorf("%q is not a decimal gwei amount", amount)
+	}
+	if strings.Trim(amount, "0.") == "" {
+		return new(big.Int), nil
+	}
+	return parseUnits(amount, 9)
+}
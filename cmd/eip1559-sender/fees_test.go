@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestCheckFeeCapLegacy(t *testing.T) {
+	defer func(limit string) { *maxFeeEthFlag = limit }(*maxFeeEthFlag)
+	// an unsigned legacy transaction derives a bogus chain ID from V=0
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1, Gas: 21000, GasPrice: big.NewInt(100e9)})
+
+	*maxFeeEthFlag = "0.001"
+	err := checkFeeCap(nil, big.NewInt(137), tx)
+	if err == nil || !strings.Contains(err.Error(), "0.0021 POL") {
+		t.Fatalf("checkFeeCap = %v, want the worst-case fee of 0.0021 POL refused", err)
+	}
+	*maxFeeEthFlag = "0.01"
+	if err := checkFeeCap(nil, big.NewInt(137), tx); err != nil {
+		t.Fatalf("checkFeeCap = %v, want nil", err)
+	}
+}
+
+func TestParseGwei(t *testing.T) {
+	for _, tc := range []struct {
+		amount string
+		want   string // wei, empty if the amount is refused
+	}{
+		{"1", "1000000000"},
+		{"1.5", "1500000000"},
+		{"0.000000001", "1"},
+		{"0", "0"},
+		{"0.00", "0"},
+		{"0.0000000001", ""},
+		{"-1", ""},
+		{"1e9", ""},
+	} {
+		got, err := parseGwei(tc.amount)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("parseGwei(%q) = %s, want an error", tc.amount, got)
+			}
+			continue
+		}
+		want, _ := new(big.Int).SetString(tc.want, 10)
+		if err != nil || got.Cmp(want) != 0 {
+			t.Errorf("parseGwei(%q) = %v, %v, want %s", tc.amount, got, err, tc.want)
+		}
+	}
+	if got, err := parseGwei(""); got != nil || err != nil {
+		t.Errorf("parseGwei(\"\") = %v, %v, want nil, nil", got, err)
+	}
+}
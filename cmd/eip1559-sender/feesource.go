@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// feeSources are the values of -feeSource besides rpc
+var feeSources = []string{"blocknative", "polygongasstation"}
+
+// blocknativeURL is Blocknative's gas price API, which serves mainnet, Polygon, Base and more
+const blocknativeURL = "https://api.blocknative.com/gasprices/blockprices?chainid=%d"
+
+// blocknativeConfidence maps -priority to the inclusion confidence of a Blocknative estimate
+var blocknativeConfidence = map[string]int{"slow": 70, "standard": 90, "fast": 95, "urgent": 99}
+
+// polygonGasStations are the gas station URLs by chain ID
+var polygonGasStations = map[uint64]string{
+	137:   "https://gasstation.polygon.technology/v2",
+	80002: "https://gasstation.polygon.technology/amoy",
+}
+
+// polygonGasStationLevel maps -priority to the levels of the Polygon gas station
+var polygonGasStationLevel = map[string]string{"slow": "safeLow", "standard": "standard", "fast": "fast", "urgent": "fast"}
+
+// checkFeeSource validates -feeSource
+func checkFeeSource() error {
+	if *feeSourceFlag == "rpc" {
+		return nil
+	}
+	for _, source := range feeSources {
+		if *feeSourceFlag == source {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown fee source %q, expected rpc, %s", *feeSourceFlag, strings.Join(feeSources, " or "))
+}
+
+// withFeeSource fills in the tip and fee cap of opts that the fee flags left open from the
+// -feeSource oracle's recommendation for preset. If the oracle fails, the fees are estimated
+// from the node as with -feeSource rpc
+func withFeeSource(ctx context.Context, client *ethclient.Client, preset feePreset, opts sender.FeeOptions) sender.FeeOptions {
+	if *feeSourceFlag == "rpc" || (opts.Tip != nil && opts.FeeCap != nil) {
+		return opts
+	}
+	tip, feeCap, err := oracleFees(ctx, client, *feeSourceFlag, preset)
+	if err != nil {
+		warnf("No fees from %s, estimating them from the node: %v", *feeSourceFlag, err)
+		return opts
+	}
+	debugf("%s recommends a tip of %s and a fee cap of %s Wei at priority %s", *feeSourceFlag, tip, feeCap, preset.name)
+	if opts.Tip == nil {
+		opts.Tip = tip
+	}
+	if opts.FeeCap == nil {
+		opts.FeeCap = feeCap
+	}
+	return opts
+}
+
+// withTipFallback lets the fee estimator fall back to a gas API when the node suggests no tip:
+// the -feeSource oracle, or with rpc the Polygon gas station on Polygon and Blocknative elsewhere
+func withTipFallback(client *ethclient.Client, preset feePreset, opts sender.FeeOptions) sender.FeeOptions {
+	opts.FallbackTip = func(ctx context.Context) (*big.Int, error) {
+		chainID, err := client.ChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		source := *feeSourceFlag
+		if source == "rpc" {
+			source = "blocknative"
+			if _, ok := polygonGasStations[chainID.Uint64()]; ok {
+				source = "polygongasstation"
+			}
+		}
+		tip, _, err := oracleFees(ctx, client, source, preset)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+		warnf("The node suggests no tip, using the %s Wei of %s", tip, source)
+		return tip, nil
+	}
+	opts.OnFallback = func(source string, err error) {
+		// nodes without the fee history are common, the node's own suggestion follows
+		if source == "eth_feeHistory" {
+			debugf("No tip from %s: %v", source, err)
+			return
+		}
+		warnf("No tip from %s, trying the next source: %v", source, err)
+	}
+	return opts
+}
+
+// oracleFees fetches the tip and fee cap the gas API source recommends for preset
+func oracleFees(ctx context.Context, client *ethclient.Client, source string, preset feePreset) (*big.Int, *big.Int, error) {
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch source {
+	case "blocknative":
+		return blocknativeFees(ctx, chainID, preset)
+	case "polygongasstation":
+		return polygonGasStationFees(ctx, chainID, preset)
+	}
+	return nil, nil, fmt.Errorf("unknown fee source %q", source)
+}
+
+// blocknativeFees reads the estimate of Blocknative's next block prices at the confidence of
+// preset. $BLOCKNATIVE_API_KEY is sent if set, some chains require it
+func blocknativeFees(ctx context.Context, chainID *big.Int, preset feePreset) (*big.Int, *big.Int, error) {
+	header := http.Header{}
+	if key := os.Getenv("BLOCKNATIVE_API_KEY"); key != "" {
+		header.Set("Authorization", key)
+	}
+	var reply struct {
+		BlockPrices []struct {
+			EstimatedPrices []struct {
+				Confidence           int     `json:"confidence"`
+				MaxPriorityFeePerGas float64 `json:"maxPriorityFeePerGas"`
+				MaxFeePerGas         float64 `json:"maxFeePerGas"`
+			} `json:"estimatedPrices"`
+		} `json:"blockPrices"`
+	}
+	if err := getJSON(ctx, fmt.Sprintf(blocknativeURL, chainID), header, &reply); err != nil {
+		return nil, nil, err
+	}
+	if len(reply.BlockPrices) == 0 {
+		return nil, nil, fmt.Errorf("no block prices for chain %s", chainID)
+	}
+	confidence := blocknativeConfidence[preset.name]
+	for _, estimate := range reply.BlockPrices[0].EstimatedPrices {
+		if estimate.Confidence == confidence {
+			return gweiFloat(estimate.MaxPriorityFeePerGas), gweiFloat(estimate.MaxFeePerGas), nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no estimate at %d%% confidence", confidence)
+}
+
+// polygonGasStationFees reads the level of preset from the Polygon gas station
+func polygonGasStationFees(ctx context.Context, chainID *big.Int, preset feePreset) (*big.Int, *big.Int, error) {
+	url := ""
+	if chainID.IsUint64() {
+		url = polygonGasStations[chainID.Uint64()]
+	}
+	if url == "" {
+		return nil, nil, fmt.Errorf("the Polygon gas station does not serve chain %s", chainID)
+	}
+	type level struct {
+		MaxPriorityFee float64 `json:"maxPriorityFee"`
+		MaxFee         float64 `json:"maxFee"`
+	}
+	var reply map[string]json.RawMessage
+	if err := getJSON(ctx, url, nil, &reply); err != nil {
+		return nil, nil, err
+	}
+	name := polygonGasStationLevel[preset.name]
+	var fees level
+	if err := json.Unmarshal(reply[name], &fees); err != nil || fees.MaxFee == 0 {
+		return nil, nil, fmt.Errorf("no %s level in the response", name)
+	}
+	return gweiFloat(fees.MaxPriorityFee), gweiFloat(fees.MaxFee), nil
+}
+
+// getJSON fetches url through the proxy and decodes its JSON response into result
+func getJSON(ctx context.Context, url string, header http.Header, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	transport, err := proxyTransport()
+	if err != nil {
+		return err
+	}
+	httpClient := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("unexpected response from %s: %v", url, err)
+	}
+	return nil
+}
+
+// gweiFloat converts a fractional gwei amount of an oracle to Wei
+func gweiFloat(gwei float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(1e9)).Int(nil)
+	return wei
+}
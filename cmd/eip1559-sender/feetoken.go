@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// transferProbeCode is run by eth_call in place of the code of the sender. Given the token,
+// receiver and amount as three words of calldata, it reads balanceOf(receiver), calls
+// transfer(receiver, amount) and reads balanceOf(receiver) again. It returns both balances, the
+// first word returned by transfer, whether transfer succeeded and the size of its return data
+var transferProbeCode = common.FromHex("0x6370a0823160e01b60005260203560045260206080602460006000355afa5063a9059cbb60e01b600052602035600452604035602452602060c06044600060006000355af160e0523d610100526370a0823160e01b600052602035600452602060a0602460006000355afa5060a06080f3")
+
+// probeTransfer simulates the transfer of amount base units from from to to and returns how much
+// the balance of the receiver grows by. The state override of eth_call puts transferProbeCode at
+// the sender's address, so the token sees the real sender and its balance
+func (t *erc20Token) probeTransfer(from, to common.Address, amount *big.Int) (*big.Int, error) {
+	input := append(common.LeftPadBytes(t.address.Bytes(), 32), common.LeftPadBytes(to.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(amount.Bytes(), 32)...)
+	call := map[string]interface{}{"from": from, "to": from, "data": hexutil.Bytes(input)}
+	override := map[common.Address]map[string]interface{}{from: {"code": hexutil.Bytes(transferProbeCode)}}
+	var out hexutil.Bytes
+	if err := t.client.Client().CallContext(opCtx, &out, "eth_call", call, "pending", override); err != nil {
+		return nil, err
+	}
+	if len(out) != 5*32 {
+		return nil, fmt.Errorf("unexpected result of %d bytes, the node may not support state overrides", len(out))
+	}
+	word := func(i int) *big.Int { return new(big.Int).SetBytes(out[i*32 : (i+1)*32]) }
+	switch {
+	case word(3).Sign() == 0:
+		return nil, errors.New("the transfer reverts")
+	case word(4).Sign() != 0 && word(2).Sign() == 0:
+		return nil, errors.New("transfer returns false")
+	}
+	return new(big.Int).Sub(word(1), word(0)), nil
+}
+
+// checkTransferFee simulates the transfer of units before it is confirmed and warns if the balance
+// of the receiver would grow by another amount, as with tokens that take a fee on transfers or
+// rebase. Nodes without state overrides skip the check
+func (t *erc20Token) checkTransferFee(from, to common.Address, units *big.Int, format func(*big.Int) string) {
+	if from == to {
+		return
+	}
+	received, err := t.probeTransfer(from, to, units)
+	if err != nil {
+		debugf("Not comparing the balance change of the receiver: %v", err)
+		return
+	}
+	switch received.Cmp(units) {
+	case 0:
+		debugf("Simulated transfer: the balance of the receiver grows by %s", format(received))
+	case -1:
+		warnf("The balance of the receiver would only grow by %s of the %s sent: the token takes a fee of %s on transfers or rebases. Adjust the amount or abort",
+			format(received), format(units), format(new(big.Int).Sub(units, received)))
+	default:
+		warnf("The balance of the receiver would grow by %s rather than the %s sent: the token rebases or pays out on transfers", format(received), format(units))
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// chainlinkFeeds are the Chainlink price feeds of the native coin in USD by chain ID
+var chainlinkFeeds = map[uint64]string{
+	1:        "0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419", // ETH / USD
+	11155111: "0x694AA1769357215DE4FAC081bf1f309aDC325306", // ETH / USD
+	10:       "0x13e3Ee699D1909E989722E753853AE30b17e08c5", // ETH / USD
+	8453:     "0x71041dddad3595F9CEd3DcCFBe3D1F4b0a16Bb70", // ETH / USD
+	42161:    "0x639Fe6ab55C921f74e7fac1ee960C0B6293ba612", // ETH / USD
+	137:      "0xAB594600376Ec9fD91F8e885dADF0CE036862dE0", // POL / USD
+	56:       "0x0567F2323251f0Aab15c8dFb1967E4e8A7D7aeB0", // BNB / USD
+}
+
+// staleFeed is the age after which a Chainlink answer is reported as outdated
+const staleFeed = 24 * time.Hour
+
+// chainlinkABI holds the aggregator methods of a Chainlink price feed
+var chainlinkABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(`[
+		{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+		{"type":"function","name":"latestRoundData","stateMutability":"view","inputs":[],"outputs":[{"name":"roundId","type":"uint80"},{"name":"answer","type":"int256"},{"name":"startedAt","type":"uint256"},{"name":"updatedAt","type":"uint256"},{"name":"answeredInRound","type":"uint80"}]}
+	]`))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// coinPrice caches the price of the native coin in -fiat, which is looked up once per run
+var coinPrice struct {
+	once  sync.Once
+	price *big.Float
+}
+
+// fiatAmount renders an amount of the native coin in -fiat, such as " (≈ 12.34 USD)", or ""
+// without -fiat or if the price is unavailable. client may be nil when signing offline
+func fiatAmount(client *ethclient.Client, chainID, amount *big.Int, decimals int, nf numberFormat) string {
+	if *fiatFlag == "" {
+		return ""
+	}
+	coinPrice.once.Do(func() {
+		price, err := nativePrice(client, chainID)
+		if err != nil {
+			warnf("No %s price of the native coin: %v", strings.ToUpper(*fiatFlag), err)
+			return
+		}
+		debugf("Native coin price: %s %s", price.Text('f', 2), strings.ToUpper(*fiatFlag))
+		coinPrice.price = price
+	})
+	if coinPrice.price == nil {
+		return ""
+	}
+	value := new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	value.Mul(value, coinPrice.price)
+	if amount.Sign() > 0 && value.Cmp(big.NewFloat(0.01)) < 0 {
+		return fmt.Sprintf(" (< %s %s)", nf.format("0.01"), strings.ToUpper(*fiatFlag))
+	}
+	return fmt.Sprintf(" (≈ %s %s)", nf.format(value.Text('f', 2)), strings.ToUpper(*fiatFlag))
+}
+
+// nativePrice looks up the price of the native coin from -priceURL, -priceFeed or the chain's
+// Chainlink USD feed
+func nativePrice(client *ethclient.Client, chainID *big.Int) (*big.Float, error) {
+	if *priceURLFlag != "" {
+		return httpPrice(chainID)
+	}
+	feed := *priceFeedFlag
+	if feed == "" {
+		if !strings.EqualFold(*fiatFlag, "usd") {
+			return nil, errors.New("Chainlink feeds are only known for USD, pass -priceURL or -priceFeed")
+		}
+		if chainID.IsUint64() {
+			feed = chainlinkFeeds[chainID.Uint64()]
+		}
+		if feed == "" {
+			return nil, fmt.Errorf("no known Chainlink feed on chain %s, pass -priceURL or -priceFeed", chainID)
+		}
+	}
+	if !common.IsHexAddress(feed) {
+		return nil, fmt.Errorf("invalid -priceFeed address %q", feed)
+	}
+	if client == nil {
+		return nil, errors.New("a Chainlink feed cannot be read offline, pass -priceURL")
+	}
+	return chainlinkPrice(client, common.HexToAddress(feed))
+}
+
+// chainlinkPrice reads the latest answer of a Chainlink aggregator
+func chainlinkPrice(client *ethclient.Client, feed common.Address) (*big.Float, error) {
+	results, err := callABI(client, chainlinkABI, feed, "decimals")
+	if err != nil {
+		return nil, err
+	}
+	decimals, ok := results[0].(uint8)
+	if !ok {
+		return nil, fmt.Errorf("unexpected decimals() type %T", results[0])
+	}
+	results, err = callABI(client, chainlinkABI, feed, "latestRoundData")
+	if err != nil {
+		return nil, err
+	}
+	answer, ok := results[1].(*big.Int)
+	if !ok || answer.Sign() <= 0 {
+		return nil, fmt.Errorf("feed %s has no valid answer", feed.Hex())
+	}
+	if updatedAt, ok := results[3].(*big.Int); ok {
+		if age := time.Since(time.Unix(updatedAt.Int64(), 0)); age > staleFeed {
+			warnf("The price of feed %s was last updated %s ago", feed.Hex(), age.Round(time.Minute))
+		}
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	return new(big.Float).Quo(new(big.Float).SetInt(answer), new(big.Float).SetInt(scale)), nil
+}
+
+// httpPrice fetches the price from -priceURL. {fiat} and {symbol} in the URL stand for the
+// currency and the native coin, and a #dotted.path fragment selects the price in the JSON
+// response, which otherwise has to hold a single number
+func httpPrice(chainID *big.Int) (*big.Float, error) {
+	rawURL, path, _ := strings.Cut(*priceURLFlag, "#")
+	rawURL = strings.NewReplacer("{fiat}", strings.ToLower(*fiatFlag), "{symbol}", lookupChain(chainID).symbol).Replace(rawURL)
+	transport, err := proxyTransport()
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", rawURL, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var reply interface{}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return nil, fmt.Errorf("%s did not return JSON: %v", rawURL, err)
+	}
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			object, ok := reply.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("no %s in the response of %s", path, rawURL)
+			}
+			reply = object[key]
+		}
+		if price, ok := jsonNumber(reply); ok {
+			return price, nil
+		}
+		return nil, fmt.Errorf("%s in the response of %s is not a number", path, rawURL)
+	}
+	var prices []*big.Float
+	collectNumbers(reply, &prices)
+	if len(prices) != 1 {
+		return nil, fmt.Errorf("the response of %s holds %d numbers, select the price with a #path fragment", rawURL, len(prices))
+	}
+	return prices[0], nil
+}
+
+// jsonNumber returns the value of a JSON number, or of a string holding one
+func jsonNumber(value interface{}) (*big.Float, bool) {
+	switch v := value.(type) {
+	case float64:
+		if v > 0 {
+			return big.NewFloat(v), true
+		}
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && !math.IsInf(f, 0) {
+			return big.NewFloat(f), true
+		}
+	}
+	return nil, false
+}
+
+// collectNumbers appends the numbers found anywhere in a decoded JSON value
+func collectNumbers(value interface{}, numbers *[]*big.Float) {
+	switch v := value.(type) {
+	case float64:
+		*numbers = append(*numbers, big.NewFloat(v))
+	case map[string]interface{}:
+		for _, elem := range v {
+			collectNumbers(elem, numbers)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			collectNumbers(elem, numbers)
+		}
+	}
+}
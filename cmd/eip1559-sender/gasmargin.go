@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// checkGasMargin validates -gasMargin, -gasFloor and -gasCeiling
+func checkGasMargin() error {
+	switch {
+	case math.IsNaN(*gasMarginFlag) || math.IsInf(*gasMarginFlag, 0) || *gasMarginFlag < 1:
+		return fmt.Errorf("-gasMargin must be at least 1, got %v", *gasMarginFlag)
+	case *gasMarginFlag > 10:
+		return fmt.Errorf("-gasMargin of %v would pay for ten times the estimate, at most 10 is allowed", *gasMarginFlag)
+	case *gasCeilingFlag != 0 && *gasFloorFlag > *gasCeilingFlag:
+		return errors.New("-gasFloor is above -gasCeiling")
+	}
+	return nil
+}
+
+// withGasMargin turns an eth_estimateGas result into the gas limit to sign: raised by -gasMargin
+// and -gasFloor, then capped by -gasCeiling. The estimate is exact for the state it runs on, so a
+// token transfer to a first-time holder, or through a hook, can run out of gas once mined
+func withGasMargin(estimate uint64) (uint64, error) {
+	gas := estimate
+	if *gasMarginFlag != 1 {
+		gas = uint64(math.Ceil(float64(estimate) * *gasMarginFlag))
+	}
+	if gas < *gasFloorFlag {
+		gas = *gasFloorFlag
+	}
+	if *gasCeilingFlag != 0 && gas > *gasCeilingFlag {
+		if estimate > *gasCeilingFlag {
+			return 0, fmt.Errorf("estimated gas of %d is above -gasCeiling %d", estimate, *gasCeilingFlag)
+		}
+		gas = *gasCeilingFlag
+	}
+	return gas, nil
+}
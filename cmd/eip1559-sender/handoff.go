@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// defaultPendingDir is where the daemon hands off its unconfirmed jobs, below the user's config
+// directory
+var defaultPendingDir = filepath.Join("eip1559-sender", "pending")
+
+// statusHandedOff is the status of a job whose transaction was still unconfirmed at shutdown. Its
+// result is written once the next start of the daemon has followed it to the end
+const statusHandedOff = "handed off"
+
+// pendingJob is a job of the daemon sent but not yet confirmed at shutdown, kept in -pendingDir
+// for the next start to follow. The file is named after the chain, sender and nonce, so handing
+// the job off again replaces it
+type pendingJob struct {
+	Queue   string          `json:"queue"`
+	Job     string          `json:"job"`
+	Reply   string          `json:"reply,omitempty"`
+	Data    json.RawMessage `json:"data"`
+	ChainID uint64          `json:"chainId"`
+	From    common.Address  `json:"from"`
+	Nonce   uint64          `json:"nonce"`
+	Hashes  []common.Hash   `json:"hashes"`
+	// RawTx is the latest version sent, which further fee bumps start from
+	RawTx hexutil.Bytes `json:"rawTx"`
+	Sent  time.Time     `json:"sent"`
+}
+
+// pendingDir returns the path of -pendingDir
+func pendingDir() (string, error) {
+	if *pendingDirFlag != "" {
+		return *pendingDirFlag, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, defaultPendingDir), nil
+}
+
+// handOff writes the job of queue whose versions tracker follows to -pendingDir, tx being the
+// latest of them
+func handOff(queue string, job *queuedJob, chainID *big.Int, tracker *sender.Tracker, tx *types.Transaction, sent time.Time) error {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil {
+		return err
+	}
+	pending := pendingJob{
+		Queue:   queue,
+		Job:     job.name,
+		Reply:   job.reply,
+		Data:    job.data,
+		ChainID: chainID.Uint64(),
+		From:    from,
+		Nonce:   tx.Nonce(),
+		Hashes:  tracker.Hashes(),
+		RawTx:   raw,
+		Sent:    sent,
+	}
+	dir, err := pendingDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s-%d.json", pending.ChainID, from.Hex(), pending.Nonce)
+	return os.WriteFile(filepath.Join(dir, name), append(data, '\n'), 0o600)
+}
+
+// loadPendingJobs reads the jobs of queue handed off to -pendingDir, by path. With chainID, those
+// of other chains are left out
+func loadPendingJobs(queue string, chainID *big.Int) (map[string]*pendingJob, error) {
+	dir, err := pendingDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	jobs := map[string]*pendingJob{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var pending pendingJob
+		if err := json.Unmarshal(data, &pending); err != nil {
+			warnf("Ignoring %s: %v", file, err)
+			continue
+		}
+		if pending.Queue != queue || len(pending.Hashes) == 0 || (chainID != nil && pending.ChainID != chainID.Uint64()) {
+			continue
+		}
+		jobs[file] = &pending
+	}
+	return jobs, nil
+}
+
+// handedOffJobs returns the names of the jobs of queue waiting in -pendingDir
+func handedOffJobs(queue string) map[string]bool {
+	names := map[string]bool{}
+	jobs, err := loadPendingJobs(queue, nil)
+	if err != nil {
+		return names
+	}
+	for _, pending := range jobs {
+		names[pending.Job] = true
+	}
+	return names
+}
+
+// resume returns the job, the tracker following the versions of its transaction and the latest
+// version, to follow them again
+func (p *pendingJob) resume(client *ethclient.Client) (*queuedJob, *sender.Tracker, *types.Transaction, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(p.RawTx); err != nil {
+		return nil, nil, nil, fmt.Errorf("rawTx: %v", err)
+	}
+	if tx.Nonce() != p.Nonce || !slices.Contains(p.Hashes, tx.Hash()) {
+		return nil, nil, nil, errors.New("rawTx is not a version of the transaction")
+	}
+	tracker := sender.ResumeTracker(client, p.From, p.Nonce, p.Hashes)
+	return &queuedJob{data: p.Data, name: p.Job, reply: p.Reply}, tracker, tx, nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// defaultHistoryFile is the history's path below the user's config directory
+var defaultHistoryFile = filepath.Join("eip1559-sender", "history.jsonl")
+
+// historyStatuses lists the statuses a transaction of the history can have
+var historyStatuses = []string{"sent", "success", "reverted", "replaced"}
+
+// historyMu serializes appends to the history, which the server and daemon make concurrently
+var historyMu sync.Mutex
+
+// historyRecord is a line of the history. A transaction is recorded as sent when it is
+// broadcast, and again with its outcome once it is known: the records share its hash
+type historyRecord struct {
+	Time   time.Time `json:"time"`
+	Status string    `json:"status"`
+	Hash   string    `json:"hash"`
+	// the transaction, on sent records
+	ChainID string  `json:"chainId,omitempty"`
+	From    string  `json:"from,omitempty"`
+	To      string  `json:"to,omitempty"`
+	Value   string  `json:"value,omitempty"`
+	Token   string  `json:"token,omitempty"`
+	Amount  string  `json:"amount,omitempty"`
+	Nonce   *uint64 `json:"nonce,omitempty"`
+	Key     string  `json:"idempotencyKey,omitempty"`
+	Memo    string  `json:"memo,omitempty"`
+	// the outcome, on the records that follow
+	Block      uint64 `json:"block,omitempty"`
+	Fee        string `json:"fee,omitempty"`
+	ReplacedBy string `json:"replacedBy,omitempty"`
+}
+
+// historyPath returns the path of -historyFile, empty if the history is disabled
+func historyPath() (string, error) {
+	switch *historyFlag {
+	case "off":
+		return "", nil
+	case "":
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, defaultHistoryFile), nil
+	}
+	return *historyFlag, nil
+}
+
+// appendHistory appends record to the history. Failures are only logged, they do not stop a send
+func appendHistory(record historyRecord) {
+	path, err := historyPath()
+	if err == nil && path == "" {
+		return
+	}
+	record.Time = time.Now().UTC()
+	line, _ := json.Marshal(record)
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o700)
+	}
+	if err == nil {
+		err = appendLine(path, line)
+	}
+	if err != nil {
+		warnf("Failed to record %s in the history: %v", record.Hash, err)
+	}
+}
+
+// appendLine appends line and a newline to the file at path, creating it readable by the user only
+func appendLine(path string, line []byte) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// recordSent records the broadcast of tx with its idempotency key and memo, if any. ERC-20
+// transfers are recorded with their receiver and amount in base units rather than the token contract
+func recordSent(tx *types.Transaction, key, memo string) {
+	nonce := tx.Nonce()
+	record := historyRecord{Status: "sent", Hash: tx.Hash().Hex(), ChainID: tx.ChainId().String(), Value: tx.Value().String(), Nonce: &nonce, Key: key, Memo: memo}
+	if from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+		record.From = from.Hex()
+	}
+	if tx.To() != nil {
+		record.To = tx.To().Hex()
+	}
+	if data := tx.Data(); len(data) >= 4 && tx.To() != nil {
+		erc20 := mustLoadABI(erc20ABIJSON)
+		if method, err := erc20.MethodById(data[:4]); err == nil && (method.Name == "transfer" || method.Name == "transferFrom") {
+			if args, err := method.Inputs.Unpack(data[4:]); err == nil {
+				record.Token = tx.To().Hex()
+				record.To = args[len(args)-2].(common.Address).Hex()
+				record.Amount = args[len(args)-1].(*big.Int).String()
+			}
+		}
+	}
+	appendHistory(record)
+}
+
+// recordReceipt records the outcome of a mined transaction and the fee it paid
+func recordReceipt(client *ethclient.Client, receipt *types.Receipt) {
+	record := historyRecord{Status: "success", Hash: receipt.TxHash.Hex(), Block: receipt.BlockNumber.Uint64()}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		record.Status = "reverted"
+	}
+	if receipt.EffectiveGasPrice != nil {
+		fee := new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+		if receipt.BlobGasPrice != nil {
+			fee.Add(fee, new(big.Int).Mul(receipt.BlobGasPrice, new(big.Int).SetUint64(receipt.BlobGasUsed)))
+		}
+		if l1Fee := receiptL1Fee(client, receipt.TxHash); l1Fee != nil {
+			fee.Add(fee, l1Fee)
+		}
+		record.Fee = fee.String()
+	}
+	appendHistory(record)
+}
+
+// recordReplaced records that tx will not be mined, as replacedBy (if known) took its nonce
+func recordReplaced(tx *types.Transaction, replacedBy common.Hash) {
+	record := historyRecord{Status: "replaced", Hash: tx.Hash().Hex()}
+	if replacedBy != (common.Hash{}) {
+		record.ReplacedBy = replacedBy.Hex()
+	}
+	appendHistory(record)
+}
+
+// loadHistory reads the history and merges the records of each transaction, returning the
+// transactions in the order they were sent
+func loadHistory(path string) ([]*historyRecord, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var sent []*historyRecord
+	byHash := map[string]*historyRecord{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var record historyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d of %s: %v", line, path, err)
+		}
+		if record.Status == "sent" {
+			// a broadcast retried on another endpoint is recorded again
+			if _, ok := byHash[record.Hash]; ok {
+				continue
+			}
+			tx := record
+			byHash[record.Hash] = &tx
+			sent = append(sent, &tx)
+			continue
+		}
+		// an outcome of a transaction sent before the history was kept is of no use
+		if tx, ok := byHash[record.Hash]; ok {
+			tx.Status, tx.Block, tx.Fee, tx.ReplacedBy = record.Status, record.Block, record.Fee, record.ReplacedBy
+		}
+	}
+	return sent, scanner.Err()
+}
+
+// historyOptions holds the flags of the history subcommand
+type historyOptions struct {
+	address string
+	status  string
+	since   time.Duration
+	limit   int
+}
+
+func newHistoryFlagSet(opts *historyOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.StringVar(&opts.address, "address", "", "Only list transactions from or to this address")
+	fs.StringVar(&opts.status, "status", "", "Only list transactions with this status: "+strings.Join(historyStatuses, ", "))
+	fs.DurationVar(&opts.since, "since", 0, "Only list transactions sent within this long, e.g. 24h")
+	fs.IntVar(&opts.limit, "limit", 0, "Only list the most recent transactions, this many")
+	addRootFlags(fs, "historyFile", "chainID", "noChecksum", "locale", "v", "quiet", "logFormat")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s history [-address 0x...] [-chainID 1] [-status success] [-since 24h] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nLists the transactions sent by this tool and their outcome, oldest first. Token amounts are in base units.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runHistory implements the "history" subcommand
+func runHistory(args []string) {
+	var opts historyOptions
+	fs := newHistoryFlagSet(&opts)
+	fs.Parse(args)
+	if err := setupLogging(); err != nil {
+		exitf(exitInvalid, "Invalid logging options: %v", err)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	path, err := historyPath()
+	if err != nil {
+		fatalf("Failed to locate the history: %v", err)
+	}
+	if path == "" {
+		exitf(exitInvalid, "The history is disabled with -historyFile off")
+	}
+	if opts.status != "" && !slices.Contains(historyStatuses, opts.status) {
+		exitf(exitInvalid, "Invalid status %q, expected one of %s", opts.status, strings.Join(historyStatuses, ", "))
+	}
+	var address common.Address
+	if opts.address != "" {
+		if address, err = parseAddress(opts.address); err != nil {
+			exitf(exitInvalid, "Invalid address: %v", err)
+		}
+	}
+	txs, err := loadHistory(path)
+	if err != nil {
+		fatalf("Failed to read the history: %v", err)
+	}
+
+	var listed []*historyRecord
+	for _, tx := range txs {
+		switch {
+		case opts.address != "" && !strings.EqualFold(tx.From, address.Hex()) && !strings.EqualFold(tx.To, address.Hex()):
+		case *chainIDFlag != 0 && tx.ChainID != fmt.Sprint(*chainIDFlag):
+		case opts.status != "" && tx.Status != opts.status:
+		case opts.since > 0 && time.Since(tx.Time) > opts.since:
+		default:
+			listed = append(listed, tx)
+		}
+	}
+	sort.SliceStable(listed, func(i, j int) bool { return listed[i].Time.Before(listed[j].Time) })
+	if opts.limit > 0 && len(listed) > opts.limit {
+		listed = listed[len(listed)-opts.limit:]
+	}
+	if len(listed) == 0 {
+		infof("No transactions in %s", path)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if *logFormatFlag != "json" {
+		fmt.Fprintln(w, "TIME\tCHAIN\tFROM\tTO\tAMOUNT\tNONCE\tHASH\tSTATUS\tFEE\tMEMO")
+	}
+	for _, tx := range listed {
+		chainID, _ := new(big.Int).SetString(tx.ChainID, 10)
+		if chainID == nil {
+			chainID = new(big.Int)
+		}
+		chain := lookupChain(chainID)
+		amount := nf.format(formatUnits(bigOrZero(tx.Value), chain.decimals)) + " " + chain.symbol
+		if tx.Token != "" {
+			amount = nf.format(tx.Amount) + " of " + tx.Token
+		}
+		fee := "-"
+		if tx.Fee != "" {
+			fee = nf.format(formatUnits(bigOrZero(tx.Fee), chain.decimals)) + " " + chain.symbol
+		}
+		if *logFormatFlag == "json" {
+			resultf([]interface{}{"sentAt", tx.Time, "chainId", tx.ChainID, "from", tx.From, "to", tx.To, "value", tx.Value, "token", tx.Token, "amount", tx.Amount,
+				"nonce", tx.Nonce, "idempotencyKey", tx.Key, "memo", tx.Memo, "hash", tx.Hash, "status", tx.Status, "block", tx.Block, "fee", tx.Fee, "replacedBy", tx.ReplacedBy}, "%s: %s", tx.Hash, tx.Status)
+			continue
+		}
+		memo := tx.Memo
+		if memo == "" {
+			memo = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n", tx.Time.Local().Format("2006-01-02 15:04:05"), tx.ChainID, tx.From, tx.To, amount, *tx.Nonce, tx.Hash, tx.Status, fee, memo)
+	}
+	w.Flush()
+}
+
+// bigOrZero parses a decimal integer of the history, treating a missing one as zero
+func bigOrZero(value string) *big.Int {
+	n, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return n
+}
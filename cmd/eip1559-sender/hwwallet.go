@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// walletSigner signs through an accounts.Wallet such as a USB hardware wallet
+type walletSigner struct {
+	device  string
+	wallet  accounts.Wallet
+	account accounts.Account
+}
+
+func (s *walletSigner) Address() common.Address {
+	return s.account.Address
+}
+
+func (s *walletSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	promptf("Please review and confirm the transaction on your %s", s.device)
+	return s.wallet.SignTx(s.account, tx, chainID)
+}
+
+func (s *walletSigner) SignTypedData(domainSeparator, structHash []byte) ([]byte, error) {
+	promptf("Please review and confirm the signature on your %s", s.device)
+	data := append(append([]byte{0x19, 0x01}, domainSeparator...), structHash...)
+	return s.wallet.SignData(s.account, accounts.MimetypeTypedData, data)
+}
+
+// loadLedger opens the first connected Ledger and derives the account at path
+func loadLedger(path accounts.DerivationPath) (sender.Signer, error) {
+	hub, err := usbwallet.NewLedgerHub()
+	if err != nil {
+		return nil, fmt.Errorf("failed to access USB devices: %v", err)
+	}
+	wallets := hub.Wallets()
+	if len(wallets) == 0 {
+		return nil, errors.New("no Ledger found, make sure it is connected, unlocked and the Ethereum app is open")
+	}
+	wallet := wallets[0]
+	if err := wallet.Open(""); err != nil {
+		return nil, fmt.Errorf("failed to open Ledger: %v", err)
+	}
+	account, err := wallet.Derive(path, true)
+	if err != nil {
+		wallet.Close()
+		return nil, fmt.Errorf("failed to derive %s on Ledger: %v", path, err)
+	}
+	infof("Using Ledger account %s at %s", account.Address.Hex(), path)
+	return &walletSigner{device: "Ledger", wallet: wallet, account: account}, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"google.golang.org/grpc/metadata"
+)
+
+// idempotencyHeader is the gRPC metadata key carrying the idempotency key of a Send request
+const idempotencyHeader = "idempotency-key"
+
+// idempotencyMu keeps two sends with the same key from both passing the check before either is
+// recorded
+var idempotencyMu sync.Mutex
+
+// idempotencyKeyCtx is the context key of the idempotency key of a send
+type idempotencyKeyCtx struct{}
+
+// withIdempotencyKey returns ctx carrying key, recorded with the transactions sent under ctx
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+// sendIdempotencyKey returns the idempotency key of ctx, or -idempotencyKey for the CLI's own sends
+func sendIdempotencyKey(ctx context.Context) string {
+	if key, ok := ctx.Value(idempotencyKeyCtx{}).(string); ok {
+		return key
+	}
+	return *idempotencyKey
+}
+
+// grpcIdempotencyKey returns the idempotency-key metadata of a gRPC request, if any
+func grpcIdempotencyKey(ctx context.Context) string {
+	if values := metadata.ValueFromIncomingContext(ctx, idempotencyHeader); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// findIdempotent looks up the transactions of the history sent with key. It returns the one that
+// succeeded or is still pending, nil if none was sent or all of them reverted or were replaced
+// without being mined. Outcomes the history lacks are asked from the node, and a transaction
+// neither mined nor pending is an error, as it could still be mined
+func findIdempotent(client *ethclient.Client, chainID *big.Int, key string) (*historyRecord, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, errors.New("idempotency keys are looked up in the history, which is disabled with -historyFile off")
+	}
+	txs, err := loadHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range txs {
+		if tx.Key != key {
+			continue
+		}
+		switch tx.Status {
+		case "success":
+			return tx, nil
+		case "reverted", "replaced":
+			continue
+		}
+		if tx.ChainID != chainID.String() {
+			return nil, fmt.Errorf("%s was sent with idempotency key %q on chain ID %s and its outcome is unknown", tx.Hash, key, tx.ChainID)
+		}
+		hash := common.HexToHash(tx.Hash)
+		receipt, err := client.TransactionReceipt(opCtx, hash)
+		if err == nil {
+			recordReceipt(client, receipt)
+			if receipt.Status == types.ReceiptStatusSuccessful {
+				tx.Status = "success"
+				return tx, nil
+			}
+			continue
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		if _, pending, err := client.TransactionByHash(opCtx, hash); err == nil && pending {
+			tx.Status = "pending"
+			return tx, nil
+		}
+		return nil, fmt.Errorf("%s was sent with idempotency key %q, but the node knows nothing of it; make sure it cannot be mined anymore before using a new key", tx.Hash, key)
+	}
+	return nil, nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gopkg.in/yaml.v3"
+)
+
+// keygenProgressInterval is how often the vanity search reports its progress
+const keygenProgressInterval = 10 * time.Second
+
+// keygenOptions holds the flags of the keygen subcommand
+type keygenOptions struct {
+	vanity        string
+	caseSensitive bool
+	workers       int
+	out           string
+	lightKDF      bool
+	writeProfile  string
+}
+
+func newKeygenFlagSet(opts *keygenOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	fs.StringVar(&opts.vanity, "vanity", "", "Hex prefix the address must start with, e.g. 0xdead")
+	fs.BoolVar(&opts.caseSensitive, "caseSensitive", false, "Match the letters of -vanity against the EIP-55 checksum capitalisation of the address")
+	fs.IntVar(&opts.workers, "workers", runtime.NumCPU(), "Number of parallel workers searching for a -vanity address")
+	fs.StringVar(&opts.out, "out", "", "Directory to write the key to as an encrypted keystore file, encrypted with -password (if empty, the private key is printed)")
+	fs.BoolVar(&opts.lightKDF, "lightKDF", false, "Encrypt the keystore with light scrypt parameters, quicker to unlock but easier to brute-force, for test accounts")
+	fs.StringVar(&opts.writeProfile, "writeProfile", "", "Add a profile of this name signing with the new keystore to the config file")
+	addRootFlags(fs, "password", "rpcURL", "chainID", "config", "v", "quiet", "logFormat")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s keygen [-vanity 0xdead] [-out ~/.ethereum/keystore [-writeProfile name]] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nCreates a new private key. No RPC is needed.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runKeygen implements the "keygen" subcommand
+func runKeygen(args []string) {
+	var opts keygenOptions
+	fs := newKeygenFlagSet(&opts)
+	fs.Parse(args)
+	if err := setupLogging(); err != nil {
+		exitf(exitInvalid, "Invalid logging options: %v", err)
+	}
+	if fs.NArg() > 0 {
+		fmt.Printf("Error: Unexpected arguments %s\n", strings.Join(fs.Args(), " "))
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	prefix := strings.TrimPrefix(strings.TrimPrefix(opts.vanity, "0x"), "0X")
+	if _, err := hex.DecodeString(prefix + strings.Repeat("0", len(prefix)%2)); err != nil || len(prefix) > 40 {
+		exitf(exitInvalid, "Invalid -vanity %q: expected up to 40 hex digits", opts.vanity)
+	}
+	if opts.workers < 1 {
+		exitf(exitInvalid, "Invalid -workers %d: must be at least 1", opts.workers)
+	}
+	var profilePath string
+	if opts.writeProfile != "" {
+		if opts.out == "" {
+			// keep the private key itself out of the config file
+			exitf(exitInvalid, "-writeProfile needs -out, the profile signs with the keystore")
+		}
+		path, err := configPath()
+		if err != nil {
+			fatalf("Failed to find the config file: %v", err)
+		}
+		if _, err := loadConfigDocument(path, opts.writeProfile); err != nil {
+			exitf(exitInvalid, "Cannot add profile %s: %v", opts.writeProfile, err)
+		}
+		profilePath = path
+	}
+	password := *passwordFlag
+	if opts.out != "" && password == "" {
+		// asked before the search, which may run unattended for long
+		var err error
+		if password, err = promptNewPassword(); err != nil {
+			fatalf("Failed to read the password: %v", err)
+		}
+	}
+
+	var key *ecdsa.PrivateKey
+	if prefix == "" {
+		var err error
+		if key, err = crypto.GenerateKey(); err != nil {
+			fatalf("Failed to generate a key: %v", err)
+		}
+	} else {
+		key = searchVanity(prefix, opts.caseSensitive, opts.workers)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	if opts.out == "" {
+		warnf("Store the private key safely, whoever holds it controls %s", address.Hex())
+		privateKey := hexutil.Encode(crypto.FromECDSA(key))
+		resultf([]interface{}{"address", address.Hex(), "privateKey", privateKey}, "Address: %s, private key: %s", address.Hex(), privateKey)
+		return
+	}
+
+	scryptN, scryptP := keystore.StandardScryptN, keystore.StandardScryptP
+	if opts.lightKDF {
+		scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+	}
+	account, err := keystore.NewKeyStore(opts.out, scryptN, scryptP).ImportECDSA(key, password)
+	if err != nil {
+		fatalf("Failed to write the keystore: %v", err)
+	}
+	path, err := filepath.Abs(account.URL.Path)
+	if err != nil {
+		path = account.URL.Path
+	}
+	resultf([]interface{}{"address", address.Hex(), "keystore", path}, "Address: %s, keystore: %s", address.Hex(), path)
+	if profilePath == "" {
+		return
+	}
+	profile := map[string]interface{}{"keystore": path}
+	if *rpcURLFlag != "" {
+		profile["rpcURL"] = *rpcURLFlag
+	}
+	if *chainIDFlag != 0 {
+		profile["chainID"] = *chainIDFlag
+	}
+	if err := addConfigProfile(profilePath, opts.writeProfile, profile); err != nil {
+		fatalf("Failed to add profile %s: %v", opts.writeProfile, err)
+	}
+	resultf([]interface{}{"profile", opts.writeProfile, "config", profilePath}, "Added profile %s to %s, use it with -profile %s", opts.writeProfile, profilePath, opts.writeProfile)
+}
+
+// promptNewPassword asks for the password of a new keystore twice, refusing an empty one
+func promptNewPassword() (string, error) {
+	password, err := promptPassword("Password for the new keystore: ")
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", errors.New("the password is empty")
+	}
+	repeated, err := promptPassword("Repeat the password: ")
+	if err != nil {
+		return "", err
+	}
+	if repeated != password {
+		return "", errors.New("the passwords do not match")
+	}
+	return password, nil
+}
+
+// searchVanity generates keys on workers goroutines until the address of one starts with the
+// hex prefix, matching the checksum capitalisation if caseSensitive
+func searchVanity(prefix string, caseSensitive bool, workers int) *ecdsa.PrivateKey {
+	// every hex digit divides the odds by 16, and every letter by 2 more to match its case
+	expected := math.Pow(16, float64(len(prefix)))
+	if caseSensitive {
+		for _, r := range prefix {
+			if unicode.IsLetter(r) {
+				expected *= 2
+			}
+		}
+	} else {
+		prefix = strings.ToLower(prefix)
+	}
+	infof("Searching for an address starting with 0x%s on %d workers, about %.0f keys to try", prefix, workers, expected)
+
+	var tried atomic.Uint64
+	found := make(chan *ecdsa.PrivateKey, 1)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				key, err := crypto.GenerateKey()
+				if err != nil {
+					continue
+				}
+				tried.Add(1)
+				address := crypto.PubkeyToAddress(key.PublicKey)
+				var digits string
+				if caseSensitive {
+					digits = address.Hex()[2:]
+				} else {
+					digits = hex.EncodeToString(address[:])
+				}
+				if !strings.HasPrefix(digits, prefix) {
+					continue
+				}
+				select {
+				case found <- key:
+				default:
+				}
+				return
+			}
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(keygenProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case key := <-found:
+			close(done)
+			wg.Wait()
+			infof("Found after %d keys in %s", tried.Load(), time.Since(start).Round(time.Millisecond))
+			return key
+		case <-ticker.C:
+			count := tried.Load()
+			rate := float64(count) / time.Since(start).Seconds()
+			infof("Tried %d keys, %.0f per second, about %s to go for an average search", count, rate, formatETA(expected, float64(count), rate))
+		}
+	}
+}
+
+// formatETA renders the time left to try expected keys at rate per second, count tried already
+func formatETA(expected, count, rate float64) string {
+	if rate <= 0 || count >= expected {
+		return "little"
+	}
+	seconds := (expected - count) / rate
+	if seconds > float64(365*24*3600) {
+		return fmt.Sprintf("%.0f years", seconds/(365*24*3600))
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// loadConfigDocument parses the config file at path as a YAML document, for adding profile name
+// to it. A missing file is an empty document
+func loadConfigDocument(path, name string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a mapping", path)
+	}
+	if profiles := mappingValue(root, "profiles"); profiles != nil && mappingValue(profiles, name) != nil {
+		return nil, fmt.Errorf("%s already has a profile %s", path, name)
+	}
+	return &doc, nil
+}
+
+// addConfigProfile adds profile name with values to the config file at path, creating the file
+// if needed. The rest of the file, comments included, is kept
+func addConfigProfile(path, name string, values map[string]interface{}) error {
+	doc, err := loadConfigDocument(path, name)
+	if err != nil {
+		return err
+	}
+	root := doc.Content[0]
+	profiles := mappingValue(root, "profiles")
+	if profiles == nil {
+		profiles = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "profiles"}, profiles)
+	} else if profiles.Kind != yaml.MappingNode {
+		// an empty "profiles:" is null
+		if profiles.Tag != "!!null" {
+			return fmt.Errorf("profiles of %s is not a mapping", path)
+		}
+		*profiles = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	var profile yaml.Node
+	if err := profile.Encode(values); err != nil {
+		return err
+	}
+	profiles.Content = append(profiles.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, &profile)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0o600)
+}
+
+// mappingValue returns the value of key in the YAML mapping node, nil if it has none
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// kmsSigner signs with an asymmetric secp256k1 key held in AWS KMS
+type kmsSigner struct {
+	client *kms.Client
+	keyID  string
+	pubkey []byte // uncompressed public key, used to recover the signature's v
+}
+
+// loadKMS resolves the public key of an AWS KMS key using the standard AWS credential chain
+func loadKMS(keyID string) (sender.Signer, error) {
+	cfg, err := config.LoadDefaultConfig(opCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+	}
+	client := kms.NewFromConfig(cfg)
+
+	out, err := client.GetPublicKey(opCtx, &kms.GetPublicKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get KMS public key: %v", err)
+	}
+	if out.KeySpec != kmstypes.KeySpecEccSecgP256k1 {
+		return nil, fmt.Errorf("KMS key %s has key spec %s, expected %s", keyID, out.KeySpec, kmstypes.KeySpecEccSecgP256k1)
+	}
+
+	// the public key is a DER encoded SubjectPublicKeyInfo
+	var info struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(out.PublicKey, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse KMS public key: %v", err)
+	}
+	if _, err := crypto.UnmarshalPubkey(info.PublicKey.Bytes); err != nil {
+		return nil, fmt.Errorf("failed to parse KMS public key: %v", err)
+	}
+	s := &kmsSigner{client: client, keyID: keyID, pubkey: info.PublicKey.Bytes}
+	infof("Using KMS key %s (account %s)", keyID, s.Address().Hex())
+	return s, nil
+}
+
+func (s *kmsSigner) Address() common.Address {
+	return common.BytesToAddress(crypto.Keccak256(s.pubkey[1:])[12:])
+}
+
+func (s *kmsSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signer := types.LatestSignerForChainID(chainID)
+	hash := signer.Hash(tx)
+	signature, err := s.signDigest(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, signature)
+}
+
+func (s *kmsSigner) SignTypedData(domainSeparator, structHash []byte) ([]byte, error) {
+	return s.signDigest(typedDataHash(domainSeparator, structHash))
+}
+
+func (s *kmsSigner) SignText(text []byte) ([]byte, error) {
+	return s.signDigest(accounts.TextHash(text))
+}
+
+// signDigest signs a 32 byte hash in KMS and returns it as a [R || S || V] signature with V 0 or 1
+func (s *kmsSigner) signDigest(hash []byte) ([]byte, error) {
+	out, err := s.client.Sign(opCtx, &kms.SignInput{
+		KeyId:            aws.String(s.keyID),
+		Message:          hash,
+		MessageType:      kmstypes.MessageTypeDigest,
+		SigningAlgorithm: kmstypes.SigningAlgorithmSpecEcdsaSha256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("KMS signing failed: %v", err)
+	}
+
+	// KMS returns a DER encoded (r, s) pair without the recovery id
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(out.Signature, &sig); err != nil {
+		return nil, fmt.Errorf("failed to parse KMS signature: %v", err)
+	}
+	signature, err := recoverableSignature(hash, sig.R, sig.S, s.pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("KMS signature: %v", err)
+	}
+	return signature, nil
+}
+
+// recoverableSignature turns the (r, s) pair of a signer that leaves out the recovery id into a
+// [R || S || V] signature, V being found by recovering pubkey, the signer's uncompressed key
+func recoverableSignature(hash []byte, r, s *big.Int, pubkey []byte) ([]byte, error) {
+	// Ethereum only accepts signatures with s in the lower half of the curve order
+	n := crypto.S256().Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s = new(big.Int).Sub(n, s)
+	}
+
+	signature := make([]byte, 65)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:64])
+	for v := byte(0); v < 2; v++ {
+		signature[64] = v
+		if recovered, err := crypto.Ecrecover(hash, signature); err == nil && string(recovered) == string(pubkey) {
+			return signature, nil
+		}
+	}
+	return nil, errors.New("failed to recover the signer from the signature")
+}
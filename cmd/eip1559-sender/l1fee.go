@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// gasPriceOracle is the OP-stack predeploy pricing the L1 data of L2 transactions
+var gasPriceOracle = common.HexToAddress("0x420000000000000000000000000000000000000F")
+
+// gasPriceOracleABI holds the L1 fee lookup of the GasPriceOracle
+var gasPriceOracleABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(`[
+		{"type":"function","name":"getL1Fee","stateMutability":"view","inputs":[{"name":"_data","type":"bytes"}],"outputs":[{"name":"","type":"uint256"}]}
+	]`))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// l1FeeHeadroom is the percentage added to the L1 data fee where it must be reserved up front,
+// as the L1 base fee it follows can rise before the transaction is mined
+const l1FeeHeadroom = 25
+
+// opStack caches whether the chain has the GasPriceOracle predeploy, which is checked once
+var opStack struct {
+	once sync.Once
+	ok   bool
+}
+
+// isOPStack reports whether the chain is an OP-stack rollup such as OP Mainnet or Base
+func isOPStack(client *ethclient.Client) bool {
+	if client == nil {
+		return false
+	}
+	opStack.once.Do(func() {
+		code, err := client.CodeAt(opCtx, gasPriceOracle, nil)
+		if err != nil {
+			debugf("Failed to look for the GasPriceOracle: %v", err)
+			return
+		}
+		opStack.ok = len(code) > 0
+	})
+	return opStack.ok
+}
+
+// l1DataFee returns the fee an OP-stack chain charges on top of the gas for posting tx to L1,
+// or nil on other chains
+func l1DataFee(client *ethclient.Client, tx *types.Transaction) (*big.Int, error) {
+	if !isOPStack(client) {
+		return nil, nil
+	}
+	// the oracle expects the unsigned transaction and allows for the signature itself
+	raw, err := tx.WithoutBlobTxSidecar().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	results, err := callABI(client, gasPriceOracleABI, gasPriceOracle, "getL1Fee", raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the L1 data fee: %v", err)
+	}
+	fee, ok := results[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected getL1Fee() type %T", results[0])
+	}
+	return fee, nil
+}
+
+// l1FeeReserve returns the L1 data fee of tx plus l1FeeHeadroom, or zero on other chains
+func l1FeeReserve(client *ethclient.Client, tx *types.Transaction) (*big.Int, error) {
+	fee, err := l1DataFee(client, tx)
+	if err != nil || fee == nil {
+		return new(big.Int), err
+	}
+	return new(big.Int).Div(new(big.Int).Mul(fee, big.NewInt(100+l1FeeHeadroom)), big.NewInt(100)), nil
+}
+
+// receiptL1Fee returns the L1 data fee an OP-stack node reports in the receipt of hash, or nil
+func receiptL1Fee(client *ethclient.Client, hash common.Hash) *big.Int {
+	if !isOPStack(client) {
+		return nil
+	}
+	var receipt struct {
+		L1Fee *hexutil.Big `json:"l1Fee"`
+	}
+	if err := client.Client().CallContext(opCtx, &receipt, "eth_getTransactionReceipt", hash); err != nil || receipt.L1Fee == nil {
+		return nil
+	}
+	return receipt.L1Fee.ToInt()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// numberFormat describes how a locale groups digits and separates decimals
+type numberFormat struct {
+	group   string
+	decimal string
+}
+
+// canonicalFormat is used when no locale is given, keeping the plain output
+var canonicalFormat = numberFormat{group: "", decimal: "."}
+
+// localeFormats maps locale names (or their language part) to number formats
+var localeFormats = map[string]numberFormat{
+	"en":    {group: ",", decimal: "."},
+	"ja":    {group: ",", decimal: "."},
+	"ko":    {group: ",", decimal: "."},
+	"zh":    {group: ",", decimal: "."},
+	"de":    {group: ".", decimal: ","},
+	"es":    {group: ".", decimal: ","},
+	"it":    {group: ".", decimal: ","},
+	"nl":    {group: ".", decimal: ","},
+	"pt":    {group: ".", decimal: ","},
+	"tr":    {group: ".", decimal: ","},
+	"fr":    {group: " ", decimal: ","},
+	"pl":    {group: " ", decimal: ","},
+	"ru":    {group: " ", decimal: ","},
+	"sv":    {group: " ", decimal: ","},
+	"uk":    {group: " ", decimal: ","},
+	"de-ch": {group: "’", decimal: "."},
+}
+
+// lookupLocale returns the number format for a locale such as "de", "de-DE" or "fr_FR.UTF-8"
+func lookupLocale(locale string) (numberFormat, error) {
+	name := strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	if name == "" || name == "c" || name == "posix" {
+		return canonicalFormat, nil
+	}
+	if nf, ok := localeFormats[name]; ok {
+		return nf, nil
+	}
+	if i := strings.IndexByte(name, '-'); i >= 0 {
+		if nf, ok := localeFormats[name[:i]]; ok {
+			return nf, nil
+		}
+	}
+	return numberFormat{}, fmt.Errorf("unsupported locale %q", locale)
+}
+
+// format rewrites a canonical decimal string (e.g. "-1234567.89") using the locale's separators
+func (nf numberFormat) format(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	intPart, fracPart, hasFrac := strings.Cut(s, ".")
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(nf.group)
+		}
+		b.WriteRune(d)
+	}
+	if hasFrac {
+		b.WriteString(nf.decimal)
+		b.WriteString(fracPart)
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestLookupLocale(t *testing.T) {
+	for _, tc := range []struct {
+		locale string
+		want   numberFormat
+		ok     bool
+	}{
+		{"", canonicalFormat, true},
+		{"C", canonicalFormat, true},
+		{"C.UTF-8", canonicalFormat, true},
+		{"POSIX", canonicalFormat, true},
+		{"en", numberFormat{",", "."}, true},
+		{"en-US", numberFormat{",", "."}, true},
+		{"de_DE.UTF-8", numberFormat{".", ","}, true},
+		{"fr_CA", numberFormat{"\u202f", ","}, true},
+		{"de-CH", numberFormat{"’", "."}, true},
+		{"DE_ch.utf8", numberFormat{"’", "."}, true},
+		{"xx-YY", numberFormat{}, false},
+		{"english", numberFormat{}, false},
+	} {
+		got, err := lookupLocale(tc.locale)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("lookupLocale(%q) = %+v, %v, want %+v, ok %v", tc.locale, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	en := numberFormat{",", "."}
+	de := numberFormat{".", ","}
+	fr := numberFormat{"\u202f", ","}
+	for _, tc := range []struct {
+		nf   numberFormat
+		in   string
+		want string
+	}{
+		{canonicalFormat, "1234567.89", "1234567.89"},
+		{en, "0", "0"},
+		{en, "999", "999"},
+		{en, "1000", "1,000"},
+		{en, "123456", "123,456"},
+		{en, "1234567", "1,234,567"},
+		{en, "-1234567.89", "-1,234,567.89"},
+		{en, "-100", "-100"},
+		{en, "0.000001", "0.000001"},
+		{en, "1234.56789012", "1,234.56789012"},
+		{de, "1234567.89", "1.234.567,89"},
+		{de, "0.5", "0,5"},
+		{fr, "-12345.6", "-12\u202f345,6"},
+	} {
+		if got := tc.nf.format(tc.in); got != tc.want {
+			t.Errorf("%+v.format(%q) = %q, want %q", tc.nf, tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+)
+
+// levelResult is used for the outcome of a command, such as the transaction hash, which is
+// printed even with -quiet
+const levelResult = slog.LevelWarn - 1
+
+// logger receives all progress output of the sending commands, configured by setupLogging
+var logger = slog.New(&textHandler{level: slog.LevelInfo, out: os.Stdout})
+
+// setupLogging configures logger from -v, -quiet and -logFormat. With -stdin, stdout carries the
+// job results, so the log goes to stderr
+func setupLogging() error {
+	var out io.Writer = os.Stdout
+	if *stdinFlag {
+		out = os.Stderr
+	}
+	level := slog.LevelInfo
+	switch {
+	case *verboseFlag && *quietFlag:
+		return errors.New("-v and -quiet are mutually exclusive")
+	case *verboseFlag:
+		level = slog.LevelDebug
+	case *quietFlag:
+		level = levelResult
+	}
+	switch *logFormatFlag {
+	case "text":
+		logger = slog.New(&textHandler{level: level, out: out})
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey && a.Value.Any() == levelResult {
+					a.Value = slog.StringValue("RESULT")
+				}
+				if a.Value.Kind() == slog.KindString {
+					a.Value = slog.StringValue(redactKeys(a.Value.String()))
+				}
+				return a
+			},
+		}))
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", *logFormatFlag)
+	}
+	return nil
+}
+
+// textHandler prints bare messages for humans: progress on out, warnings on stderr and errors on
+// stderr with the timestamp of the standard logger. Both handlers redact loaded private keys
+type textHandler struct {
+	level slog.Level
+	out   io.Writer
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	msg := redactKeys(r.Message)
+	switch {
+	case r.Level >= slog.LevelError:
+		log.Print(msg)
+	case r.Level >= slog.LevelWarn:
+		fmt.Fprintln(os.Stderr, "Warning: "+msg)
+	default:
+		fmt.Fprintln(h.out, msg)
+	}
+	return nil
+}
+
+// attributes only matter to the JSON format, the messages already contain them
+func (h *textHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *textHandler) WithGroup(string) slog.Handler      { return h }
+
+func debugf(format string, args ...interface{}) {
+	logger.Debug(fmt.Sprintf(format, args...))
+}
+
+func infof(format string, args ...interface{}) {
+	logger.Info(fmt.Sprintf(format, args...))
+}
+
+func warnf(format string, args ...interface{}) {
+	logger.Warn(fmt.Sprintf(format, args...))
+}
+
+// resultf logs the outcome of a command together with machine readable attributes
+func resultf(attrs []interface{}, format string, args ...interface{}) {
+	logger.Log(context.Background(), levelResult, fmt.Sprintf(format, args...), attrs...)
+}
+
+// promptf asks the user to act, on stderr regardless of -quiet and -logFormat
+func promptf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// fatalf logs an error and exits with exitFailure
+func fatalf(format string, args ...interface{}) {
+	exitf(exitFailure, format, args...)
+}
+
+// exitf logs an error and exits with code, or with exitTimeout once -deadline has passed,
+// whatever call the deadline interrupted
+func exitf(code int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	// the error of whatever call the deadline interrupted only says "context deadline exceeded"
+	if opCtx.Err() != nil {
+		msg += fmt.Sprintf(" (%v)", context.Cause(opCtx))
+		if errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+			code = exitTimeout
+		}
+	}
+	logger.Error(msg, "exitCode", code)
+	exit(code)
+}
+
+// abortf logs an error and exits with code, even within a scheduled send. It is for goroutines
+// other than the one sending, which cannot end only the send
+func abortf(code int, format string, args ...interface{}) {
+	logger.Error(fmt.Sprintf(format, args...), "exitCode", code)
+	quit(code)
+}
+
+// exit exits with code. Within a scheduled send, it ends only that send, see runTick
+func exit(code int) {
+	if ticking.Load() {
+		panic(tickFailure{code})
+	}
+	quit(code)
+}
+
+// quit wipes the loaded keys and exits the process with code. Every exit goes through it, as
+// os.Exit skips the deferred forgetKeys of main
+func quit(code int) {
+	forgetKeys()
+	os.Exit(code)
+}
@@ -0,0 +1,1056 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+var (
+	privateKeyFlag      = flag.String("privateKey", "", "Sender's private key (visible in shell history and process lists, prefer -privateKeyEnv or -privateKeyStdin)")
+	privateKeyEnv       = flag.String("privateKeyEnv", "", "Name of an environment variable holding the sender's private key")
+	privateKeyIn        = flag.Bool("privateKeyStdin", false, "Read the sender's private key from the first line of stdin")
+	privateKeysEnv      = flag.String("privateKeysEnv", "", "Comma-separated environment variables holding the private keys of a sender pool for -batch, -stdin, daemon and sweep")
+	keystoreDir         = flag.String("keystoreDir", "", "Directory of keystore files forming a sender pool for -batch, -stdin, daemon and sweep, all decrypted with -password")
+	keystoreFlag        = flag.String("keystore", "", "Path to an encrypted keystore (UTC/JSON) file to sign with instead of -privateKey")
+	passwordFlag        = flag.String("password", "", "Keystore password (if empty, it will be prompted for)")
+	ledgerFlag          = flag.Bool("ledger", false, "Sign on a Ledger hardware wallet connected over USB")
+	trezorFlag          = flag.Bool("trezor", false, "Sign on a Trezor hardware wallet connected over USB")
+	clefFlag            = flag.String("clef", "", "Sign through go-ethereum's Clef at this IPC path or HTTP URL, e.g. ~/.clef/clef.ipc")
+	clefAccountFlag     = flag.String("clefAccount", "", "Account of -clef to sign with (default: the only account Clef lists)")
+	kmsKeyIDFlag        = flag.String("kmsKeyId", "", "AWS KMS key ID, ARN or alias of a secp256k1 signing key (uses the standard AWS credentials)")
+	pkcs11Module        = flag.String("pkcs11Module", "", "PKCS#11 module of an HSM holding the secp256k1 signing key, e.g. /usr/lib/softhsm/libsofthsm2.so (PIN from PKCS11_PIN or prompted)")
+	pkcs11Slot          = flag.Int("pkcs11Slot", -1, "PKCS#11 slot of the token holding the key (default: the first slot with a token)")
+	pkcs11Label         = flag.String("pkcs11KeyLabel", "", "Label (CKA_LABEL) of the PKCS#11 key pair to sign with")
+	vaultAddrFlag       = flag.String("vaultAddr", "", "HashiCorp Vault address (default: $VAULT_ADDR)")
+	vaultPathFlag       = flag.String("vaultPath", "", "Vault KV secret holding the private key, e.g. secret/data/payouts (token from $VAULT_TOKEN)")
+	vaultField          = flag.String("vaultField", "private_key", "Field of the Vault secret that holds the private key")
+	mnemonicFlag        = flag.String("mnemonic", "", "BIP-39 mnemonic to derive the signing key from")
+	mnemonicFile        = flag.String("mnemonicFile", "", "Path to a file containing the BIP-39 mnemonic")
+	hdPathFlag          = flag.String("hdPath", accounts.DefaultBaseDerivationPath.String(), "HD derivation path of the signing account")
+	mlockFlag           = flag.Bool("mlock", false, "Lock private keys held in memory so they are never swapped to disk (may need a higher RLIMIT_MEMLOCK)")
+	receiverFlag        = flag.String("receiver", "", "Receiver's address")
+	uriFlag             = flag.String("uri", "", "EIP-681 payment request to pay, e.g. ethereum:0x...@1?value=1e18, giving the receiver, chain, token and amount")
+	dataFlag            = flag.String("data", "", "Hex calldata to send along with the native coin, e.g. to call a contract without its ABI")
+	noChecksumFlag      = flag.Bool("noChecksum", false, "Accept all-lowercase or all-uppercase receiver and spender addresses, which carry no EIP-55 checksum")
+	rpcURLFlag          = flag.String("rpcURL", "", "RPC URL, or a comma-separated list of HTTP(S) URLs to fail over between")
+	networkFlag         = flag.String("network", "", "Named network (mainnet, sepolia, base, arbitrum, polygon, ...) providing the chain ID and a default public RPC URL")
+	nativeSymFlag       = flag.String("nativeSymbol", "", "Symbol of the native coin in amounts and fees (default: from the chain registry, e.g. POL on polygon)")
+	nativeDecFlag       = flag.Int("nativeDecimals", -1, "Decimals -amount and fees of the native coin are converted with (default: from the chain registry, 18 on unknown chains)")
+	devFlag             = flag.Bool("dev", false, "Local test chain mode (anvil, hardhat, devnet): defaults to http://127.0.0.1:8545 and the test mnemonic's accounts")
+	devAccount          = flag.Int("devAccount", 0, "Index of the test mnemonic account -dev signs with")
+	devFund             = flag.String("devFund", "", "With -dev, set the sender's balance to this many ETH with anvil_setBalance or hardhat_setBalance first")
+	qrFlag              = flag.Bool("qr", false, "Print the block explorer link of the sent transaction, or its hash, as a QR code")
+	explorerURL         = flag.String("explorerURL", "", "Block explorer link printed for transactions, with {hash} standing for the hash or a base URL such as https://gnosis.blockscout.com (default: the chain's explorer)")
+	proxyFlag           = flag.String("proxy", "", "Proxy for RPC connections: http://, https://, socks5:// or socks5h:// (default: $HTTPS_PROXY, $HTTP_PROXY)")
+	rpcHeaders          = headerFlag("header", `Header sent with every RPC request, as "Name: value" (repeatable)`)
+	rpcRetries          = flag.Int("rpcRetries", 3, "Number of times an RPC request failing with a connection error, timeout, 429 or 5xx is retried, with exponential backoff")
+	rpcTimeout          = flag.Duration("rpcTimeout", 30*time.Second, "Timeout of a single RPC request")
+	deadlineFlag        = flag.Duration("deadline", 0, "Give up on the whole operation, waiting for the receipt included, after this long (e.g. 10m; default none)")
+	chainIDFlag         = flag.Int64("chainID", 0, "Chain ID (if 0, it will be automatically obtained)")
+	tokenValueFlag      = flag.Float64("tokenValue", 0, "Transfer amount as a float (deprecated: use -amount, which is exact)")
+	amountFlag          = flag.String("amount", "", "Transfer amount in whole tokens or coins, as an exact decimal such as 123.456789012345678")
+	amountRawFlag       = flag.String("amountRaw", "", "ERC-20 transfer amount in base units, sent as is without looking up the token's decimals")
+	unitFlag            = flag.String("unit", "", "Unit of a native coin -amount: wei, gwei or ether (default: ether); a suffix such as -amount 1500gwei works too")
+	maxFlag             = flag.Bool("max", false, "Send the entire balance instead of -amount: all ERC-20 tokens, or all ETH minus the maximum gas cost")
+	tokenContract       = flag.String("tokenContract", "", "ERC-20 contract to transfer tokens from instead of the native coin, or its symbol in the -tokenList")
+	tokenListFlag       = flag.String("tokenList", "", "Token list JSON (tokenlists.org format) whose symbols -tokenContract accepts (default: eip1559-sender/tokenlist.json in the user's config directory), searched before the bundled list of common tokens")
+	blobFlag            = flag.String("blob", "", "Comma-separated files to attach as EIP-4844 blobs, one blob (up to 126976 bytes) each")
+	blobProofsFlag      = flag.String("blobProofs", "cell", "KZG proofs of the blobs: cell (EIP-7594, since Osaka) or blob for chains before Osaka")
+	maxBlobFeeFlag      = flag.String("maxFeePerBlobGas", "", "maxFeePerBlobGas in gwei (default: twice the current blob base fee)")
+	delegateFlag        = flag.String("delegate", "", "Send an EIP-7702 set-code transaction delegating the authority's code to this contract (0x0 clears it)")
+	authKeyEnvFlag      = flag.String("authKeyEnv", "", "Environment variable holding the key that signs the -delegate authorization (default: the sender's key)")
+	tokenIDFlag         = flag.String("tokenId", "", "ERC-1155 token id to transfer from -tokenContract")
+	tokenIDsFlag        = flag.String("tokenIds", "", "Comma-separated ERC-1155 token ids for a batch transfer from -tokenContract")
+	amountsFlag         = flag.String("amounts", "", "Comma-separated ERC-1155 amounts, one per token id")
+	ownerFlag           = flag.String("owner", "", "Move ERC-20 tokens owned by this address with transferFrom, the signer must be an approved spender")
+	decimalsFlag        = flag.Int("decimals", -1, "Decimals of -tokenContract, skipping its decimals() call, for tokens that lack or misreport it (e.g. 6)")
+	tokenABIFlag        = flag.String("tokenABI", "", "ABI JSON (or path to a file containing it) for non-standard tokens (default: the standard ERC-20 ABI)")
+	errorABIFlag        = flag.String("errorABI", "", "ABI JSON (or path to a file containing it) declaring the custom errors to decode revert reasons with, besides -tokenABI and the standard token errors")
+	ensRegistry         = flag.String("ensRegistry", defaultENSRegistry, "ENS registry used to resolve names given for -receiver and -tokenContract")
+	localeFlag          = flag.String("locale", "", "Locale for number formatting in the output, e.g. en-US, de-DE (default: no grouping)")
+	gasLimitFlag        = flag.Uint64("gasLimit", 0, "Gas limit to use instead of estimating it")
+	gasMarginFlag       = flag.Float64("gasMargin", 1, "Multiply estimated gas limits by this factor, e.g. 1.2, for calls that can cost more once mined than estimated")
+	gasFloorFlag        = flag.Uint64("gasFloor", 0, "Lowest gas limit to sign when the limit is estimated")
+	gasCeilingFlag      = flag.Uint64("gasCeiling", 0, "Highest gas limit to sign when the limit is estimated; an estimate above it is refused")
+	maxFeeFlag          = flag.String("maxFeePerGas", "", "maxFeePerGas in gwei (default: the base fee grown by -feeHeadroom plus the tip)")
+	maxTipFlag          = flag.String("maxPriorityFeePerGas", "", "maxPriorityFeePerGas in gwei (default: estimated from -feeBlocks and -feePercentile)")
+	priorityFlag        = flag.String("priority", "standard", "Fee level: slow, standard, fast or urgent, tuning -feePercentile and -feeHeadroom")
+	feeBlocksFlag       = flag.Uint64("feeBlocks", 20, "Number of recent blocks whose eth_feeHistory sets the tip (0 uses the node's suggestion)")
+	feePercentile       = flag.Float64("feePercentile", 50, "Percentile of the tips paid in each recent block to use as the tip")
+	feeSourceFlag       = flag.String("feeSource", "rpc", "Source of fee recommendations at -priority: rpc (the node's fee history), blocknative or polygongasstation")
+	feeHeadroom         = flag.Uint("feeHeadroom", 6, "Number of full blocks of base fee growth (12.5% each) the fee cap must survive")
+	fiatFlag            = flag.String("fiat", "", "Also show amounts and fees in this fiat currency, e.g. usd")
+	priceFeedFlag       = flag.String("priceFeed", "", "Chainlink feed of the native coin's price in -fiat (default: the chain's USD feed)")
+	priceURLFlag        = flag.String("priceURL", "", "HTTP API returning the native coin's price in -fiat as JSON, {fiat} and {symbol} filled in and a #path fragment selecting the price")
+	maxFeeEthFlag       = flag.String("maxFeeEth", "", "Refuse to sign if the worst-case fee (gasLimit * maxFeePerGas) exceeds this many of the native coin, e.g. ETH")
+	maxFeeGweiFlag      = flag.String("maxFeeGwei", "", "Refuse to sign if maxFeePerGas exceeds this many gwei")
+	waitFlag            = flag.Bool("wait", false, "Wait for the transaction receipt and exit with an error if it reverted")
+	confirmations       = flag.Uint64("confirmations", 1, "Number of confirmations to wait for with -wait")
+	webhookFlag         = flag.String("webhook", "", "POST a JSON payload to this URL when the transaction confirms, reverts or is replaced (implies -wait)")
+	webhookRetries      = flag.Int("webhookRetries", 5, "Number of times a failed -webhook delivery is retried, with exponential backoff")
+	privateFlag         = flag.Bool("private", false, "Send through a private relay (Flashbots Protect) with eth_sendPrivateTransaction instead of the public mempool")
+	relayURLFlag        = flag.String("relayURL", "", "Private relay for -private (default: Flashbots on mainnet and Sepolia)")
+	broadcastAll        = flag.Bool("broadcastAll", false, "With several -rpcURL endpoints, broadcast signed transactions to all of them at once")
+	otlpEndpointFlag    = flag.String("otlpEndpoint", "", "OTLP/gRPC collector to export OpenTelemetry traces of every send to, e.g. localhost:4317 (server and daemon)")
+	metricsFlag         = flag.String("metrics", "", "Serve Prometheus metrics on this address at /metrics, and the active configuration at /config, e.g. 127.0.0.1:9100 (server and daemon)")
+	rateLimitFlag       = flag.Float64("rateLimit", 0, "Send at most this many transactions per minute, 0 for no limit (server and daemon)")
+	allowToFlag         = flag.String("allowTo", "", "Only send to these comma-separated addresses or address book names (server and daemon, default: any receiver)")
+	policyFlag          = flag.String("policy", "", "YAML file of the receivers, tokens and per-transaction and daily amounts allowed (server and daemon)")
+	rescueFlag          = flag.Bool("rescue", false, "Re-send the account's transactions pending ahead of this one with fees raised by -bumpPercent before sending it")
+	bumpAfter           = flag.Duration("bumpAfter", 0, "Re-send with higher fees if the transaction is not mined within this time, e.g. 60s (implies -wait)")
+	bumpPercent         = flag.Int("bumpPercent", 15, "Percentage to raise the tip and fee cap by on every bump (nodes require at least 10)")
+	maxBumps            = flag.Int("maxBumps", 5, "Maximum number of fee bumps")
+	cancelNonce         = flag.Int64("cancelNonce", -1, "Cancel the pending transaction with this nonce by sending a 0-value self-transfer with higher fees")
+	replaceTx           = flag.String("replaceTx", "", "Hash of a pending transaction to re-send with the same payload and higher fees")
+	batchFlag           = flag.String("batch", "", "Send the transfers listed in a CSV (receiver,amount[,token]) or JSON file")
+	stdinFlag           = flag.Bool("stdin", false, "Read transfers from stdin as JSON objects {\"receiver\": \"0x...\", \"amount\": \"0.1\"} and print one JSON result per line")
+	concurrency         = flag.Int("concurrency", 1, "Number of -batch transfers signed and broadcast in parallel")
+	disperseFlag        = flag.Bool("disperse", false, "Send the -batch transfers as one Disperse contract call per token instead of one transaction per row")
+	splitFlag           = flag.String("split", "", "Divide -amount among the -batch receivers: equal, or weighted by the amount column")
+	resumeFlag          = flag.Bool("resume", false, "Continue a -batch interrupted earlier from its .progress file, skipping the rows already paid")
+	costReport          = flag.Bool("costReport", false, "After a -batch, report the fees paid against what the fee caps allowed, with the average base fee and tip (implies -wait)")
+	disperseAddr        = flag.String("disperseContract", defaultDisperse, "Disperse contract used by -disperse")
+	yesFlag             = flag.Bool("yes", false, "Send without asking for confirmation, for non-interactive use")
+	txTypeFlag          = flag.String("txType", "auto", "Transaction type: dynamic (EIP-1559), legacy, or auto to use legacy on chains without a base fee")
+	accessListFlag      = flag.Bool("accessList", false, "Attach the access list from eth_createAccessList to contract calls when it lowers the estimated gas")
+	dryRunFlag          = flag.Bool("dryRun", false, "Build and simulate the transaction with eth_call and eth_estimateGas without broadcasting it")
+	simulateFlag        = flag.String("simulate", "", "Simulate the transaction with this service and print its call trace before sending: tenderly")
+	tenderlyProj        = flag.String("tenderlyProject", "", "Tenderly account and project for -simulate tenderly, e.g. my-team/my-project")
+	tenderlyKey         = flag.String("tenderlyKey", "", "Tenderly access key for -simulate tenderly (default: $TENDERLY_ACCESS_KEY)")
+	traceFlag           = flag.Bool("trace", false, "Trace the transaction with debug_traceCall before sending and print its internal calls, or why gas estimation failed")
+	configFlag          = flag.String("config", "", "Config file with named profiles of flag values (default: ~/.eip1559-sender.yaml)")
+	profileFlag         = flag.String("profile", "", "Profile of the config file to use (default: the file's default profile)")
+	verboseFlag         = flag.Bool("v", false, "Verbose output, including debug messages")
+	quietFlag           = flag.Bool("quiet", false, "Only print warnings, errors and results such as the transaction hash")
+	logFormatFlag       = flag.String("logFormat", "text", "Output format: text, or json for one JSON object per line")
+	offlineFlag         = flag.Bool("offline", false, "Sign a native coin transfer without any RPC connection and print the raw transaction (requires -chainID, -nonce, -gasLimit and fees)")
+	nonceFlag           = flag.Int64("nonce", -1, "Nonce of the transaction, or of the first one of a batch, instead of asking the node (required with -offline)")
+	nonceSource         = flag.String("nonceSource", "pending", "Account state the nonce is read from: pending (after the transactions in the node's pool) or latest (after the last block)")
+	exportFlag          = flag.String("exportUnsigned", "", "Write the unsigned transaction to this JSON file for an external signer instead of sending it")
+	signToFlag          = flag.String("signTo", "", "Sign the transaction and write it to this file instead of broadcasting it, for a later -sendFrom")
+	expiresIn           = flag.Duration("expiresIn", defaultExpiresIn, "How long a -signTo file may be broadcast for before -sendFrom refuses it, 0 for no expiry")
+	sendFromFlag        = flag.String("sendFrom", "", "Broadcast the signed transaction of a -signTo file")
+	approvalAboveFlag   = flag.String("approvalAbove", "", "Stage transfers of more than this amount, in whole coins or tokens, for approval with \"approve -requestID\" instead of broadcasting them")
+	approversFlag       = flag.String("approvers", "", "Comma-separated addresses or address book names one of which must sign the approval of a staged transfer (default: anyone may approve)")
+	shutdownTimeoutFlag = flag.Duration("shutdownTimeout", 30*time.Second, "On Ctrl+C or SIGTERM, how long -batch and the daemon wait for the transactions being sent before exiting")
+	pendingDirFlag      = flag.String("pendingDir", "", "Directory where the daemon keeps the jobs still unconfirmed when it stops, to follow them on the next start (default: eip1559-sender/pending in the user's config directory)")
+	approvalDirFlag     = flag.String("approvalDir", "", "Directory of the transfers waiting for approval (default: eip1559-sender/approvals in the user's config directory)")
+	fromFlag            = flag.String("from", "", "Sender address for -exportUnsigned when the key is held by an external signer")
+	printAddress        = flag.Bool("printAddress", false, "Print the sender address derived from the key source and exit without sending")
+	sendAtFlag          = flag.String("sendAt", "", "Wait and send at this time, e.g. 2025-01-01T00:00Z (RFC 3339, the seconds may be left out)")
+	everyFlag           = flag.Duration("every", 0, "Send again at this interval, e.g. 24h, starting at -sendAt or now")
+	countFlag           = flag.Int("count", 0, "Number of sends with -every (default: until stopped)")
+	baseFeeBelow        = flag.String("sendWhenBaseFeeBelow", "", "Wait for a block whose base fee is below this many gwei before sending, e.g. 15gwei")
+	preflightFlag       = flag.String("preflight", "", "With full, print the sender's nonce, pending transactions, balances and latest outgoing transactions before sending")
+	preflightTxs        = flag.Int("preflightTxs", 5, "How many of the latest outgoing transactions -preflight full lists")
+	explorerAPIFlag     = flag.String("explorerAPI", "", "Etherscan-compatible API to list the latest outgoing transactions with, such as a Blockscout instance's https://.../api (default: Etherscan's given -explorerAPIKey, else the history)")
+	explorerAPIKey      = flag.String("explorerAPIKey", "", "API key of -explorerAPI or Etherscan (default: $ETHERSCAN_API_KEY)")
+	validForFlag        = flag.Duration("validFor", 0, "Cancel the transaction with a self-transfer at its nonce if it is not mined within this long, e.g. 10m (implies -wait)")
+	maxWaitFlag         = flag.Duration("maxWait", 0, "Send anyway once -sendWhenBaseFeeBelow has waited this long, e.g. 6h (default: wait indefinitely)")
+	forceFlag           = flag.Bool("force", false, "Send even though the receiver looks like a mistake: the zero address, the token contract itself, or a contract rejecting the native coin")
+	idempotencyKey      = flag.String("idempotencyKey", "", "Unique key of this payment: if the history holds a successful or pending transaction sent with it, nothing is sent again")
+	memoFlag            = flag.String("memo", "", "Note recorded with the transaction in the history, the JSON output and webhook payloads, e.g. an invoice number")
+	memoCalldata        = flag.Bool("memoCalldata", false, "Also put -memo on chain as the calldata of native transfers, where anyone can read it")
+	historyFlag         = flag.String("historyFile", "", "JSONL file every broadcast transaction and its outcome is recorded in (default: eip1559-sender/history.jsonl in the user's config directory, off to disable)")
+)
+
+// subcommands lists the commands available besides the default send
+var subcommands = []string{"service", "completion", "bench", "devnet", "approve", "allowance", "permit", "permit2", "transfer-auth", "wrap", "unwrap", "broadcast", "call", "deploy", "send", "cancel", "cancel-all", "sweep", "watch-forward", "balance", "estimate", "fees", "decode", "server", "daemon", "bundle", "dispatch", "request", "tui", "userop", "sign-typed", "sign-message", "verify-message", "verify", "addressbook", "history", "keygen"}
+
+// sharedFlags lists the root flags that subcommands sending a transaction accept as well
+var sharedFlags = []string{
+	"privateKey", "privateKeyEnv", "privateKeyStdin", "keystore", "password", "ledger", "trezor",
+	"kmsKeyId", "pkcs11Module", "pkcs11Slot", "pkcs11KeyLabel", "vaultAddr", "vaultPath", "vaultField", "clef", "clefAccount", "mnemonic", "mnemonicFile", "hdPath",
+	"rpcURL", "proxy", "header", "rpcRetries", "rpcTimeout", "deadline", "network", "nativeSymbol", "nativeDecimals", "explorerURL", "qr", "chainID", "tokenContract", "token", "tokenList", "tokenABI", "errorABI", "decimals", "ensRegistry", "noChecksum", "locale",
+	"gasLimit", "gasMargin", "gasFloor", "gasCeiling", "maxFeePerGas", "maxPriorityFeePerGas", "maxFeeEth", "maxFeeGwei", "fiat", "priceFeed", "priceURL",
+	"priority", "feeBlocks", "feePercentile", "feeHeadroom", "feeSource",
+	"wait", "confirmations", "webhook", "webhookRetries", "broadcastAll", "private", "relayURL", "bumpAfter", "bumpPercent", "maxBumps", "validFor", "rescue", "preflight", "preflightTxs", "explorerAPI", "explorerAPIKey", "nonce", "nonceSource", "txType", "accessList", "dryRun", "simulate", "tenderlyProject", "tenderlyKey", "trace", "exportUnsigned", "signTo", "expiresIn", "approvalAbove", "approvers", "approvalDir", "from", "yes", "y", "memo", "historyFile", "dev", "devAccount", "devFund",
+	"v", "quiet", "logFormat", "config", "profile",
+}
+
+// keyFlags lists the root flags selecting the signing key
+var keyFlags = []string{
+	"privateKey", "privateKeyEnv", "privateKeyStdin", "keystore", "password", "ledger", "trezor",
+	"kmsKeyId", "pkcs11Module", "pkcs11Slot", "pkcs11KeyLabel", "vaultAddr", "vaultPath", "vaultField", "clef", "clefAccount", "mnemonic", "mnemonicFile", "hdPath", "mlock",
+}
+
+// readFlags lists the root flags of commands that only read from a node
+var readFlags = []string{
+	"rpcURL", "proxy", "header", "rpcRetries", "rpcTimeout", "deadline", "network", "nativeSymbol", "nativeDecimals", "chainID", "ensRegistry", "noChecksum", "locale",
+	"v", "quiet", "logFormat", "config", "profile",
+}
+
+// addSharedFlags registers the shared root flags on fs, backed by the same variables. A flag fs
+// defines itself, such as the -validFor of the signing subcommands, takes precedence
+func addSharedFlags(fs *flag.FlagSet) {
+	for _, name := range sharedFlags {
+		if fs.Lookup(name) == nil {
+			addRootFlags(fs, name)
+		}
+	}
+}
+
+// addRootFlags registers the named root flags on fs, backed by the same variables
+func addRootFlags(fs *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		f := flag.CommandLine.Lookup(name)
+		fs.Var(f.Value, f.Name, f.Usage)
+	}
+}
+
+func init() {
+	flag.BoolVar(yesFlag, "y", false, "Shorthand for -yes")
+	flag.StringVar(tokenContract, "token", "", "Shorthand for -tokenContract, e.g. -token USDC")
+}
+
+func main() {
+	// quit wipes the keys itself, as os.Exit skips deferred calls
+	defer forgetKeys()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "service":
+			runService(os.Args[2:])
+			return
+		case "completion":
+			runCompletion(os.Args[2:])
+			return
+		case "bench":
+			runBench(os.Args[2:])
+			return
+		case "devnet":
+			runDevnet(os.Args[2:])
+			return
+		case "approve":
+			runApprove(os.Args[2:])
+			return
+		case "permit":
+			runPermit(os.Args[2:])
+			return
+		case "permit2":
+			runPermit2(os.Args[2:])
+			return
+		case "transfer-auth":
+			runTransferAuth(os.Args[2:])
+			return
+		case "wrap", "unwrap":
+			runWrap(os.Args[1], os.Args[2:])
+			return
+		case "broadcast":
+			runBroadcast(os.Args[2:])
+			return
+		case "call":
+			runCall(os.Args[2:])
+			return
+		case "deploy":
+			runDeploy(os.Args[2:])
+			return
+		case "send":
+			runSend(os.Args[2:])
+			return
+		case "cancel":
+			runCancel(os.Args[2:])
+			return
+		case "cancel-all":
+			runCancelAll(os.Args[2:])
+			return
+		case "sweep":
+			runSweep(os.Args[2:])
+			return
+		case "watch-forward":
+			runWatchForward(os.Args[2:])
+			return
+		case "balance":
+			runBalance(os.Args[2:])
+			return
+		case "allowance":
+			runAllowance(os.Args[2:])
+			return
+		case "estimate":
+			runEstimate(os.Args[2:])
+			return
+		case "fees":
+			runFees(os.Args[2:])
+			return
+		case "decode":
+			runDecode(os.Args[2:])
+			return
+		case "server":
+			runServer(os.Args[2:])
+			return
+		case "daemon":
+			runDaemon(os.Args[2:])
+			return
+		case "bundle":
+			runBundle(os.Args[2:])
+			return
+		case "dispatch":
+			runDispatch(os.Args[2:])
+			return
+		case "request":
+			runRequest(os.Args[2:])
+			return
+		case "tui":
+			runTUI(os.Args[2:])
+			return
+		case "userop":
+			runUserOp(os.Args[2:])
+			return
+		case "sign-typed":
+			runSignTyped(os.Args[2:])
+			return
+		case "sign-message":
+			runSignMessage(os.Args[2:])
+			return
+		case "verify-message":
+			runVerifyMessage(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "addressbook":
+			runAddressBook(os.Args[2:])
+			return
+		case "history":
+			runHistory(os.Args[2:])
+			return
+		case "keygen":
+			runKeygen(os.Args[2:])
+			return
+		case "__complete":
+			// hidden entry point used by the generated completion scripts
+			for _, candidate := range completeArgs(os.Args[2:]) {
+				fmt.Println(candidate)
+			}
+			return
+		}
+	}
+
+	// Add usage information
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s service install|uninstall|status [options] [-- arguments...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s completion bash|zsh|fish|powershell\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s bench -rpcURL https://a,https://b [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s devnet up [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s approve -tokenContract 0x... -spender 0x... -amount 100|-unlimited [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s approve -requestID 0123456789ab -rpcURL https://... [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s permit sign|submit [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s permit2 sign|submit [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s transfer-auth sign|submit [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s wrap|unwrap -amount 1.0 [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s broadcast -rawTx 0x02f8... [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s call -to 0x... -method \"name(types)\" -args ... [-send] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s deploy -bytecode Token.bin [-abi Token.abi -args ...] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s send eth|erc20 -receiver 0x... -amount 0.1 [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s send -stdin -yes [options] < jobs.jsonl\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s cancel -nonce 7 [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s cancel-all [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s sweep -to 0x... -privateKeysEnv KEY_1,KEY_2 [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s watch-forward -to 0x... -yes [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s balance [-address 0x...] [-tokenContract 0x...] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s allowance -tokenContract 0x... -spender 0x... [-owner 0x...] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s estimate [-receiver 0x... -amount 0.1] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s fees [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s fees history [-blocks 1000] [-out csv|json] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s decode -rawTx 0x02f8...|-data 0xa9059cbb... [-abi Token.abi]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s server -rpcURL https://... -privateKeyEnv SENDER_KEY [-listen 127.0.0.1:50051] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s daemon -queue /var/spool/payouts|redis://...?list=name|nats://...?subject=name [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s bundle -txs bundle.json [-blocks 5] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s dispatch -jobs jobs.json [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s request -receiver 0x... -amount 0.1 [-tokenContract 0x...] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s tui -rpcURL https://... [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s userop -account 0x... -bundlerURL https://... -receiver 0x... -amount 0.1 [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s sign-typed -file data.json [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s sign-message|verify-message -message \"...\" [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s verify -rawTx 0x02f8...|tx.json -expectedFrom 0x... -expectedChainID 1\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s addressbook add|remove|list [name] [address]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s history [-address 0x...] [-status success] [-since 24h] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s keygen [-vanity 0xdead] [-out ~/.ethereum/keystore [-writeProfile name]] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\nOptions:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nExample:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -privateKeyEnv SENDER_KEY -receiver 0x... -rpcURL https://... -chainID 1 -amount 0.1\n", os.Args[0])
+	}
+
+	flag.Parse()
+	configure(flag.CommandLine)
+
+	if *printAddress {
+		signer, err := loadSigner()
+		if err != nil {
+			exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+		}
+		fmt.Println(signer.Address().Hex())
+		return
+	}
+
+	runTransfer(flag.Usage)
+}
+
+// runTransfer sends what the root flags describe: a transfer, a batch, or a cancellation or
+// replacement of a pending transaction. usage is printed when required flags are missing
+func runTransfer(usage func()) {
+	if *sendFromFlag != "" {
+		if *rpcURLFlag == "" {
+			fmt.Println("Error: Missing required parameters")
+			usage()
+			exit(exitInvalid)
+		}
+		sendSignedFile(*sendFromFlag)
+		return
+	}
+	if *stdinFlag {
+		if *rpcURLFlag == "" {
+			fmt.Println("Error: Missing required parameters")
+			usage()
+			exit(exitInvalid)
+		}
+		if *validForFlag > 0 {
+			exitf(exitInvalid, "-validFor cannot be combined with -stdin")
+		}
+		runStdinJobs()
+		return
+	}
+
+	// Check if required parameters are provided
+	replacing := *cancelNonce >= 0 || *replaceTx != ""
+	erc1155 := *tokenIDFlag != "" || *tokenIDsFlag != ""
+	if (*rpcURLFlag == "" && !*offlineFlag) || (!replacing && *batchFlag == "" && (*receiverFlag == "" || (*amountFlag == "" && *amountRawFlag == "" && !*maxFlag && !erc1155 && *blobFlag == "" && *delegateFlag == "" && *dataFlag == ""))) ||
+		(erc1155 && (*tokenContract == "" || *amountsFlag == "")) {
+		fmt.Println("Error: Missing required parameters")
+		usage()
+		exit(exitInvalid)
+	}
+
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+
+	if *dataFlag != "" && (*batchFlag != "" || replacing || *tokenContract != "" || erc1155 || *maxFlag) {
+		exitf(exitInvalid, "-data only applies to native coin transfers, not to -batch, -cancelNonce, -replaceTx, -tokenContract, ERC-1155 transfers or -max")
+	}
+	if *memoCalldata && ((*memoFlag == "" && *batchFlag == "") || *dataFlag != "" || replacing || *tokenContract != "" || erc1155 || *maxFlag || *blobFlag != "" || *delegateFlag != "") {
+		exitf(exitInvalid, "-memoCalldata needs -memo or a -batch with memos and only applies to native coin transfers, not to -data, -cancelNonce, -replaceTx, -tokenContract, ERC-1155 transfers, -max, -blob or -delegate")
+	}
+	if (*disperseFlag || *resumeFlag || *splitFlag != "" || *costReport) && *batchFlag == "" {
+		exitf(exitInvalid, "-disperse, -resume, -split and -costReport only apply to -batch")
+	}
+	if *splitFlag != "" && *splitFlag != "equal" && *splitFlag != "weighted" {
+		exitf(exitInvalid, "Invalid -split %q, expected equal or weighted", *splitFlag)
+	}
+	if *splitFlag != "" && *amountFlag == "" {
+		exitf(exitInvalid, "-split divides the total given with -amount")
+	}
+	schedule, err := parseSchedule()
+	if err != nil {
+		exitf(exitInvalid, "Invalid schedule: %v", err)
+	}
+	if schedule != nil && (*offlineFlag || *exportFlag != "" || *signToFlag != "" || replacing) {
+		exitf(exitInvalid, "-sendAt and -every cannot be combined with -offline, -exportUnsigned, -signTo, -cancelNonce or -replaceTx")
+	}
+	if *signToFlag != "" && *batchFlag != "" {
+		exitf(exitInvalid, "-signTo cannot be combined with -batch")
+	}
+	if *validForFlag > 0 && (*batchFlag != "" || *offlineFlag || *exportFlag != "" || *signToFlag != "" || *blobFlag != "") {
+		// a blob transaction can only be replaced by another one carrying blobs
+		exitf(exitInvalid, "-validFor cannot be combined with -batch, -offline, -exportUnsigned, -signTo or -blob")
+	}
+	baseFeeLimit, err := parseBaseFeeBelow()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if baseFeeLimit != nil && (*offlineFlag || *exportFlag != "" || *signToFlag != "" || replacing) {
+		exitf(exitInvalid, "-sendWhenBaseFeeBelow cannot be combined with -offline, -exportUnsigned, -signTo, -cancelNonce or -replaceTx")
+	}
+	if *idempotencyKey != "" && (schedule != nil || *batchFlag != "" || *offlineFlag || *exportFlag != "" || *signToFlag != "" || replacing) {
+		// the key stands for a single payment, sent by this process
+		exitf(exitInvalid, "-idempotencyKey cannot be combined with -sendAt, -every, -batch, -offline, -exportUnsigned, -signTo, -cancelNonce or -replaceTx")
+	}
+	if poolKeysGiven() && *batchFlag == "" {
+		exitf(exitInvalid, "-privateKeysEnv and -keystoreDir load a pool of senders, which only -batch, -stdin, daemon and sweep use")
+	}
+	if *offlineFlag {
+		if *exportFlag != "" {
+			exitf(exitInvalid, "-exportUnsigned cannot be combined with -offline")
+		}
+		signOffline(nf)
+		return
+	}
+
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	if *idempotencyKey != "" {
+		existing, err := findIdempotent(client, chainID, *idempotencyKey)
+		if err != nil {
+			fatalf("Failed to check idempotency key: %v", err)
+		}
+		if existing != nil {
+			resultf([]interface{}{"hash", existing.Hash, "status", existing.Status, "idempotencyKey", *idempotencyKey},
+				"Already sent with idempotency key %s: %s (%s), not sending again", *idempotencyKey, existing.Hash, existing.Status)
+			return
+		}
+	}
+
+	pool, err := loadSenderPool()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	send := func() {
+		// the account may have sent from elsewhere since the previous scheduled send
+		pool.resyncNonces()
+		if baseFeeLimit != nil {
+			waitForBaseFee(client, baseFeeLimit)
+		}
+		sendTransfer(client, pool, chainID, nf)
+	}
+	if schedule != nil {
+		schedule.run(send)
+		return
+	}
+	send()
+}
+
+// sendTransfer builds and sends the transfer, batch, cancellation or replacement the root
+// flags describe. Only a batch makes use of more than the first account of pool
+func sendTransfer(client *ethclient.Client, pool *senderPool, chainID *big.Int, nf numberFormat) {
+	replacing := *cancelNonce >= 0 || *replaceTx != ""
+	erc1155 := *tokenIDFlag != "" || *tokenIDsFlag != ""
+	if *batchFlag != "" {
+		if *exportFlag != "" {
+			exitf(exitInvalid, "-exportUnsigned cannot be combined with -batch")
+		}
+		runBatch(client, pool, chainID, nf)
+		return
+	}
+	signer := pool.accounts[0].signer
+	if replacing {
+		if *nonceFlag >= 0 {
+			exitf(exitInvalid, "-nonce cannot be combined with -cancelNonce or -replaceTx, which take the nonce of the transaction they replace")
+		}
+		tx, err := buildReplacement(client, signer.Address(), chainID, nf)
+		if err != nil {
+			fatalf("Failed to build replacement transaction: %v", err)
+		}
+		sendAndFollow(client, signer, chainID, tx, nf)
+		return
+	}
+
+	// get sender's address
+	fromAddress := signer.Address()
+	toAddress, err := parseAddress(*receiverFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid receiver: %v", err)
+	}
+	infof("Sender's address: %s", fromAddress.Hex())
+	infof("Receiver address: %s", toAddress.Hex())
+	// -data and -delegate call the receiver on purpose
+	native := *tokenContract == "" && *dataFlag == "" && *delegateFlag == "" && *blobFlag == ""
+	checkReceiver(receiverProblems(client, chainID, fromAddress, toAddress, *tokenContract, native))
+	if *memoCalldata {
+		if err := checkMemoReceiver(client, toAddress); err != nil {
+			exitf(exitInvalid, "Cannot use -memoCalldata: %v", err)
+		}
+	}
+
+	// set transfer amount
+	txTo, txValue, txData := toAddress, new(big.Int), []byte(nil)
+	if *ownerFlag != "" && (erc1155 || *tokenContract == "") {
+		exitf(exitInvalid, "-owner requires an ERC-20 -tokenContract")
+	}
+	if *maxFlag && (*amountFlag != "" || *amountRawFlag != "" || erc1155 || *ownerFlag != "") {
+		exitf(exitInvalid, "-max cannot be combined with -amount, -amountRaw, ERC-1155 transfers or -owner")
+	}
+	if *maxFlag && (*blobFlag != "" || *delegateFlag != "") {
+		exitf(exitInvalid, "-max cannot be combined with -blob or -delegate")
+	}
+	if *blobFlag != "" && *delegateFlag != "" {
+		exitf(exitInvalid, "-blob and -delegate are mutually exclusive")
+	}
+	if *maxFlag && *tokenContract == "" && *bumpAfter > 0 {
+		// a bumped fee cap would no longer be covered by the balance left after the sweep
+		exitf(exitInvalid, "-max cannot be combined with -bumpAfter for ETH transfers")
+	}
+	if erc1155 {
+		if *tokenIDFlag != "" && *tokenIDsFlag != "" {
+			exitf(exitInvalid, "-tokenId and -tokenIds are mutually exclusive")
+		}
+		if !common.IsHexAddress(*tokenContract) {
+			exitf(exitInvalid, "Invalid token contract address %q", *tokenContract)
+		}
+		txTo = common.HexToAddress(*tokenContract)
+		if txData, err = erc1155TransferData(client, txTo, fromAddress, toAddress, *tokenIDFlag+*tokenIDsFlag, *amountsFlag, nf); err != nil {
+			fatalf("Failed to build ERC-1155 transfer: %v", err)
+		}
+	} else if *tokenContract != "" {
+		token, err := loadToken(client, *tokenContract, *tokenABIFlag)
+		if err != nil {
+			fatalf("Failed to load token contract: %v", err)
+		}
+		amount := *amountFlag
+		if *maxFlag {
+			if amount, err = token.balanceAmount(fromAddress); err != nil {
+				exitf(exitRPC, "Failed to get token balance: %v", err)
+			}
+		}
+		if *ownerFlag != "" {
+			if !common.IsHexAddress(*ownerFlag) {
+				exitf(exitInvalid, "Invalid owner address %q", *ownerFlag)
+			}
+			txData, err = token.transferFromData(common.HexToAddress(*ownerFlag), fromAddress, toAddress, amount, nf)
+		} else {
+			txData, err = token.transferData(fromAddress, toAddress, amount, nf)
+		}
+		if err != nil {
+			fatalf("Failed to build token transfer: %v", err)
+		}
+		txTo = token.address
+	} else if *maxFlag {
+		tx := sweepTx(client, fromAddress, chainID, toAddress, nf)
+		sendAndFollow(client, signer, chainID, tx, nf)
+		return
+	} else {
+		txValue = nativeAmount(chainID, nf)
+		if txData, err = callData(); err != nil {
+			exitf(exitInvalid, "Invalid -data: %v", err)
+		}
+	}
+
+	tx := newTransaction(client, fromAddress, chainID, &txTo, txValue, txData, nf)
+	if *blobFlag != "" {
+		tx = blobTx(client, fromAddress, chainID, tx, nf)
+	}
+	if *delegateFlag != "" {
+		tx = setCodeTx(client, signer, chainID, tx, nf)
+	}
+	sendAndFollow(client, signer, chainID, tx, nf)
+}
+
+// nativeAmount converts -amount in -unit to Wei, zero if no amount is given
+func nativeAmount(chainID *big.Int, nf numberFormat) *big.Int {
+	if *amountFlag == "" {
+		return new(big.Int)
+	}
+	chain := lookupChain(chainID)
+	value, err := parseUnits(*amountFlag, unitDecimals(chain))
+	if err != nil {
+		exitf(exitInvalid, "Invalid -amount: %v", err)
+	}
+	unit := chain.symbol
+	if *unitFlag == "wei" || *unitFlag == "gwei" {
+		unit = *unitFlag
+	}
+	infof("Transfer amount: %s %s (equivalent to %s Wei)", nf.format(*amountFlag), unit, nf.format(value.String()))
+	return value
+}
+
+// callData decodes the raw calldata of -data, or returns -memo with -memoCalldata, logging its size
+func callData() ([]byte, error) {
+	if *memoCalldata {
+		infof("Calldata: the memo, %d bytes", len(*memoFlag))
+		return []byte(*memoFlag), nil
+	}
+	if *dataFlag == "" {
+		return nil, nil
+	}
+	data, err := hexutil.Decode(*dataFlag)
+	if err != nil {
+		return nil, err
+	}
+	infof("Calldata: %d bytes", len(data))
+	return data, nil
+}
+
+// dialRPC connects to -rpcURL and resolves the chain ID, from -chainID or the node
+func dialRPC() (*ethclient.Client, *big.Int) {
+	var chainID *big.Int
+	if *chainIDFlag != 0 {
+		chainID = big.NewInt(*chainIDFlag)
+	}
+	client, chainID, err := dialEndpoint(*rpcURLFlag, chainID)
+	if err != nil {
+		exitf(exitRPC, "Failed to connect to the RPC URL: %v", err)
+	}
+	infof("Connected to the RPC URL %s", *rpcURLFlag)
+	if *chainIDFlag != 0 {
+		infof("Using specified chain ID: %d", chainID)
+	} else {
+		infof("Automatically obtained chain ID: %d", chainID)
+	}
+	// cross-check the chain the RPC reports against -network and -chainID: a transaction signed
+	// for another chain is rejected by the node, or lands on the wrong network if URLs got swapped
+	if *networkFlag != "" || *chainIDFlag != 0 {
+		reported, err := client.ChainID(opCtx)
+		if err != nil {
+			exitf(exitRPC, "Failed to get chain ID: %v", err)
+		}
+		if err := checkNetwork(reported); err != nil {
+			exitf(exitInvalid, "Network mismatch: %v", err)
+		}
+		if reported.Cmp(chainID) != 0 {
+			exitf(exitInvalid, "Chain ID mismatch: -chainID is %s, but the RPC serves chain ID %s (%s)", chainID, reported, lookupChain(reported).name)
+		}
+	}
+	if *devFlag {
+		if err := checkDevChain(client, chainID); err != nil {
+			exitf(exitInvalid, "Refusing -dev: %v", err)
+		}
+	}
+
+	return client, chainID
+}
+
+// dialEndpoint connects to rawURLs, a comma-separated list of endpoints with failover, through
+// the proxy, headers and retries of the flags. A nil chainID is asked from the node
+func dialEndpoint(rawURLs string, chainID *big.Int) (*ethclient.Client, *big.Int, error) {
+	transport, err := rpcTransport()
+	if err != nil {
+		return nil, nil, err
+	}
+	// a list of endpoints is served by the one in front of the failover transport
+	rawURL := rawURLs
+	if urls := strings.Split(rawURLs, ","); len(urls) > 1 {
+		failover, err := newFailoverTransport(opCtx, urls, transport, *broadcastAll)
+		if err != nil {
+			return nil, nil, err
+		}
+		transport, rawURL = failover, failover.primary()
+	}
+	transport = retryTransport{next: transport, retries: *rpcRetries, timeout: *rpcTimeout}
+	options := []rpc.ClientOption{rpc.WithHTTPClient(&http.Client{Transport: transport})}
+	if strings.HasPrefix(rawURL, "ws://") || strings.HasPrefix(rawURL, "wss://") {
+		wsOptions, err := websocketOptions()
+		if err != nil {
+			return nil, nil, err
+		}
+		options = append(options, wsOptions...)
+	}
+	conn, err := sender.Dial(opCtx, rawURL, chainID, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn.Client, conn.ChainID, nil
+}
+
+// newTransaction builds an unsigned EIP-1559 transaction, or a legacy one where needed, with the
+// next nonce, suggested fees and estimated gas. A nil to deploys data as a contract
+func newTransaction(client *ethclient.Client, from common.Address, chainID *big.Int, to *common.Address, value *big.Int, data []byte, nf numberFormat) *types.Transaction {
+	// get nonce
+	nonce, err := nonces.Reserve(opCtx, client, from)
+	if err != nil {
+		exitf(exitRPC, "Failed to get nonce: %v", err)
+	}
+	infof("nonce: %d", nonce)
+
+	// get base fee
+	header, err := client.HeaderByNumber(opCtx, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get header: %v", err)
+	}
+	legacy, err := legacyTx(header)
+	if err != nil {
+		exitf(exitInvalid, "Invalid transaction type: %v", err)
+	}
+	baseFee := header.BaseFee
+	if legacy {
+		baseFee = nil
+		infof("Building a legacy transaction")
+	} else {
+		infof("Base fee: %s", nf.format(baseFee.String()))
+	}
+
+	// get maxPriorityFeePerGas and maxFeePerGas (from the fee history unless overridden)
+	maxPriorityFeePerGas, maxFeePerGas, err := suggestFees(opCtx, client, baseFee)
+	if err != nil {
+		exitf(exitRPC, "Failed to determine fees: %v", err)
+	}
+	if legacy {
+		infof("Gas price: %s", nf.format(maxFeePerGas.String()))
+	} else if *maxTipFlag == "" {
+		infof("Suggested maxPriorityFeePerGas: %s", nf.format(maxPriorityFeePerGas.String()))
+	} else {
+		infof("maxPriorityFeePerGas: %s", nf.format(maxPriorityFeePerGas.String()))
+	}
+	if !legacy {
+		infof("Max fee per gas: %s", nf.format(maxFeePerGas.String()))
+	}
+	if !legacy && *maxTipFlag == "" && *maxFeeFlag == "" {
+		infof("Priority %s: %s", *priorityFlag, inclusionEstimate(chainID))
+	}
+
+	fundDevAccount(client, from)
+
+	// check the value first, estimating gas with an unaffordable value fails with a vaguer error
+	balance, err := client.PendingBalanceAt(opCtx, from)
+	if err != nil {
+		exitf(exitRPC, "Failed to get balance: %v", err)
+	}
+	debugf("Balance: %s Wei", nf.format(balance.String()))
+	if err := sender.CheckFunds(balance, value, 0, maxFeePerGas); err != nil {
+		exitf(exitFunds, "Insufficient funds: %v", err)
+	}
+
+	// estimate gas limit
+	msg := ethereum.CallMsg{
+		From:  from,
+		To:    to,
+		Value: value,
+		Data:  data,
+	}
+	gasLimit := *gasLimitFlag
+	if gasLimit == 0 {
+		gasLimit, err = client.EstimateGas(opCtx, msg)
+		if err != nil {
+			if *traceFlag {
+				traceCall(client, msg)
+			}
+			fatalf("Failed to estimate gas: %s", describeCallError(err))
+		}
+		infof("Estimated gas limit: %s", nf.format(fmt.Sprint(gasLimit)))
+	} else {
+		infof("Gas limit: %s", nf.format(fmt.Sprint(gasLimit)))
+	}
+
+	// pre-declare the storage the call touches where that costs less than accessing it cold
+	var accessList types.AccessList
+	if *accessListFlag && len(data) > 0 {
+		if legacy {
+			warnf("-accessList is ignored for legacy transactions")
+		} else if *gasLimitFlag == 0 {
+			accessList, gasLimit = accessListFor(client, msg, gasLimit, nf)
+		} else if estimated, err := client.EstimateGas(opCtx, msg); err != nil {
+			warnf("Failed to estimate gas, sending without an access list: %s", describeCallError(err))
+		} else {
+			// -gasLimit is kept as given
+			accessList, _ = accessListFor(client, msg, estimated, nf)
+		}
+	}
+	if *gasLimitFlag == 0 {
+		estimated := gasLimit
+		if gasLimit, err = withGasMargin(estimated); err != nil {
+			fatalf("Invalid gas limit: %v", err)
+		}
+		if gasLimit != estimated {
+			infof("Gas limit: %s after -gasMargin, -gasFloor and -gasCeiling", nf.format(fmt.Sprint(gasLimit)))
+		}
+	}
+	if err := sender.CheckFunds(balance, value, gasLimit, maxFeePerGas); err != nil {
+		exitf(exitFunds, "Insufficient funds: %v", err)
+	}
+
+	// create EIP-1559 transaction
+	return sender.MakeTx(legacy, &types.DynamicFeeTx{
+		ChainID:    chainID,
+		Nonce:      nonce,
+		GasTipCap:  maxPriorityFeePerGas,
+		GasFeeCap:  maxFeePerGas,
+		Gas:        gasLimit,
+		To:         to,
+		Value:      value,
+		Data:       data,
+		AccessList: accessList,
+	})
+}
+
+// sweepTx builds a transaction sending the whole ETH balance of from, less the maximum fee it can be charged
+func sweepTx(client *ethclient.Client, from common.Address, chainID *big.Int, to common.Address, nf numberFormat) *types.Transaction {
+	balance, err := client.PendingBalanceAt(opCtx, from)
+	if err != nil {
+		exitf(exitRPC, "Failed to get balance: %v", err)
+	}
+	infof("Balance: %s Wei", nf.format(balance.String()))
+
+	// estimate with an empty value, the full balance would leave nothing for gas
+	tx := newTransaction(client, from, chainID, &to, new(big.Int), nil, nf)
+	maxCost := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasFeeCap())
+	l1Fee, err := l1FeeReserve(client, tx)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	maxCost.Add(maxCost, l1Fee)
+	value := new(big.Int).Sub(balance, maxCost)
+	if value.Sign() <= 0 {
+		exitf(exitFunds, "Balance of %s Wei does not cover the maximum gas cost of %s Wei", balance, maxCost)
+	}
+	infof("Transfer amount: entire balance less %s Wei reserved for gas (%s Wei)", nf.format(maxCost.String()), nf.format(value.String()))
+	return sender.MakeTx(tx.Type() == types.LegacyTxType, &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     tx.Nonce(),
+		GasTipCap: tx.GasTipCap(),
+		GasFeeCap: tx.GasFeeCap(),
+		Gas:       tx.Gas(),
+		To:        &to,
+		Value:     value,
+	})
+}
+
+// sendAndFollow signs and broadcasts tx, then waits for it and bumps its fees as requested by the flags
+func sendAndFollow(client *ethclient.Client, signer sender.Signer, chainID *big.Int, tx *types.Transaction, nf numberFormat) {
+	if err := checkFeeCap(client, chainID, tx); err != nil {
+		fatalf("Fee cap exceeded: %v", err)
+	}
+	if *cancelNonce < 0 && *replaceTx == "" && *exportFlag == "" {
+		var tokens []string
+		if *tokenContract != "" && *tokenIDFlag == "" && *tokenIDsFlag == "" {
+			tokens = []string{*tokenContract}
+		}
+		runPreflight(client, chainID, signer.Address(), tokens, nf)
+		checkPendingAhead(client, signer, chainID, tx.Nonce())
+	}
+	if *simulateFlag != "" {
+		tenderlySimulate(chainID, signer.Address(), tx)
+	}
+	if *traceFlag {
+		traceTx(client, signer.Address(), tx)
+	}
+	if *dryRunFlag {
+		simulateTx(client, signer.Address(), tx, nf)
+		return
+	}
+	if *exportFlag != "" {
+		if err := exportUnsigned(*exportFlag, chainID, signer.Address(), tx); err != nil {
+			fatalf("Failed to export transaction: %v", err)
+		}
+		resultf([]interface{}{"file", *exportFlag, "signingHash", types.LatestSignerForChainID(chainID).Hash(tx).Hex()}, "Unsigned transaction written to %s", *exportFlag)
+		return
+	}
+	if err := confirmTx(client, chainID, signer.Address(), tx, nf); err != nil {
+		fatalf("Not sending: %v", err)
+	}
+
+	// sign transaction
+	signedTx, err := signer.SignTx(tx, chainID)
+	if err != nil {
+		fatalf("Failed to sign transaction: %v", err)
+	}
+
+	if staged, err := stageIfLarge(client, chainID, signer.Address(), signedTx, nf); err != nil {
+		fatalf("Failed to stage the transfer for approval: %v", err)
+	} else if staged {
+		return
+	}
+	if *signToFlag != "" {
+		if err := writeSignedTx(*signToFlag, chainID, signer.Address(), signedTx); err != nil {
+			fatalf("Failed to write signed transaction: %v", err)
+		}
+		resultf([]interface{}{"file", *signToFlag, "hash", signedTx.Hash().Hex()}, "Signed transaction %s written to %s, broadcast it with -sendFrom", signedTx.Hash().Hex(), *signToFlag)
+		return
+	}
+
+	// the blobs would fill the screen, only show the transaction itself
+	if raw, err := signedTx.WithoutBlobTxSidecar().MarshalBinary(); err == nil {
+		debugf("Signed transaction: %s", hexutil.Encode(raw))
+	}
+	broadcastAndFollow(client, signer, chainID, signedTx, nf)
+}
+
+// broadcastAndFollow sends the signed tx, then waits for it and bumps its fees as requested by
+// the flags. Fee bumps need the signer, it may be nil without -bumpAfter
+func broadcastAndFollow(client *ethclient.Client, signer sender.Signer, chainID *big.Int, signedTx *types.Transaction, nf numberFormat) {
+	// send transaction
+	err := sendTransaction(opCtx, client, signedTx)
+	if err != nil {
+		exitf(broadcastExitCode(err), "Failed to send transaction: %v", err)
+	}
+
+	resultf(withMemoAttr([]interface{}{"hash", signedTx.Hash().Hex()}), "Transaction sent successfully! Transaction hash: %s", signedTx.Hash().Hex())
+	url := explorerTxURL(chainID, signedTx.Hash().Hex())
+	if url != "" {
+		infof("Explorer: %s", url)
+	}
+	if *qrFlag {
+		link := url
+		if link == "" {
+			link = signedTx.Hash().Hex()
+		}
+		printQR(link)
+	}
+	if !*waitFlag && *bumpAfter == 0 && *webhookFlag == "" && *validForFlag == 0 {
+		if url == "" {
+			infof("Please check the transaction status on the blockchain explorer")
+		}
+		return
+	}
+
+	// a transaction signed elsewhere comes without a signer
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), signedTx)
+	if err != nil {
+		fatalf("Failed to recover the sender: %v", err)
+	}
+	// keep bumping the fees until one version of the transaction is mined, or cancel it once
+	// -validFor has passed
+	tracker := sender.NewTracker(client, from, signedTx)
+	var cancel *types.Transaction
+	if *bumpAfter > 0 || *validForFlag > 0 {
+		var receipt *types.Receipt
+		receipt, cancel, err = waitMined(client, signer, chainID, tracker, signedTx)
+		if hashes := tracker.Hashes(); len(hashes) > 1 {
+			infof("Sent transactions:")
+			for _, hash := range hashes {
+				infof("  %s", hash.Hex())
+			}
+		}
+		if err != nil {
+			fatalf("Failed to get transaction receipt: %v", err)
+		}
+		minedHash := receipt.TxHash
+		resultf(withMemoAttr([]interface{}{"hash", minedHash.Hex()}), "Mined transaction: %s", minedHash.Hex())
+		if url := explorerTxURL(chainID, minedHash.Hex()); url != "" && minedHash != signedTx.Hash() {
+			infof("Explorer: %s", url)
+			if *qrFlag {
+				printQR(url)
+			}
+		}
+	}
+
+	// wait for the receipt of whichever version is mined, which a reorg may change
+	infof("Waiting for %d confirmation(s)...", *confirmations)
+	receipt, err := tracker.Wait(opCtx, *confirmations, pollInterval)
+	if err != nil {
+		exitf(exitRPC, "Failed to get transaction receipt: %v", err)
+	}
+	infof("Block number: %s", nf.format(receipt.BlockNumber.String()))
+	infof("Gas used: %s", nf.format(fmt.Sprint(receipt.GasUsed)))
+	minedHash := receipt.TxHash
+	minedTx := signedTx
+	if minedHash != signedTx.Hash() {
+		if tx, _, err := client.TransactionByHash(opCtx, minedHash); err == nil {
+			minedTx = tx
+		}
+	}
+	describeReceipt(client, from, minedTx, receipt, nf)
+	recordReceipt(client, receipt)
+	event := receiptEvent(chainID, from, signedTx.Nonce(), receipt)
+	attrs := []interface{}{"hash", minedHash.Hex(), "block", receipt.BlockNumber.Uint64(), "gasUsed", receipt.GasUsed}
+	if cancel != nil && minedHash == cancel.Hash() {
+		event.Status = statusExpired
+		notifyWebhook(event)
+		resultf(append(attrs, "status", statusExpired), "Status: expired, nonce %d was cancelled after %s and the transaction will never be mined", signedTx.Nonce(), *validForFlag)
+		exit(exitExpired)
+	}
+	notifyWebhook(event)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		resultf(append(attrs, "status", "reverted"), "Status: reverted")
+		exit(exitReverted)
+	}
+	resultf(append(attrs, "status", "success"), "Status: success")
+	if signedTx.To() == nil {
+		resultf([]interface{}{"contract", receipt.ContractAddress.Hex()}, "Contract address: %s", receipt.ContractAddress.Hex())
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// memoCtx is the context key of the memo of a send
+type memoCtx struct{}
+
+// txMemos maps the hashes of the transactions broadcast with a memo to it, for the webhook
+// payloads about them, which are built from receipts
+var txMemos sync.Map
+
+// withMemo returns ctx carrying memo, recorded with the transactions sent under ctx. An empty
+// memo leaves ctx to -memo
+func withMemo(ctx context.Context, memo string) context.Context {
+	if memo == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, memoCtx{}, memo)
+}
+
+// sendMemo returns the memo of ctx, or -memo for the CLI's own sends
+func sendMemo(ctx context.Context) string {
+	if memo, ok := ctx.Value(memoCtx{}).(string); ok {
+		return memo
+	}
+	return *memoFlag
+}
+
+// rememberMemo keeps the memo of a broadcast transaction for notifyWebhook
+func rememberMemo(hash common.Hash, memo string) {
+	if memo != "" {
+		txMemos.Store(hash, memo)
+	}
+}
+
+// takeMemo returns the memo hash was broadcast with and forgets it, as every transaction gets
+// a single final webhook event
+func takeMemo(hash string) string {
+	if memo, ok := txMemos.LoadAndDelete(common.HexToHash(hash)); ok {
+		return memo.(string)
+	}
+	return ""
+}
+
+// withMemoAttr appends the -memo of the CLI's own send to the attributes of a result
+func withMemoAttr(attrs []interface{}) []interface{} {
+	if *memoFlag == "" {
+		return attrs
+	}
+	return append(attrs, "memo", *memoFlag)
+}
+
+// checkMemoReceiver refuses to put a memo in the calldata of a transfer to an account with code,
+// which would run it as a call: its first bytes could well match a function
+func checkMemoReceiver(client *ethclient.Client, receiver common.Address) error {
+	code, err := client.CodeAt(opCtx, receiver, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get the code of %s: %v", receiver.Hex(), err)
+	}
+	if len(code) > 0 {
+		return fmt.Errorf("%s has code, which would run the memo as a call", receiver.Hex())
+	}
+	return nil
+}
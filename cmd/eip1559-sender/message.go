@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// messageOptions holds the flags of the sign-message and verify-message subcommands
+type messageOptions struct {
+	message   string
+	file      string
+	hex       bool
+	signature string
+	address   string
+}
+
+func newMessageFlagSet(name string, opts *messageOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&opts.message, "message", "", "Message to sign or verify")
+	fs.StringVar(&opts.file, "file", "", "File holding the message instead of -message")
+	fs.BoolVar(&opts.hex, "hex", false, "The message is 0x-prefixed hex of the bytes to sign, such as a hash")
+	switch name {
+	case "sign-message":
+		addRootFlags(fs, keyFlags...)
+		addRootFlags(fs, "v", "quiet", "logFormat", "config", "profile")
+	case "verify-message":
+		fs.StringVar(&opts.signature, "signature", "", "65 byte [R || S || V] signature as 0x-prefixed hex")
+		fs.StringVar(&opts.address, "address", "", "Address the signature must come from")
+		addRootFlags(fs, "noChecksum", "v", "quiet", "logFormat")
+	}
+	fs.Usage = func() {
+		if name == "sign-message" {
+			fmt.Fprintf(fs.Output(), "Usage: %s sign-message -message \"...\"|-file message.txt -privateKeyEnv SENDER_KEY [options]\n", os.Args[0])
+		} else {
+			fmt.Fprintf(fs.Output(), "Usage: %s verify-message -message \"...\"|-file message.txt -signature 0x... [-address 0x...] [options]\n", os.Args[0])
+		}
+		fmt.Fprintf(fs.Output(), "\nMessages are signed with the EIP-191 prefix \"\\x19Ethereum Signed Message:\\n\" and their length, as personal_sign does. No RPC is needed.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runSignMessage implements the "sign-message" subcommand
+func runSignMessage(args []string) {
+	var opts messageOptions
+	fs := newMessageFlagSet("sign-message", &opts)
+	fs.Parse(args)
+	configure(fs)
+
+	message, ok := readMessage(&opts)
+	if !ok {
+		fmt.Println("Error: Missing required parameters (either -message or -file)")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	text, ok := signer.(textSigner)
+	if !ok {
+		exitf(exitInvalid, "The selected key source cannot sign messages")
+	}
+	infof("Signer: %s", signer.Address().Hex())
+	infof("Message hash: %s", hexutil.Encode(accounts.TextHash(message)))
+	signature, err := text.SignText(message)
+	if err != nil {
+		fatalf("Failed to sign message: %v", err)
+	}
+	if signature[64] < 27 {
+		signature[64] += 27
+	}
+	resultf([]interface{}{"signature", hexutil.Encode(signature), "signer", signer.Address().Hex()}, "Signature: %s", hexutil.Encode(signature))
+}
+
+// runVerifyMessage implements the "verify-message" subcommand, exiting with an error if the
+// signature does not come from -address
+func runVerifyMessage(args []string) {
+	var opts messageOptions
+	fs := newMessageFlagSet("verify-message", &opts)
+	fs.Parse(args)
+	if err := setupLogging(); err != nil {
+		exitf(exitInvalid, "Invalid logging options: %v", err)
+	}
+
+	message, ok := readMessage(&opts)
+	if !ok || opts.signature == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	signature, err := hexutil.Decode(opts.signature)
+	if err != nil || len(signature) != 65 {
+		exitf(exitInvalid, "Invalid signature: expected 65 bytes of 0x-prefixed hex")
+	}
+	if signature[64] >= 27 {
+		signature[64] -= 27
+	}
+	pubkey, err := crypto.SigToPub(accounts.TextHash(message), signature)
+	if err != nil {
+		exitf(exitInvalid, "Invalid signature: %v", err)
+	}
+	signer := crypto.PubkeyToAddress(*pubkey)
+	if opts.address == "" {
+		resultf([]interface{}{"signer", signer.Hex()}, "Signed by %s", signer.Hex())
+		return
+	}
+	expected, err := parseAddress(opts.address)
+	if err != nil {
+		exitf(exitInvalid, "Invalid address: %v", err)
+	}
+	if signer != expected {
+		fatalf("Signature is from %s, not from %s", signer.Hex(), expected.Hex())
+	}
+	resultf([]interface{}{"signer", signer.Hex(), "valid", true}, "Valid signature by %s", signer.Hex())
+}
+
+// readMessage returns the bytes of -message or -file, decoded from hex with -hex, and whether
+// exactly one of them was given
+func readMessage(opts *messageOptions) ([]byte, bool) {
+	if (opts.message == "") == (opts.file == "") {
+		return nil, false
+	}
+	message := []byte(opts.message)
+	if opts.file != "" {
+		var err error
+		if message, err = os.ReadFile(opts.file); err != nil {
+			fatalf("Failed to read message: %v", err)
+		}
+	}
+	if !opts.hex {
+		return message, true
+	}
+	decoded, err := hexutil.Decode(strings.TrimSpace(string(message)))
+	if err != nil {
+		exitf(exitInvalid, "Invalid hex message: %v", err)
+	}
+	return decoded, true
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsRegistry holds the metrics served with -metrics, without the Go runtime defaults of
+// the global registry
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	txSent   = newCounter("transactions_sent_total", "Transactions broadcast, replacements included")
+	txFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eip1559_sender",
+		Name:      "transactions_failed_total",
+		Help:      "Transactions that failed, by reason: send (never broadcast), reverted or unknown (not seen mined)",
+	}, []string{"reason"})
+	txConfirmed  = newCounter("transactions_confirmed_total", "Transactions mined successfully")
+	txReplaced   = newCounter("transactions_replaced_total", "Transactions replaced by a fee bump or another transaction with their nonce")
+	feesPaid     = newCounter("fees_paid_wei_total", "Fees paid by mined transactions in Wei")
+	nonceGaps    = newCounter("nonce_gaps_total", "Reserved nonces released because their transaction was never sent")
+	rpcRequests  = newCounter("rpc_requests_total", "HTTP requests made to the RPC endpoint")
+	rpcErrors    = newCounter("rpc_errors_total", "HTTP requests to the RPC endpoint that failed or returned a non-2xx status")
+	confirmation = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "eip1559_sender",
+		Name:      "confirmation_seconds",
+		Help:      "Time from broadcast until the transaction had -confirmations",
+		Buckets:   []float64{1, 2, 5, 10, 15, 30, 60, 120, 300, 600, 1800},
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(txFailed, confirmation)
+}
+
+// newCounter registers a counter of the eip1559_sender namespace
+func newCounter(name, help string) prometheus.Counter {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Namespace: "eip1559_sender", Name: name, Help: help})
+	metricsRegistry.MustRegister(counter)
+	return counter
+}
+
+// serveMetrics serves /metrics on -metrics, if given
+func serveMetrics() {
+	if *metricsFlag == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/config", serveConfig)
+	go func() {
+		if err := http.ListenAndServe(*metricsFlag, mux); err != nil {
+			fatalf("Failed to serve metrics: %v", err)
+		}
+	}()
+	infof("Serving metrics on http://%s/metrics and the configuration on http://%s/config", *metricsFlag, *metricsFlag)
+}
+
+// observeReceipt records the outcome of a transaction broadcast at sent
+func observeReceipt(receipt *types.Receipt, sent time.Time) {
+	confirmation.Observe(time.Since(sent).Seconds())
+	if receipt.EffectiveGasPrice != nil {
+		fee := new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+		value, _ := new(big.Float).SetInt(fee).Float64()
+		feesPaid.Add(value)
+	}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		txConfirmed.Inc()
+	} else {
+		txFailed.WithLabelValues("reverted").Inc()
+	}
+}
+
+// rpcTransport returns the HTTP transport of RPC requests, counting requests and errors with
+// -metrics and tracing them with -otlpEndpoint
+func rpcTransport() (http.RoundTripper, error) {
+	transport, err := baseTransport()
+	if err != nil {
+		return nil, err
+	}
+	if *metricsFlag != "" {
+		transport = countingTransport{transport}
+	}
+	if tracingEnabled {
+		transport = tracingTransport{transport}
+	}
+	return transport, nil
+}
+
+// countingTransport counts the requests made through it and those that failed
+type countingTransport struct {
+	next http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rpcRequests.Inc()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		rpcErrors.Inc()
+	}
+	return resp, err
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockKey pins the memory holding key's secret so that it is never written to swap
+func lockKey(key *ecdsa.PrivateKey) error {
+	words := key.D.Bits()
+	if len(words) == 0 {
+		return nil
+	}
+	return unix.Mlock(unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), len(words)*int(unsafe.Sizeof(words[0]))))
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockKey pins the memory holding key's secret so that it is never written to the page file
+func lockKey(key *ecdsa.PrivateKey) error {
+	words := key.D.Bits()
+	if len(words) == 0 {
+		return nil
+	}
+	return windows.VirtualLock(uintptr(unsafe.Pointer(&words[0])), uintptr(len(words))*unsafe.Sizeof(words[0]))
+}
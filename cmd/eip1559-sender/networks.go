@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// network is a well-known chain selectable with -network
+type network struct {
+	key      string // value of -network
+	name     string
+	chainID  uint64
+	rpcURL   string // default public RPC
+	symbol   string // native currency
+	decimals int
+	explorer string
+	block    time.Duration // typical block time, 0 if unknown
+	wrapped  string        // canonical wrapped native currency (WETH), empty if unknown
+}
+
+var networks = []network{
+	{"mainnet", "Ethereum Mainnet", 1, "https://ethereum-rpc.publicnode.com", "ETH", 18, "https://etherscan.io", 12 * time.Second, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"},
+	{"sepolia", "Sepolia", 11155111, "https://ethereum-sepolia-rpc.publicnode.com", "ETH", 18, "https://sepolia.etherscan.io", 12 * time.Second, "0xfFf9976782d46CC05630D1f6eBAb18b2324d6B14"},
+	{"holesky", "Holesky", 17000, "https://ethereum-holesky-rpc.publicnode.com", "ETH", 18, "https://holesky.etherscan.io", 12 * time.Second, "0x94373a4919B3240D86eA41593D5eBa789FEF3848"},
+	{"optimism", "OP Mainnet", 10, "https://mainnet.optimism.io", "ETH", 18, "https://optimistic.etherscan.io", 2 * time.Second, "0x4200000000000000000000000000000000000006"},
+	{"base", "Base", 8453, "https://mainnet.base.org", "ETH", 18, "https://basescan.org", 2 * time.Second, "0x4200000000000000000000000000000000000006"},
+	{"base-sepolia", "Base Sepolia", 84532, "https://sepolia.base.org", "ETH", 18, "https://sepolia.basescan.org", 2 * time.Second, "0x4200000000000000000000000000000000000006"},
+	{"arbitrum", "Arbitrum One", 42161, "https://arb1.arbitrum.io/rpc", "ETH", 18, "https://arbiscan.io", 250 * time.Millisecond, "0x82aF49447D8a07e3bd95BD0d56f35241523fBab1"},
+	{"arbitrum-sepolia", "Arbitrum Sepolia", 421614, "https://sepolia-rollup.arbitrum.io/rpc", "ETH", 18, "https://sepolia.arbiscan.io", 250 * time.Millisecond, "0x980B62Da83eFf3D4576C647993b0c1D7faf17c73"},
+	{"polygon", "Polygon", 137, "https://polygon-rpc.com", "POL", 18, "https://polygonscan.com", 2 * time.Second, "0x0d500B1d8E8eF31E21C99d1Db9A6444d3ADf1270"},
+	{"bsc", "BNB Smart Chain", 56, "https://bsc-dataseed.bnbchain.org", "BNB", 18, "https://bscscan.com", 3 * time.Second, "0xbb4CdB9CBd36B01bD1cBaEBF2De08d9173bc095c"},
+	{"gnosis", "Gnosis", 100, "https://rpc.gnosischain.com", "xDAI", 18, "https://gnosis.blockscout.com", 5 * time.Second, "0xe91D153E0b41518A2Ce8Dd3D7944Fa863463a97d"},
+	{"optimism-sepolia", "OP Sepolia", 11155420, "https://sepolia.optimism.io", "ETH", 18, "https://optimism-sepolia.blockscout.com", 2 * time.Second, "0x4200000000000000000000000000000000000006"},
+	{"devnet", "local devnet", 1337, "http://127.0.0.1:8545", "ETH", 18, "", 0, ""},
+	{"hardhat", "local devnet", 31337, "http://127.0.0.1:8545", "ETH", 18, "", 0, ""},
+}
+
+// networkKeys returns the values accepted by -network
+func networkKeys() []string {
+	var keys []string
+	for _, n := range networks {
+		keys = append(keys, n.key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lookupChain returns the registry entry of chainID, or a generic one for unknown chains.
+// -nativeSymbol and -nativeDecimals override its native currency
+func lookupChain(chainID *big.Int) network {
+	chain := network{name: "unknown chain", chainID: chainID.Uint64(), symbol: "native coin", decimals: 18}
+	for _, n := range networks {
+		if chainID.IsUint64() && n.chainID == chainID.Uint64() {
+			chain = n
+			break
+		}
+	}
+	if *nativeSymFlag != "" {
+		chain.symbol = *nativeSymFlag
+	}
+	if *nativeDecFlag >= 0 {
+		chain.decimals = *nativeDecFlag
+	}
+	return chain
+}
+
+// applyNetwork fills in -rpcURL and -chainID from -network where they were not given
+func applyNetwork() error {
+	if *nativeDecFlag != -1 && (*nativeDecFlag < 0 || *nativeDecFlag > maxTokenDecimals) {
+		return fmt.Errorf("-nativeDecimals %d is outside 0 to %d", *nativeDecFlag, maxTokenDecimals)
+	}
+	if *networkFlag == "" {
+		return nil
+	}
+	for _, n := range networks {
+		if n.key != *networkFlag {
+			continue
+		}
+		if *chainIDFlag != 0 && uint64(*chainIDFlag) != n.chainID {
+			return fmt.Errorf("-chainID %d contradicts -network %s (chain ID %d)", *chainIDFlag, n.key, n.chainID)
+		}
+		if *rpcURLFlag == "" {
+			*rpcURLFlag = n.rpcURL
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown network %q, expected one of %s", *networkFlag, strings.Join(networkKeys(), ", "))
+}
+
+// checkNetwork verifies that the RPC serves the chain selected with -network
+func checkNetwork(reported *big.Int) error {
+	if *networkFlag == "" {
+		return nil
+	}
+	if n := lookupChain(reported); n.key != *networkFlag {
+		return fmt.Errorf("the RPC serves chain ID %s, not %s", reported, *networkFlag)
+	}
+	return nil
+}
+
+// explorerTxURL returns the block explorer link of a transaction, from -explorerURL or the
+// chain's explorer, or "" for chains without one. Etherscan and Blockscout instances share the
+// /tx/<hash> path
+func explorerTxURL(chainID *big.Int, hash string) string {
+	base := *explorerURL
+	if base == "" {
+		base = lookupChain(chainID).explorer
+	}
+	if base == "" {
+		return ""
+	}
+	if strings.Contains(base, "{hash}") {
+		return strings.ReplaceAll(base, "{hash}", hash)
+	}
+	return strings.TrimSuffix(base, "/") + "/tx/" + hash
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// nonces is the allocator shared by every transaction the process builds
+var nonces = &sender.NonceManager{Next: nextNonce}
+
+// nextNonce returns the nonce for the next transaction of from: -nonce if given, otherwise the
+// account's nonce in the state selected by -nonceSource
+func nextNonce(ctx context.Context, client *ethclient.Client, from common.Address) (uint64, error) {
+	if *nonceFlag >= 0 {
+		debugf("Using nonce %d from -nonce", *nonceFlag)
+		return uint64(*nonceFlag), nil
+	}
+	return sourceNonce(ctx, client, from)
+}
+
+// sourceNonce returns the nonce of from in the pending or latest state, as selected by -nonceSource
+func sourceNonce(ctx context.Context, client *ethclient.Client, from common.Address) (uint64, error) {
+	return sender.AccountNonce(ctx, client, from, sender.NonceSource(*nonceSource))
+}
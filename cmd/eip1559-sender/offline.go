@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// offlineChainID returns the chain ID of -chainID, or of -network
+func offlineChainID() (*big.Int, error) {
+	if *chainIDFlag != 0 {
+		return big.NewInt(*chainIDFlag), nil
+	}
+	for _, n := range networks {
+		if n.key == *networkFlag {
+			return new(big.Int).SetUint64(n.chainID), nil
+		}
+	}
+	return nil, errors.New("-offline requires -chainID or -network")
+}
+
+// offlineTx builds a native coin transfer, with the calldata of -data, entirely from the flags, without asking a node for
+// the nonce, fees or gas limit
+func offlineTx(chainID *big.Int, nf numberFormat) (*types.Transaction, error) {
+	switch {
+	case *tokenContract != "" || *tokenIDFlag != "" || *tokenIDsFlag != "":
+		return nil, errors.New("-offline only signs native coin transfers")
+	case *maxFlag || *blobFlag != "" || *delegateFlag != "" || *batchFlag != "" || *cancelNonce >= 0 || *replaceTx != "":
+		return nil, errors.New("-offline cannot be combined with -max, -blob, -delegate, -batch, -cancelNonce or -replaceTx")
+	case *nonceFlag < 0:
+		return nil, errors.New("-offline requires -nonce")
+	case *gasLimitFlag == 0:
+		return nil, errors.New("-offline requires -gasLimit")
+	case *maxFeeFlag == "":
+		return nil, errors.New("-offline requires -maxFeePerGas")
+	}
+	if err := resolveAddressBook(chainID); err != nil {
+		return nil, err
+	}
+	to, err := parseAddress(*receiverFlag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receiver: %v", err)
+	}
+	feeCap, err := parseGwei(*maxFeeFlag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -maxFeePerGas: %v", err)
+	}
+
+	// without a node there is no base fee to detect legacy chains, -txType must say so
+	var legacy bool
+	switch *txTypeFlag {
+	case "legacy":
+		legacy = true
+		if *maxTipFlag != "" {
+			return nil, errors.New("-maxPriorityFeePerGas does not apply to legacy transactions, use -maxFeePerGas for the gas price")
+		}
+	case "auto", "dynamic":
+		if *maxTipFlag == "" {
+			return nil, errors.New("-offline requires -maxPriorityFeePerGas, or -txType legacy")
+		}
+	default:
+		return nil, fmt.Errorf("unknown -txType %q, expected auto, dynamic or legacy", *txTypeFlag)
+	}
+	tip := feeCap
+	if !legacy {
+		if tip, err = parseGwei(*maxTipFlag); err != nil {
+			return nil, fmt.Errorf("invalid -maxPriorityFeePerGas: %v", err)
+		}
+		if feeCap.Cmp(tip) < 0 {
+			return nil, fmt.Errorf("maxFeePerGas %s Wei is below maxPriorityFeePerGas %s Wei", feeCap, tip)
+		}
+	}
+
+	infof("Receiver address: %s", to.Hex())
+	value := nativeAmount(chainID, nf)
+	data, err := callData()
+	if err != nil {
+		return nil, fmt.Errorf("invalid -data: %v", err)
+	}
+	infof("nonce: %d", *nonceFlag)
+	infof("Gas limit: %s", nf.format(fmt.Sprint(*gasLimitFlag)))
+	return sender.MakeTx(legacy, &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     uint64(*nonceFlag),
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       *gasLimitFlag,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	}), nil
+}
+
+// signOffline signs the transaction of offlineTx and prints it for a later broadcast
+func signOffline(nf numberFormat) {
+	chainID, err := offlineChainID()
+	if err != nil {
+		exitf(exitInvalid, "Invalid chain: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	infof("Sender's address: %s", signer.Address().Hex())
+	tx, err := offlineTx(chainID, nf)
+	if err != nil {
+		fatalf("Failed to build offline transaction: %v", err)
+	}
+	if err := checkFeeCap(nil, chainID, tx); err != nil {
+		fatalf("Fee cap exceeded: %v", err)
+	}
+	if err := confirmTx(nil, chainID, signer.Address(), tx, nf); err != nil {
+		fatalf("Not signing: %v", err)
+	}
+	signedTx, err := signer.SignTx(tx, chainID)
+	if err != nil {
+		fatalf("Failed to sign transaction: %v", err)
+	}
+	if *signToFlag != "" {
+		if err := writeSignedTx(*signToFlag, chainID, signer.Address(), signedTx); err != nil {
+			fatalf("Failed to write signed transaction: %v", err)
+		}
+		resultf([]interface{}{"file", *signToFlag, "hash", signedTx.Hash().Hex()}, "Signed transaction %s written to %s, broadcast it with -sendFrom", signedTx.Hash().Hex(), *signToFlag)
+		return
+	}
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		fatalf("Failed to encode transaction: %v", err)
+	}
+	infof("Transaction hash: %s", signedTx.Hash().Hex())
+	resultf([]interface{}{"hash", signedTx.Hash().Hex(), "rawTx", hexutil.Encode(raw)}, "%s", hexutil.Encode(raw))
+}
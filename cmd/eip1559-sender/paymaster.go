@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// paymasterFields is the result of pm_getPaymasterStubData and pm_getPaymasterData (ERC-7677).
+// The final data of v0.7 operations only carries the paymaster and its data
+type paymasterFields struct {
+	Paymaster                     *common.Address `json:"paymaster"`
+	PaymasterData                 hexutil.Bytes   `json:"paymasterData"`
+	PaymasterVerificationGasLimit *hexutil.Big    `json:"paymasterVerificationGasLimit"`
+	PaymasterPostOpGasLimit       *hexutil.Big    `json:"paymasterPostOpGasLimit"`
+	Sponsor                       *struct {
+		Name string `json:"name"`
+	} `json:"sponsor"`
+	IsFinal bool `json:"isFinal"`
+}
+
+// parsePaymasterContext decodes -paymasterContext, the JSON object passed on to the paymaster
+// service, such as {"token": "0x..."} to pay in an ERC-20 token or a sponsorship policy ID.
+// The fields depend on the service
+func parsePaymasterContext(raw string) (map[string]interface{}, error) {
+	context := map[string]interface{}{}
+	if raw == "" {
+		return context, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &context); err != nil {
+		return nil, fmt.Errorf("expected a JSON object: %v", err)
+	}
+	return context, nil
+}
+
+// requestPaymaster calls method of the paymaster service for op and sets the paymaster fields
+// it returns on op
+func requestPaymaster(paymaster *rpc.Client, method string, op *userOperation, entryPoint common.Address, chainID *big.Int, context map[string]interface{}) (*paymasterFields, error) {
+	var fields paymasterFields
+	if err := paymaster.CallContext(opCtx, &fields, method, op, entryPoint, (*hexutil.Big)(chainID), context); err != nil {
+		return nil, errors.New(describeCallError(err))
+	}
+	if fields.Paymaster == nil {
+		return nil, fmt.Errorf("%s returned no paymaster", method)
+	}
+	op.Paymaster, op.PaymasterData = fields.Paymaster, fields.PaymasterData
+	if fields.PaymasterVerificationGasLimit != nil {
+		op.PaymasterVerificationGasLimit = fields.PaymasterVerificationGasLimit
+	} else if op.PaymasterVerificationGasLimit == nil {
+		op.PaymasterVerificationGasLimit = new(hexutil.Big)
+	}
+	if fields.PaymasterPostOpGasLimit != nil {
+		op.PaymasterPostOpGasLimit = fields.PaymasterPostOpGasLimit
+	} else if op.PaymasterPostOpGasLimit == nil {
+		op.PaymasterPostOpGasLimit = new(hexutil.Big)
+	}
+	return &fields, nil
+}
+
+// paymasterAndData packs the paymaster fields of op as the EntryPoint hashes them: the address,
+// the two 128-bit gas limits and the data. It is empty without a paymaster
+func (op *userOperation) paymasterAndData() []byte {
+	if op.Paymaster == nil {
+		return nil
+	}
+	packed := append([]byte{}, op.Paymaster.Bytes()...)
+	packed = append(packed, common.LeftPadBytes(op.PaymasterVerificationGasLimit.ToInt().Bytes(), 16)...)
+	packed = append(packed, common.LeftPadBytes(op.PaymasterPostOpGasLimit.ToInt().Bytes(), 16)...)
+	return append(packed, op.PaymasterData...)
+}
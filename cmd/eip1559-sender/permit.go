@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// erc2612ABIJSON holds the EIP-2612 permit extension of ERC-20
+//
+//go:embed erc2612.abi.json
+var erc2612ABIJSON string
+
+var erc2612ABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(erc2612ABIJSON))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+var (
+	eip712DomainTypeHash = crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	permitTypeHash       = crypto.Keccak256([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+)
+
+// permitActions lists the actions of the permit subcommand
+var permitActions = []string{"sign", "submit"}
+
+// signedPermit is an EIP-2612 permit signed by the token owner, as exchanged between sign and submit
+type signedPermit struct {
+	Token    common.Address `json:"token"`
+	ChainID  uint64         `json:"chainId"`
+	Version  string         `json:"version"`
+	Owner    common.Address `json:"owner"`
+	Spender  common.Address `json:"spender"`
+	Value    string         `json:"value"`
+	Nonce    string         `json:"nonce"`
+	Deadline uint64         `json:"deadline"`
+	V        uint8          `json:"v"`
+	R        common.Hash    `json:"r"`
+	S        common.Hash    `json:"s"`
+}
+
+// permitOptions holds the flags of the permit subcommand
+type permitOptions struct {
+	spender  string
+	amount   string
+	validFor time.Duration
+	version  string
+	file     string
+	receiver string
+}
+
+func newPermitFlagSet(action string, opts *permitOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("permit "+action, flag.ExitOnError)
+	switch action {
+	case "sign":
+		fs.StringVar(&opts.spender, "spender", "", "Address allowed to spend the tokens, usually the receiver or a relayer")
+		fs.StringVar(&opts.amount, "amount", "", "Permitted amount in whole tokens")
+		fs.DurationVar(&opts.validFor, "validFor", time.Hour, "How long the permit stays valid, its deadline")
+		fs.StringVar(&opts.version, "permitVersion", "", "EIP-712 domain version of the token (default: detected from the token)")
+		fs.StringVar(&opts.file, "out", "permit.json", "File to write the signed permit to")
+	case "submit":
+		fs.StringVar(&opts.file, "permit", "permit.json", "Signed permit file produced by \"permit sign\"")
+		fs.StringVar(&opts.receiver, "receiver", "", "Receiver of the tokens")
+	}
+	addSharedFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s permit sign -tokenContract 0x... -spender 0x... -amount 100 [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s permit submit -permit permit.json -receiver 0x... [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runPermit implements the "permit sign|submit" subcommand
+func runPermit(args []string) {
+	if len(args) == 0 || (args[0] != "sign" && args[0] != "submit") {
+		newPermitFlagSet("sign", &permitOptions{}).Usage()
+		exit(exitInvalid)
+	}
+	var opts permitOptions
+	fs := newPermitFlagSet(args[0], &opts)
+	fs.Parse(args[1:])
+	configure(fs)
+	if *exportFlag != "" || *nonceFlag >= 0 {
+		exitf(exitInvalid, "-exportUnsigned and -nonce do not apply to permits, which take two transactions")
+	}
+
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	if args[0] == "sign" {
+		if *rpcURLFlag == "" || *tokenContract == "" || opts.spender == "" || opts.amount == "" {
+			fmt.Println("Error: Missing required parameters")
+			fs.Usage()
+			exit(exitInvalid)
+		}
+		signPermit(&opts, nf)
+		return
+	}
+	if *rpcURLFlag == "" || opts.receiver == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	submitPermit(&opts, nf)
+}
+
+// signPermit signs a permit off-chain with the owner's key and writes it to a file
+func signPermit(opts *permitOptions, nf numberFormat) {
+	spender, err := parseAddress(opts.spender)
+	if err != nil {
+		exitf(exitInvalid, "Invalid spender: %v", err)
+	}
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	typed, ok := signer.(typedDataSigner)
+	if !ok {
+		exitf(exitInvalid, "The selected key source cannot sign EIP-712 permits")
+	}
+	token, err := loadToken(client, *tokenContract, *tokenABIFlag)
+	if err == nil {
+		err = token.verify()
+	}
+	if err != nil {
+		fatalf("Failed to load token contract: %v", err)
+	}
+	decimals, err := token.decimals()
+	if err != nil {
+		exitf(exitRPC, "Failed to get token decimals: %v", err)
+	}
+	value, err := parseUnits(opts.amount, decimals)
+	if err != nil {
+		exitf(exitInvalid, "Invalid amount: %v", err)
+	}
+
+	owner := signer.Address()
+	nonce, err := permitNonce(client, token.address, owner)
+	if err != nil {
+		fatalf("Token does not support EIP-2612 permits: %v", err)
+	}
+	version, domain, err := permitDomain(client, token, chainID, opts.version)
+	if err != nil {
+		fatalf("Failed to build the EIP-712 domain: %v", err)
+	}
+	p := &signedPermit{
+		Token:    token.address,
+		ChainID:  chainID.Uint64(),
+		Version:  version,
+		Owner:    owner,
+		Spender:  spender,
+		Value:    value.String(),
+		Nonce:    nonce.String(),
+		Deadline: uint64(time.Now().Add(opts.validFor).Unix()),
+	}
+	infof("Permitting %s to spend %s base units of %s owned by %s until %s", p.Spender.Hex(), nf.format(p.Value), token.symbol(), owner.Hex(), time.Unix(int64(p.Deadline), 0).Format(time.RFC3339))
+
+	signature, err := typed.SignTypedData(domain, p.structHash())
+	if err != nil {
+		fatalf("Failed to sign permit: %v", err)
+	}
+	if signature[64] < 27 {
+		signature[64] += 27
+	}
+	p.V, p.R, p.S = signature[64], common.BytesToHash(signature[:32]), common.BytesToHash(signature[32:64])
+	if err := p.verify(domain); err != nil {
+		fatalf("Failed to verify permit signature: %v", err)
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		fatalf("Failed to encode permit: %v", err)
+	}
+	if err := os.WriteFile(opts.file, append(data, '\n'), 0o600); err != nil {
+		fatalf("Failed to write permit: %v", err)
+	}
+	resultf([]interface{}{"file", opts.file}, "Signed permit written to %s, hand it to %s to submit", opts.file, p.Spender.Hex())
+}
+
+// submitPermit sends the permit followed by transferFrom, signed by the spender
+func submitPermit(opts *permitOptions, nf numberFormat) {
+	receiver, err := parseAddress(opts.receiver)
+	if err != nil {
+		exitf(exitInvalid, "Invalid receiver: %v", err)
+	}
+	data, err := os.ReadFile(opts.file)
+	if err != nil {
+		fatalf("Failed to read permit: %v", err)
+	}
+	p := new(signedPermit)
+	if err := json.Unmarshal(data, p); err != nil {
+		fatalf("Failed to parse permit: %v", err)
+	}
+	value, ok := new(big.Int).SetString(p.Value, 10)
+	if !ok {
+		exitf(exitInvalid, "Invalid permit value %q", p.Value)
+	}
+
+	client, chainID := dialRPC()
+	if chainID.Uint64() != p.ChainID {
+		exitf(exitInvalid, "Permit is for chain ID %d, but the RPC serves chain ID %s", p.ChainID, chainID)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	if signer.Address() != p.Spender {
+		exitf(exitInvalid, "Permit names %s as spender, but the signing key is %s", p.Spender.Hex(), signer.Address().Hex())
+	}
+	if time.Now().Unix() > int64(p.Deadline) {
+		exitf(exitInvalid, "Permit expired at %s", time.Unix(int64(p.Deadline), 0).Format(time.RFC3339))
+	}
+	token, err := loadToken(client, p.Token.Hex(), *tokenABIFlag)
+	if err != nil {
+		fatalf("Failed to load token contract: %v", err)
+	}
+	if nonce, err := permitNonce(client, token.address, p.Owner); err != nil || nonce.String() != p.Nonce {
+		fatalf("Permit nonce %s is no longer valid (current nonce %v, %v)", p.Nonce, nonce, err)
+	}
+	_, domain, err := permitDomain(client, token, chainID, p.Version)
+	if err != nil {
+		fatalf("Failed to build the EIP-712 domain: %v", err)
+	}
+	if err := p.verify(domain); err != nil {
+		exitf(exitInvalid, "Invalid permit signature: %v", err)
+	}
+	infof("Owner's address: %s", p.Owner.Hex())
+	infof("Receiver address: %s", receiver.Hex())
+	infof("Amount: %s base units of %s", nf.format(value.String()), token.symbol())
+
+	// the permit has to be mined before transferFrom can be estimated and sent
+	permitData, err := erc2612ABI.Pack("permit", p.Owner, p.Spender, value, new(big.Int).SetUint64(p.Deadline), p.V, p.R, p.S)
+	if err != nil {
+		fatalf("Failed to pack permit call: %v", err)
+	}
+	tx := newTransaction(client, signer.Address(), chainID, &token.address, new(big.Int), permitData, nf)
+	if *dryRunFlag {
+		simulateTx(client, signer.Address(), tx, nf)
+		infof("transferFrom can only be simulated once the permit is mined")
+		return
+	}
+	if err := checkFeeCap(client, chainID, tx); err != nil {
+		fatalf("Fee cap exceeded: %v", err)
+	}
+	if err := confirmTx(client, chainID, signer.Address(), tx, nf); err != nil {
+		fatalf("Not sending: %v", err)
+	}
+	signedTx, err := signer.SignTx(tx, chainID)
+	if err != nil {
+		fatalf("Failed to sign transaction: %v", err)
+	}
+	if err := sendTransaction(opCtx, client, signedTx); err != nil {
+		exitf(broadcastExitCode(err), "Failed to send transaction: %v", err)
+	}
+	resultf([]interface{}{"hash", signedTx.Hash().Hex()}, "Permit sent, waiting for it to be mined: %s", signedTx.Hash().Hex())
+	receipt, err := sender.WaitReceipt(opCtx, client, signedTx.Hash(), 1, pollInterval)
+	if err != nil {
+		exitf(exitRPC, "Failed to get transaction receipt: %v", err)
+	}
+	recordReceipt(client, receipt)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		exitf(exitReverted, "Permit transaction reverted in block %s", receipt.BlockNumber)
+	}
+
+	transferData, err := token.abi.Pack("transferFrom", p.Owner, receiver, value)
+	if err != nil {
+		fatalf("Failed to pack transferFrom call: %v", err)
+	}
+	tx = newTransaction(client, signer.Address(), chainID, &token.address, new(big.Int), transferData, nf)
+	sendAndFollow(client, signer, chainID, tx, nf)
+}
+
+// permitNonce returns the current EIP-2612 nonce of owner
+func permitNonce(client *ethclient.Client, token, owner common.Address) (*big.Int, error) {
+	results, err := callABI(client, erc2612ABI, token, "nonces", owner)
+	if err != nil {
+		return nil, err
+	}
+	return results[0].(*big.Int), nil
+}
+
+// permitDomain returns the domain version and EIP-712 domain separator of token. Without an explicit
+// version it uses version() or tries the common "1" and "2", checked against DOMAIN_SEPARATOR()
+func permitDomain(client *ethclient.Client, token *erc20Token, chainID *big.Int, version string) (string, []byte, error) {
+	results, err := token.call("name")
+	if err != nil {
+		return "", nil, err
+	}
+	name, _ := results[0].(string)
+
+	candidates := []string{version}
+	if version == "" {
+		candidates = []string{"1", "2"}
+		if results, err := callABI(client, erc2612ABI, token.address, "version"); err == nil {
+			candidates = append([]string{results[0].(string)}, candidates...)
+		}
+	}
+	var expected []byte
+	if results, err := callABI(client, erc2612ABI, token.address, "DOMAIN_SEPARATOR"); err == nil {
+		separator := results[0].([32]byte)
+		expected = separator[:]
+	}
+	for _, candidate := range candidates {
+		domain := crypto.Keccak256(
+			eip712DomainTypeHash,
+			crypto.Keccak256([]byte(name)),
+			crypto.Keccak256([]byte(candidate)),
+			math.U256Bytes(new(big.Int).Set(chainID)),
+			common.LeftPadBytes(token.address.Bytes(), 32),
+		)
+		if expected == nil || bytes.Equal(domain, expected) {
+			return candidate, domain, nil
+		}
+	}
+	return "", nil, errors.New("cannot reproduce the token's DOMAIN_SEPARATOR, pass -permitVersion")
+}
+
+// structHash returns the EIP-712 hash of the Permit message
+func (p *signedPermit) structHash() []byte {
+	value, _ := new(big.Int).SetString(p.Value, 10)
+	nonce, _ := new(big.Int).SetString(p.Nonce, 10)
+	if value == nil || nonce == nil {
+		return nil
+	}
+	return crypto.Keccak256(
+		permitTypeHash,
+		common.LeftPadBytes(p.Owner.Bytes(), 32),
+		common.LeftPadBytes(p.Spender.Bytes(), 32),
+		math.U256Bytes(value),
+		math.U256Bytes(nonce),
+		math.U256Bytes(new(big.Int).SetUint64(p.Deadline)),
+	)
+}
+
+// verify checks that the permit was signed by its owner
+func (p *signedPermit) verify(domain []byte) error {
+	structHash := p.structHash()
+	if structHash == nil {
+		return errors.New("invalid value or nonce")
+	}
+	if p.V != 27 && p.V != 28 {
+		return fmt.Errorf("invalid v %d", p.V)
+	}
+	signature := append(append(p.R.Bytes(), p.S.Bytes()...), p.V-27)
+	pubkey, err := crypto.SigToPub(typedDataHash(domain, structHash), signature)
+	if err != nil {
+		return err
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != p.Owner {
+		return fmt.Errorf("signed by %s, not by the owner %s", signer.Hex(), p.Owner.Hex())
+	}
+	return nil
+}
+
+// callABI runs a read-only call of method on contract through the given ABI
+func callABI(client *ethclient.Client, contract abi.ABI, address common.Address, method string, args ...interface{}) ([]interface{}, error) {
+	token := &erc20Token{client: client, address: address, abi: contract}
+	return token.call(method, args...)
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// defaultPermit2 is the address Uniswap's Permit2 is deployed at on every chain
+const defaultPermit2 = "0x000000000022D473030F116dDEE9F6B43aC78BA3"
+
+// permit2ABIJSON holds the signature transfer and nonce methods of Permit2
+//
+//go:embed permit2.abi.json
+var permit2ABIJSON string
+
+var permit2ABI = mustLoadABI(permit2ABIJSON)
+
+var (
+	permit2DomainTypeHash      = crypto.Keccak256([]byte("EIP712Domain(string name,uint256 chainId,address verifyingContract)"))
+	tokenPermissionsTypeHash   = crypto.Keccak256([]byte("TokenPermissions(address token,uint256 amount)"))
+	permitTransferFromTypeHash = crypto.Keccak256([]byte("PermitTransferFrom(TokenPermissions permitted,address spender,uint256 nonce,uint256 deadline)TokenPermissions(address token,uint256 amount)"))
+)
+
+// signedPermit2 is a Permit2 signature transfer signed by the token owner, as exchanged between
+// sign and submit
+type signedPermit2 struct {
+	Permit2   common.Address `json:"permit2"`
+	Token     common.Address `json:"token"`
+	ChainID   uint64         `json:"chainId"`
+	Owner     common.Address `json:"owner"`
+	Spender   common.Address `json:"spender"`
+	Amount    string         `json:"amount"`
+	Nonce     string         `json:"nonce"`
+	Deadline  uint64         `json:"deadline"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// permit2Options holds the flags of the permit2 subcommand
+type permit2Options struct {
+	contract string
+	spender  string
+	amount   string
+	validFor time.Duration
+	file     string
+	receiver string
+}
+
+func newPermit2FlagSet(action string, opts *permit2Options) *flag.FlagSet {
+	fs := flag.NewFlagSet("permit2 "+action, flag.ExitOnError)
+	switch action {
+	case "sign":
+		fs.StringVar(&opts.contract, "permit2Contract", defaultPermit2, "Permit2 contract the transfer is signed for")
+		fs.StringVar(&opts.spender, "spender", "", "Address allowed to submit the transfer, usually the receiver or a relayer")
+		fs.StringVar(&opts.amount, "amount", "", "Amount in whole tokens")
+		fs.DurationVar(&opts.validFor, "validFor", time.Hour, "How long the signature stays valid, its deadline")
+		fs.StringVar(&opts.file, "out", "permit2.json", "File to write the signed transfer to")
+	case "submit":
+		fs.StringVar(&opts.file, "permit", "permit2.json", "Signed transfer file produced by \"permit2 sign\"")
+		fs.StringVar(&opts.receiver, "receiver", "", "Receiver of the tokens")
+	}
+	addSharedFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s permit2 sign -tokenContract 0x... -spender 0x... -amount 100 [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s permit2 submit -permit permit2.json -receiver 0x... [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nTransfers tokens through Uniswap's Permit2 with a signature of the owner, for tokens without EIP-2612.\n")
+		fmt.Fprintf(fs.Output(), "The owner approves Permit2 once with \"approve -spender %s -unlimited\".\n", defaultPermit2)
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runPermit2 implements the "permit2 sign|submit" subcommand
+func runPermit2(args []string) {
+	if len(args) == 0 || (args[0] != "sign" && args[0] != "submit") {
+		newPermit2FlagSet("sign", &permit2Options{}).Usage()
+		exit(exitInvalid)
+	}
+	var opts permit2Options
+	fs := newPermit2FlagSet(args[0], &opts)
+	fs.Parse(args[1:])
+	configure(fs)
+
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	if args[0] == "sign" {
+		if *rpcURLFlag == "" || *tokenContract == "" || opts.spender == "" || opts.amount == "" {
+			fmt.Println("Error: Missing required parameters")
+			fs.Usage()
+			exit(exitInvalid)
+		}
+		signPermit2(&opts, nf)
+		return
+	}
+	if *rpcURLFlag == "" || opts.receiver == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	submitPermit2(&opts, nf)
+}
+
+// signPermit2 signs a Permit2 transfer off-chain with the owner's key and writes it to a file
+func signPermit2(opts *permit2Options, nf numberFormat) {
+	spender, err := parseAddress(opts.spender)
+	if err != nil {
+		exitf(exitInvalid, "Invalid spender: %v", err)
+	}
+	if !common.IsHexAddress(opts.contract) {
+		exitf(exitInvalid, "Invalid -permit2Contract address %q", opts.contract)
+	}
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	typed, ok := signer.(typedDataSigner)
+	if !ok {
+		exitf(exitInvalid, "The selected key source cannot sign EIP-712 permits")
+	}
+	token, err := loadToken(client, *tokenContract, *tokenABIFlag)
+	if err == nil {
+		err = token.verify()
+	}
+	if err != nil {
+		fatalf("Failed to load token contract: %v", err)
+	}
+	decimals, err := token.decimals()
+	if err != nil {
+		exitf(exitRPC, "Failed to get token decimals: %v", err)
+	}
+	value, err := parseUnits(opts.amount, decimals)
+	if err != nil {
+		exitf(exitInvalid, "Invalid amount: %v", err)
+	}
+	contract := common.HexToAddress(opts.contract)
+	domain, err := permit2Domain(client, contract, chainID)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	owner := signer.Address()
+	nonce, err := unusedPermit2Nonce(client, contract, owner)
+	if err != nil {
+		exitf(exitRPC, "Failed to get the Permit2 nonce: %v", err)
+	}
+	if allowance, err := token.call("allowance", owner, contract); err == nil && allowance[0].(*big.Int).Cmp(value) < 0 {
+		warnf("%s has not approved Permit2 for this amount yet, the transfer fails until it does: %s approve -tokenContract %s -spender %s -unlimited",
+			owner.Hex(), os.Args[0], token.address.Hex(), contract.Hex())
+	}
+	p := &signedPermit2{
+		Permit2:  contract,
+		Token:    token.address,
+		ChainID:  chainID.Uint64(),
+		Owner:    owner,
+		Spender:  spender,
+		Amount:   value.String(),
+		Nonce:    nonce.String(),
+		Deadline: uint64(time.Now().Add(opts.validFor).Unix()),
+	}
+	infof("Allowing %s to transfer %s base units of %s owned by %s through Permit2 until %s", p.Spender.Hex(), nf.format(p.Amount), token.symbol(), owner.Hex(), time.Unix(int64(p.Deadline), 0).Format(time.RFC3339))
+
+	signature, err := typed.SignTypedData(domain, p.structHash())
+	if err != nil {
+		fatalf("Failed to sign permit: %v", err)
+	}
+	// Permit2 passes v to ecrecover as is
+	if signature[64] < 27 {
+		signature[64] += 27
+	}
+	p.Signature = signature
+	if err := p.verify(domain); err != nil {
+		fatalf("Failed to verify permit signature: %v", err)
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		fatalf("Failed to encode permit: %v", err)
+	}
+	if err := os.WriteFile(opts.file, append(data, '\n'), 0o600); err != nil {
+		fatalf("Failed to write permit: %v", err)
+	}
+	resultf([]interface{}{"file", opts.file}, "Signed Permit2 transfer written to %s, hand it to %s to submit", opts.file, p.Spender.Hex())
+}
+
+// submitPermit2 sends the signed transfer through Permit2 in a single transaction, signed by the
+// spender
+func submitPermit2(opts *permit2Options, nf numberFormat) {
+	receiver, err := parseAddress(opts.receiver)
+	if err != nil {
+		exitf(exitInvalid, "Invalid receiver: %v", err)
+	}
+	data, err := os.ReadFile(opts.file)
+	if err != nil {
+		fatalf("Failed to read permit: %v", err)
+	}
+	p := new(signedPermit2)
+	if err := json.Unmarshal(data, p); err != nil {
+		fatalf("Failed to parse permit: %v", err)
+	}
+	value, ok := new(big.Int).SetString(p.Amount, 10)
+	nonce, nonceOK := new(big.Int).SetString(p.Nonce, 10)
+	if !ok || !nonceOK {
+		exitf(exitInvalid, "Invalid permit amount %q or nonce %q", p.Amount, p.Nonce)
+	}
+
+	client, chainID := dialRPC()
+	if chainID.Uint64() != p.ChainID {
+		exitf(exitInvalid, "Permit is for chain ID %d, but the RPC serves chain ID %s", p.ChainID, chainID)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	if signer.Address() != p.Spender {
+		exitf(exitInvalid, "Permit names %s as spender, but the signing key is %s", p.Spender.Hex(), signer.Address().Hex())
+	}
+	if time.Now().Unix() > int64(p.Deadline) {
+		exitf(exitInvalid, "Permit expired at %s", time.Unix(int64(p.Deadline), 0).Format(time.RFC3339))
+	}
+	domain, err := permit2Domain(client, p.Permit2, chainID)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := p.verify(domain); err != nil {
+		exitf(exitInvalid, "Invalid permit signature: %v", err)
+	}
+	if used, err := permit2NonceUsed(client, p.Permit2, p.Owner, nonce); err != nil {
+		exitf(exitRPC, "Failed to get the Permit2 nonce: %v", err)
+	} else if used {
+		fatalf("Permit2 nonce %s of %s was already used, the transfer was submitted or cancelled", p.Nonce, p.Owner.Hex())
+	}
+	token, err := loadToken(client, p.Token.Hex(), *tokenABIFlag)
+	if err != nil {
+		fatalf("Failed to load token contract: %v", err)
+	}
+	if allowance, err := token.call("allowance", p.Owner, p.Permit2); err != nil {
+		exitf(exitRPC, "Failed to get the allowance of Permit2: %v", err)
+	} else if allowance[0].(*big.Int).Cmp(value) < 0 {
+		fatalf("%s approved Permit2 for only %s base units of the %s, it has to approve it first", p.Owner.Hex(), allowance[0], value)
+	}
+	if balance, err := token.balanceOf(p.Owner); err != nil {
+		exitf(exitRPC, "Failed to get token balance: %v", err)
+	} else if balance.Cmp(value) < 0 {
+		exitf(exitFunds, "Insufficient funds: %s holds %s base units of the %s to transfer", p.Owner.Hex(), balance, value)
+	}
+	infof("Owner's address: %s", p.Owner.Hex())
+	infof("Receiver address: %s", receiver.Hex())
+	infof("Amount: %s base units of %s", nf.format(value.String()), token.symbol())
+
+	type tokenPermissions struct {
+		Token  common.Address
+		Amount *big.Int
+	}
+	permit := struct {
+		Permitted tokenPermissions
+		Nonce     *big.Int
+		Deadline  *big.Int
+	}{tokenPermissions{p.Token, value}, nonce, new(big.Int).SetUint64(p.Deadline)}
+	details := struct {
+		To              common.Address
+		RequestedAmount *big.Int
+	}{receiver, value}
+	transferData, err := permit2ABI.Pack("permitTransferFrom", permit, details, p.Owner, []byte(p.Signature))
+	if err != nil {
+		fatalf("Failed to pack permitTransferFrom call: %v", err)
+	}
+	tx := newTransaction(client, signer.Address(), chainID, &p.Permit2, new(big.Int), transferData, nf)
+	sendAndFollow(client, signer, chainID, tx, nf)
+}
+
+// permit2Domain returns the EIP-712 domain separator of the Permit2 contract at address, checked
+// against its DOMAIN_SEPARATOR()
+func permit2Domain(client *ethclient.Client, contract common.Address, chainID *big.Int) ([]byte, error) {
+	if code, err := client.CodeAt(opCtx, contract, nil); err != nil {
+		return nil, fmt.Errorf("failed to get the code of the Permit2 contract: %v", err)
+	} else if len(code) == 0 {
+		return nil, fmt.Errorf("no Permit2 contract at %s on this chain, deploy one and pass -permit2Contract", contract.Hex())
+	}
+	domain := crypto.Keccak256(
+		permit2DomainTypeHash,
+		crypto.Keccak256([]byte("Permit2")),
+		math.U256Bytes(new(big.Int).Set(chainID)),
+		common.LeftPadBytes(contract.Bytes(), 32),
+	)
+	results, err := callABI(client, permit2ABI, contract, "DOMAIN_SEPARATOR")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a Permit2 contract: %v", contract.Hex(), err)
+	}
+	if separator := results[0].([32]byte); !bytes.Equal(separator[:], domain) {
+		return nil, fmt.Errorf("%s is not a Permit2 contract, its DOMAIN_SEPARATOR differs", contract.Hex())
+	}
+	return domain, nil
+}
+
+// unusedPermit2Nonce returns the lowest nonce owner has not used with Permit2. Signature transfers
+// take unordered nonces, marked as used in words of 256 bits
+func unusedPermit2Nonce(client *ethclient.Client, contract, owner common.Address) (*big.Int, error) {
+	for word := int64(0); ; word++ {
+		results, err := callABI(client, permit2ABI, contract, "nonceBitmap", owner, big.NewInt(word))
+		if err != nil {
+			return nil, err
+		}
+		bitmap := results[0].(*big.Int)
+		for bit := 0; bit < 256; bit++ {
+			if bitmap.Bit(bit) == 0 {
+				return big.NewInt(word<<8 | int64(bit)), nil
+			}
+		}
+	}
+}
+
+// permit2NonceUsed tells whether owner has used nonce with Permit2
+func permit2NonceUsed(client *ethclient.Client, contract, owner common.Address, nonce *big.Int) (bool, error) {
+	results, err := callABI(client, permit2ABI, contract, "nonceBitmap", owner, new(big.Int).Rsh(nonce, 8))
+	if err != nil {
+		return false, err
+	}
+	return results[0].(*big.Int).Bit(int(nonce.Uint64()&0xff)) == 1, nil
+}
+
+// structHash returns the EIP-712 hash of the PermitTransferFrom message
+func (p *signedPermit2) structHash() []byte {
+	amount, _ := new(big.Int).SetString(p.Amount, 10)
+	nonce, _ := new(big.Int).SetString(p.Nonce, 10)
+	if amount == nil || nonce == nil {
+		return nil
+	}
+	permitted := crypto.Keccak256(
+		tokenPermissionsTypeHash,
+		common.LeftPadBytes(p.Token.Bytes(), 32),
+		math.U256Bytes(amount),
+	)
+	return crypto.Keccak256(
+		permitTransferFromTypeHash,
+		permitted,
+		common.LeftPadBytes(p.Spender.Bytes(), 32),
+		math.U256Bytes(nonce),
+		math.U256Bytes(new(big.Int).SetUint64(p.Deadline)),
+	)
+}
+
+// verify checks that the transfer was signed by its owner
+func (p *signedPermit2) verify(domain []byte) error {
+	structHash := p.structHash()
+	if structHash == nil {
+		return errors.New("invalid amount or nonce")
+	}
+	if len(p.Signature) != 65 || (p.Signature[64] != 27 && p.Signature[64] != 28) {
+		return errors.New("expected a 65-byte signature with v of 27 or 28")
+	}
+	signature := append(bytes.Clone(p.Signature[:64]), p.Signature[64]-27)
+	pubkey, err := crypto.SigToPub(typedDataHash(domain, structHash), signature)
+	if err != nil {
+		return err
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != p.Owner {
+		return fmt.Errorf("signed by %s, not by the owner %s", signer.Hex(), p.Owner.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+//go:build cgo
+
+package main
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+	"github.com/miekg/pkcs11"
+)
+
+// secp256k1Params is the DER encoded OID 1.3.132.0.10 of secp256k1, as in CKA_EC_PARAMS
+var secp256k1Params = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+// pkcs11Signer signs with a secp256k1 key that never leaves a PKCS#11 token, such as an HSM
+type pkcs11Signer struct {
+	mu      sync.Mutex // a session runs one operation at a time
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+	pubkey  []byte // uncompressed public key, used to recover the signature's v
+}
+
+// loadPKCS11 opens a session on the token in slot, or the first slot holding a token if slot is
+// negative, logs in with the PIN of PKCS11_PIN or the prompt, and finds the key pair labelled label
+func loadPKCS11(module string, slot int, label string) (sender.Signer, error) {
+	if label == "" {
+		return nil, errors.New("-pkcs11Module requires -pkcs11KeyLabel")
+	}
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %v", err)
+	}
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKCS#11 slots: %v", err)
+	}
+	slotID := uint(slot)
+	if slot < 0 {
+		if len(slots) == 0 {
+			return nil, errors.New("no PKCS#11 slot holds a token")
+		}
+		slotID = slots[0]
+	}
+	session, err := ctx.OpenSession(slotID, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a session on PKCS#11 slot %d: %v", slotID, err)
+	}
+	pin, ok := os.LookupEnv("PKCS11_PIN")
+	if !ok {
+		if pin, err = promptPassword(fmt.Sprintf("PIN of the token in PKCS#11 slot %d: ", slotID)); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, pin); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return nil, fmt.Errorf("failed to log in to PKCS#11 slot %d: %v", slotID, err)
+	}
+
+	s := &pkcs11Signer{ctx: ctx, session: session}
+	if s.key, err = s.findObject(pkcs11.CKO_PRIVATE_KEY, label); err != nil {
+		return nil, err
+	}
+	public, err := s.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := ctx.GetAttributeValue(session, public, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PKCS#11 public key %q: %v", label, err)
+	}
+	if !bytes.Equal(attrs[0].Value, secp256k1Params) {
+		return nil, fmt.Errorf("PKCS#11 key %q is not a secp256k1 key", label)
+	}
+	// CKA_EC_POINT is the uncompressed point wrapped in a DER octet string, though some modules
+	// return it bare
+	point := attrs[1].Value
+	var wrapped []byte
+	if rest, err := asn1.Unmarshal(point, &wrapped); err == nil && len(rest) == 0 {
+		point = wrapped
+	}
+	if _, err := crypto.UnmarshalPubkey(point); err != nil {
+		return nil, fmt.Errorf("failed to parse PKCS#11 public key %q: %v", label, err)
+	}
+	s.pubkey = point
+	infof("Using PKCS#11 key %q in slot %d (account %s)", label, slotID, s.Address().Hex())
+	return s, nil
+}
+
+// findObject returns the only object of class labelled label
+func (s *pkcs11Signer) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return 0, fmt.Errorf("failed to search the PKCS#11 token: %v", err)
+	}
+	objects, _, err := s.ctx.FindObjects(s.session, 2)
+	if finalErr := s.ctx.FindObjectsFinal(s.session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to search the PKCS#11 token: %v", err)
+	}
+	kind := "private"
+	if class == pkcs11.CKO_PUBLIC_KEY {
+		kind = "public"
+	}
+	switch len(objects) {
+	case 0:
+		return 0, fmt.Errorf("no EC %s key labelled %q on the PKCS#11 token", kind, label)
+	case 1:
+		return objects[0], nil
+	}
+	return 0, fmt.Errorf("several EC %s keys are labelled %q on the PKCS#11 token", kind, label)
+}
+
+func (s *pkcs11Signer) Address() common.Address {
+	return common.BytesToAddress(crypto.Keccak256(s.pubkey[1:])[12:])
+}
+
+func (s *pkcs11Signer) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signer := types.LatestSignerForChainID(chainID)
+	hash := signer.Hash(tx)
+	signature, err := s.signDigest(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, signature)
+}
+
+func (s *pkcs11Signer) SignTypedData(domainSeparator, structHash []byte) ([]byte, error) {
+	return s.signDigest(typedDataHash(domainSeparator, structHash))
+}
+
+func (s *pkcs11Signer) SignText(text []byte) ([]byte, error) {
+	return s.signDigest(accounts.TextHash(text))
+}
+
+// signDigest signs a 32 byte hash with CKM_ECDSA and returns it as a [R || S || V] signature
+// with V 0 or 1
+func (s *pkcs11Signer) signDigest(hash []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, s.key); err != nil {
+		return nil, fmt.Errorf("PKCS#11 signing failed: %v", err)
+	}
+	out, err := s.ctx.Sign(s.session, hash)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 signing failed: %v", err)
+	}
+	// CKM_ECDSA returns r and s side by side, without the recovery id
+	if len(out) != 64 {
+		return nil, fmt.Errorf("PKCS#11 signature has %d bytes, expected 64", len(out))
+	}
+	signature, err := recoverableSignature(hash, new(big.Int).SetBytes(out[:32]), new(big.Int).SetBytes(out[32:]), s.pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 signature: %v", err)
+	}
+	return signature, nil
+}
@@ -0,0 +1,14 @@
+//go:build !cgo
+
+package main
+
+import (
+	"errors"
+
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// loadPKCS11 needs cgo to load the module, which cross-compiled builds leave out
+func loadPKCS11(module string, slot int, label string) (sender.Signer, error) {
+	return nil, errors.New("this build has no PKCS#11 support, build it with CGO_ENABLED=1")
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"gopkg.in/yaml.v3"
+)
+
+// policyFile is the layout of the -policy file
+type policyFile struct {
+	// Destinations are the receivers allowed, addresses or address book names. Empty allows any
+	Destinations []string `yaml:"destinations"`
+	// Tokens are the token contracts allowed, and ETH for the native coin. Empty allows any
+	Tokens []string `yaml:"tokens"`
+	// Limits caps the amounts of ETH or of a token contract
+	Limits map[string]policyLimit `yaml:"limits"`
+}
+
+// policyLimit caps the amounts of one asset, in whole coins or tokens
+type policyLimit struct {
+	PerTx string `yaml:"perTx" json:"perTx,omitempty"`
+	Daily string `yaml:"daily" json:"daily,omitempty"`
+}
+
+// policyNative names the native coin in a policy, whatever its symbol on the chain
+const policyNative = "ETH"
+
+// sendPolicy is a loaded -policy file. Assets are keyed by token contract, and the native coin by
+// the zero address
+type sendPolicy struct {
+	path         string
+	destinations map[common.Address]bool // nil allows any receiver
+	assets       map[common.Address]bool // nil allows any asset
+	limits       map[common.Address]*assetLimit
+}
+
+// assetLimit holds the limits of one asset in base units, nil where there is none
+type assetLimit struct {
+	name     string
+	decimals int
+	perTx    *big.Int
+	daily    *big.Int
+}
+
+// activePolicy is the -policy of a server or daemon, nil without one. It is guarded by configMu
+var activePolicy *sendPolicy
+
+// policySpend adds up by asset what the transactions sent on the current UTC day took of the daily
+// limits. It outlives reloads of the policy
+var policySpend = struct {
+	sync.Mutex
+	day   string
+	spent map[common.Address]*big.Int
+}{spent: map[common.Address]*big.Int{}}
+
+// loadPolicy reads the policy file at path, looking up address book names and the decimals of
+// limited tokens on chainID. Unknown keys are refused, as a typo would drop a limit
+func loadPolicy(client *ethclient.Client, chainID *big.Int, path string) (*sendPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file policyFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	p := &sendPolicy{path: path, limits: map[common.Address]*assetLimit{}}
+	for _, entry := range file.Destinations {
+		address, err := resolveAllowed(entry, chainID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination in %s: %v", path, err)
+		}
+		if p.destinations == nil {
+			p.destinations = map[common.Address]bool{}
+		}
+		p.destinations[address] = true
+	}
+	for _, entry := range file.Tokens {
+		asset, err := policyAsset(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token in %s: %v", path, err)
+		}
+		if p.assets == nil {
+			p.assets = map[common.Address]bool{}
+		}
+		p.assets[asset] = true
+	}
+	for key, limit := range file.Limits {
+		asset, err := policyAsset(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit in %s: %v", path, err)
+		}
+		l := &assetLimit{name: policyNative, decimals: lookupChain(chainID).decimals}
+		if asset != (common.Address{}) {
+			token, err := loadToken(client, asset.Hex(), *tokenABIFlag)
+			if err == nil {
+				l.decimals, err = token.decimals()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the decimals of %s for its limits: %v", asset.Hex(), err)
+			}
+			l.name = token.symbol()
+		}
+		if limit.PerTx != "" {
+			if l.perTx, err = parseUnits(limit.PerTx, l.decimals); err != nil {
+				return nil, fmt.Errorf("invalid perTx limit of %s in %s: %v", key, path, err)
+			}
+		}
+		if limit.Daily != "" {
+			if l.daily, err = parseUnits(limit.Daily, l.decimals); err != nil {
+				return nil, fmt.Errorf("invalid daily limit of %s in %s: %v", key, path, err)
+			}
+		}
+		p.limits[asset] = l
+	}
+	return p, nil
+}
+
+// policyAsset parses an asset of a policy: ETH for the native coin, or a token contract
+func policyAsset(entry string) (common.Address, error) {
+	if entry == policyNative {
+		return common.Address{}, nil
+	}
+	return parseAddress(entry)
+}
+
+// seedPolicySpend counts the transactions the history has from senders on chainID today against
+// the daily limits, so that a restart does not reset them. Reverted and replaced ones took nothing
+func seedPolicySpend(chainID *big.Int, senders []common.Address) {
+	path, err := historyPath()
+	if err != nil || path == "" {
+		return
+	}
+	txs, err := loadHistory(path)
+	if err != nil {
+		warnf("Failed to read today's transactions for the policy's daily limits: %v", err)
+		return
+	}
+	policySpend.Lock()
+	defer policySpend.Unlock()
+	policySpend.day = time.Now().UTC().Format(time.DateOnly)
+	for _, tx := range txs {
+		if tx.ChainID != chainID.String() || tx.Status == "reverted" || tx.Status == "replaced" || tx.Time.UTC().Format(time.DateOnly) != policySpend.day ||
+			!slices.Contains(senders, common.HexToAddress(tx.From)) {
+			continue
+		}
+		asset, amount := common.Address{}, bigOrZero(tx.Value)
+		if tx.Token != "" {
+			asset, amount = common.HexToAddress(tx.Token), bigOrZero(tx.Amount)
+		}
+		if spent := policySpend.spent[asset]; spent != nil {
+			spent.Add(spent, amount)
+		} else {
+			policySpend.spent[asset] = amount
+		}
+	}
+}
+
+// admit checks a transaction to to of value and data against the policy, and counts its amount
+// against the daily limit. The returned function takes the amount back, for a transaction that
+// ends up not being sent. With a policy, only transfers of the native coin and ERC-20 transfer
+// calls are allowed
+func (p *sendPolicy) admit(to *common.Address, value *big.Int, data []byte) (func(), error) {
+	if p == nil {
+		return func() {}, nil
+	}
+	if to == nil {
+		return nil, errors.New("the policy allows no contract deployments")
+	}
+	receiver, asset, amount := *to, common.Address{}, value
+	if len(data) > 0 {
+		erc20 := mustLoadABI(erc20ABIJSON)
+		method, err := erc20.MethodById(data[:min(len(data), 4)])
+		if err != nil || method.Name != "transfer" || value.Sign() != 0 {
+			return nil, errors.New("the policy only allows transfers of the native coin and ERC-20 transfer calls")
+		}
+		args, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid transfer call: %v", err)
+		}
+		receiver, asset, amount = args[0].(common.Address), *to, args[1].(*big.Int)
+	}
+	if p.destinations != nil && !p.destinations[receiver] {
+		return nil, fmt.Errorf("receiver %s is not a destination of the policy %s", receiver.Hex(), p.path)
+	}
+	if p.assets != nil && !p.assets[asset] {
+		if asset == (common.Address{}) {
+			return nil, fmt.Errorf("the policy %s allows no transfers of the native coin", p.path)
+		}
+		return nil, fmt.Errorf("token %s is not allowed by the policy %s", asset.Hex(), p.path)
+	}
+	limit := p.limits[asset]
+	if limit == nil {
+		return func() {}, nil
+	}
+	format := func(units *big.Int) string {
+		return fmt.Sprintf("%s %s", formatUnits(units, limit.decimals), limit.name)
+	}
+	if limit.perTx != nil && amount.Cmp(limit.perTx) > 0 {
+		return nil, fmt.Errorf("%s exceeds the per-transaction limit of %s of the policy %s", format(amount), format(limit.perTx), p.path)
+	}
+	if limit.daily == nil {
+		return func() {}, nil
+	}
+
+	policySpend.Lock()
+	defer policySpend.Unlock()
+	day := time.Now().UTC().Format(time.DateOnly)
+	if day != policySpend.day {
+		policySpend.day, policySpend.spent = day, map[common.Address]*big.Int{}
+	}
+	spent := policySpend.spent[asset]
+	if spent == nil {
+		spent = new(big.Int)
+		policySpend.spent[asset] = spent
+	}
+	if total := new(big.Int).Add(spent, amount); total.Cmp(limit.daily) > 0 {
+		return nil, fmt.Errorf("%s would exceed the daily limit of %s of the policy %s, %s were sent today", format(amount), format(limit.daily), p.path, format(spent))
+	}
+	spent.Add(spent, amount)
+	return func() {
+		policySpend.Lock()
+		defer policySpend.Unlock()
+		if policySpend.day == day {
+			spent.Sub(spent, amount)
+		}
+	}, nil
+}
+
+// policyState describes the policy at /config, with what was sent today of each limited asset
+type policyState struct {
+	File         string                 `json:"file"`
+	Destinations []string               `json:"destinations,omitempty"`
+	Tokens       []string               `json:"tokens,omitempty"`
+	Limits       map[string]policyLimit `json:"limits,omitempty"`
+	SentToday    map[string]string      `json:"sentToday,omitempty"`
+}
+
+// state returns the policy as served at /config
+func (p *sendPolicy) state() *policyState {
+	if p == nil {
+		return nil
+	}
+	name := func(asset common.Address) string {
+		if asset == (common.Address{}) {
+			return policyNative
+		}
+		return asset.Hex()
+	}
+	s := &policyState{File: p.path, Limits: map[string]policyLimit{}, SentToday: map[string]string{}}
+	for address := range p.destinations {
+		s.Destinations = append(s.Destinations, address.Hex())
+	}
+	slices.Sort(s.Destinations)
+	for asset := range p.assets {
+		s.Tokens = append(s.Tokens, name(asset))
+	}
+	slices.Sort(s.Tokens)
+	policySpend.Lock()
+	defer policySpend.Unlock()
+	today := policySpend.day == time.Now().UTC().Format(time.DateOnly)
+	for asset, limit := range p.limits {
+		var l policyLimit
+		if limit.perTx != nil {
+			l.PerTx = formatUnits(limit.perTx, limit.decimals)
+		}
+		if limit.daily != nil {
+			l.Daily = formatUnits(limit.daily, limit.decimals)
+			sent := new(big.Int)
+			if spent := policySpend.spent[asset]; today && spent != nil {
+				sent = spent
+			}
+			s.SentToday[name(asset)] = formatUnits(sent, limit.decimals)
+		}
+		s.Limits[name(asset)] = l
+	}
+	return s
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// senderAccount is an account of the sender pool. It has nonces of its own, so its transactions
+// never queue behind those of another account
+type senderAccount struct {
+	signer sender.Signer
+	nonces *sender.NonceManager
+	busy   int // jobs in flight
+}
+
+// senderPool spreads the transfers of -batch, -stdin and daemon over several accounts, to get
+// past the rate at which a single account's sequential nonces are mined
+type senderPool struct {
+	mu       sync.Mutex
+	accounts []*senderAccount
+	next     int // where the search for the least busy account starts, so ties take turns
+}
+
+// newSenderPool returns a pool of a single account, which shares the process-wide nonces
+func newSenderPool(signer sender.Signer) *senderPool {
+	return &senderPool{accounts: []*senderAccount{{signer: signer, nonces: nonces}}}
+}
+
+// resyncNonces makes the next send of every account of the pool read its nonce from the node
+// again, moving past transactions sent elsewhere
+func (p *senderPool) resyncNonces() {
+	for _, account := range p.accounts {
+		from := account.signer.Address()
+		account.nonces.Resync(from)
+		nonces.Resync(from)
+	}
+}
+
+// acquire returns the account with the fewest jobs in flight and counts one more for it
+func (p *senderPool) acquire() *senderAccount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	best := -1
+	for i := range p.accounts {
+		index := (p.next + i) % len(p.accounts)
+		if best < 0 || p.accounts[index].busy < p.accounts[best].busy {
+			best = index
+		}
+	}
+	p.next = best + 1
+	p.accounts[best].busy++
+	return p.accounts[best]
+}
+
+// release counts a job of account as done
+func (p *senderPool) release(account *senderAccount) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	account.busy--
+}
+
+// describe lists the addresses of the pool
+func (p *senderPool) describe() string {
+	addresses := make([]string, len(p.accounts))
+	for i, account := range p.accounts {
+		addresses[i] = account.signer.Address().Hex()
+	}
+	return strings.Join(addresses, ", ")
+}
+
+// addresses returns the addresses of the pool
+func (p *senderPool) addresses() []common.Address {
+	addresses := make([]common.Address, len(p.accounts))
+	for i, account := range p.accounts {
+		addresses[i] = account.signer.Address()
+	}
+	return addresses
+}
+
+// poolKeysGiven tells whether -privateKeysEnv or -keystoreDir selects a pool of senders
+func poolKeysGiven() bool {
+	return *privateKeysEnv != "" || *keystoreDir != ""
+}
+
+// loadSenderPool loads the accounts of -privateKeysEnv or -keystoreDir, or the single account of
+// the other key sources
+func loadSenderPool() (*senderPool, error) {
+	if !poolKeysGiven() {
+		signer, err := loadSigner()
+		if err != nil {
+			return nil, err
+		}
+		return newSenderPool(signer), nil
+	}
+	for name, set := range keySources() {
+		if set && name != "-privateKeysEnv" && name != "-keystoreDir" {
+			return nil, invalidf("%s cannot be combined with a sender pool", name)
+		}
+	}
+	if *nonceFlag >= 0 {
+		return nil, invalidf("-nonce cannot be combined with a sender pool, whose accounts take their own nonces")
+	}
+
+	var signers []sender.Signer
+	switch {
+	case *privateKeysEnv != "" && *keystoreDir != "":
+		return nil, invalidf("-privateKeysEnv and -keystoreDir are mutually exclusive")
+	case *privateKeysEnv != "":
+		for _, name := range strings.Split(*privateKeysEnv, ",") {
+			signer, err := envKeySigner(strings.TrimSpace(name))
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, signer)
+		}
+	default:
+		entries, err := os.ReadDir(*keystoreDir)
+		if err != nil {
+			return nil, invalidf("%v", err)
+		}
+		password := *passwordFlag
+		if password == "" {
+			if password, err = promptPassword(fmt.Sprintf("Password for the keystores in %s: ", *keystoreDir)); err != nil {
+				return nil, invalidf("%v", err)
+			}
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			signer, err := loadKeystore(filepath.Join(*keystoreDir, entry.Name()), password)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+			signers = append(signers, signer)
+		}
+		if len(signers) == 0 {
+			return nil, invalidf("%s holds no keystore files", *keystoreDir)
+		}
+	}
+
+	pool := &senderPool{}
+	seen := map[common.Address]bool{}
+	for _, signer := range signers {
+		if seen[signer.Address()] {
+			return nil, invalidf("%s is in the pool twice, its transactions would share nonces", signer.Address().Hex())
+		}
+		seen[signer.Address()] = true
+		pool.accounts = append(pool.accounts, &senderAccount{signer: signer, nonces: &sender.NonceManager{Next: nextNonce}})
+	}
+	return pool, nil
+}
+
+// account returns the account of the pool sending from address, nil if there is none
+func (p *senderPool) account(address common.Address) *senderAccount {
+	for _, account := range p.accounts {
+		if account.signer.Address() == address {
+			return account
+		}
+	}
+	return nil
+}
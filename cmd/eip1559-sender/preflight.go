@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// etherscanAPI is the multichain endpoint of the Etherscan API, which takes the chain ID as a
+// parameter
+const etherscanAPI = "https://api.etherscan.io/v2/api"
+
+// explorerTxPage is how many transactions of the account are asked from the explorer, incoming
+// ones included, to find the latest outgoing ones among them
+const explorerTxPage = 100
+
+// recentTx is an outgoing transaction of the account, as the explorer or the history knows it
+type recentTx struct {
+	time  time.Time
+	hash  string
+	to    string
+	value *big.Int
+	// token and amount, in base units, describe an ERC-20 transfer
+	token  string
+	amount *big.Int
+	status string
+}
+
+// runPreflight prints the state of the account from for -preflight full, for a last look before
+// a big transfer: its nonces, its native coin balance and that of tokens, and its latest outgoing
+// transactions
+func runPreflight(client *ethclient.Client, chainID *big.Int, from common.Address, tokens []string, nf numberFormat) {
+	if *preflightFlag == "" {
+		return
+	}
+	if *preflightFlag != "full" {
+		exitf(exitInvalid, "Invalid -preflight %q, expected full", *preflightFlag)
+	}
+	ctx := opCtx
+	chain := lookupChain(chainID)
+	infof("Preflight of %s:", from.Hex())
+	mined, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get nonce: %v", err)
+	}
+	pending, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		exitf(exitRPC, "Failed to get pending nonce: %v", err)
+	}
+	infof("  Nonce: %d, %d transaction(s) pending", mined, max(pending, mined)-mined)
+	balance, err := client.BalanceAt(ctx, from, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get balance: %v", err)
+	}
+	infof("  Balance: %s %s%s", nf.format(formatUnits(balance, chain.decimals)), chain.symbol, fiatAmount(client, chainID, balance, chain.decimals, nf))
+	for _, address := range tokens {
+		token, err := loadToken(client, address, *tokenABIFlag)
+		if err != nil {
+			warnf("  Token %s: %v", address, err)
+			continue
+		}
+		amount, err := token.balanceOf(from)
+		if err != nil {
+			warnf("  Token %s: %v", token.address.Hex(), err)
+			continue
+		}
+		decimals, err := token.decimals()
+		if err != nil {
+			warnf("  Token %s: %v", token.address.Hex(), err)
+			continue
+		}
+		infof("  Balance: %s %s (%s)", nf.format(formatUnits(amount, decimals)), token.symbol(), token.address.Hex())
+	}
+
+	txs, source, err := recentOutgoing(chainID, from, *preflightTxs)
+	if err != nil {
+		warnf("  Failed to list the latest outgoing transactions: %v", err)
+		return
+	}
+	if len(txs) == 0 {
+		infof("  No outgoing transactions in %s", source)
+		return
+	}
+	infof("  Latest outgoing transactions, from %s:", source)
+	units := map[common.Address]*tokenUnits{}
+	for _, tx := range txs {
+		amount := nf.format(formatUnits(tx.value, chain.decimals)) + " " + chain.symbol
+		if tx.token != "" {
+			amount = nf.format(tx.amount.String()) + " of " + tx.token
+			if u := lookupTokenUnits(client, common.HexToAddress(tx.token), units); u != nil {
+				amount = nf.format(formatUnits(tx.amount, u.decimals)) + " " + u.symbol
+			}
+		}
+		infof("    %s  %s  to %s  %s  %s", tx.time.Local().Format("2006-01-02 15:04:05"), tx.hash, tx.to, amount, tx.status)
+	}
+}
+
+// recentOutgoing returns the latest limit transactions sent by from, newest first, and where
+// they come from: the explorer API if one is configured, else the history
+func recentOutgoing(chainID *big.Int, from common.Address, limit int) ([]recentTx, string, error) {
+	if api, key := explorerAPI(); api != "" {
+		txs, err := explorerOutgoing(api, key, chainID, from, limit)
+		return txs, "the explorer API", err
+	}
+	path, err := historyPath()
+	if err != nil {
+		return nil, "", err
+	}
+	if path == "" {
+		return nil, "", errors.New("the history is off, pass -explorerAPI or -explorerAPIKey")
+	}
+	records, err := loadHistory(path)
+	if err != nil {
+		return nil, "", err
+	}
+	var txs []recentTx
+	for i := len(records) - 1; i >= 0 && len(txs) < limit; i-- {
+		r := records[i]
+		if r.ChainID != chainID.String() || !strings.EqualFold(r.From, from.Hex()) {
+			continue
+		}
+		tx := recentTx{time: r.Time, hash: r.Hash, to: r.To, value: bigOrZero(r.Value), status: r.Status}
+		if r.Token != "" {
+			tx.token, tx.amount = r.Token, bigOrZero(r.Amount)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, path, nil
+}
+
+// explorerAPI returns the Etherscan-compatible API to list transactions with and its key: that
+// of -explorerAPI, or Etherscan's if there is a key. It returns "" if there is neither
+func explorerAPI() (api, key string) {
+	key = *explorerAPIKey
+	if key == "" {
+		key = os.Getenv("ETHERSCAN_API_KEY")
+	}
+	switch {
+	case *explorerAPIFlag != "":
+		return *explorerAPIFlag, key
+	case key != "":
+		return etherscanAPI, key
+	}
+	return "", ""
+}
+
+// explorerOutgoing lists the latest limit transactions sent by from with the txlist action of
+// the Etherscan-compatible api, which Blockscout instances serve too
+func explorerOutgoing(api, key string, chainID *big.Int, from common.Address, limit int) ([]recentTx, error) {
+	params := url.Values{
+		"module":  {"account"},
+		"action":  {"txlist"},
+		"address": {from.Hex()},
+		"sort":    {"desc"},
+		"page":    {"1"},
+		"offset":  {strconv.Itoa(explorerTxPage)},
+		"chainid": {chainID.String()},
+	}
+	if key != "" {
+		params.Set("apikey", key)
+	}
+	endpoint := api
+	if strings.Contains(endpoint, "?") {
+		endpoint += "&" + params.Encode()
+	} else {
+		endpoint += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(opCtx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := proxyTransport()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := (&http.Client{Transport: transport, Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		// the URL carries the key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	var reply struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Result  json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	var entries []struct {
+		Hash      string `json:"hash"`
+		TimeStamp string `json:"timeStamp"`
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Value     string `json:"value"`
+		Input     string `json:"input"`
+		IsError   string `json:"isError"`
+	}
+	if reply.Status != "1" {
+		if strings.HasPrefix(reply.Message, "No transactions found") {
+			return nil, nil
+		}
+		// the result holds the reason of an error
+		var reason string
+		json.Unmarshal(reply.Result, &reason)
+		return nil, fmt.Errorf("%s: %s", reply.Message, reason)
+	}
+	if err := json.Unmarshal(reply.Result, &entries); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	erc20 := mustLoadABI(erc20ABIJSON)
+	var txs []recentTx
+	for _, e := range entries {
+		if len(txs) == limit {
+			break
+		}
+		if !strings.EqualFold(e.From, from.Hex()) {
+			continue
+		}
+		seconds, _ := strconv.ParseInt(e.TimeStamp, 10, 64)
+		tx := recentTx{time: time.Unix(seconds, 0), hash: e.Hash, to: e.To, value: bigOrZero(e.Value), status: "success"}
+		if e.IsError == "1" {
+			tx.status = "reverted"
+		}
+		if e.To == "" {
+			tx.to = "(contract creation)"
+		}
+		// show ERC-20 transfers as the tokens they move
+		if input, err := hexutil.Decode(e.Input); err == nil && len(input) >= 4 && e.To != "" {
+			if method, err := erc20.MethodById(input[:4]); err == nil && method.Name == "transfer" {
+				if args, err := method.Inputs.Unpack(input[4:]); err == nil {
+					tx.token = common.HexToAddress(e.To).Hex()
+					tx.to = args[0].(common.Address).Hex()
+					tx.amount = args[1].(*big.Int)
+				}
+			}
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
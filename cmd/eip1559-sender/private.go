@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// privateTxBlocks is how many blocks a relay keeps trying to include a private transaction
+const privateTxBlocks = 25
+
+// flashbotsRelays are the default -relayURL by chain ID
+var flashbotsRelays = map[uint64]string{
+	1:        "https://relay.flashbots.net",
+	11155111: "https://relay-sepolia.flashbots.net",
+}
+
+// relayKey signs the requests to the relay. It only identifies the sender to the relay, so an
+// ephemeral key is generated per run
+var relayKey = sync.OnceValues(crypto.GenerateKey)
+
+// sendTransaction broadcasts tx through -rpcURL, or with -private through the private relay
+func sendTransaction(ctx context.Context, client *ethclient.Client, tx *types.Transaction) error {
+	var err error
+	if *privateFlag {
+		err = sendPrivateTransaction(ctx, client, tx)
+	} else {
+		err = sender.Broadcast(ctx, client, tx)
+		// a broadcast retried after its response was lost finds the transaction in the pool,
+		// or already mined
+		if err != nil && (strings.Contains(err.Error(), "already known") || strings.Contains(err.Error(), "nonce too low")) {
+			if _, _, lookupErr := client.TransactionByHash(ctx, tx.Hash()); lookupErr == nil {
+				debugf("Transaction %s was already known to the node", tx.Hash().Hex())
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		return explainBroadcastError(ctx, client, tx, err)
+	}
+	memo := sendMemo(ctx)
+	rememberMemo(tx.Hash(), memo)
+	recordSent(tx, sendIdempotencyKey(ctx), memo)
+	return nil
+}
+
+// sendPrivateTransaction submits tx with eth_sendPrivateTransaction, so it only reaches block
+// builders and never the public mempool
+func sendPrivateTransaction(ctx context.Context, client *ethclient.Client, tx *types.Transaction) error {
+	if tx.Type() == types.BlobTxType {
+		return errors.New("blob transactions cannot be sent privately")
+	}
+	relay, err := relayURL(ctx, client)
+	if err != nil {
+		return err
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	params := map[string]string{
+		"tx":             hexutil.Encode(raw),
+		"maxBlockNumber": hexutil.EncodeUint64(head + privateTxBlocks),
+	}
+	if err := relayCall(ctx, relay, "eth_sendPrivateTransaction", params, nil); err != nil {
+		return err
+	}
+	infof("Sent privately through %s, valid until block %d", relay, head+privateTxBlocks)
+	return nil
+}
+
+// relayURL returns -relayURL, or the Flashbots relay of the chain
+func relayURL(ctx context.Context, client *ethclient.Client) (string, error) {
+	if *relayURLFlag != "" {
+		return *relayURLFlag, nil
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return "", err
+	}
+	relay := flashbotsRelays[chainID.Uint64()]
+	if relay == "" {
+		return "", fmt.Errorf("no default private relay on chain %s, pass -relayURL", chainID)
+	}
+	return relay, nil
+}
+
+// relayCall calls method on the relay with a single parameter object, signed as the relay
+// requires, and decodes its result into result unless nil
+func relayCall(ctx context.Context, relay, method string, params, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  []interface{}{params},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, relay, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signature, err := relaySignature(body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Flashbots-Signature", signature)
+
+	transport, err := proxyTransport()
+	if err != nil {
+		return err
+	}
+	httpClient := &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("relay: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("relay: %v", err)
+	}
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return fmt.Errorf("relay: %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("relay: %s (code %d)", reply.Error.Message, reply.Error.Code)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(reply.Result, result); err != nil {
+		return fmt.Errorf("relay: unexpected result of %s: %v", method, err)
+	}
+	return nil
+}
+
+// relaySignature signs body for the X-Flashbots-Signature header
+func relaySignature(body []byte) (string, error) {
+	key, err := relayKey()
+	if err != nil {
+		return "", err
+	}
+	hash := accounts.TextHash([]byte(crypto.Keccak256Hash(body).Hex()))
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return "", err
+	}
+	return crypto.PubkeyToAddress(key.PublicKey).Hex() + ":" + hexutil.Encode(sig), nil
+}
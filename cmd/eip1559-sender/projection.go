@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// balanceProbeCode is run by eth_call in place of the code of the sender. Given the target, value,
+// receiver and token as four words of calldata followed by the transaction's data, it makes the
+// call of the transaction and returns whether it succeeded, the ETH balances of the sender and
+// the receiver, and their balanceOf on the token, 0 without a token
+var balanceProbeCode = common.FromHex("0x36608090038060806101003760006000826101006020356000355af16000525047602052604035316040526370a0823160e01b60c0523060c45260206060602460c06060355afa5060403560c45260206080602460c06060355afa5060a06000f3")
+
+// balanceProjection is what the sender and the receiver of a transaction hold once it is mined,
+// leaving out the gas fee
+type balanceProjection struct {
+	receiver  common.Address
+	token     *erc20Token // of a transfer call, nil otherwise
+	from, to  *big.Int    // ETH balances
+	tokenFrom *big.Int
+	tokenTo   *big.Int
+}
+
+// projectBalances simulates tx with the state override of eth_call that puts balanceProbeCode at
+// the sender's address, so the call runs with the real sender and its balances. The receiver of
+// a token transfer is the one in its calldata
+func projectBalances(client *ethclient.Client, from common.Address, tx *types.Transaction) (*balanceProjection, error) {
+	if tx.To() == nil || *tx.To() == from || len(tx.SetCodeAuthorizations()) > 0 {
+		return nil, errors.New("only calls to other accounts without authorizations can be simulated")
+	}
+	p := &balanceProjection{receiver: *tx.To()}
+	tokenAddress := common.Address{}
+	if data := tx.Data(); len(data) >= 4 {
+		erc20 := mustLoadABI(erc20ABIJSON)
+		if method, err := erc20.MethodById(data[:4]); err == nil && method.Name == "transfer" {
+			if args, err := method.Inputs.Unpack(data[4:]); err == nil {
+				if p.token, err = loadToken(client, tx.To().Hex(), *tokenABIFlag); err != nil {
+					return nil, err
+				}
+				p.receiver, tokenAddress = args[0].(common.Address), *tx.To()
+			}
+		}
+	}
+	input := append(common.LeftPadBytes(tx.To().Bytes(), 32), common.LeftPadBytes(tx.Value().Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(p.receiver.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(tokenAddress.Bytes(), 32)...)
+	input = append(input, tx.Data()...)
+	call := map[string]interface{}{"from": from, "to": from, "data": hexutil.Bytes(input)}
+	override := map[common.Address]map[string]interface{}{from: {"code": hexutil.Bytes(balanceProbeCode)}}
+	var out hexutil.Bytes
+	if err := client.Client().CallContext(opCtx, &out, "eth_call", call, "pending", override); err != nil {
+		return nil, err
+	}
+	if len(out) != 5*32 {
+		return nil, fmt.Errorf("unexpected result of %d bytes, the node may not support state overrides", len(out))
+	}
+	word := func(i int) *big.Int { return new(big.Int).SetBytes(out[i*32 : (i+1)*32]) }
+	if word(0).Sign() == 0 {
+		return nil, errors.New("the transaction reverts")
+	}
+	p.from, p.to, p.tokenFrom, p.tokenTo = word(1), word(2), word(3), word(4)
+	return p, nil
+}
+
+// describeProjection returns the summary lines with the balances after tx, the sender's less fee,
+// the most tx may pay for gas. It warns when that leaves the sender too little to pay as much
+// again, so it could not even send a transaction like this one afterwards
+func describeProjection(client *ethclient.Client, chainID *big.Int, from common.Address, tx *types.Transaction, fee *big.Int, nf numberFormat) []string {
+	p, err := projectBalances(client, from, tx)
+	if err != nil {
+		debugf("Not projecting the balances after the transaction: %v", err)
+		return nil
+	}
+	chain := lookupChain(chainID)
+	left := new(big.Int).Sub(p.from, fee)
+	if left.Sign() < 0 {
+		left.SetInt64(0)
+	}
+	eth := func(amount *big.Int) string {
+		return fmt.Sprintf("%s %s", nf.format(formatUnits(amount, chain.decimals)), chain.symbol)
+	}
+	tokens := func(amount *big.Int) string {
+		if p.token == nil {
+			return ""
+		}
+		decimals, err := p.token.decimals()
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf(" and %s %s", nf.format(formatUnits(amount, decimals)), p.token.symbol())
+	}
+	lines := []string{fmt.Sprintf("Sender:   at least %s%s after the transaction", eth(left), tokens(p.tokenFrom))}
+	if p.receiver != from {
+		lines = append(lines, fmt.Sprintf("Receiver: %s%s after the transaction", eth(p.to), tokens(p.tokenTo)))
+	}
+	// -max spends the whole ETH balance on purpose
+	if left.Cmp(fee) < 0 && !(*maxFlag && *tokenContract == "") {
+		warnf("The sender may be left with %s, less than the %s this transaction may pay for gas: too little to pay for another like it", eth(left), eth(fee))
+	}
+	return lines
+}
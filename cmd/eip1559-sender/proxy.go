@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// headerFlags collects the values of a repeatable "Name: value" header flag
+type headerFlags []string
+
+// headerFlag registers a repeatable header flag on the root flag set
+func headerFlag(name, usage string) *headerFlags {
+	h := &headerFlags{}
+	flag.Var(h, name, usage)
+	return h
+}
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	name, _, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return errors.New(`expected "Name: value"`)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// header returns the headers as an http.Header
+func (h *headerFlags) header() http.Header {
+	header := http.Header{}
+	for _, value := range *h {
+		name, value, _ := strings.Cut(value, ":")
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return header
+}
+
+// proxyFunc returns the proxy of -proxy, or the one of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables
+func proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if *proxyFlag == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(*proxyFlag)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https, socks5 or socks5h", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// proxyTransport returns an HTTP transport going through the proxy
+func proxyTransport() (*http.Transport, error) {
+	proxy, err := proxyFunc()
+	if err != nil {
+		return nil, fmt.Errorf("invalid -proxy: %v", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	return transport, nil
+}
+
+// baseTransport returns the transport HTTP RPC requests go out on, through the proxy and with
+// the headers of the flags
+func baseTransport() (http.RoundTripper, error) {
+	transport, err := proxyTransport()
+	if err != nil || len(*rpcHeaders) == 0 {
+		return transport, err
+	}
+	return headerTransport{transport, rpcHeaders.header()}, nil
+}
+
+// websocketOptions returns the options of WebSocket RPC connections: the proxy and the headers
+// of the flags, which the HTTP transport does not apply to them
+func websocketOptions() ([]rpc.ClientOption, error) {
+	proxy, err := proxyFunc()
+	if err != nil {
+		return nil, fmt.Errorf("invalid -proxy: %v", err)
+	}
+	dialer := *websocket.DefaultDialer
+	dialer.Proxy = proxy
+	return []rpc.ClientOption{rpc.WithWebsocketDialer(dialer), rpc.WithHeaders(rpcHeaders.header())}, nil
+}
+
+// headerTransport adds headers to every request
+type headerTransport struct {
+	next   http.RoundTripper
+	header http.Header
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		req.Header[name] = values
+	}
+	return t.next.RoundTrip(req)
+}
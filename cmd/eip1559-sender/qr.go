@@ -0,0 +1,438 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// qrVersion is the error correction layout of a QR code version at level M: the number of error
+// correction codewords of each block, the data codewords of each block, and the centers of its
+// alignment patterns
+type qrVersion struct {
+	ecPerBlock int
+	blocks     []int
+	alignment  []int
+}
+
+// qrVersions are versions 1 to 10 at error correction level M, which fit 213 bytes, more than a
+// payment request or explorer link takes
+var qrVersions = []qrVersion{
+	{10, []int{16}, nil},
+	{16, []int{28}, []int{6, 18}},
+	{26, []int{44}, []int{6, 22}},
+	{18, []int{32, 32}, []int{6, 26}},
+	{24, []int{43, 43}, []int{6, 30}},
+	{16, []int{27, 27, 27, 27}, []int{6, 34}},
+	{18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	{22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	{22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	{26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+// qrCode is the module matrix of a QR code, true for dark modules
+type qrCode struct {
+	size     int
+	dark     [][]bool
+	function [][]bool // finder, timing, alignment, format and version modules, which masks skip
+}
+
+// encodeQR encodes text in byte mode at error correction level M, in the smallest version it
+// fits, with the mask the standard's penalty rules favor
+func encodeQR(text string) (*qrCode, error) {
+	data := []byte(text)
+	version := 0
+	var layout qrVersion
+	for i, v := range qrVersions {
+		if len(data) <= qrCapacity(i+1, v) {
+			version, layout = i+1, v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%d bytes do not fit a QR code of version 10 (at most %d)", len(data), qrCapacity(10, qrVersions[9]))
+	}
+
+	// mode, length, data, terminator and padding
+	var bits qrBits
+	bits.append(0b0100, 4)
+	bits.append(len(data), qrCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := qrDataCodewords(layout) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	// split into blocks, add their error correction and interleave them
+	divisor := rsDivisor(layout.ecPerBlock)
+	var dataBlocks, ecBlocks [][]byte
+	for _, n := range layout.blocks {
+		dataBlocks = append(dataBlocks, codewords[:n])
+		ecBlocks = append(ecBlocks, rsRemainder(codewords[:n], divisor))
+		codewords = codewords[n:]
+	}
+	var stream []byte
+	for i := 0; i < layout.blocks[len(layout.blocks)-1]; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				stream = append(stream, block[i])
+			}
+		}
+	}
+	for i := 0; i < layout.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			stream = append(stream, block[i])
+		}
+	}
+
+	qr := newQRCode(version, layout)
+	qr.placeData(stream)
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormat(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		// masking twice restores the data
+		qr.applyMask(mask)
+	}
+	qr.applyMask(best)
+	qr.drawFormat(best)
+	return qr, nil
+}
+
+// qrCountBits is the length of the byte count in version
+func qrCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// qrDataCodewords is the number of data codewords of layout
+func qrDataCodewords(layout qrVersion) int {
+	total := 0
+	for _, n := range layout.blocks {
+		total += n
+	}
+	return total
+}
+
+// qrCapacity is the number of bytes version holds in byte mode
+func qrCapacity(version int, layout qrVersion) int {
+	return (qrDataCodewords(layout)*8 - 4 - qrCountBits(version)) / 8
+}
+
+// qrBits is a bit stream, most significant bit first
+type qrBits []bool
+
+func (b *qrBits) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 == 1)
+	}
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ z>>7*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of degree n, without its leading 1
+func rsDivisor(n int) []byte {
+	result := make([]byte, n)
+	result[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < n {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+// newQRCode draws the function patterns of version
+func newQRCode(version int, layout qrVersion) *qrCode {
+	size := version*4 + 17
+	qr := &qrCode{size: size, dark: make([][]bool, size), function: make([][]bool, size)}
+	for y := range qr.dark {
+		qr.dark[y] = make([]bool, size)
+		qr.function[y] = make([]bool, size)
+	}
+	for i := 0; i < size; i++ {
+		qr.set(6, i, i%2 == 0)
+		qr.set(i, 6, i%2 == 0)
+	}
+	for _, center := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x >= 0 && x < size && y >= 0 && y < size {
+					dist := max(abs(dx), abs(dy))
+					qr.set(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+	last := len(layout.alignment) - 1
+	for i, cx := range layout.alignment {
+		for j, cy := range layout.alignment {
+			// the finder patterns take these corners
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// reserve the format modules, drawn once the mask is chosen
+	qr.drawFormat(0)
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ rem>>11*0x1F25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			a, b := size-11+i%3, i/3
+			qr.set(a, b, bits>>i&1 == 1)
+			qr.set(b, a, bits>>i&1 == 1)
+		}
+	}
+	return qr
+}
+
+// set sets a function module, x being the column and y the row
+func (qr *qrCode) set(x, y int, dark bool) {
+	qr.dark[y][x] = dark
+	qr.function[y][x] = true
+}
+
+// drawFormat draws both copies of the format information of level M with mask
+func (qr *qrCode) drawFormat(mask int) {
+	// level M is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ rem>>9*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		qr.set(8, i, bit(i))
+	}
+	qr.set(8, 7, bit(6))
+	qr.set(8, 8, bit(7))
+	qr.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		qr.set(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.set(8, qr.size-15+i, bit(i))
+	}
+	// the dark module
+	qr.set(8, qr.size-8, true)
+}
+
+// placeData fills the modules left free by the function patterns with stream, in two-column
+// strips zigzagging up and down from the bottom right corner
+func (qr *qrCode) placeData(stream []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// the vertical timing pattern
+			right = 5
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if !qr.function[y][x] && i < len(stream)*8 {
+					qr.dark[y][x] = stream[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by mask
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip && !qr.function[y][x] {
+				qr.dark[y][x] = !qr.dark[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan: long runs of one color, 2x2 blocks, patterns
+// resembling a finder and an uneven share of dark modules
+func (qr *qrCode) penalty() int {
+	score, dark := 0, 0
+	finderLike := []string{"10111010000", "00001011101"}
+	for _, vertical := range []bool{false, true} {
+		for a := 0; a < qr.size; a++ {
+			var line strings.Builder
+			run := 0
+			for b := 0; b < qr.size; b++ {
+				x, y := b, a
+				if vertical {
+					x, y = a, b
+				}
+				if b > 0 && qr.at(x, y) == qr.at(x-boolInt(!vertical), y-boolInt(vertical)) {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					score += 3
+				} else if run > 5 {
+					score++
+				}
+				if qr.at(x, y) {
+					line.WriteByte('1')
+				} else {
+					line.WriteByte('0')
+				}
+			}
+			for _, pattern := range finderLike {
+				score += 40 * strings.Count(line.String(), pattern)
+			}
+		}
+	}
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.dark[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 && qr.at(x, y) == qr.at(x-1, y) && qr.at(x, y) == qr.at(x, y-1) && qr.at(x, y) == qr.at(x-1, y-1) {
+				score += 3
+			}
+		}
+	}
+	total := qr.size * qr.size
+	score += ((abs(dark*20-total*10)+total-1)/total - 1) * 10
+	return score
+}
+
+func (qr *qrCode) at(x, y int) bool {
+	return qr.dark[y][x]
+}
+
+// print renders the code with half blocks, two rows per line, in white and black regardless of
+// the terminal's colors and with the quiet zone scanners need around it
+func (qr *qrCode) print(w io.Writer) {
+	const quiet = 4
+	light := func(x, y int) bool {
+		return x < 0 || y < 0 || x >= qr.size || y >= qr.size || !qr.dark[y][x]
+	}
+	for y := -quiet; y < qr.size+quiet; y += 2 {
+		var line strings.Builder
+		line.WriteString("\x1b[97;40m")
+		for x := -quiet; x < qr.size+quiet; x++ {
+			switch top, bottom := light(x, y), light(x, y+1); {
+			case top && bottom:
+				line.WriteString("█")
+			case top:
+				line.WriteString("▀")
+			case bottom:
+				line.WriteString("▄")
+			default:
+				line.WriteString(" ")
+			}
+		}
+		line.WriteString("\x1b[0m")
+		fmt.Fprintln(w, line.String())
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// printQR prints text as a QR code on stdout, unless the output is JSON
+func printQR(text string) {
+	if *logFormatFlag == "json" {
+		return
+	}
+	qr, err := encodeQR(text)
+	if err != nil {
+		warnf("Failed to render the QR code: %v", err)
+		return
+	}
+	qr.print(os.Stdout)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// jobQueue is a source of daemon jobs that their results are written back to
+type jobQueue interface {
+	// next blocks until a job is available or ctx is done
+	next(ctx context.Context) (*queuedJob, error)
+	// finish writes the result of job back to the queue
+	finish(job *queuedJob, result []byte, failed bool) error
+	close()
+}
+
+// queuedJob is the raw JSON of a job together with where it came from
+type queuedJob struct {
+	data []byte
+	// name identifies the job in the log: a file name, or the list or subject it was read from
+	name string
+	// reply is the NATS subject awaiting the result, if any
+	reply string
+}
+
+// openQueue connects to the queue named by spec: a directory path, redis://...?list=name or
+// nats://...?subject=name
+func openQueue(spec string) (jobQueue, error) {
+	switch {
+	case strings.HasPrefix(spec, "redis://"), strings.HasPrefix(spec, "rediss://"):
+		return openRedisQueue(spec)
+	case strings.HasPrefix(spec, "nats://"), strings.HasPrefix(spec, "tls://"):
+		return openNATSQueue(spec)
+	}
+	return openDirQueue(spec)
+}
+
+// takeQueryParam removes the query parameter key from rawURL, returning both
+func takeQueryParam(rawURL, key string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := u.Query()
+	value := query.Get(key)
+	if value == "" {
+		return "", "", fmt.Errorf("missing %s parameter in %s", key, u.Redacted())
+	}
+	query.Del(key)
+	u.RawQuery = query.Encode()
+	return u.String(), value, nil
+}
+
+// dirQueue takes jobs from the *.json files of a directory. A job file is moved to processing/
+// while it runs, then to done/ or failed/ with its result written next to it as *.result.json
+type dirQueue struct {
+	dir string
+}
+
+func openDirQueue(dir string) (*dirQueue, error) {
+	for _, sub := range []string{"processing", "done", "failed"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	// jobs left in processing/ by a crash may or may not have been sent, unlike those handed off
+	// at shutdown, which the daemon follows again
+	stale, _ := filepath.Glob(filepath.Join(dir, "processing", "*.json"))
+	handedOff := handedOffJobs(dir)
+	stale = slices.DeleteFunc(stale, func(file string) bool { return handedOff[filepath.Base(file)] })
+	if len(stale) > 0 {
+		warnf("%d job(s) in %s were interrupted, check them and move them back to retry", len(stale), filepath.Join(dir, "processing"))
+	}
+	return &dirQueue{dir: dir}, nil
+}
+
+func (q *dirQueue) next(ctx context.Context) (*queuedJob, error) {
+	for {
+		files, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		// oldest name first, so jobs named by time run in order
+		sort.Strings(files)
+		for _, file := range files {
+			name := filepath.Base(file)
+			claimed := filepath.Join(q.dir, "processing", name)
+			// the rename claims the job, it fails if another daemon took it first
+			if err := os.Rename(file, claimed); err != nil {
+				continue
+			}
+			data, err := os.ReadFile(claimed)
+			if err != nil {
+				return nil, err
+			}
+			return &queuedJob{data: data, name: name}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (q *dirQueue) finish(job *queuedJob, result []byte, failed bool) error {
+	target := filepath.Join(q.dir, "done")
+	if failed {
+		target = filepath.Join(q.dir, "failed")
+	}
+	resultFile := filepath.Join(target, strings.TrimSuffix(job.name, ".json")+".result.json")
+	if err := os.WriteFile(resultFile, append(result, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(q.dir, "processing", job.name), filepath.Join(target, job.name))
+}
+
+func (q *dirQueue) close() {}
+
+// redisQueue pops jobs off a Redis list and pushes the results onto <list>:results
+type redisQueue struct {
+	client *redis.Client
+	list   string
+}
+
+func openRedisQueue(spec string) (*redisQueue, error) {
+	rawURL, list, err := takeQueryParam(spec, "list")
+	if err != nil {
+		return nil, err
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	return &redisQueue{client: client, list: list}, nil
+}
+
+func (q *redisQueue) next(ctx context.Context) (*queuedJob, error) {
+	for {
+		// a bounded wait keeps the connection from looking dead to proxies
+		values, err := q.client.BLPop(ctx, 30*time.Second, q.list).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		return &queuedJob{data: []byte(values[1]), name: q.list}, nil
+	}
+}
+
+func (q *redisQueue) finish(job *queuedJob, result []byte, failed bool) error {
+	return q.client.RPush(context.Background(), q.list+":results", result).Err()
+}
+
+func (q *redisQueue) close() {
+	q.client.Close()
+}
+
+// natsQueue receives jobs on a subject through a queue group, so several daemons share the work.
+// Results answer requests, and go to <subject>.results for plain publishes
+type natsQueue struct {
+	conn     *nats.Conn
+	sub      *nats.Subscription
+	subject  string
+	messages chan *nats.Msg
+}
+
+func openNATSQueue(spec string) (*natsQueue, error) {
+	rawURL, subject, err := takeQueryParam(spec, "subject")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := nats.Connect(rawURL, nats.Name("eip1559-sender"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %v", err)
+	}
+	messages := make(chan *nats.Msg, 64)
+	sub, err := conn.ChanQueueSubscribe(subject, "eip1559-sender", messages)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &natsQueue{conn: conn, sub: sub, subject: subject, messages: messages}, nil
+}
+
+func (q *natsQueue) next(ctx context.Context) (*queuedJob, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case msg := <-q.messages:
+		return &queuedJob{data: msg.Data, name: msg.Subject, reply: msg.Reply}, nil
+	}
+}
+
+func (q *natsQueue) finish(job *queuedJob, result []byte, failed bool) error {
+	if job.reply != "" {
+		return q.conn.Publish(job.reply, result)
+	}
+	return q.conn.Publish(q.subject+".results", result)
+}
+
+func (q *natsQueue) close() {
+	q.sub.Unsubscribe()
+	q.conn.Drain()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// describeReceipt logs what a mined transaction did: the price it paid per gas against its fee
+// cap, the events it emitted, whether an ERC-20 transfer moved the amount it was asked to and, if
+// it reverted, the reason
+func describeReceipt(client *ethclient.Client, from common.Address, tx *types.Transaction, receipt *types.Receipt, nf numberFormat) {
+	describeGasPrice(client, tx, receipt, nf)
+	events := receiptEventABIs()
+	tokens := map[common.Address]*tokenUnits{}
+	for _, log := range receipt.Logs {
+		infof("Event: %s", describeLog(client, log, events, tokens, nf))
+	}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		checkTransferEvent(client, from, tx, receipt, tokens, nf)
+	} else {
+		if reason := revertReason(client, from, tx, receipt); reason != "" {
+			infof("Revert reason: %s", reason)
+		}
+	}
+}
+
+// describeGasPrice logs the effective gas price against the transaction's maxFeePerGas, split
+// into base fee and tip, the fee paid and what the fee cap saved
+func describeGasPrice(client *ethclient.Client, tx *types.Transaction, receipt *types.Receipt, nf numberFormat) {
+	f := receiptFees(client, tx, receipt)
+	if f == nil {
+		return
+	}
+	chain := lookupChain(tx.ChainId())
+	legacy := tx.Type() == types.LegacyTxType || tx.Type() == types.AccessListTxType
+	if legacy {
+		infof("Effective gas price: %s", nf.format(f.price.String()))
+	} else {
+		percent := new(big.Int).Div(new(big.Int).Mul(f.price, big.NewInt(100)), f.feeCap)
+		infof("Effective gas price: %s (%s%% of the max fee per gas of %s)", nf.format(f.price.String()), percent, nf.format(f.feeCap.String()))
+		if f.baseFee != nil {
+			infof("Base fee: %s, tip: %s of at most %s", nf.format(f.baseFee.String()), nf.format(f.tip.String()), nf.format(tx.GasTipCap().String()))
+		}
+	}
+	fee := f.fee(f.price)
+	if l1Fee := receiptL1Fee(client, receipt.TxHash); l1Fee != nil {
+		infof("L1 data fee: %s %s", nf.format(formatUnits(l1Fee, chain.decimals)), chain.symbol)
+		fee.Add(fee, l1Fee)
+	}
+	infof("Transaction fee: %s %s%s", nf.format(formatUnits(fee, chain.decimals)), chain.symbol, fiatAmount(client, tx.ChainId(), fee, chain.decimals, nf))
+	if !legacy {
+		saved := new(big.Int).Sub(f.fee(f.feeCap), f.fee(f.price))
+		infof("Saved against the max fee per gas: %s %s of the %s %s it allowed", nf.format(formatUnits(saved, chain.decimals)), chain.symbol,
+			nf.format(formatUnits(f.fee(f.feeCap), chain.decimals)), chain.symbol)
+	}
+}
+
+// receiptEventABIs returns the ABIs events are decoded with: -tokenABI if given, then the
+// embedded ones
+func receiptEventABIs() []abi.ABI {
+	abis := []abi.ABI{mustLoadABI(erc20ABIJSON), erc1155ABI, erc2612ABI, eip3009ABI}
+	if *tokenABIFlag != "" {
+		if custom, err := loadABI(*tokenABIFlag); err == nil {
+			abis = append([]abi.ABI{custom}, abis...)
+		}
+	}
+	return abis
+}
+
+// describeLog renders a log as the event of abis it matches, with ERC-20 amounts in whole
+// tokens, or as its emitter and topic if none does
+func describeLog(client *ethclient.Client, log *types.Log, abis []abi.ABI, tokens map[common.Address]*tokenUnits, nf numberFormat) string {
+	if len(log.Topics) == 0 {
+		return fmt.Sprintf("anonymous event of %s (%d bytes of data)", log.Address.Hex(), len(log.Data))
+	}
+	for _, contract := range abis {
+		event, err := contract.EventByID(log.Topics[0])
+		if err != nil {
+			continue
+		}
+		values, err := unpackLog(event, log)
+		if err != nil {
+			// same signature with other indexed arguments, such as an ERC-721 Transfer
+			continue
+		}
+		if amount := tokenAmount(client, event, log.Address, values, tokens, nf); amount != "" {
+			return fmt.Sprintf("%s of %s from %s to %s", event.Name, amount, formatValue(values[0]), formatValue(values[1]))
+		}
+		args := make([]string, len(values))
+		for i, value := range values {
+			args[i] = formatValue(value)
+		}
+		return fmt.Sprintf("%s(%s) on %s", event.Name, strings.Join(args, ", "), log.Address.Hex())
+	}
+	return fmt.Sprintf("%s on %s (%d bytes of data)", log.Topics[0].Hex(), log.Address.Hex(), len(log.Data))
+}
+
+// unpackLog returns the arguments of event in the order it declares them, the indexed ones
+// taken from the topics
+func unpackLog(event *abi.Event, log *types.Log) ([]interface{}, error) {
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if len(log.Topics) != len(indexed)+1 {
+		return nil, fmt.Errorf("%s has %d indexed arguments, the log %d topics", event.Sig, len(indexed), len(log.Topics))
+	}
+	topics := map[string]interface{}{}
+	if err := abi.ParseTopicsIntoMap(topics, indexed, log.Topics[1:]); err != nil {
+		return nil, err
+	}
+	data, err := event.Inputs.NonIndexed().UnpackValues(log.Data)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(event.Inputs))
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			values = append(values, topics[input.Name])
+		} else {
+			values = append(values, data[0])
+			data = data[1:]
+		}
+	}
+	return values, nil
+}
+
+// tokenUnits are the decimals and symbol of an ERC-20 token, looked up once per receipt
+type tokenUnits struct {
+	decimals int
+	symbol   string
+}
+
+// tokenAmount renders the amount of an ERC-20 Transfer or Approval in whole tokens, empty for
+// other events or if the emitter does not report its decimals
+func tokenAmount(client *ethclient.Client, event *abi.Event, address common.Address, values []interface{}, tokens map[common.Address]*tokenUnits, nf numberFormat) string {
+	if (event.Name != "Transfer" && event.Name != "Approval") || len(values) != 3 {
+		return ""
+	}
+	amount, ok := values[2].(*big.Int)
+	if !ok {
+		return ""
+	}
+	units := lookupTokenUnits(client, address, tokens)
+	if units == nil {
+		return ""
+	}
+	if event.Name == "Approval" && amount.Cmp(math.MaxBig256) == 0 {
+		return "unlimited " + units.symbol + " (" + address.Hex() + ")"
+	}
+	return nf.format(formatUnits(amount, units.decimals)) + " " + units.symbol + " (" + address.Hex() + ")"
+}
+
+// lookupTokenUnits returns the decimals and symbol of the token at address, nil if it does not
+// report its decimals, and remembers them in tokens
+func lookupTokenUnits(client *ethclient.Client, address common.Address, tokens map[common.Address]*tokenUnits) *tokenUnits {
+	units, seen := tokens[address]
+	if !seen {
+		if token, err := loadToken(client, address.Hex(), ""); err == nil {
+			if decimals, err := token.decimals(); err == nil {
+				units = &tokenUnits{decimals: decimals, symbol: token.symbol()}
+			}
+		}
+		tokens[address] = units
+	}
+	return units
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// receiverProblems returns why sending to receiver looks like a mistake: it is the zero address,
+// the token contract itself, or, when native is set, a contract that rejects the native coin.
+// token is the token contract sent, empty for the native coin
+func receiverProblems(client *ethclient.Client, chainID *big.Int, from, receiver common.Address, token string, native bool) []string {
+	var problems []string
+	if receiver == (common.Address{}) {
+		problems = append(problems, "the receiver is the zero address, anything sent there is lost")
+	}
+	if token != "" && receiver == common.HexToAddress(token) {
+		problems = append(problems, fmt.Sprintf("the receiver is the token contract %s itself, which usually cannot send the tokens back", receiver.Hex()))
+	}
+	if !native || receiver == (common.Address{}) {
+		return problems
+	}
+	code, err := client.CodeAt(opCtx, receiver, nil)
+	if err != nil || len(code) == 0 {
+		return problems
+	}
+	// any amount is rejected without a payable receive or fallback function, 1 Wei will do
+	_, err = client.CallContract(opCtx, ethereum.CallMsg{From: from, To: &receiver, Value: big.NewInt(1)}, nil)
+	var dataErr rpc.DataError
+	if err != nil && (errors.As(err, &dataErr) || strings.Contains(err.Error(), "revert")) {
+		problems = append(problems, fmt.Sprintf("the receiver %s is a contract that rejects %s: %s", receiver.Hex(), lookupChain(chainID).symbol, describeCallError(err)))
+	} else if err != nil {
+		debugf("Failed to check whether %s accepts the native coin: %v", receiver.Hex(), err)
+	}
+	return problems
+}
+
+// checkReceiver reports the problems of the receivers, refusing to send unless -force is given
+func checkReceiver(problems []string) {
+	if len(problems) == 0 {
+		return
+	}
+	for _, problem := range problems {
+		warnf("Suspicious receiver: %s", problem)
+	}
+	if !*forceFlag {
+		fatalf("Not sending: a receiver looks like a mistake, pass -force to send anyway")
+	}
+	warnf("Sending anyway as -force is given")
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+// reloadableFlags are the flags a running server or daemon takes over from its config file without
+// a restart: the fee policy, the rate limit, the webhook, the receiver allowlist and the -policy file
+var reloadableFlags = []string{
+	"maxFeePerGas", "maxPriorityFeePerGas", "maxFeeEth", "maxFeeGwei", "priority", "feeBlocks", "feePercentile", "feeHeadroom", "feeSource",
+	"gasMargin", "gasFloor", "gasCeiling", "bumpAfter", "bumpPercent", "maxBumps", "webhook", "webhookRetries", "rateLimit", "allowTo", "policy",
+}
+
+// configReloadInterval is how often a server or daemon checks its config file for changes
+const configReloadInterval = 5 * time.Second
+
+// configMu guards the reloadable flags and what is derived from them. A reload holds it to write
+// them, the server and the daemon hold it for reading while they build and send a transaction
+var configMu sync.RWMutex
+
+// sendLimiter enforces -rateLimit
+var sendLimiter = rate.NewLimiter(rate.Inf, 1)
+
+// allowedReceivers holds the addresses of -allowTo, nil to allow any receiver
+var allowedReceivers map[common.Address]bool
+
+// applySendPolicy sets up -rateLimit, -allowTo and -policy, looking up address book names on
+// chainID. Nothing changes if one of them is invalid
+func applySendPolicy(client *ethclient.Client, chainID *big.Int) error {
+	if math.IsNaN(*rateLimitFlag) || math.IsInf(*rateLimitFlag, 0) || *rateLimitFlag < 0 {
+		return fmt.Errorf("-rateLimit must be a positive number of transactions per minute, got %v", *rateLimitFlag)
+	}
+	var allowed map[common.Address]bool
+	for _, entry := range strings.Split(*allowToFlag, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		address, err := resolveAllowed(entry, chainID)
+		if err != nil {
+			return fmt.Errorf("invalid -allowTo: %v", err)
+		}
+		if allowed == nil {
+			allowed = map[common.Address]bool{}
+		}
+		allowed[address] = true
+	}
+	var policy *sendPolicy
+	if *policyFlag != "" {
+		var err error
+		if policy, err = loadPolicy(client, chainID, *policyFlag); err != nil {
+			return fmt.Errorf("invalid -policy: %v", err)
+		}
+	}
+	limit := rate.Inf
+	if *rateLimitFlag > 0 {
+		limit = rate.Limit(*rateLimitFlag / 60)
+	}
+	sendLimiter.SetLimit(limit)
+	allowedReceivers, activePolicy = allowed, policy
+	return nil
+}
+
+// resolveAllowed parses an address of -allowTo or of a -policy file, which may be an address
+// book name on chainID
+func resolveAllowed(entry string, chainID *big.Int) (common.Address, error) {
+	if isAddressBookName(entry) {
+		return lookupAddressBook(entry, chainID)
+	}
+	return parseAddress(entry)
+}
+
+// checkAllowed refuses a receiver left out of -allowTo. The caller holds configMu
+func checkAllowed(to common.Address) error {
+	if allowedReceivers != nil && !allowedReceivers[to] {
+		return fmt.Errorf("receiver %s is not in -allowTo", to.Hex())
+	}
+	return nil
+}
+
+// configWatcher reloads the profile of a server or daemon whenever its config file changes, and
+// its -policy whenever the policy file does
+type configWatcher struct {
+	fs       *flag.FlagSet
+	client   *ethclient.Client
+	chainID  *big.Int
+	profile  fileStamp
+	policy   fileStamp
+	reloaded time.Time // of the last reload that changed a flag or the policy
+}
+
+// fileStamp is what a watched file looked like when it was last read
+type fileStamp struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// changed tells whether the file at path is another file or was written since it was last seen
+func (s *fileStamp) changed(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || (path == s.path && info.ModTime().Equal(s.modTime) && info.Size() == s.size) {
+		return false
+	}
+	s.path, s.modTime, s.size = path, info.ModTime(), info.Size()
+	return true
+}
+
+// activeConfig is the watcher of the running server or daemon, served at /config
+var activeConfig *configWatcher
+
+// watchConfig checks the config file of the profile in use and the -policy file every
+// configReloadInterval and applies the changes of the reloadable flags of fs and of the policy.
+// Flags given on the command line keep their value
+func watchConfig(fs *flag.FlagSet, client *ethclient.Client, chainID *big.Int) {
+	w := &configWatcher{fs: fs, client: client, chainID: chainID}
+	configMu.Lock()
+	activeConfig = w
+	configMu.Unlock()
+	if loadedProfile != nil {
+		w.profile.changed(loadedProfile.path)
+		infof("Watching profile %s of %s for changes", loadedProfile.name, loadedProfile.path)
+	}
+	w.policy.changed(*policyFlag)
+	if *policyFlag != "" {
+		infof("Watching the policy %s for changes", *policyFlag)
+	}
+	if loadedProfile == nil && *policyFlag == "" {
+		return
+	}
+	// only this goroutine writes the flags, so it reads them without configMu
+	go func() {
+		for range time.Tick(configReloadInterval) {
+			if loadedProfile != nil && w.profile.changed(loadedProfile.path) {
+				if err := w.reload(); err != nil {
+					warnf("Keeping the configuration, failed to reload %s: %v", loadedProfile.path, err)
+				}
+				// a reload that changed -policy has loaded the new file already
+				w.policy.changed(*policyFlag)
+			}
+			if *policyFlag != "" && w.policy.changed(*policyFlag) {
+				if err := w.reloadPolicy(); err != nil {
+					warnf("Keeping the policy, failed to reload %s: %v", *policyFlag, err)
+				}
+			}
+		}
+	}()
+}
+
+// reloadPolicy applies the -policy file as it is now. An invalid policy changes nothing
+func (w *configWatcher) reloadPolicy() error {
+	// the tokens of the limits are looked up before a send has to wait for the reload
+	policy, err := loadPolicy(w.client, w.chainID, *policyFlag)
+	if err != nil {
+		return err
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	activePolicy, w.reloaded = policy, time.Now()
+	infof("Reloaded the policy %s", *policyFlag)
+	return nil
+}
+
+// reload applies the profile as it is now in the config file. A profile with an invalid value
+// changes nothing
+func (w *configWatcher) reload() error {
+	data, err := os.ReadFile(loadedProfile.path)
+	if err != nil {
+		return err
+	}
+	var config configFile
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse it: %v", err)
+	}
+	profile, ok := config.Profiles[loadedProfile.name]
+	if !ok {
+		return fmt.Errorf("profile %q is gone", loadedProfile.name)
+	}
+	for key := range mergeKeys(profile, loadedProfile.values) {
+		if !slices.Contains(reloadableFlags, key) && fmt.Sprint(profile[key]) != fmt.Sprint(loadedProfile.values[key]) {
+			warnf("-%s changed in %s, it takes effect after a restart", key, loadedProfile.path)
+		}
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	before := map[string]string{}
+	for _, name := range reloadableFlags {
+		if f := w.fs.Lookup(name); f != nil {
+			before[name] = f.Value.String()
+		}
+	}
+	if err := w.apply(profile); err != nil {
+		for name, value := range before {
+			w.fs.Lookup(name).Value.Set(value)
+		}
+		if restoreErr := applySendPolicy(w.client, w.chainID); restoreErr != nil {
+			warnf("Failed to restore -rateLimit, -allowTo and -policy: %v", restoreErr)
+		}
+		return err
+	}
+	loadedProfile.values = profile
+	shown := func(name, value string) string {
+		if value == "" {
+			return `""`
+		}
+		return describeFlagValue(name, value)
+	}
+	changed := false
+	for _, name := range reloadableFlags {
+		if f := w.fs.Lookup(name); f != nil && f.Value.String() != before[name] {
+			infof("Reloaded -%s: %s (was %s)", name, shown(name, f.Value.String()), shown(name, before[name]))
+			changed = true
+		}
+	}
+	if changed {
+		w.reloaded = time.Now()
+	}
+	return nil
+}
+
+// apply sets the reloadable flags of fs from profile, or back to their defaults where profile no
+// longer sets them, and checks them as at startup
+func (w *configWatcher) apply(profile map[string]interface{}) error {
+	given := map[string]bool{}
+	for _, name := range reloadableFlags {
+		f := w.fs.Lookup(name)
+		if f == nil || loadedProfile.commandLine[name] {
+			given[name] = f != nil
+			continue
+		}
+		text := f.DefValue
+		if value, ok := profile[name]; ok {
+			text, given[name] = profileValue(value), true
+		}
+		if err := f.Value.Set(text); err != nil {
+			return fmt.Errorf("invalid value %q for -%s: %v", text, name, err)
+		}
+	}
+	if err := applyPriorityPreset(given); err != nil {
+		return err
+	}
+	if _, err := feeOptions(); err != nil {
+		return err
+	}
+	if _, err := parseGwei(*maxFeeGweiFlag); err != nil {
+		return fmt.Errorf("invalid -maxFeeGwei: %v", err)
+	}
+	if *maxFeeEthFlag != "" {
+		if _, err := parseUnits(*maxFeeEthFlag, lookupChain(w.chainID).decimals); err != nil {
+			return fmt.Errorf("invalid -maxFeeEth: %v", err)
+		}
+	}
+	if err := checkGasMargin(); err != nil {
+		return err
+	}
+	return applySendPolicy(w.client, w.chainID)
+}
+
+// mergeKeys returns the keys of both a and b
+func mergeKeys(a, b map[string]interface{}) map[string]bool {
+	keys := map[string]bool{}
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// describeFlagValue shows value of the flag name in the log and at /config. Webhook URLs are cut
+// to their host, as their path and query often hold a secret
+func describeFlagValue(name, value string) string {
+	if name != "webhook" || value == "" {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return "[redacted]"
+	}
+	if u.Path != "" || u.RawQuery != "" {
+		return u.Scheme + "://" + u.Host + "/..."
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// serveConfig answers /config with the reloadable flags in effect as a JSON object
+func serveConfig(w http.ResponseWriter, r *http.Request) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if activeConfig == nil {
+		http.NotFound(w, r)
+		return
+	}
+	type configState struct {
+		File     string            `json:"file,omitempty"`
+		Profile  string            `json:"profile,omitempty"`
+		Reloaded *time.Time        `json:"reloaded,omitempty"`
+		Flags    map[string]string `json:"flags"`
+		AllowTo  []string          `json:"allowTo,omitempty"`
+		Policy   *policyState      `json:"policy,omitempty"`
+	}
+	state := configState{Flags: map[string]string{}}
+	if loadedProfile != nil {
+		state.File, state.Profile = loadedProfile.path, loadedProfile.name
+	}
+	if !activeConfig.reloaded.IsZero() {
+		state.Reloaded = &activeConfig.reloaded
+	}
+	for _, name := range reloadableFlags {
+		if f := activeConfig.fs.Lookup(name); f != nil {
+			state.Flags[name] = describeFlagValue(name, f.Value.String())
+		}
+	}
+	for address := range allowedReceivers {
+		state.AllowTo = append(state.AllowTo, address.Hex())
+	}
+	slices.Sort(state.AllowTo)
+	state.Policy = activePolicy.state()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
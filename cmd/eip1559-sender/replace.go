@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+	"github.com/holiman/uint256"
+)
+
+// buildReplacement builds the transaction for -cancelNonce or -replaceTx: the same nonce as the
+// pending transaction, with fees high enough for nodes to accept it as a replacement
+func buildReplacement(client *ethclient.Client, from common.Address, chainID *big.Int, nf numberFormat) (*types.Transaction, error) {
+	ctx := opCtx
+
+	var original *types.Transaction
+	var nonce uint64
+	if *replaceTx != "" {
+		tx, pending, err := client.TransactionByHash(ctx, common.HexToHash(*replaceTx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transaction %s: %v", *replaceTx, err)
+		}
+		if !pending {
+			return nil, fmt.Errorf("transaction %s is already mined", *replaceTx)
+		}
+		sender, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+		if err != nil {
+			return nil, err
+		}
+		if sender != from {
+			return nil, fmt.Errorf("transaction %s was sent by %s, not by %s", *replaceTx, sender.Hex(), from.Hex())
+		}
+		original, nonce = tx, tx.Nonce()
+	} else {
+		nonce = uint64(*cancelNonce)
+		mined, err := client.NonceAt(ctx, from, nil)
+		if err != nil {
+			return nil, err
+		}
+		if nonce < mined {
+			return nil, fmt.Errorf("nonce %d is already mined", nonce)
+		}
+		original = pendingTxByNonce(client, from, nonce)
+	}
+	if original != nil && original.Type() == types.BlobTxType {
+		// the node does not return the blobs, and the blob pool accepts no other type in their place
+		return nil, fmt.Errorf("transaction %s carries blobs and cannot be replaced, re-send it with -blob and the same nonce", original.Hash().Hex())
+	}
+
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := legacyTx(header)
+	if err != nil {
+		return nil, err
+	}
+	baseFee := header.BaseFee
+	if legacy {
+		baseFee = nil
+	}
+	// for legacy transactions tip and fee cap are both the gas price
+	tip, feeCap, err := suggestFees(ctx, client, baseFee)
+	if err != nil {
+		return nil, err
+	}
+	if original != nil {
+		// nodes only accept a replacement that raises both the tip and the fee cap, explicit fees are left to the user
+		if bumped := bumpFee(original.GasTipCap(), *bumpPercent); bumped.Cmp(tip) > 0 && *maxTipFlag == "" {
+			tip = bumped
+		}
+		if bumped := bumpFee(original.GasFeeCap(), *bumpPercent); bumped.Cmp(feeCap) > 0 && *maxFeeFlag == "" {
+			feeCap = bumped
+		}
+		if feeCap.Cmp(tip) < 0 {
+			feeCap = new(big.Int).Set(tip)
+		}
+		infof("Replacing %s (maxPriorityFeePerGas %s, maxFeePerGas %s)", original.Hash().Hex(), nf.format(original.GasTipCap().String()), nf.format(original.GasFeeCap().String()))
+	} else {
+		infof("No pending transaction found for nonce %d, using the suggested fees", nonce)
+	}
+	infof("nonce: %d", nonce)
+	infof("Max priority fee per gas: %s", nf.format(tip.String()))
+	infof("Max fee per gas: %s", nf.format(feeCap.String()))
+
+	if *replaceTx != "" {
+		gas := original.Gas()
+		if *gasLimitFlag != 0 {
+			gas = *gasLimitFlag
+		}
+		if original.Type() == types.SetCodeTxType {
+			// keep the authorizations, they are still valid for the same nonce
+			return types.NewTx(&types.SetCodeTx{
+				ChainID:    uint256.MustFromBig(chainID),
+				Nonce:      nonce,
+				GasTipCap:  uint256.MustFromBig(tip),
+				GasFeeCap:  uint256.MustFromBig(feeCap),
+				Gas:        gas,
+				To:         *original.To(),
+				Value:      uint256.MustFromBig(original.Value()),
+				Data:       original.Data(),
+				AccessList: original.AccessList(),
+				AuthList:   original.SetCodeAuthorizations(),
+			}), nil
+		}
+		return sender.MakeTx(legacy, &types.DynamicFeeTx{
+			ChainID:    chainID,
+			Nonce:      nonce,
+			GasTipCap:  tip,
+			GasFeeCap:  feeCap,
+			Gas:        gas,
+			To:         original.To(),
+			Value:      original.Value(),
+			Data:       original.Data(),
+			AccessList: original.AccessList(),
+		}), nil
+	}
+	// cancel with a 0-value transfer to ourselves
+	infof("Cancelling nonce %d with a self-transfer to %s", nonce, from.Hex())
+	return sender.MakeTx(legacy, &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       21000,
+		To:        &from,
+		Value:     new(big.Int),
+	}), nil
+}
+
+// pendingTxByNonce looks up the pending transaction of from with the given nonce through the
+// txpool namespace, returning nil if the node does not expose it
+func pendingTxByNonce(client *ethclient.Client, from common.Address, nonce uint64) *types.Transaction {
+	for _, txs := range poolContentFrom(client, from) {
+		if tx, ok := txs[nonce]; ok {
+			return tx
+		}
+	}
+	return nil
+}
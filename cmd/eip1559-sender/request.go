@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newRequestFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("request", flag.ExitOnError)
+	addRootFlags(fs, "receiver", "amount", "amountRaw", "unit", "tokenContract", "token", "tokenList", "tokenABI", "decimals")
+	addRootFlags(fs, readFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s request -receiver 0x... -amount 0.1 [-tokenContract 0x...] -chainID 1|-network mainnet|-rpcURL https://... [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nPrints an EIP-681 payment request and its QR code, for a wallet to scan or -uri to pay.\n")
+		fmt.Fprintf(fs.Output(), "Without an RPC, token amounts have to be given with -amountRaw and ENS names are left to the payer.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runRequest implements the "request" subcommand: the EIP-681 URI asking for a payment of
+// -amount to -receiver, printed with its QR code
+func runRequest(args []string) {
+	fs := newRequestFlagSet()
+	fs.Parse(args)
+	configure(fs)
+
+	if *receiverFlag == "" || (*amountFlag == "" && *amountRawFlag == "") {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+
+	// the RPC is only needed for the token's decimals and ENS names
+	chainID := big.NewInt(*chainIDFlag)
+	var token *erc20Token
+	if *rpcURLFlag != "" {
+		client, id := dialRPC()
+		chainID = id
+		if err := resolveAddressFlags(client, chainID); err != nil {
+			fatalf("Failed to resolve address: %v", err)
+		}
+		if *tokenContract != "" {
+			if token, err = loadToken(client, *tokenContract, *tokenABIFlag); err != nil {
+				fatalf("Failed to load token: %v", err)
+			}
+		}
+	} else {
+		if err := resolveAddressBook(chainID); err != nil {
+			fatalf("Failed to resolve address: %v", err)
+		}
+		if err := resolveTokenList(chainID); err != nil {
+			fatalf("Failed to resolve address: %v", err)
+		}
+	}
+
+	receiver := *receiverFlag
+	if !isENSName(receiver) {
+		address, err := parseAddress(receiver)
+		if err != nil {
+			exitf(exitInvalid, "Invalid receiver: %v", err)
+		}
+		receiver = address.Hex()
+	}
+	chain := lookupChain(chainID)
+	target, tokenReceiver := receiver, ""
+	var value *big.Int
+	var amount string
+	switch {
+	case token != nil:
+		var format func(*big.Int) string
+		if value, format, err = token.transferUnits(*amountFlag, nf); err != nil {
+			exitf(exitInvalid, "Invalid amount: %v", err)
+		}
+		target, tokenReceiver, amount = token.address.Hex(), receiver, format(value)
+	case *tokenContract != "":
+		if *amountRawFlag == "" {
+			exitf(exitInvalid, "The decimals of the token are unknown without -rpcURL, give the amount in base units with -amountRaw")
+		}
+		if !common.IsHexAddress(*tokenContract) {
+			exitf(exitInvalid, "Invalid token contract: %s is not an address, ENS names need -rpcURL", *tokenContract)
+		}
+		if value, err = parseRawAmount(*amountRawFlag); err != nil {
+			exitf(exitInvalid, "Invalid amount: %v", err)
+		}
+		target, tokenReceiver = common.HexToAddress(*tokenContract).Hex(), receiver
+		amount = value.String() + " base units of token " + target
+	default:
+		if value, err = parseUnits(*amountFlag, unitDecimals(chain)); err != nil {
+			exitf(exitInvalid, "Invalid amount: %v", err)
+		}
+		amount = nf.format(formatUnits(value, chain.decimals)) + " " + chain.symbol
+	}
+
+	if chainID.Sign() == 0 {
+		warnf("No chain given with -chainID, -network or -rpcURL, the payer's wallet picks the chain")
+	} else {
+		infof("Requesting %s on %s (chain ID %s) to %s", amount, chain.name, chainID, receiver)
+	}
+	uri := formatPaymentURI(target, chainID.Uint64(), tokenReceiver, value)
+	resultf([]interface{}{"uri", uri}, "%s", uri)
+	if !*quietFlag {
+		printQR(uri)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// retryBackoff is the delay before the first retry, doubled for every further one
+	retryBackoff = 500 * time.Millisecond
+	// maxRetryBackoff caps the delay between retries, including a server's Retry-After
+	maxRetryBackoff = 10 * time.Second
+)
+
+// retryTransport retries RPC requests that failed for a transient reason: connection errors,
+// timeouts, 429 and 5xx responses and rate limit errors. Errors the node reports for the
+// request itself, such as "nonce too low" or a revert, are returned at once
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	timeout time.Duration
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	method := rpcMethod(body)
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req, body)
+		reason, retryAfter := retryReason(resp, err)
+		if reason == "" || attempt >= t.retries || req.Context().Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		delay := backoff(attempt, retryAfter)
+		warnf("RPC request %s failed (%s), retrying in %s (%d/%d)", method, reason, delay.Round(time.Millisecond), attempt+1, t.retries)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attempt sends the request once, giving up after the timeout
+func (t retryTransport) attempt(req *http.Request, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	out := req.Clone(ctx)
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		cancel()
+		if ctx.Err() == context.DeadlineExceeded && req.Context().Err() == nil {
+			err = fmt.Errorf("no response within %s", t.timeout)
+		}
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body = cancelOnClose{resp.Body, cancel}
+		return resp, nil
+	}
+	// successful responses are read here, so a rate limit error in the body can be retried
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// retryReason describes why a request should be retried, empty if it should not. It also
+// returns the delay a 429 or 503 response asked for with Retry-After
+func retryReason(resp *http.Response, err error) (string, time.Duration) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return "", 0
+		}
+		return err.Error(), 0
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return resp.Status, retryAfter
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	var reply struct {
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &reply) != nil || reply.Error == nil {
+		return "", 0
+	}
+	// -32005 is the "limit exceeded" code of EIP-1474, some providers use 429 instead
+	if reply.Error.Code == -32005 || reply.Error.Code == 429 || strings.Contains(strings.ToLower(reply.Error.Message), "rate limit") {
+		return reply.Error.Message, 0
+	}
+	return "", 0
+}
+
+// backoff returns the delay before retry attempt+1: exponential with jitter, or the server's
+// Retry-After
+func backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, maxRetryBackoff)
+	}
+	delay := maxRetryBackoff
+	if attempt < 16 {
+		delay = min(retryBackoff<<attempt, maxRetryBackoff)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
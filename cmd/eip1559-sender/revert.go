@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// tokenErrorsABIJSON declares the custom errors of the OpenZeppelin tokens (ERC-6093, ERC-2612)
+// and of Permit2
+//
+//go:embed tokenerrors.abi.json
+var tokenErrorsABIJSON string
+
+// panicSelector is the selector of Panic(uint256), which Solidity reverts with on failed asserts,
+// overflows and the like
+var panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+
+// errorABIs returns the ABIs custom errors are decoded with: -errorABI and -tokenABI if given,
+// then the embedded token errors
+func errorABIs() []abi.ABI {
+	var abis []abi.ABI
+	for _, spec := range []string{*errorABIFlag, *tokenABIFlag} {
+		if spec == "" {
+			continue
+		}
+		if parsed, err := loadABI(spec); err == nil {
+			abis = append(abis, parsed)
+		}
+	}
+	return append(abis, mustLoadABI(tokenErrorsABIJSON))
+}
+
+// callRevertData returns the revert data carried by the error of a failed call, nil if it has none
+func callRevertData(err error) []byte {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil
+	}
+	data, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return nil
+	}
+	revert, err := hexutil.Decode(data)
+	if err != nil {
+		return nil
+	}
+	return revert
+}
+
+// decodeRevert renders revert data as the message of Error(string), the cause of Panic(uint256)
+// or the custom error of errorABIs it matches with its arguments, "" if it is none of them
+func decodeRevert(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		if bytes.Equal(data[:4], panicSelector) {
+			return "panic: " + reason
+		}
+		return reason
+	}
+	for _, contract := range errorABIs() {
+		customErr, err := contract.ErrorByID([4]byte(data[:4]))
+		if err != nil {
+			continue
+		}
+		unpacked, err := customErr.Unpack(data)
+		if err != nil {
+			continue
+		}
+		values, _ := unpacked.([]interface{})
+		args := make([]string, len(values))
+		for i, value := range values {
+			args[i] = formatValue(value)
+		}
+		return customErr.Name + "(" + strings.Join(args, ", ") + ")"
+	}
+	return ""
+}
+
+// callRevertReason returns why a call reverted: its decoded revert data, else the error itself
+func callRevertReason(err error) string {
+	data := callRevertData(err)
+	if reason := decodeRevert(data); reason != "" {
+		return reason
+	}
+	if len(data) > 0 {
+		return "revert data: " + hexutil.Encode(data)
+	}
+	return err.Error()
+}
+
+// replayMsg is the call tx from from makes, to run it again with eth_call
+func replayMsg(from common.Address, tx *types.Transaction) ethereum.CallMsg {
+	return ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+}
+
+// explainBroadcastError adds the revert reason to the error of a broadcast the node refused,
+// found by replaying tx on the latest block. Nodes and relays that simulate transactions before
+// accepting them only answer "execution reverted"
+func explainBroadcastError(ctx context.Context, client *ethclient.Client, tx *types.Transaction, err error) error {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		// the node was not reached
+		return err
+	}
+	from, senderErr := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if senderErr != nil {
+		return err
+	}
+	_, callErr := client.CallContract(ctx, replayMsg(from, tx), nil)
+	if callErr == nil || (callRevertData(callErr) == nil && !strings.Contains(callErr.Error(), "revert")) {
+		return err
+	}
+	return fmt.Errorf("%w (revert reason: %s)", err, callRevertReason(callErr))
+}
+
+// minedRevertReason returns why the transaction of receipt reverted, for the callers that only
+// kept its hash, "" if the replay does not tell
+func minedRevertReason(client *ethclient.Client, receipt *types.Receipt) string {
+	tx, _, err := client.TransactionByHash(opCtx, receipt.TxHash)
+	if err != nil {
+		return ""
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return ""
+	}
+	return revertReason(client, from, tx, receipt)
+}
+
+// revertedStatus is the status of a transfer whose transaction reverted, with the reason if the
+// replay finds it
+func revertedStatus(client *ethclient.Client, receipt *types.Receipt) string {
+	status := fmt.Sprintf("reverted (block %d)", receipt.BlockNumber)
+	if reason := minedRevertReason(client, receipt); reason != "" {
+		status += ": " + reason
+	}
+	return status
+}
+
+// revertReason replays a reverted transaction on the state before its block to recover the
+// reason, which receipts do not record
+func revertReason(client *ethclient.Client, from common.Address, tx *types.Transaction, receipt *types.Receipt) string {
+	block := new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	_, err := client.CallContract(opCtx, replayMsg(from, tx), block)
+	if err == nil {
+		if receipt.GasUsed == tx.Gas() {
+			return "out of gas"
+		}
+		// earlier transactions of the block changed the state the replay ran on
+		return ""
+	}
+	return callRevertReason(err)
+}
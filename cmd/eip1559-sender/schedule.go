@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// sendAtLayouts are the accepted formats of -sendAt
+var sendAtLayouts = []string{time.RFC3339, "2006-01-02T15:04Z07:00"}
+
+// sendSchedule is when -sendAt and -every send: count times from start on, count 0 meaning
+// until stopped
+type sendSchedule struct {
+	start time.Time
+	every time.Duration
+	count int
+}
+
+// parseSchedule reads -sendAt, -every and -count. It returns nil if the transfer is sent right away
+func parseSchedule() (*sendSchedule, error) {
+	if *sendAtFlag == "" && *everyFlag == 0 {
+		if *countFlag != 0 {
+			return nil, errors.New("-count requires -every")
+		}
+		return nil, nil
+	}
+	schedule := &sendSchedule{start: time.Now(), every: *everyFlag, count: *countFlag}
+	if *sendAtFlag != "" {
+		var err error
+		for _, layout := range sendAtLayouts {
+			if schedule.start, err = time.Parse(layout, *sendAtFlag); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("-sendAt %q is not a time such as 2025-01-01T00:00Z", *sendAtFlag)
+		}
+		if schedule.start.Before(time.Now()) {
+			return nil, fmt.Errorf("-sendAt %s is in the past", schedule.start.Format(time.RFC3339))
+		}
+	}
+	switch {
+	case schedule.every < 0:
+		return nil, errors.New("-every must be positive")
+	case schedule.every == 0 && schedule.count != 0:
+		return nil, errors.New("-count requires -every")
+	case schedule.count < 0:
+		return nil, errors.New("-count must be positive")
+	case schedule.every == 0:
+		schedule.count = 1
+	}
+	if schedule.count != 1 && *nonceFlag >= 0 {
+		return nil, errors.New("-nonce cannot be combined with -every, every send needs a new nonce")
+	}
+	// nobody is around to answer the confirmation prompt when the time comes
+	if !*yesFlag && !*dryRunFlag {
+		return nil, errors.New("scheduled sends happen unattended and require -yes")
+	}
+	return schedule, nil
+}
+
+// run calls send at the scheduled times. Fees are estimated anew by every send, and -deadline
+// bounds each send rather than the wait for it. A send that fails is reported and the schedule
+// goes on, exiting with the code of the last failure once done
+func (s *sendSchedule) run(send func()) {
+	stopDeadline()
+	failed, lastCode := 0, 0
+	for i := 0; s.count == 0 || i < s.count; i++ {
+		at := s.start.Add(time.Duration(i) * s.every)
+		if wait := time.Until(at); wait > 0 {
+			infof("Next send at %s (in %s)", at.Format(time.RFC3339), wait.Round(time.Second))
+			time.Sleep(wait)
+		} else if i > 0 && wait < -time.Second {
+			warnf("Send %d is %s late, the previous send took longer than -every", i+1, (-wait).Round(time.Second))
+		}
+		if s.count > 1 {
+			infof("Send %d of %d", i+1, s.count)
+		} else if s.count == 0 {
+			infof("Send %d", i+1)
+		}
+		if code := runTick(send); code != 0 {
+			failed, lastCode = failed+1, code
+			resultf([]interface{}{"send", i + 1, "status", "failed", "exitCode", code}, "Send %d failed", i+1)
+		}
+	}
+	if failed > 0 {
+		exitf(lastCode, "%d of %d scheduled sends failed", failed, s.count)
+	}
+}
+
+// tickFailure is the panic value of exit within a scheduled send, recovered by runTick
+type tickFailure struct{ code int }
+
+// ticking is set while a scheduled send runs
+var ticking atomic.Bool
+
+// runTick calls send with opCtx bounded by -deadline, and returns the code send exited with, 0
+// if it did not. An exit within send panics, ending only that send. Every goroutine send starts
+// is done once it returns, so the next send starts from a clean state
+func runTick(send func()) (code int) {
+	startDeadline()
+	defer stopDeadline()
+	ticking.Store(true)
+	defer func() {
+		ticking.Store(false)
+		if r := recover(); r != nil {
+			failure, ok := r.(tickFailure)
+			if !ok {
+				panic(r)
+			}
+			code = failure.code
+		}
+	}()
+	send()
+	return 0
+}
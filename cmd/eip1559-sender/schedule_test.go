@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestRunTick(t *testing.T) {
+	if code := runTick(func() {}); code != 0 {
+		t.Errorf("runTick of a send that succeeded = %d, want 0", code)
+	}
+	sentAfter := false
+	code := runTick(func() {
+		exit(exitFunds)
+		sentAfter = true
+	})
+	if code != exitFunds || sentAfter {
+		t.Errorf("runTick of a send exiting with %d = %d, went on after the exit: %v", exitFunds, code, sentAfter)
+	}
+	if ticking.Load() {
+		t.Error("ticking still set after runTick returned")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// loadedKeys are the private keys held in memory, which forgetKeys wipes before the process
+// exits and the log never prints
+var loadedKeys struct {
+	sync.Mutex
+	signers []*keySigner
+}
+
+// hexKeyPattern matches what could be a hex private key in a log message
+var hexKeyPattern = regexp.MustCompile(`\b(0x)?[0-9a-fA-F]{64}\b`)
+
+// newKeySigner returns a signer for key and registers the key with loadedKeys. With -mlock the
+// key is also pinned in memory
+func newKeySigner(key *ecdsa.PrivateKey) (sender.Signer, error) {
+	if *mlockFlag {
+		if err := lockKey(key); err != nil {
+			return nil, fmt.Errorf("failed to lock the private key in memory: %v", err)
+		}
+	}
+	s := &keySigner{sender.NewKeySigner(key)}
+	loadedKeys.Lock()
+	loadedKeys.signers = append(loadedKeys.signers, s)
+	loadedKeys.Unlock()
+	return s, nil
+}
+
+// forgetKeys wipes the loaded private keys, which can no longer sign afterwards
+func forgetKeys() {
+	loadedKeys.Lock()
+	defer loadedKeys.Unlock()
+	for _, s := range loadedKeys.signers {
+		s.Zero()
+	}
+	loadedKeys.signers = nil
+}
+
+// redactKeys replaces the loaded private keys in msg, so that a key echoed by an error never
+// reaches the log
+func redactKeys(msg string) string {
+	loadedKeys.Lock()
+	defer loadedKeys.Unlock()
+	if len(loadedKeys.signers) == 0 {
+		return msg
+	}
+	return hexKeyPattern.ReplaceAllStringFunc(msg, func(match string) string {
+		raw, err := hex.DecodeString(strings.TrimPrefix(match, "0x"))
+		if err != nil {
+			return match
+		}
+		defer wipe(raw)
+		key := make([]byte, 32)
+		defer wipe(key)
+		for _, s := range loadedKeys.signers {
+			if subtle.ConstantTimeCompare(s.Key.D.FillBytes(key), raw) == 1 {
+				return "[redacted]"
+			}
+		}
+		return match
+	})
+}
+
+// parseHexKey parses a hex private key with an optional 0x prefix. Its error never quotes text,
+// and the decoded bytes are wiped once the key is built
+func parseHexKey(text []byte) (*ecdsa.PrivateKey, error) {
+	text = bytes.TrimPrefix(bytes.TrimSpace(text), []byte("0x"))
+	if len(text) != 64 {
+		return nil, fmt.Errorf("expected 64 hex digits, got %d", len(text))
+	}
+	raw := make([]byte, 32)
+	defer wipe(raw)
+	if _, err := hex.Decode(raw, text); err != nil {
+		return nil, errors.New("not a hex string")
+	}
+	return crypto.ToECDSA(raw)
+}
+
+// readSecretLine reads the first line of r a byte at a time, so that no buffer keeps a copy of
+// it or consumes the input after it. The caller wipes the line
+func readSecretLine(r io.Reader) ([]byte, error) {
+	line := make([]byte, 0, 256)
+	var b [1]byte
+	for {
+		n, err := r.Read(b[:])
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			if len(line) == cap(line) {
+				wipe(line)
+				return nil, errors.New("line too long")
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF && len(line) > 0 {
+			break
+		}
+		if err != nil {
+			wipe(line)
+			return nil, err
+		}
+	}
+	b[0] = 0
+	return line, nil
+}
+
+// wipe overwrites b with zeros
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
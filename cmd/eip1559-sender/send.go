@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sendKinds lists the kinds of transfer of the send subcommand
+var sendKinds = []string{"eth", "erc20"}
+
+// sendFlags lists the root flags each kind of send accepts besides the shared ones. The kind is
+// left out for -stdin, whose jobs name their own token
+var sendFlags = map[string][]string{
+	"":      {"stdin", "concurrency", "privateKeysEnv", "keystoreDir"},
+	"eth":   {"receiver", "uri", "amount", "unit", "tokenValue", "max", "data", "blob", "blobProofs", "maxFeePerBlobGas", "delegate", "authKeyEnv", "memoCalldata", "offline", "sendAt", "every", "count", "sendWhenBaseFeeBelow", "maxWait", "idempotencyKey", "force"},
+	"erc20": {"receiver", "uri", "amount", "amountRaw", "tokenValue", "max", "owner", "sendAt", "every", "count", "sendWhenBaseFeeBelow", "maxWait", "idempotencyKey", "force"},
+}
+
+func newSendFlagSet(kind string) *flag.FlagSet {
+	fs := flag.NewFlagSet(strings.TrimSpace("send "+kind), flag.ExitOnError)
+	addRootFlags(fs, sendFlags[kind]...)
+	addSharedFlags(fs)
+	fs.Usage = func() {
+		switch kind {
+		case "":
+			fmt.Fprintf(fs.Output(), "Usage: %s send -stdin -rpcURL https://... -privateKeyEnv SENDER_KEY -yes [options] < jobs.jsonl\n", os.Args[0])
+			fmt.Fprintf(fs.Output(), "\nSends every JSON object {\"id\": \"...\", \"receiver\": \"0x...\", \"amount\": \"0.1\", \"token\": \"0x...\"} read from stdin\n")
+			fmt.Fprintf(fs.Output(), "and prints its result as one line of JSON on stdout, the log going to stderr.\n")
+		case "erc20":
+			fmt.Fprintf(fs.Output(), "Usage: %s send erc20 -tokenContract 0x... -receiver 0x... -amount 100|-max [options]\n", os.Args[0])
+		default:
+			fmt.Fprintf(fs.Output(), "Usage: %s send eth -receiver 0x... -amount 0.1|-max [options]\n", os.Args[0])
+		}
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runSend implements the "send" subcommand, a transfer with only the flags of its kind
+func runSend(args []string) {
+	kind := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		kind, args = args[0], args[1:]
+	}
+	if _, ok := sendFlags[kind]; !ok {
+		fmt.Fprintf(os.Stderr, "Usage: %s send eth|erc20 [options], or send -stdin [options]\n", os.Args[0])
+		exit(exitInvalid)
+	}
+	fs := newSendFlagSet(kind)
+	fs.Parse(args)
+	configure(fs)
+
+	switch {
+	case kind == "" && !*stdinFlag:
+		fmt.Fprintf(os.Stderr, "Usage: %s send eth|erc20 [options], or send -stdin [options]\n", os.Args[0])
+		exit(exitInvalid)
+	case kind == "erc20" && *tokenContract == "":
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	case kind == "eth" && *tokenContract != "":
+		exitf(exitInvalid, "send eth transfers the native coin, use send erc20 for -tokenContract")
+	}
+	runTransfer(fs.Usage)
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+	"github.com/gmh5225/EIP1559-sender/pkg/senderpb"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// serverOptions holds the flags of the server subcommand
+type serverOptions struct {
+	listen string
+}
+
+// serverFlags lists the root flags the server applies to every transaction it sends
+var serverFlags = []string{
+	"tokenABI", "errorABI", "maxFeePerGas", "maxPriorityFeePerGas", "maxFeeEth", "maxFeeGwei",
+	"priority", "feeBlocks", "feePercentile", "feeHeadroom", "feeSource", "txType", "nonceSource", "confirmations", "metrics", "otlpEndpoint", "rateLimit", "allowTo", "policy", "broadcastAll", "private", "relayURL", "historyFile",
+}
+
+func newServerFlagSet(opts *serverOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	fs.StringVar(&opts.listen, "listen", "127.0.0.1:50051", "Address to serve gRPC on")
+	addRootFlags(fs, serverFlags...)
+	addRootFlags(fs, readFlags...)
+	addRootFlags(fs, keyFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s server -rpcURL https://... -privateKeyEnv SENDER_KEY [-listen 127.0.0.1:50051] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nServes the Sender gRPC service of pkg/senderpb/sender.proto, sending from the account of the key source.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runServer implements the "server" subcommand
+func runServer(args []string) {
+	var opts serverOptions
+	fs := newServerFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	if _, err := feeOptions(); err != nil {
+		exitf(exitInvalid, "Invalid fees: %v", err)
+	}
+	// the RPC transport is traced from the first request
+	stopTracing, err := setupTracing()
+	if err != nil {
+		fatalf("Failed to set up tracing: %v", err)
+	}
+	defer stopTracing()
+	client, chainID := dialRPC()
+	if err := applySendPolicy(client, chainID); err != nil {
+		exitf(exitInvalid, "Invalid send policy: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	infof("Sender's address: %s", signer.Address().Hex())
+	seedPolicySpend(chainID, []common.Address{signer.Address()})
+
+	watchConfig(fs, client, chainID)
+	serveMetrics()
+	lis, err := net.Listen("tcp", opts.listen)
+	if err != nil {
+		fatalf("Failed to listen: %v", err)
+	}
+	var serverOpts []grpc.ServerOption
+	if tracingEnabled {
+		// a span per call, continuing the trace of the client if it sent one
+		serverOpts = append(serverOpts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	}
+	srv := grpc.NewServer(serverOpts...)
+	senderpb.RegisterSenderServer(srv, &grpcServer{client: client, chainID: chainID, signer: signer})
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		infof("Shutting down gRPC server")
+		srv.GracefulStop()
+	}()
+	infof("Serving gRPC on %s", lis.Addr())
+	if err := srv.Serve(lis); err != nil {
+		stopTracing()
+		fatalf("gRPC server failed: %v", err)
+	}
+}
+
+// grpcServer implements senderpb.SenderServer on top of the RPC connection and key source
+type grpcServer struct {
+	senderpb.UnimplementedSenderServer
+	client  *ethclient.Client
+	chainID *big.Int
+	signer  sender.Signer
+}
+
+// Send builds, signs and broadcasts the transfer of req
+func (s *grpcServer) Send(ctx context.Context, req *senderpb.SendRequest) (*senderpb.SendResponse, error) {
+	// a request retried with the same idempotency-key metadata gets the transaction of the first
+	if key := grpcIdempotencyKey(ctx); key != "" {
+		idempotencyMu.Lock()
+		defer idempotencyMu.Unlock()
+		existing, err := findIdempotent(s.client, s.chainID, key)
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "idempotency key %q: %v", key, err)
+		}
+		if existing != nil {
+			infof("Request with idempotency key %s already sent as %s", key, existing.Hash)
+			return &senderpb.SendResponse{Hash: existing.Hash, From: existing.From, Nonce: *existing.Nonce}, nil
+		}
+		ctx = withIdempotencyKey(ctx, key)
+	}
+	to, err := parseAddress(req.To)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid to: %v", err)
+	}
+	// the policy stays the same until the transaction is sent, a reload waits for it
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if err := checkAllowed(to); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if !sendLimiter.Allow() {
+		return nil, status.Errorf(codes.ResourceExhausted, "-rateLimit of %v transactions per minute reached", *rateLimitFlag)
+	}
+	amount := new(big.Int)
+	if req.Value != "" {
+		if _, ok := amount.SetString(req.Value, 10); !ok || amount.Sign() < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid value %q, expected a non-negative decimal integer", req.Value)
+		}
+	}
+	value, data := amount, req.Data
+	if req.TokenContract != "" {
+		if len(req.Data) > 0 {
+			return nil, status.Error(codes.InvalidArgument, "data cannot be combined with token_contract")
+		}
+		token, err := loadToken(s.client, req.TokenContract, *tokenABIFlag)
+		if err == nil {
+			err = token.verify()
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid token_contract: %v", err)
+		}
+		if data, err = token.abi.Pack("transfer", to, amount); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to encode transfer: %v", err)
+		}
+		to, value = token.address, new(big.Int)
+	}
+
+	refund, err := activePolicy.admit(&to, value, data)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	fees, err := feeOptions()
+	if err != nil {
+		refund()
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid fees: %v", err)
+	}
+	from := s.signer.Address()
+	builder := &sender.Builder{
+		Client:   &sender.Client{Client: s.client, ChainID: s.chainID},
+		Fees:     fees,
+		TxType:   sender.TxType(*txTypeFlag),
+		GasLimit: req.GasLimit,
+		Nonces:   nonces,
+	}
+	tx, err := builder.Build(ctx, from, &to, value, data)
+	if err != nil {
+		refund()
+		txFailed.WithLabelValues("send").Inc()
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	signedTx, err := s.signTx(ctx, tx)
+	if err == nil {
+		err = sendTransaction(ctx, s.client, signedTx)
+	}
+	if err != nil {
+		// an unused nonce is handed out again, so the next request does not leave a gap
+		refund()
+		nonces.Fail(from, tx.Nonce(), err)
+		nonceGaps.Inc()
+		txFailed.WithLabelValues("send").Inc()
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to send transaction: %v", err)
+	}
+	infof("Sent %s with nonce %d to %s", signedTx.Hash().Hex(), tx.Nonce(), to.Hex())
+	txSent.Inc()
+	go s.track(trace.SpanContextFromContext(ctx), signedTx, time.Now())
+	return &senderpb.SendResponse{Hash: signedTx.Hash().Hex(), From: from.Hex(), Nonce: tx.Nonce()}, nil
+}
+
+// track follows tx until it is mined for the metrics and the history, as clients need not watch it.
+// The wait outlives the call that sent tx, its span is linked to the call's
+func (s *grpcServer) track(call trace.SpanContext, tx *types.Transaction, sent time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "track", trace.WithLinks(trace.Link{SpanContext: call}),
+		trace.WithAttributes(attribute.String("eth.tx_hash", tx.Hash().Hex())))
+	receipt, err := sender.WaitReceipt(ctx, s.client, tx.Hash(), *confirmations, pollInterval)
+	endSpan(span, err)
+	if err != nil {
+		txFailed.WithLabelValues("unknown").Inc()
+		return
+	}
+	observeReceipt(receipt, sent)
+	recordReceipt(s.client, receipt)
+}
+
+// signTx enforces the fee limits of the flags and signs tx
+func (s *grpcServer) signTx(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	if err := checkFeeCap(s.client, s.chainID, tx); err != nil {
+		return nil, err
+	}
+	return sender.SignTx(ctx, s.signer, tx, s.chainID)
+}
+
+// WatchTransaction polls the transaction of req and streams its status whenever it changes
+func (s *grpcServer) WatchTransaction(req *senderpb.WatchTransactionRequest, stream senderpb.Sender_WatchTransactionServer) error {
+	if len(req.Hash) != 66 || !strings.HasPrefix(req.Hash, "0x") {
+		return status.Errorf(codes.InvalidArgument, "invalid hash %q", req.Hash)
+	}
+	hash := common.HexToHash(req.Hash)
+	want := req.Confirmations
+	if want == 0 {
+		want = *confirmations
+	}
+	ctx := stream.Context()
+	var last *senderpb.TransactionStatus
+	for {
+		current, err := s.transactionStatus(ctx, hash, want)
+		if err != nil {
+			if ctx.Err() != nil {
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			return status.Errorf(codes.Unavailable, "failed to get transaction status: %v", err)
+		}
+		if current == nil {
+			if last == nil {
+				return status.Errorf(codes.NotFound, "transaction %s is unknown to the node", hash.Hex())
+			}
+			current = &senderpb.TransactionStatus{Hash: hash.Hex(), State: senderpb.TransactionStatus_DROPPED}
+		}
+		if !proto.Equal(current, last) {
+			debugf("Transaction %s is %s", hash.Hex(), current.State)
+			if err := stream.Send(current); err != nil {
+				return err
+			}
+			last = current
+		}
+		switch current.State {
+		case senderpb.TransactionStatus_CONFIRMED, senderpb.TransactionStatus_FAILED, senderpb.TransactionStatus_DROPPED:
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// transactionStatus returns the status of hash, or nil if the node does not know it
+func (s *grpcServer) transactionStatus(ctx context.Context, hash common.Hash, want uint64) (*senderpb.TransactionStatus, error) {
+	receipt, err := s.client.TransactionReceipt(ctx, hash)
+	if err != nil {
+		// freshly started geth nodes report missing receipts as "indexing in progress"
+		if !errors.Is(err, ethereum.NotFound) && !strings.Contains(err.Error(), "indexing is in progress") {
+			return nil, err
+		}
+		if _, _, err := s.client.TransactionByHash(ctx, hash); errors.Is(err, ethereum.NotFound) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return &senderpb.TransactionStatus{Hash: hash.Hex(), State: senderpb.TransactionStatus_PENDING}, nil
+	}
+	head, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	block := receipt.BlockNumber.Uint64()
+	current := &senderpb.TransactionStatus{
+		Hash:        hash.Hex(),
+		State:       senderpb.TransactionStatus_INCLUDED,
+		BlockNumber: block,
+		GasUsed:     receipt.GasUsed,
+	}
+	if head >= block {
+		current.Confirmations = head - block + 1
+	}
+	if receipt.EffectiveGasPrice != nil {
+		current.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+	}
+	switch {
+	case receipt.Status != types.ReceiptStatusSuccessful:
+		current.State = senderpb.TransactionStatus_FAILED
+	case current.Confirmations >= want:
+		current.State = senderpb.TransactionStatus_CONFIRMED
+	}
+	return current, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// runService implements the "service install|uninstall|status" subcommand
+func runService(args []string) {
+	if len(args) == 0 {
+		serviceUsage()
+		exit(exitInvalid)
+	}
+
+	action := args[0]
+	var opts serviceOptions
+	fs := newServiceFlagSet(action, &opts)
+	fs.Parse(args[1:])
+
+	var err error
+	switch action {
+	case "install":
+		serviceArgs := fs.Args()
+		if len(serviceArgs) == 0 {
+			fmt.Println("Error: Missing arguments for the daemon command line")
+			serviceUsage()
+			exit(exitInvalid)
+		}
+		if err := checkServiceArgs(serviceArgs, opts.envFile); err != nil {
+			exitf(exitInvalid, "Invalid service arguments: %v", err)
+		}
+		// the service restarts the daemon on failure, which picks up the queue where it stopped. A
+		// one-shot send would be paid again on every restart
+		serviceArgs = append([]string{"daemon"}, serviceArgs...)
+		var exe string
+		exe, err = os.Executable()
+		if err != nil {
+			fatalf("Failed to locate executable: %v", err)
+		}
+		exe, err = filepath.Abs(exe)
+		if err != nil {
+			fatalf("Failed to locate executable: %v", err)
+		}
+		err = installService(opts.name, exe, serviceArgs, opts.user, opts.envFile)
+		if err == nil {
+			fmt.Printf("Service %s installed and started\n", opts.name)
+		}
+	case "uninstall":
+		err = uninstallService(opts.name)
+		if err == nil {
+			fmt.Printf("Service %s uninstalled\n", opts.name)
+		}
+	case "status":
+		err = serviceStatus(opts.name)
+	case "run":
+		// entry point used by the service manager itself
+		err = runInstalledService(opts.name, opts.envFile, fs.Args())
+	default:
+		fmt.Printf("Error: Unknown service action %q\n", action)
+		serviceUsage()
+		exit(exitInvalid)
+	}
+	if err != nil {
+		fatalf("Service %s failed: %v", action, err)
+	}
+}
+
+// serviceActions lists the verbs accepted by the service subcommand
+var serviceActions = []string{"install", "uninstall", "status"}
+
+// serviceOptions holds the flags of the service subcommand
+type serviceOptions struct {
+	name    string
+	user    string
+	envFile string
+}
+
+// serviceSecretFlags take a secret on the command line, which ends up in the service definition
+// readable by other local users
+var serviceSecretFlags = []string{"privateKey", "mnemonic", "password"}
+
+// checkServiceArgs refuses daemon arguments that carry a secret, and keys read from the
+// environment without an -envFile to provide them
+func checkServiceArgs(args []string, envFile string) error {
+	var names []string
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		names = append(names, name)
+	}
+	for _, name := range serviceSecretFlags {
+		if slices.Contains(names, name) {
+			return fmt.Errorf("-%s would be stored in the service definition, use -privateKeyEnv and -envFile instead", name)
+		}
+	}
+	if (slices.Contains(names, "privateKeyEnv") || slices.Contains(names, "privateKeysEnv")) && envFile == "" {
+		return errors.New("the service does not inherit this shell's environment, pass the file setting the key variable with -envFile")
+	}
+	if envFile == "" {
+		return nil
+	}
+	info, err := os.Stat(envFile)
+	if err != nil {
+		return fmt.Errorf("invalid -envFile: %v", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("-envFile %s is readable by other users, restrict it with chmod 600", envFile)
+	}
+	return nil
+}
+
+func newServiceFlagSet(action string, opts *serviceOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("service "+action, flag.ExitOnError)
+	fs.StringVar(&opts.name, "name", "eip1559-sender", "Service name")
+	fs.StringVar(&opts.user, "user", "", "Account the service runs as (default: the service manager's default)")
+	fs.StringVar(&opts.envFile, "envFile", "", "File of KEY=VALUE lines setting the environment of the daemon, such as its -privateKeyEnv variable. It must not be readable by other users")
+	fs.Usage = serviceUsage
+	return fs
+}
+
+func serviceUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s service install|uninstall|status [options] [-- daemon arguments...]\n", os.Args[0])
+	fmt.Fprintf(out, "\nOptions:\n")
+	fmt.Fprintf(out, "  -envFile string\n    \tFile of KEY=VALUE lines setting the environment of the daemon, such as its -privateKeyEnv variable. It must not be readable by other users\n")
+	fmt.Fprintf(out, "  -name string\n    \tService name (default \"eip1559-sender\")\n")
+	fmt.Fprintf(out, "  -user string\n    \tAccount the service runs as (default: the service manager's default)\n")
+	fmt.Fprintf(out, "\nThe service runs %s daemon with the arguments after --, and restarts it on failure.\n", os.Args[0])
+	fmt.Fprintf(out, "\nExample:\n")
+	fmt.Fprintf(out, "  %s service install -name payouts -user payouts -envFile /etc/payouts/env -- -queue /var/spool/payouts -rpcURL https://... -privateKeyEnv SENDER_KEY\n", os.Args[0])
+}
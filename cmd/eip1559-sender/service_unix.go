@@ -0,0 +1,138 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const systemdUnitDir = "/etc/systemd/system"
+
+// systemdUnit renders a unit file that restarts the command on failure and logs to the journal.
+// The environment, secrets included, is read from envFile rather than kept in the unit
+func systemdUnit(name, exe string, args []string, user, envFile string) string {
+	cmd := []string{systemdQuote(exe)}
+	for _, arg := range args {
+		cmd = append(cmd, systemdQuote(arg))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=EIP1559-sender (%s)\n", name)
+	fmt.Fprintf(&b, "Wants=network-online.target\n")
+	fmt.Fprintf(&b, "After=network-online.target\n")
+	fmt.Fprintf(&b, "StartLimitIntervalSec=300\n")
+	fmt.Fprintf(&b, "StartLimitBurst=5\n")
+	fmt.Fprintf(&b, "\n[Service]\n")
+	fmt.Fprintf(&b, "Type=simple\n")
+	if envFile != "" {
+		fmt.Fprintf(&b, "EnvironmentFile=%s\n", envFile)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(cmd, " "))
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=10s\n")
+	if user != "" {
+		fmt.Fprintf(&b, "User=%s\n", user)
+	}
+	fmt.Fprintf(&b, "StandardOutput=journal\n")
+	fmt.Fprintf(&b, "StandardError=journal\n")
+	fmt.Fprintf(&b, "SyslogIdentifier=%s\n", name)
+	fmt.Fprintf(&b, "NoNewPrivileges=true\n")
+	fmt.Fprintf(&b, "\n[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// systemdQuote quotes a single ExecStart argument, escaping systemd specifiers
+func systemdQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	arg = strings.ReplaceAll(arg, `"`, `\"`)
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	arg = strings.ReplaceAll(arg, "$", "$$")
+	return `"` + arg + `"`
+}
+
+func unitPath(name string) string {
+	return filepath.Join(systemdUnitDir, name+".service")
+}
+
+func requireSystemd() error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("service management is not supported on %s (only systemd on Linux and Windows services)", runtime.GOOS)
+	}
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return errors.New("systemctl not found, is systemd running?")
+	}
+	return nil
+}
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func installService(name, exe string, args []string, user, envFile string) error {
+	if err := requireSystemd(); err != nil {
+		return err
+	}
+	path := unitPath(name)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("unit %s already exists, uninstall it first", path)
+	}
+	if envFile != "" {
+		var err error
+		if envFile, err = filepath.Abs(envFile); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(path, []byte(systemdUnit(name, exe, args, user, envFile)), 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote unit file %s\n", path)
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", "--now", name+".service")
+}
+
+func uninstallService(name string) error {
+	if err := requireSystemd(); err != nil {
+		return err
+	}
+	path := unitPath(name)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("unit %s not found", path)
+	}
+	if err := systemctl("disable", "--now", name+".service"); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return systemctl("daemon-reload")
+}
+
+func serviceStatus(name string) error {
+	if err := requireSystemd(); err != nil {
+		return err
+	}
+	err := systemctl("status", "--no-pager", name+".service")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// systemctl status uses non-zero exit codes to report inactive units
+		exit(exitErr.ExitCode())
+	}
+	return err
+}
+
+func runInstalledService(name, envFile string, args []string) error {
+	return errors.New("service run is only used by the Windows service manager")
+}
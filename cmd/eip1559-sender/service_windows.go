@@ -0,0 +1,228 @@
+//go:build windows
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func installService(name, exe string, args []string, user, envFile string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists, uninstall it first", name)
+	}
+
+	// the service manager starts us with "service run", which supervises the actual command
+	runArgs := []string{"service", "run", "-name", name}
+	if envFile != "" {
+		if envFile, err = filepath.Abs(envFile); err != nil {
+			return err
+		}
+		runArgs = append(runArgs, "-envFile", envFile)
+	}
+	runArgs = append(append(runArgs, "--"), args...)
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName:      "EIP1559-sender (" + name + ")",
+		Description:      "EIP1559-sender transaction service",
+		StartType:        mgr.StartAutomatic,
+		ServiceStartName: user,
+	}, runArgs...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	err = s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+	}, uint32((24 * time.Hour).Seconds()))
+	if err != nil {
+		return err
+	}
+
+	if err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("failed to register event log source: %v", err)
+	}
+	return s.Start()
+}
+
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s not found", name)
+	}
+	defer s.Close()
+
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		if _, err := s.Control(svc.Stop); err != nil {
+			return err
+		}
+	}
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	return eventlog.Remove(name)
+}
+
+func serviceStatus(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s not found", name)
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	config, err := s.Config()
+	if err != nil {
+		return err
+	}
+	states := map[svc.State]string{
+		svc.Stopped:         "stopped",
+		svc.StartPending:    "start pending",
+		svc.StopPending:     "stop pending",
+		svc.Running:         "running",
+		svc.ContinuePending: "continue pending",
+		svc.PausePending:    "pause pending",
+		svc.Paused:          "paused",
+	}
+	fmt.Printf("Service: %s\n", name)
+	fmt.Printf("State: %s\n", states[status.State])
+	fmt.Printf("PID: %d\n", status.ProcessId)
+	fmt.Printf("Command: %s\n", config.BinaryPathName)
+	return nil
+}
+
+// serviceHandler runs the configured command as a child process and stops it on request
+type serviceHandler struct {
+	name    string
+	envFile string
+	args    []string
+	elog    *eventlog.Log
+}
+
+func (h *serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	exe, err := os.Executable()
+	if err != nil {
+		h.elog.Error(1, fmt.Sprintf("Failed to locate executable: %v", err))
+		return false, 1
+	}
+	cmd := exec.Command(exe, h.args...)
+	if h.envFile != "" {
+		env, err := readEnvFile(h.envFile)
+		if err != nil {
+			h.elog.Error(1, fmt.Sprintf("Failed to read the environment file: %v", err))
+			return false, 1
+		}
+		cmd.Env = append(os.Environ(), env...)
+	}
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	go func() {
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			h.elog.Info(1, scanner.Text())
+		}
+	}()
+	if err := cmd.Start(); err != nil {
+		h.elog.Error(1, fmt.Sprintf("Failed to start command: %v", err))
+		return false, 1
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait(); pw.Close() }()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				h.elog.Error(1, fmt.Sprintf("Command exited: %v", err))
+				// a non-zero exit code makes the service manager apply the recovery actions
+				return false, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				cmd.Process.Kill()
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+// readEnvFile reads the KEY=VALUE lines of an environment file, skipping blank lines and comments
+func readEnvFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.Contains(line, "=") {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, i+1)
+		}
+		env = append(env, line)
+	}
+	return env, nil
+}
+
+func runInstalledService(name, envFile string, args []string) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return fmt.Errorf("service run must be started by the Windows service manager")
+	}
+	elog, err := eventlog.Open(name)
+	if err != nil {
+		return err
+	}
+	defer elog.Close()
+	return svc.Run(name, &serviceHandler{name: name, envFile: envFile, args: args, elog: elog})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+	"github.com/holiman/uint256"
+)
+
+// authorizationSigner is implemented by signers that can sign EIP-7702 authorizations
+type authorizationSigner interface {
+	SignAuthorization(auth types.SetCodeAuthorization) (types.SetCodeAuthorization, error)
+}
+
+func (s *keySigner) SignAuthorization(auth types.SetCodeAuthorization) (types.SetCodeAuthorization, error) {
+	return types.SignSetCode(s.Key, auth)
+}
+
+func (s *kmsSigner) SignAuthorization(auth types.SetCodeAuthorization) (types.SetCodeAuthorization, error) {
+	hash := auth.SigHash()
+	signature, err := s.signDigest(hash[:])
+	if err != nil {
+		return types.SetCodeAuthorization{}, err
+	}
+	auth.R.SetBytes(signature[:32])
+	auth.S.SetBytes(signature[32:64])
+	auth.V = signature[64]
+	return auth, nil
+}
+
+// loadAuthority returns the signer of the authorization: the key in the environment variable
+// named by -authKeyEnv, or the sender itself
+func loadAuthority(account sender.Signer) (sender.Signer, error) {
+	if *authKeyEnvFlag == "" {
+		return account, nil
+	}
+	return envKeySigner(*authKeyEnvFlag)
+}
+
+// envKeySigner returns a signer for the hex private key in the environment variable name, for the
+// second key of a command
+func envKeySigner(name string) (sender.Signer, error) {
+	hexKey, ok := os.LookupEnv(name)
+	if !ok || hexKey == "" {
+		return nil, invalidf("environment variable %s is not set", name)
+	}
+	raw := []byte(hexKey)
+	key, err := parseHexKey(raw)
+	wipe(raw)
+	if err != nil {
+		return nil, invalidf("failed to parse private key in %s: %v", name, err)
+	}
+	return newKeySigner(key)
+}
+
+// setCodeTx turns tx into an EIP-7702 transaction whose authorization delegates the authority's
+// code to -delegate. The authority is the sender, or the account of -authKeyEnv
+func setCodeTx(client *ethclient.Client, account sender.Signer, chainID *big.Int, tx *types.Transaction, nf numberFormat) *types.Transaction {
+	if tx.Type() == types.LegacyTxType {
+		exitf(exitInvalid, "Set-code transactions cannot be sent as legacy transactions")
+	}
+	delegate, err := parseAddress(*delegateFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid delegate: %v", err)
+	}
+	ctx := opCtx
+	if delegate == (common.Address{}) {
+		infof("Clearing the delegation")
+	} else if code, err := client.CodeAt(ctx, delegate, nil); err != nil {
+		exitf(exitRPC, "Failed to get delegate code: %v", err)
+	} else if len(code) == 0 {
+		warnf("Delegate %s has no code, calls to the authority will do nothing", delegate.Hex())
+	}
+
+	authority, err := loadAuthority(account)
+	if err != nil {
+		fatalf("Failed to load authorization key: %v", err)
+	}
+	signer, ok := authority.(authorizationSigner)
+	if !ok {
+		exitf(exitInvalid, "The signer cannot sign EIP-7702 authorizations, use -authKeyEnv")
+	}
+	// the sender's nonce is incremented before the authorization is applied
+	nonce := tx.Nonce() + 1
+	if authority.Address() != account.Address() {
+		if nonce, err = sourceNonce(ctx, client, authority.Address()); err != nil {
+			exitf(exitRPC, "Failed to get authority nonce: %v", err)
+		}
+	}
+	auth, err := signer.SignAuthorization(types.SetCodeAuthorization{
+		ChainID: *uint256.MustFromBig(chainID),
+		Address: delegate,
+		Nonce:   nonce,
+	})
+	if err != nil {
+		fatalf("Failed to sign authorization: %v", err)
+	}
+	infof("Authorization: %s delegates to %s (nonce %d)", authority.Address().Hex(), delegate.Hex(), nonce)
+	auths := []types.SetCodeAuthorization{auth}
+
+	// the authorization costs gas of its own and changes the code the call runs into
+	gas := tx.Gas()
+	if *gasLimitFlag == 0 {
+		if gas, err = client.EstimateGas(ctx, ethereum.CallMsg{
+			From:              account.Address(),
+			To:                tx.To(),
+			Value:             tx.Value(),
+			Data:              tx.Data(),
+			AuthorizationList: auths,
+		}); err != nil {
+			fatalf("Failed to estimate gas: %s", describeCallError(err))
+		}
+		infof("Estimated gas limit with the authorization: %s", nf.format(fmt.Sprint(gas)))
+		if gas, err = withGasMargin(gas); err != nil {
+			fatalf("Invalid gas limit: %v", err)
+		}
+	}
+	balance, err := client.PendingBalanceAt(ctx, account.Address())
+	if err != nil {
+		exitf(exitRPC, "Failed to get balance: %v", err)
+	}
+	if err := sender.CheckFunds(balance, tx.Value(), gas, tx.GasFeeCap()); err != nil {
+		exitf(exitFunds, "Insufficient funds: %v", err)
+	}
+	return types.NewTx(&types.SetCodeTx{
+		ChainID:   uint256.MustFromBig(chainID),
+		Nonce:     tx.Nonce(),
+		GasTipCap: uint256.MustFromBig(tx.GasTipCap()),
+		GasFeeCap: uint256.MustFromBig(tx.GasFeeCap()),
+		Gas:       gas,
+		To:        *tx.To(),
+		Value:     uint256.MustFromBig(tx.Value()),
+		Data:      tx.Data(),
+		AuthList:  auths,
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// notifyShutdown returns a context done on the first SIGINT or SIGTERM. Signals then get their
+// default handling back, so a second one exits at once
+func notifyShutdown() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	return ctx
+}
+
+// waitShutdown waits up to -shutdownTimeout for wg, and tells whether it got done
+func waitShutdown(wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(*shutdownTimeoutFlag):
+		return false
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// defaultExpiresIn is how long a -signTo file is meant to be broadcast within by default
+const defaultExpiresIn = 24 * time.Hour
+
+// signedTxFile is a signed transaction written by -signTo, to be broadcast later with -sendFrom
+// or "broadcast -rawTx", possibly on another machine
+type signedTxFile struct {
+	ChainID   uint64         `json:"chainId"`
+	From      common.Address `json:"from"`
+	Nonce     uint64         `json:"nonce"`
+	Hash      common.Hash    `json:"hash"`
+	SignedAt  time.Time      `json:"signedAt"`
+	ExpiresAt *time.Time     `json:"expiresAt,omitempty"`
+	RawTx     hexutil.Bytes  `json:"rawTx"`
+}
+
+// writeSignedTx writes tx, signed by from, to path with its chain ID and, unless -expiresIn is 0,
+// the time after which it should no longer be broadcast, as its fees and nonce go stale
+func writeSignedTx(path string, chainID *big.Int, from common.Address, tx *types.Transaction) error {
+	out, err := newSignedTxFile(chainID, from, tx)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// newSignedTxFile describes tx, signed by from, expiring after -expiresIn unless it is 0
+func newSignedTxFile(chainID *big.Int, from common.Address, tx *types.Transaction) (signedTxFile, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return signedTxFile{}, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	out := signedTxFile{
+		ChainID:  chainID.Uint64(),
+		From:     from,
+		Nonce:    tx.Nonce(),
+		Hash:     tx.Hash(),
+		SignedAt: now,
+		RawTx:    raw,
+	}
+	if *expiresIn > 0 {
+		expires := now.Add(*expiresIn)
+		out.ExpiresAt = &expires
+	}
+	return out, nil
+}
+
+// parseSignedTx reads the transaction of a -signTo file, refusing it once expired unless -force
+// is given
+func parseSignedTx(data []byte) (*types.Transaction, error) {
+	var in signedTxFile
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(in.RawTx); err != nil {
+		return nil, fmt.Errorf("rawTx: %v", err)
+	}
+	if tx.Hash() != in.Hash {
+		return nil, fmt.Errorf("rawTx has hash %s, not %s", tx.Hash().Hex(), in.Hash.Hex())
+	}
+	if !tx.Protected() || tx.ChainId().Uint64() != in.ChainID {
+		return nil, fmt.Errorf("rawTx is not signed for chain ID %d", in.ChainID)
+	}
+	infof("Signed transaction of %s for chain ID %d, nonce %d, signed at %s", in.From.Hex(), in.ChainID, in.Nonce, in.SignedAt.Local().Format(time.RFC3339))
+	if in.ExpiresAt != nil && time.Now().After(*in.ExpiresAt) {
+		if !*forceFlag {
+			return nil, fmt.Errorf("it expired at %s, its fees may be stale; pass -force to broadcast it anyway", in.ExpiresAt.Local().Format(time.RFC3339))
+		}
+		warnf("The transaction expired at %s, broadcasting anyway as -force is given", in.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	return tx, nil
+}
+
+// checkSignTo rejects -signTo and -expiresIn where they cannot apply
+func checkSignTo() error {
+	if *signToFlag == "" {
+		if *expiresIn != defaultExpiresIn {
+			return errors.New("-expiresIn requires -signTo")
+		}
+		return nil
+	}
+	if *expiresIn < 0 {
+		return errors.New("-expiresIn must not be negative")
+	}
+	switch {
+	case *exportFlag != "":
+		return errors.New("-signTo cannot be combined with -exportUnsigned")
+	case *waitFlag || *bumpAfter > 0:
+		return errors.New("-signTo does not broadcast, -wait and -bumpAfter apply to -sendFrom")
+	}
+	return nil
+}
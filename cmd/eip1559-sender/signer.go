@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+	"golang.org/x/term"
+)
+
+// typedDataSigner is implemented by signers that can sign EIP-712 typed data
+type typedDataSigner interface {
+	// SignTypedData returns a [R || S || V] signature of the EIP-712 hash of structHash
+	// within the domain, with V either 0/1 or 27/28
+	SignTypedData(domainSeparator, structHash []byte) ([]byte, error)
+}
+
+// textSigner is implemented by signers that can sign EIP-191 personal messages
+type textSigner interface {
+	// SignText returns a [R || S || V] signature of the EIP-191 hash of text, with V either
+	// 0/1 or 27/28
+	SignText(text []byte) ([]byte, error)
+}
+
+// typedDataHash returns keccak256("\x19\x01" || domainSeparator || structHash)
+func typedDataHash(domainSeparator, structHash []byte) []byte {
+	return crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, structHash)
+}
+
+// keySigner signs with a private key held in memory, also typed data, messages and authorizations
+type keySigner struct {
+	*sender.KeySigner
+}
+
+func (s *keySigner) SignTypedData(domainSeparator, structHash []byte) ([]byte, error) {
+	return crypto.Sign(typedDataHash(domainSeparator, structHash), s.Key)
+}
+
+func (s *keySigner) SignText(text []byte) ([]byte, error) {
+	return crypto.Sign(accounts.TextHash(text), s.Key)
+}
+
+// keySources returns which of the key source flags are given
+func keySources() map[string]bool {
+	return map[string]bool{
+		"-privateKey":      *privateKeyFlag != "",
+		"-privateKeyEnv":   *privateKeyEnv != "",
+		"-privateKeyStdin": *privateKeyIn,
+		"-keystore":        *keystoreFlag != "",
+		"-privateKeysEnv":  *privateKeysEnv != "",
+		"-keystoreDir":     *keystoreDir != "",
+		"-ledger":          *ledgerFlag,
+		"-trezor":          *trezorFlag,
+		"-mnemonic":        *mnemonicFlag != "",
+		"-vaultPath":       *vaultPathFlag != "",
+		"-kmsKeyId":        *kmsKeyIDFlag != "",
+		"-clef":            *clefFlag != "",
+		"-pkcs11Module":    *pkcs11Module != "",
+		"-mnemonicFile":    *mnemonicFile != "",
+		"-from":            *fromFlag != "",
+	}
+}
+
+// loadSigner builds the signer selected by the key source flags
+func loadSigner() (sender.Signer, error) {
+	var selected []string
+	for name, set := range keySources() {
+		if set {
+			selected = append(selected, name)
+		}
+	}
+	sort.Strings(selected)
+	switch {
+	case len(selected) == 0:
+		return nil, invalidf("no key source given, use -privateKeyEnv, -privateKeyStdin, -privateKey, -keystore, -mnemonic, -mnemonicFile, -kmsKeyId, -pkcs11Module, -vaultPath, -clef, -ledger, -trezor, or -from with -exportUnsigned")
+	case len(selected) > 1:
+		return nil, invalidf("%s are mutually exclusive", strings.Join(selected, ", "))
+	}
+
+	switch {
+	case poolKeysGiven():
+		return nil, invalidf("-privateKeysEnv and -keystoreDir load a pool of senders, which only -batch, -stdin, daemon and sweep use")
+	case *fromFlag != "":
+		if *exportFlag == "" {
+			return nil, invalidf("-from only works with -exportUnsigned, it cannot sign")
+		}
+		address, err := parseAddress(*fromFlag)
+		if err != nil {
+			return nil, invalidf("invalid -from: %v", err)
+		}
+		return &addressSigner{address: address}, nil
+	case *keystoreFlag != "":
+		return loadKeystore(*keystoreFlag, *passwordFlag)
+	case *kmsKeyIDFlag != "":
+		return loadKMS(*kmsKeyIDFlag)
+	case *pkcs11Module != "":
+		return loadPKCS11(*pkcs11Module, *pkcs11Slot, *pkcs11Label)
+	case *vaultPathFlag != "":
+		return loadVaultKey(*vaultAddrFlag, *vaultPathFlag, *vaultField)
+	case *clefFlag != "":
+		return loadClef(*clefFlag, *clefAccountFlag)
+	case *ledgerFlag, *trezorFlag, *mnemonicFlag != "", *mnemonicFile != "":
+		path, err := accounts.ParseDerivationPath(*hdPathFlag)
+		if err != nil {
+			return nil, invalidf("invalid HD path: %v", err)
+		}
+		switch {
+		case *trezorFlag:
+			return loadTrezor(path)
+		case *ledgerFlag:
+			return loadLedger(path)
+		}
+		mnemonic := *mnemonicFlag
+		if *mnemonicFile != "" {
+			data, err := os.ReadFile(*mnemonicFile)
+			if err != nil {
+				return nil, invalidf("%v", err)
+			}
+			mnemonic = string(data)
+		}
+		key, err := mnemonicToKey(strings.Join(strings.Fields(mnemonic), " "), "", path)
+		if err != nil {
+			return nil, invalidf("failed to derive key from mnemonic: %v", err)
+		}
+		return newKeySigner(key)
+	}
+	var hexKey []byte
+	switch {
+	case *privateKeyEnv != "":
+		value, ok := os.LookupEnv(*privateKeyEnv)
+		if !ok || value == "" {
+			return nil, invalidf("environment variable %s is not set", *privateKeyEnv)
+		}
+		hexKey = []byte(value)
+	case *privateKeyIn:
+		line, err := readSecretLine(os.Stdin)
+		if err != nil {
+			return nil, invalidf("failed to read private key from stdin: %v", err)
+		}
+		hexKey = line
+	default:
+		warnf("-privateKey exposes the key in shell history and process lists, use -privateKeyEnv or -privateKeyStdin instead")
+		hexKey = []byte(*privateKeyFlag)
+		*privateKeyFlag = ""
+	}
+	key, err := parseHexKey(hexKey)
+	wipe(hexKey)
+	if err != nil {
+		return nil, invalidf("failed to parse private key: %v", err)
+	}
+	return newKeySigner(key)
+}
+
+// loadKeystore decrypts a go-ethereum keystore (UTC/JSON) file, prompting for the password if none is given
+func loadKeystore(path, password string) (sender.Signer, error) {
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, invalidf("%v", err)
+	}
+	if password == "" {
+		if password, err = promptPassword(fmt.Sprintf("Password for %s: ", path)); err != nil {
+			return nil, invalidf("%v", err)
+		}
+	}
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		return nil, invalidf("failed to decrypt keystore: %v", err)
+	}
+	return newKeySigner(key.PrivateKey)
+}
+
+// promptPassword reads a password from the terminal without echoing it
+func promptPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no password given and stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	defer wipe(password)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
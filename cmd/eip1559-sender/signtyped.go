@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// signTypedOptions holds the flags of the sign-typed subcommand
+type signTypedOptions struct {
+	file string
+}
+
+func newSignTypedFlagSet(opts *signTypedOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("sign-typed", flag.ExitOnError)
+	fs.StringVar(&opts.file, "file", "", "JSON file holding the typed data as for eth_signTypedData_v4: types, primaryType, domain and message")
+	addRootFlags(fs, keyFlags...)
+	addRootFlags(fs, "v", "quiet", "logFormat", "config", "profile")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s sign-typed -file data.json -privateKeyEnv SENDER_KEY [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nSigns EIP-712 typed data, such as an off-chain order or approval, and prints the signature. No RPC is needed.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runSignTyped implements the "sign-typed" subcommand
+func runSignTyped(args []string) {
+	var opts signTypedOptions
+	fs := newSignTypedFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if opts.file == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	data, err := os.ReadFile(opts.file)
+	if err != nil {
+		fatalf("Failed to read typed data: %v", err)
+	}
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal(data, &typedData); err != nil {
+		exitf(exitInvalid, "Invalid typed data: %v", err)
+	}
+	domain, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		exitf(exitInvalid, "Invalid typed data domain: %v", err)
+	}
+	message, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		exitf(exitInvalid, "Invalid typed data message: %v", err)
+	}
+	hash := typedDataHash(domain, message)
+	describeTypedData(&typedData)
+	infof("EIP-712 hash: %s", hexutil.Encode(hash))
+
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	typed, ok := signer.(typedDataSigner)
+	if !ok {
+		exitf(exitInvalid, "The selected key source cannot sign EIP-712 typed data")
+	}
+	infof("Signer: %s", signer.Address().Hex())
+	signature, err := typed.SignTypedData(domain, message)
+	if err != nil {
+		fatalf("Failed to sign typed data: %v", err)
+	}
+	if signature[64] < 27 {
+		signature[64] += 27
+	}
+	// a hardware wallet signs what it was shown, so check the result covers the same hash
+	recovered := append([]byte{}, signature...)
+	recovered[64] -= 27
+	if pubkey, err := crypto.SigToPub(hash, recovered); err != nil {
+		fatalf("Failed to verify signature: %v", err)
+	} else if address := crypto.PubkeyToAddress(*pubkey); address != signer.Address() {
+		fatalf("Signature recovers to %s, not to the signer %s", address.Hex(), signer.Address().Hex())
+	}
+	resultf([]interface{}{"signature", hexutil.Encode(signature), "signer", signer.Address().Hex(), "hash", hexutil.Encode(hash)},
+		"Signature: %s", hexutil.Encode(signature))
+}
+
+// describeTypedData logs what is being signed: the primary type and the domain it is valid in
+func describeTypedData(typedData *apitypes.TypedData) {
+	domain := typedData.Domain
+	infof("Signing %s for %s (version %s)", typedData.PrimaryType, domain.Name, domain.Version)
+	if domain.ChainId != nil {
+		infof("Chain ID: %s", (*big.Int)(domain.ChainId))
+	}
+	if common.IsHexAddress(domain.VerifyingContract) {
+		infof("Verifying contract: %s", common.HexToAddress(domain.VerifyingContract).Hex())
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// simulateTx runs tx through eth_call and eth_estimateGas without broadcasting it and prints
+// the outcome and projected fee. It exits with status 1 if the transaction would fail
+func simulateTx(client *ethclient.Client, from common.Address, tx *types.Transaction, nf numberFormat) {
+	ctx := opCtx
+	msg := ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		GasFeeCap:  tx.GasFeeCap(),
+		GasTipCap:  tx.GasTipCap(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+	if tx.Type() == types.LegacyTxType {
+		msg.GasPrice, msg.GasFeeCap, msg.GasTipCap = tx.GasPrice(), nil, nil
+	}
+	if tx.Type() == types.BlobTxType {
+		msg.BlobGasFeeCap, msg.BlobHashes = tx.BlobGasFeeCap(), tx.BlobHashes()
+	}
+	msg.AuthorizationList = tx.SetCodeAuthorizations()
+	infof("Dry run, the transaction will not be broadcast")
+
+	output, err := client.PendingCallContract(ctx, msg)
+	if err != nil {
+		resultf([]interface{}{"status", "failed"}, "Simulation failed: %s", describeCallError(err))
+		exit(callExitCode(err))
+	}
+	if len(output) > 0 {
+		infof("Return data: %s", hexutil.Encode(output))
+	}
+	msg.Gas = 0
+	gas, err := client.EstimateGas(ctx, msg)
+	if err != nil {
+		resultf([]interface{}{"status", "failed"}, "Gas estimation failed: %s", describeCallError(err))
+		exit(callExitCode(err))
+	}
+
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get header: %v", err)
+	}
+	// the fee actually paid per gas is capped by maxFeePerGas
+	price := tx.GasFeeCap()
+	if header.BaseFee != nil {
+		price = new(big.Int).Add(header.BaseFee, tx.GasTipCap())
+	}
+	if price.Cmp(tx.GasFeeCap()) > 0 {
+		price = tx.GasFeeCap()
+	}
+	expected := new(big.Int).Mul(price, new(big.Int).SetUint64(gas))
+	worst := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas()))
+	resultf([]interface{}{"status", "success", "gas", gas}, "Simulation succeeded")
+	infof("Estimated gas used: %s (gas limit %s)", nf.format(fmt.Sprint(gas)), nf.format(fmt.Sprint(tx.Gas())))
+	infof("Projected fee: %s Wei at the current base fee, at most %s Wei", nf.format(expected.String()), nf.format(worst.String()))
+	infof("Total cost: at most %s Wei including the transferred value", nf.format(new(big.Int).Add(worst, tx.Value()).String()))
+}
+
+// describeCallError appends the decoded revert reason, if any, to a failed call's error
+func describeCallError(err error) string {
+	data := callRevertData(err)
+	if len(data) == 0 {
+		return err.Error()
+	}
+	if reason := decodeRevert(data); reason != "" {
+		return fmt.Sprintf("%v (reason: %s)", err, reason)
+	}
+	return fmt.Sprintf("%v (revert data: %s)", err, hexutil.Encode(data))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// weightDecimals is the precision of -split weighted weights, which are scaled to integers
+const weightDecimals = 18
+
+// splitBatch sets the amounts of transfers to their share of -amount, in the native coin or the
+// -tokenContract token: equal shares with -split equal, or shares in proportion to the amount
+// column with -split weighted. Shares are rounded down, and the base units left over go one each
+// to the first rows, so that they add up to -amount exactly
+func splitBatch(client *ethclient.Client, chainID *big.Int, transfers []*batchTransfer) error {
+	decimals, token := lookupChain(chainID).decimals, ""
+	if *tokenContract != "" {
+		erc20, err := loadToken(client, *tokenContract, *tokenABIFlag)
+		if err != nil {
+			return err
+		}
+		if decimals, err = erc20.decimals(); err != nil {
+			return err
+		}
+		token = erc20.address.Hex()
+	}
+	total, err := parseUnits(*amountFlag, decimals)
+	if err != nil {
+		return err
+	}
+
+	weights := make([]*big.Int, len(transfers))
+	sum := new(big.Int)
+	for i, t := range transfers {
+		if t.Token != "" {
+			return fmt.Errorf("row %d has a token, but -split sends a single asset, chosen with -tokenContract", t.row)
+		}
+		weights[i] = big.NewInt(1)
+		if *splitFlag == "weighted" {
+			if weights[i], err = parseUnits(t.Amount.String(), weightDecimals); err != nil {
+				return fmt.Errorf("row %d: invalid weight: %v", t.row, err)
+			}
+		}
+		sum.Add(sum, weights[i])
+	}
+	shares := make([]*big.Int, len(transfers))
+	left := new(big.Int).Set(total)
+	for i, weight := range weights {
+		shares[i] = new(big.Int).Div(new(big.Int).Mul(total, weight), sum)
+		left.Sub(left, shares[i])
+	}
+	// each share lost less than one base unit, so fewer units are left than there are rows
+	for i := 0; left.Sign() > 0; i++ {
+		shares[i].Add(shares[i], big.NewInt(1))
+		left.Sub(left, big.NewInt(1))
+	}
+	for i, t := range transfers {
+		if shares[i].Sign() == 0 {
+			return fmt.Errorf("the share of row %d rounds down to nothing, -amount is too small to split", t.row)
+		}
+		t.Amount, t.Token = json.Number(formatUnits(shares[i], decimals)), token
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// runStdinJobs implements -stdin: every JSON object read from stdin is a transfer in the format of
+// the daemon's jobs, sent as soon as it is read, and its result is printed as one line of JSON on
+// stdout. It exits with status 1 if any job failed or reverted
+func runStdinJobs() {
+	switch {
+	case *privateKeyIn:
+		exitf(exitInvalid, "-stdin and -privateKeyStdin both read stdin, give the key with -privateKeyEnv or another key source")
+	case *receiverFlag != "" || *amountFlag != "" || *amountRawFlag != "" || *tokenContract != "" || *batchFlag != "" || *uriFlag != "":
+		exitf(exitInvalid, "-stdin reads the receiver, amount and token of every transfer from stdin, and cannot be combined with -receiver, -amount, -amountRaw, -tokenContract, -batch or -uri")
+	case !*yesFlag && !*dryRunFlag:
+		exitf(exitInvalid, "-stdin cannot ask for confirmation as stdin carries the jobs, pass -yes to send them")
+	case *concurrency < 1:
+		exitf(exitInvalid, "-concurrency must be at least 1")
+	}
+	client, chainID := dialRPC()
+	pool, err := loadSenderPool()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	infof("Sender's address: %s", pool.describe())
+	wait := *waitFlag || *bumpAfter > 0 || *webhookFlag != ""
+
+	out := json.NewEncoder(os.Stdout)
+	var mu sync.Mutex
+	failed := false
+	report := func(result daemonResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := out.Encode(result); err != nil {
+			fatalf("Failed to write the result of job %d: %v", result.Job, err)
+		}
+		switch result.Status {
+		case "success", "sent", "simulated":
+		default:
+			failed = true
+		}
+	}
+
+	decimals := &tokenDecimals{byToken: map[string]int{}}
+	dec := json.NewDecoder(os.Stdin)
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, *concurrency)
+	for n := 1; ; n++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			// the decoder cannot find the next object after a syntax error, so reading stops here
+			report(daemonResult{Job: n, Status: "failed", Error: "invalid JSON: " + err.Error()})
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(n int, job *queuedJob) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			account := pool.acquire()
+			defer pool.release(account)
+			result := runJob(client, account, chainID, job, decimals, wait, nil)
+			result.Job = n
+			report(result)
+		}(n, &queuedJob{data: raw, name: strconv.Itoa(n)})
+	}
+	wg.Wait()
+	if failed {
+		exit(exitFailure)
+	}
+}
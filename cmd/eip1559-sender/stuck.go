@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// checkPendingAhead warns about the transactions of the signer that are pending with nonces below
+// nonce, which a new transaction at nonce is only mined after, and about queued transactions
+// held up by a nonce gap. With -rescue the pending ones are re-sent with raised fees first
+func checkPendingAhead(client *ethclient.Client, signer sender.Signer, chainID *big.Int, nonce uint64) {
+	ctx := opCtx
+	from := signer.Address()
+	mined, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		debugf("Failed to get the mined nonce: %v", err)
+		return
+	}
+	pool := poolContentFrom(client, from)
+	for _, tx := range pool["queued"] {
+		if tx.Nonce() > nonce {
+			warnf("Transaction %s with nonce %d is queued behind a nonce gap, it is only mined once nonces %d to %d are used", tx.Hash().Hex(), tx.Nonce(), nonce+1, tx.Nonce()-1)
+		}
+	}
+	if mined >= nonce {
+		return
+	}
+
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		debugf("Failed to get header: %v", err)
+		return
+	}
+	baseFee := header.BaseFee
+	if legacy, err := legacyTx(header); err != nil || legacy {
+		baseFee = nil
+	}
+	tip, feeCap, err := suggestFees(ctx, client, baseFee)
+	if err != nil {
+		debugf("Failed to suggest fees: %v", err)
+	}
+	warnf("%d transaction(s) of %s are pending ahead of nonce %d, which is only mined after them:", nonce-mined, from.Hex(), nonce)
+	var stuck []*types.Transaction
+	for n := mined; n < nonce; n++ {
+		tx := pool["pending"][n]
+		if tx == nil {
+			tx = historyPendingTx(client, from, chainID, n)
+		}
+		if tx == nil {
+			warnf("  nonce %d: unknown to the node's txpool and the history", n)
+			continue
+		}
+		note := ""
+		if baseFee != nil && tx.GasFeeCap().Cmp(baseFee) < 0 {
+			note = fmt.Sprintf(", below the base fee of %s gwei", formatUnits(baseFee, 9))
+		} else if tip != nil && tx.GasTipCap().Cmp(tip) < 0 {
+			note = fmt.Sprintf(", below the suggested tip of %s gwei", formatUnits(tip, 9))
+		}
+		warnf("  nonce %d: %s, maxFeePerGas %s gwei, maxPriorityFeePerGas %s gwei%s", n, tx.Hash().Hex(), formatUnits(tx.GasFeeCap(), 9), formatUnits(tx.GasTipCap(), 9), note)
+		stuck = append(stuck, tx)
+	}
+	if !*rescueFlag {
+		warnf("Pass -rescue to re-send them with fees raised by -bumpPercent first, or cancel them with -cancelNonce")
+		return
+	}
+	if *dryRunFlag {
+		infof("Dry run, the pending transactions are not rescued")
+		return
+	}
+	for _, tx := range stuck {
+		if err := rescueTx(client, signer, chainID, tx, tip, feeCap); err != nil {
+			warnf("Failed to rescue nonce %d: %v", tx.Nonce(), err)
+		}
+	}
+}
+
+// rescueTx re-sends the pending tx with its tip and fee cap raised by -bumpPercent, or to the
+// suggested tip and fee cap if higher, like -cancelNonce and -replaceTx
+func rescueTx(client *ethclient.Client, signer sender.Signer, chainID *big.Int, tx *types.Transaction, suggestedTip, suggestedFeeCap *big.Int) error {
+	if tx.Type() == types.BlobTxType {
+		return fmt.Errorf("%s carries blobs, which the node does not return, re-send it with -blob and -nonce %d", tx.Hash().Hex(), tx.Nonce())
+	}
+	tip := bumpFee(tx.GasTipCap(), *bumpPercent)
+	if suggestedTip != nil && suggestedTip.Cmp(tip) > 0 {
+		tip = suggestedTip
+	}
+	feeCap := bumpFee(tx.GasFeeCap(), *bumpPercent)
+	if suggestedFeeCap != nil && suggestedFeeCap.Cmp(feeCap) > 0 {
+		feeCap = suggestedFeeCap
+	}
+	if feeCap.Cmp(tip) < 0 {
+		feeCap = new(big.Int).Set(tip)
+	}
+	unsigned := sender.WithFees(tx, chainID, tip, feeCap, nil)
+	if err := checkFeeCap(client, chainID, unsigned); err != nil {
+		return err
+	}
+	replacement, err := signer.SignTx(unsigned, chainID)
+	if err != nil {
+		return err
+	}
+	if err := sendTransaction(opCtx, client, replacement); err != nil {
+		return err
+	}
+	txSent.Inc()
+	txReplaced.Inc()
+	notifyWebhook(replacedEvent(chainID, signer.Address(), tx, replacement.Hash()))
+	recordReplaced(tx, replacement.Hash())
+	infof("Rescued nonce %d: %s, maxFeePerGas %s gwei, maxPriorityFeePerGas %s gwei", tx.Nonce(), replacement.Hash().Hex(), formatUnits(feeCap, 9), formatUnits(tip, 9))
+	return nil
+}
+
+// poolContentFrom returns the pending and queued transactions of from by nonce through the
+// txpool namespace, empty if the node does not expose it
+func poolContentFrom(client *ethclient.Client, from common.Address) map[string]map[uint64]*types.Transaction {
+	var content map[string]map[string]*types.Transaction
+	if err := client.Client().CallContext(opCtx, &content, "txpool_contentFrom", from); err != nil {
+		debugf("Failed to get the txpool content: %v", err)
+		return nil
+	}
+	pool := map[string]map[uint64]*types.Transaction{}
+	for status, txs := range content {
+		pool[status] = map[uint64]*types.Transaction{}
+		for key, tx := range txs {
+			// geth keys transactions by decimal nonce, other clients by hex
+			nonce, err := strconv.ParseUint(key, 10, 64)
+			if err != nil {
+				if nonce, err = hexutil.DecodeUint64(key); err != nil {
+					continue
+				}
+			}
+			pool[status][nonce] = tx
+		}
+	}
+	return pool
+}
+
+// historyPendingTx returns the transaction of the history that from sent with nonce, if the node
+// still has it pending
+func historyPendingTx(client *ethclient.Client, from common.Address, chainID *big.Int, nonce uint64) *types.Transaction {
+	path, err := historyPath()
+	if err != nil || path == "" {
+		return nil
+	}
+	txs, err := loadHistory(path)
+	if err != nil {
+		return nil
+	}
+	// the latest version sent is the most likely to be pending
+	for i := len(txs) - 1; i >= 0; i-- {
+		record := txs[i]
+		if record.Status != "sent" || record.Nonce == nil || *record.Nonce != nonce || record.From != from.Hex() || record.ChainID != chainID.String() {
+			continue
+		}
+		if tx, pending, err := client.TransactionByHash(opCtx, common.HexToHash(record.Hash)); err == nil && pending {
+			return tx
+		}
+	}
+	return nil
+}
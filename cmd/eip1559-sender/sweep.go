@@ -0,0 +1,294 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// sweepOptions holds the flags of the sweep subcommand
+type sweepOptions struct {
+	to        string
+	minAmount string
+}
+
+func newSweepFlagSet(opts *sweepOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	fs.StringVar(&opts.to, "to", "", "Address receiving the balances of every account")
+	fs.StringVar(&opts.minAmount, "minAmount", "", "Skip accounts with less to send than this, in whole coins or tokens (default: skip only empty accounts)")
+	// every account takes its own nonce, and a sweep leaves nothing to pay for a bump
+	skipped := []string{"nonce", "nonceSource", "rescue", "exportUnsigned", "qr", "signTo", "expiresIn", "bumpAfter", "maxBumps", "validFor", "preflight", "preflightTxs", "explorerAPI", "explorerAPIKey", "from", "accessList", "simulate", "tenderlyProject", "tenderlyKey", "trace"}
+	for _, name := range sharedFlags {
+		if !slices.Contains(skipped, name) {
+			addRootFlags(fs, name)
+		}
+	}
+	addRootFlags(fs, "privateKeysEnv", "keystoreDir")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s sweep -to 0x... -privateKeysEnv KEY_1,KEY_2 [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nSends the entire balance of every account to one address: ETH less the maximum gas cost, or with -tokenContract the whole token balance.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// sweptAccount is an account of a sweep and what became of it
+type sweptAccount struct {
+	signer sender.Signer
+	nonces *sender.NonceManager
+	amount *big.Int
+	tx     *types.Transaction // unsigned until sent
+	hash   common.Hash
+	status string
+}
+
+// runSweep implements the "sweep" subcommand: -max for each account of the sender pool, all to
+// the same receiver and confirmed once
+func runSweep(args []string) {
+	var opts sweepOptions
+	fs := newSweepFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" || opts.to == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, chainID := dialRPC()
+	to, err := parseAddress(opts.to)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -to address: %v", err)
+	}
+	pool, err := loadSenderPool()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	infof("Sweeping %d accounts to %s", len(pool.accounts), to.Hex())
+
+	var token *erc20Token
+	decimals, symbol := lookupChain(chainID).decimals, lookupChain(chainID).symbol
+	if *tokenContract != "" {
+		if token, err = loadToken(client, *tokenContract, *tokenABIFlag); err != nil {
+			fatalf("Failed to load token contract: %v", err)
+		}
+		if decimals, err = token.decimals(); err != nil {
+			fatalf("Failed to get token decimals: %v", err)
+		}
+		symbol = token.symbol()
+		infof("Token: %s", token.describe())
+	}
+	minAmount := big.NewInt(1)
+	if opts.minAmount != "" {
+		if minAmount, err = parseUnits(opts.minAmount, decimals); err != nil {
+			exitf(exitInvalid, "Invalid -minAmount: %v", err)
+		}
+	}
+
+	ctx := opCtx
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		exitf(exitRPC, "Failed to get header: %v", err)
+	}
+	legacy, err := legacyTx(header)
+	if err != nil {
+		exitf(exitInvalid, "Invalid transaction type: %v", err)
+	}
+	baseFee := header.BaseFee
+	if legacy {
+		baseFee = nil
+	}
+	tip, feeCap, err := suggestFees(ctx, client, baseFee)
+	if err != nil {
+		exitf(exitRPC, "Failed to determine fees: %v", err)
+	}
+
+	accounts := make([]*sweptAccount, len(pool.accounts))
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "\nChain: %s (chain ID %s)\n", lookupChain(chainID).name, chainID)
+	sending := 0
+	for i, account := range pool.accounts {
+		swept := &sweptAccount{signer: account.signer, nonces: account.nonces}
+		accounts[i] = swept
+		from := account.signer.Address()
+		if from == to {
+			swept.status = "skipped: the account is the destination"
+			continue
+		}
+		if err := swept.prepare(client, chainID, token, to, legacy, tip, feeCap); err != nil {
+			swept.status = "skipped: " + err.Error()
+			continue
+		}
+		if swept.amount.Sign() == 0 {
+			swept.status = "skipped: nothing to send"
+			continue
+		}
+		if swept.amount.Cmp(minAmount) < 0 {
+			swept.status = fmt.Sprintf("skipped: dust of %s %s", nf.format(formatUnits(swept.amount, decimals)), symbol)
+			continue
+		}
+		sending++
+		fmt.Fprintf(&summary, "%d. %s %s from %s\n", sending, nf.format(formatUnits(swept.amount, decimals)), symbol, from.Hex())
+	}
+	if sending > 0 && !*dryRunFlag {
+		fmt.Fprintf(&summary, "All to %s\n", to.Hex())
+		if err := confirm(summary.String()); err != nil {
+			fatalf("Not sending: %v", err)
+		}
+	}
+
+	for _, swept := range accounts {
+		if swept.status != "" {
+			continue
+		}
+		swept.send(client, chainID)
+	}
+	if *waitFlag {
+		for _, swept := range accounts {
+			if swept.status != "sent" {
+				continue
+			}
+			receipt, err := sender.WaitReceipt(ctx, client, swept.hash, *confirmations, pollInterval)
+			if err == nil {
+				recordReceipt(client, receipt)
+			}
+			switch {
+			case err != nil:
+				swept.status = "unknown: " + err.Error()
+			case receipt.Status == types.ReceiptStatusSuccessful:
+				swept.status = fmt.Sprintf("success (block %d)", receipt.BlockNumber)
+			default:
+				swept.status = revertedStatus(client, receipt)
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nACCOUNT\tAMOUNT\tHASH\tSTATUS")
+	failed := false
+	total := new(big.Int)
+	for _, swept := range accounts {
+		from, amount, hash := swept.signer.Address().Hex(), "-", "-"
+		if swept.amount != nil {
+			amount = formatUnits(swept.amount, decimals)
+		}
+		if swept.status == "sent" || swept.status == "simulated" || strings.HasPrefix(swept.status, "success") {
+			total.Add(total, swept.amount)
+		} else if !strings.HasPrefix(swept.status, "skipped") {
+			failed = true
+		}
+		if swept.hash != (common.Hash{}) {
+			hash = swept.hash.Hex()
+		}
+		if *logFormatFlag == "json" {
+			resultf([]interface{}{"account", from, "amount", amount, "hash", hash, "status", swept.status}, "Sweep of %s: %s", from, swept.status)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", from, nf.format(amount), hash, swept.status)
+	}
+	w.Flush()
+	resultf([]interface{}{"to", to.Hex(), "total", formatUnits(total, decimals)}, "Swept %s %s to %s", nf.format(formatUnits(total, decimals)), symbol, to.Hex())
+	if failed {
+		exit(exitFailure)
+	}
+}
+
+// prepare works out what the account can send to to and builds the unsigned transaction: its
+// whole token balance, or its ETH balance less the maximum gas cost
+func (s *sweptAccount) prepare(client *ethclient.Client, chainID *big.Int, token *erc20Token, to common.Address, legacy bool, tip, feeCap *big.Int) error {
+	ctx := opCtx
+	from := s.signer.Address()
+	balance, err := client.PendingBalanceAt(ctx, from)
+	if err != nil {
+		return fmt.Errorf("failed to get balance: %v", err)
+	}
+	txTo, data := to, []byte(nil)
+	s.amount = new(big.Int)
+	if token != nil {
+		if s.amount, err = token.balanceOf(from); err != nil {
+			return fmt.Errorf("failed to get token balance: %v", err)
+		}
+		if s.amount.Sign() == 0 {
+			return nil
+		}
+		txTo = token.address
+		if data, err = token.abi.Pack("transfer", to, s.amount); err != nil {
+			return err
+		}
+	}
+	if token == nil && balance.Sign() == 0 {
+		return nil
+	}
+	// estimate with an empty value, the full balance would leave nothing for gas
+	gas := *gasLimitFlag
+	if gas == 0 {
+		if gas, err = client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &txTo, Data: data}); err != nil {
+			return fmt.Errorf("failed to estimate gas: %s", describeCallError(err))
+		}
+		if gas, err = withGasMargin(gas); err != nil {
+			return err
+		}
+	}
+	nonce, err := s.nonces.Reserve(ctx, client, from)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %v", err)
+	}
+	tx := sender.MakeTx(legacy, &types.DynamicFeeTx{ChainID: chainID, Nonce: nonce, GasTipCap: tip, GasFeeCap: feeCap, Gas: gas, To: &txTo, Data: data})
+	maxCost := new(big.Int).Mul(new(big.Int).SetUint64(gas), feeCap)
+	l1Fee, err := l1FeeReserve(client, tx)
+	if err != nil {
+		s.nonces.Release(from, nonce)
+		return err
+	}
+	maxCost.Add(maxCost, l1Fee)
+	value := new(big.Int)
+	if token == nil {
+		if s.amount.Sub(balance, maxCost).Sign() < 0 {
+			s.amount.SetInt64(0)
+		}
+		value = s.amount
+	} else if balance.Cmp(maxCost) < 0 {
+		s.nonces.Release(from, nonce)
+		chain := lookupChain(chainID)
+		return fmt.Errorf("%s %s does not cover the maximum gas cost of %s", formatUnits(balance, chain.decimals), chain.symbol, formatUnits(maxCost, chain.decimals))
+	}
+	s.tx = sender.MakeTx(legacy, &types.DynamicFeeTx{ChainID: chainID, Nonce: nonce, GasTipCap: tip, GasFeeCap: feeCap, Gas: gas, To: &txTo, Value: value, Data: data})
+	if err := checkFeeCap(client, chainID, s.tx); err != nil {
+		s.nonces.Release(from, nonce)
+		return err
+	}
+	return nil
+}
+
+// send signs and broadcasts the sweep of the account, or only simulates it with -dryRun
+func (s *sweptAccount) send(client *ethclient.Client, chainID *big.Int) {
+	if *dryRunFlag {
+		s.status = "simulated"
+		return
+	}
+	tx, err := s.signer.SignTx(s.tx, chainID)
+	if err == nil {
+		err = sendTransaction(opCtx, client, tx)
+	}
+	if err != nil {
+		s.status = "failed: " + err.Error()
+		return
+	}
+	s.hash, s.status = tx.Hash(), "sent"
+	infof("Swept %s: %s", s.signer.Address().Hex(), tx.Hash().Hex())
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// tenderlyAPI is the base URL of Tenderly's REST API
+const tenderlyAPI = "https://api.tenderly.co/api/v1"
+
+// tenderlyCall is a frame of the call trace of a Tenderly simulation
+type tenderlyCall struct {
+	CallType     string         `json:"call_type"`
+	From         string         `json:"from"`
+	To           string         `json:"to"`
+	FunctionName string         `json:"function_name"`
+	Value        string         `json:"value"`
+	GasUsed      uint64         `json:"gas_used"`
+	Error        string         `json:"error"`
+	ErrorReason  string         `json:"error_reason"`
+	Calls        []tenderlyCall `json:"calls"`
+}
+
+// tenderlyAssetChange is a token or native coin movement of a Tenderly simulation
+type tenderlyAssetChange struct {
+	Type      string `json:"type"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Amount    string `json:"amount"`
+	RawAmount string `json:"raw_amount"`
+	TokenInfo struct {
+		Symbol          string `json:"symbol"`
+		ContractAddress string `json:"contract_address"`
+	} `json:"token_info"`
+}
+
+// tenderlySimulation is the part of Tenderly's simulate response that is printed
+type tenderlySimulation struct {
+	Transaction struct {
+		Status          bool   `json:"status"`
+		GasUsed         uint64 `json:"gas_used"`
+		ErrorMessage    string `json:"error_message"`
+		TransactionInfo struct {
+			CallTrace    *tenderlyCall         `json:"call_trace"`
+			AssetChanges []tenderlyAssetChange `json:"asset_changes"`
+		} `json:"transaction_info"`
+	} `json:"transaction"`
+}
+
+// tenderlySimulate simulates tx from from with Tenderly and prints its call trace and asset
+// changes. It exits if the simulation cannot be run or the transaction would revert, before
+// anything is broadcast
+func tenderlySimulate(chainID *big.Int, from common.Address, tx *types.Transaction) {
+	if *simulateFlag != "tenderly" {
+		exitf(exitInvalid, "Invalid -simulate %q, expected tenderly", *simulateFlag)
+	}
+	result, err := runTenderlySimulation(chainID, from, tx)
+	if err != nil {
+		fatalf("Tenderly simulation failed: %v", err)
+	}
+	sim := result.Transaction
+	if trace := sim.TransactionInfo.CallTrace; trace != nil {
+		infof("Call trace:")
+		printTenderlyCall(trace, 1)
+	}
+	if changes := sim.TransactionInfo.AssetChanges; len(changes) > 0 {
+		infof("Asset changes:")
+		for _, change := range changes {
+			asset := change.TokenInfo.Symbol
+			if change.TokenInfo.ContractAddress != "" {
+				asset += " (" + change.TokenInfo.ContractAddress + ")"
+			}
+			amount := change.Amount
+			if amount == "" {
+				amount = change.RawAmount + " base units of"
+			}
+			infof("  %s %s %s from %s to %s", change.Type, amount, asset, change.From, change.To)
+		}
+	}
+	if !sim.Status {
+		resultf([]interface{}{"status", "reverted", "error", sim.ErrorMessage}, "Tenderly simulation reverted: %s", sim.ErrorMessage)
+		exit(exitReverted)
+	}
+	resultf([]interface{}{"status", "success", "gas", sim.GasUsed}, "Tenderly simulation succeeded, gas used %d", sim.GasUsed)
+}
+
+// runTenderlySimulation submits tx to the simulate endpoint of -tenderlyProject without saving it
+func runTenderlySimulation(chainID *big.Int, from common.Address, tx *types.Transaction) (*tenderlySimulation, error) {
+	account, project, ok := strings.Cut(*tenderlyProj, "/")
+	if !ok || account == "" || project == "" {
+		return nil, errors.New("-tenderlyProject must be given as account/project")
+	}
+	key := *tenderlyKey
+	if key == "" {
+		key = os.Getenv("TENDERLY_ACCESS_KEY")
+	}
+	if key == "" {
+		return nil, errors.New("pass the access key with -tenderlyKey or $TENDERLY_ACCESS_KEY")
+	}
+	request := map[string]interface{}{
+		"network_id":      chainID.String(),
+		"from":            from.Hex(),
+		"input":           hexutil.Encode(tx.Data()),
+		"gas":             tx.Gas(),
+		"gas_price":       tx.GasFeeCap().String(),
+		"value":           tx.Value().String(),
+		"simulation_type": "full",
+		"save":            false,
+	}
+	if tx.To() != nil {
+		request["to"] = tx.To().Hex()
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/account/%s/project/%s/simulate", tenderlyAPI, account, project)
+	req, err := http.NewRequestWithContext(opCtx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Access-Key", key)
+	transport, err := proxyTransport()
+	if err != nil {
+		return nil, err
+	}
+	infof("Simulating the transaction with Tenderly")
+	resp, err := (&http.Client{Transport: transport, Timeout: 60 * time.Second}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var reply struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &reply) == nil && reply.Error.Message != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, reply.Error.Message)
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	var result tenderlySimulation
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unexpected response: %v", err)
+	}
+	return &result, nil
+}
+
+// printTenderlyCall prints call and its subcalls, indented by depth
+func printTenderlyCall(call *tenderlyCall, depth int) {
+	line := fmt.Sprintf("%s%s %s -> %s", strings.Repeat("  ", depth), call.CallType, call.From, call.To)
+	if call.FunctionName != "" {
+		line += " " + call.FunctionName
+	}
+	if call.Value != "" && call.Value != "0" && call.Value != "0x0" {
+		line += " value " + call.Value
+	}
+	line += fmt.Sprintf(" (gas %d)", call.GasUsed)
+	if call.Error != "" {
+		line += " failed: " + call.Error
+		if call.ErrorReason != "" {
+			line += " (" + call.ErrorReason + ")"
+		}
+	}
+	infof("%s", line)
+	for i := range call.Calls {
+		printTenderlyCall(&call.Calls[i], depth+1)
+	}
+}
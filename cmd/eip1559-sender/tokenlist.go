@@ -0,0 +1,142 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// defaultTokenList is the token list's path below the user's config directory
+var defaultTokenList = filepath.Join("eip1559-sender", "tokenlist.json")
+
+// bundledTokenList holds the most used stablecoins and wrapped coins of mainnet, Optimism,
+// Polygon, Base, Arbitrum and Sepolia, for symbols the user's token list does not have
+//
+//go:embed tokenlist.json
+var bundledTokenList []byte
+
+// listedTokens are the tokens resolveTokenList resolved, by address, whose decimals() is
+// checked against the list
+var listedTokens = map[common.Address]tokenListEntry{}
+
+// tokenListEntry is a token of a list in the token list format of https://tokenlists.org, as
+// published by Uniswap, CoinGecko and others
+type tokenListEntry struct {
+	ChainID  uint64 `json:"chainId"`
+	Address  string `json:"address"`
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Decimals int    `json:"decimals"`
+}
+
+// tokenListPath returns the path of -tokenList or of the default list
+func tokenListPath() (string, error) {
+	if *tokenListFlag != "" {
+		return *tokenListFlag, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, defaultTokenList), nil
+}
+
+// loadTokenList reads the tokens of the token list, followed by those of the bundled list. A
+// missing default list holds no tokens
+func loadTokenList() ([]tokenListEntry, error) {
+	bundled, err := parseTokenList(bundledTokenList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the bundled token list: %v", err)
+	}
+	path, err := tokenListPath()
+	if err != nil {
+		return bundled, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && *tokenListFlag == "" {
+		return bundled, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := parseTokenList(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return append(tokens, bundled...), nil
+}
+
+// parseTokenList decodes a list in the token list format
+func parseTokenList(data []byte) ([]tokenListEntry, error) {
+	var list struct {
+		Tokens []tokenListEntry `json:"tokens"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return list.Tokens, nil
+}
+
+// resolveTokenList replaces a token symbol given for -tokenContract with the address the token
+// list has for it on chainID. Values that are not in the list are left for ENS
+func resolveTokenList(chainID *big.Int) error {
+	symbol := *tokenContract
+	if symbol == "" || common.IsHexAddress(symbol) {
+		return nil
+	}
+	tokens, err := loadTokenList()
+	if err != nil {
+		return err
+	}
+	var chains []uint64
+	for _, token := range tokens {
+		if !strings.EqualFold(token.Symbol, symbol) {
+			continue
+		}
+		if !chainID.IsUint64() || token.ChainID != chainID.Uint64() {
+			if !slices.Contains(chains, token.ChainID) {
+				chains = append(chains, token.ChainID)
+			}
+			continue
+		}
+		if !common.IsHexAddress(token.Address) {
+			return fmt.Errorf("token list entry %s holds the invalid address %q", token.Symbol, token.Address)
+		}
+		address := common.HexToAddress(token.Address)
+		infof("Resolved token %s to %s (%s) from the token list", symbol, address.Hex(), token.Name)
+		listedTokens[address] = token
+		*tokenContract = address.Hex()
+		return nil
+	}
+	if len(chains) > 0 {
+		return fmt.Errorf("the token lists have %s on chain IDs %s, not %s", symbol, formatChains(chains), chainID)
+	}
+	return nil
+}
+
+// tokenSymbols returns the sorted symbols of the token list, for shell completion
+func tokenSymbols() []string {
+	tokens, err := loadTokenList()
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var symbols []string
+	for _, token := range tokens {
+		if !seen[token.Symbol] {
+			seen[token.Symbol] = true
+			symbols = append(symbols, token.Symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// traceFrame is a frame of the call tree returned by debug_traceCall with the callTracer
+type traceFrame struct {
+	Type         string         `json:"type"`
+	From         common.Address `json:"from"`
+	To           common.Address `json:"to"`
+	Value        *hexutil.Big   `json:"value"`
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	Input        hexutil.Bytes  `json:"input"`
+	Output       hexutil.Bytes  `json:"output"`
+	Error        string         `json:"error"`
+	RevertReason string         `json:"revertReason"`
+	Calls        []traceFrame   `json:"calls"`
+}
+
+// traceTx runs tx from from through debug_traceCall and prints its call tree. It exits if the
+// transaction would revert, before anything is broadcast, and only warns if the node cannot trace
+func traceTx(client *ethclient.Client, from common.Address, tx *types.Transaction) {
+	msg := ethereum.CallMsg{From: from, To: tx.To(), Gas: tx.Gas(), Value: tx.Value(), Data: tx.Data(), AccessList: tx.AccessList()}
+	frame, ok := traceCall(client, msg)
+	if !ok {
+		return
+	}
+	if frame.Error != "" {
+		resultf([]interface{}{"status", "reverted", "error", frame.Error}, "Trace reverted: %s", describeFrameError(frame))
+		exit(exitReverted)
+	}
+	resultf([]interface{}{"status", "success", "gas", uint64(frame.GasUsed)}, "Trace succeeded, gas used %d", uint64(frame.GasUsed))
+}
+
+// traceCall runs msg through debug_traceCall with the callTracer and prints the call tree and
+// its deepest reverting subcall. ok is false if the node does not support tracing, which archive
+// nodes and local devnets usually do and public endpoints usually do not
+func traceCall(client *ethclient.Client, msg ethereum.CallMsg) (frame *traceFrame, ok bool) {
+	call := map[string]interface{}{"from": msg.From}
+	if msg.To != nil {
+		call["to"] = msg.To
+	}
+	if msg.Gas != 0 {
+		call["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.Value != nil {
+		call["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if len(msg.Data) > 0 {
+		call["data"] = hexutil.Bytes(msg.Data)
+	}
+	if len(msg.AccessList) > 0 {
+		call["accessList"] = msg.AccessList
+	}
+	infof("Tracing the transaction with debug_traceCall")
+	if err := client.Client().CallContext(opCtx, &frame, "debug_traceCall", call, "latest", map[string]string{"tracer": "callTracer"}); err != nil {
+		warnf("Failed to trace the transaction, the node may not support debug_traceCall: %v", err)
+		return nil, false
+	}
+	if frame == nil {
+		warnf("Failed to trace the transaction: the node returned no call tree")
+		return nil, false
+	}
+	abis := traceABIs()
+	infof("Call tree:")
+	printTraceFrame(frame, abis, 1)
+	if failed := deepestRevert(frame); failed != nil && failed != frame {
+		infof("Reverting subcall: %s %s", describeFrameCall(failed, abis), describeFrameError(failed))
+	}
+	return frame, true
+}
+
+// traceABIs returns the ABIs used to name the methods of the call tree: -tokenABI if given, then
+// the embedded ERC-20, ERC-1155, EIP-2612 and EIP-3009 ones
+func traceABIs() []abi.ABI {
+	abis := []abi.ABI{mustLoadABI(erc20ABIJSON), erc1155ABI, erc2612ABI, eip3009ABI}
+	if *tokenABIFlag != "" {
+		if parsed, err := loadABI(*tokenABIFlag); err == nil {
+			abis = append([]abi.ABI{parsed}, abis...)
+		}
+	}
+	return abis
+}
+
+// printTraceFrame prints frame and its subcalls, indented by depth
+func printTraceFrame(frame *traceFrame, abis []abi.ABI, depth int) {
+	line := fmt.Sprintf("%s%s %s -> %s", strings.Repeat("  ", depth), frame.Type, frame.From.Hex(), frame.To.Hex())
+	if len(frame.Input) > 0 {
+		line += " " + describeFrameCall(frame, abis)
+	}
+	if frame.Value != nil && frame.Value.ToInt().Sign() > 0 {
+		line += fmt.Sprintf(" value %s Wei", frame.Value.ToInt())
+	}
+	line += fmt.Sprintf(" (gas %d)", uint64(frame.GasUsed))
+	if frame.Error != "" {
+		line += " failed: " + describeFrameError(frame)
+	}
+	infof("%s", line)
+	for i := range frame.Calls {
+		printTraceFrame(&frame.Calls[i], abis, depth+1)
+	}
+}
+
+// describeFrameCall names the method frame calls, or its selector if none of abis declares it
+func describeFrameCall(frame *traceFrame, abis []abi.ABI) string {
+	if frame.Type == "CREATE" || frame.Type == "CREATE2" {
+		return fmt.Sprintf("%d bytes of init code", len(frame.Input))
+	}
+	if call, err := decodeCalldata(frame.Input, abis); err == nil {
+		return call
+	}
+	if len(frame.Input) >= 4 {
+		for _, contract := range abis {
+			if method, err := contract.MethodById(frame.Input[:4]); err == nil {
+				return method.Sig
+			}
+		}
+		return hexutil.Encode(frame.Input[:4])
+	}
+	return hexutil.Encode(frame.Input)
+}
+
+// describeFrameError returns the error of frame with its revert reason, decoded from the output
+// if the node does not return it
+func describeFrameError(frame *traceFrame) string {
+	reason := frame.RevertReason
+	if reason == "" && len(frame.Output) > 0 {
+		if reason = decodeRevert(frame.Output); reason == "" {
+			reason = "revert data " + hexutil.Encode(frame.Output)
+		}
+	}
+	if reason == "" {
+		return frame.Error
+	}
+	return frame.Error + " (" + reason + ")"
+}
+
+// deepestRevert returns the innermost failed frame that frame's failure bubbled up from, or nil
+// if frame succeeded. A revert propagates from the last subcall, earlier failed ones were caught
+func deepestRevert(frame *traceFrame) *traceFrame {
+	if frame.Error == "" {
+		return nil
+	}
+	if n := len(frame.Calls); n > 0 {
+		if failed := deepestRevert(&frame.Calls[n-1]); failed != nil {
+			return failed
+		}
+	}
+	return frame
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records the spans of the command around those of pkg/sender
+var tracer = otel.Tracer("github.com/gmh5225/EIP1559-sender/cmd/eip1559-sender")
+
+// tracingEnabled tells whether -otlpEndpoint set up a tracer provider
+var tracingEnabled bool
+
+// setupTracing exports the spans of the send pipeline to the OTLP collector of -otlpEndpoint, and
+// takes the trace context of incoming requests and passes it on to the RPC. The returned function
+// sends the spans still buffered, for before the process exits
+func setupTracing() (func(), error) {
+	if *otlpEndpointFlag == "" {
+		return func() {}, nil
+	}
+	endpoint := *otlpEndpointFlag
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	exporter, err := otlptracegrpc.New(opCtx, otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("eip1559-sender"))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	tracingEnabled = true
+	infof("Exporting traces to %s", endpoint)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			warnf("Failed to export the last traces: %v", err)
+		}
+	}, nil
+}
+
+// endSpan ends span, recording err as the reason it failed
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingTransport records every RPC request as a span named after its JSON-RPC method, with the
+// endpoint's host, and passes the trace context on in the traceparent header
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := "rpc " + rpcMethods(req)
+	ctx, span := tracer.Start(req.Context(), name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("rpc.system", "jsonrpc"),
+		attribute.String("server.address", req.URL.Host),
+	))
+	out := req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(out.Header))
+	resp, err := t.next.RoundTrip(out)
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	endSpan(span, err)
+	return resp, err
+}
+
+// rpcMethods returns the methods of the JSON-RPC call or batch in the body of req, without
+// consuming it
+func rpcMethods(req *http.Request) string {
+	if req.GetBody == nil {
+		return "request"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "request"
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "request"
+	}
+	type call struct {
+		Method string `json:"method"`
+	}
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var calls []call
+		if json.Unmarshal(data, &calls) != nil || len(calls) == 0 {
+			return "batch"
+		}
+		methods := make([]string, len(calls))
+		for i, c := range calls {
+			methods[i] = c.Method
+		}
+		return "batch " + strings.Join(methods, ",")
+	}
+	var c call
+	if json.Unmarshal(data, &c) != nil || c.Method == "" {
+		return "request"
+	}
+	return c.Method
+}
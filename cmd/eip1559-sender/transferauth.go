@@ -0,0 +1,316 @@
+package main
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// eip3009ABIJSON holds the EIP-3009 transferWithAuthorization extension of ERC-20, as in USDC
+//
+//go:embed eip3009.abi.json
+var eip3009ABIJSON string
+
+var eip3009ABI = mustLoadABI(eip3009ABIJSON)
+
+var transferWithAuthorizationTypeHash = crypto.Keccak256([]byte("TransferWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"))
+
+// signedTransferAuth is an EIP-3009 transfer authorization signed by the token owner, as exchanged
+// between sign and submit
+type signedTransferAuth struct {
+	Token       common.Address `json:"token"`
+	ChainID     uint64         `json:"chainId"`
+	Version     string         `json:"version"`
+	From        common.Address `json:"from"`
+	To          common.Address `json:"to"`
+	Value       string         `json:"value"`
+	ValidAfter  uint64         `json:"validAfter"`
+	ValidBefore uint64         `json:"validBefore"`
+	Nonce       common.Hash    `json:"nonce"`
+	V           uint8          `json:"v"`
+	R           common.Hash    `json:"r"`
+	S           common.Hash    `json:"s"`
+}
+
+// transferAuthOptions holds the flags of the transfer-auth subcommand
+type transferAuthOptions struct {
+	amount     string
+	validFor   time.Duration
+	version    string
+	file       string
+	relayerEnv string
+}
+
+func newTransferAuthFlagSet(action string, opts *transferAuthOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("transfer-auth "+action, flag.ExitOnError)
+	switch action {
+	case "sign":
+		fs.StringVar(&opts.amount, "amount", "", "Amount in whole tokens")
+		fs.DurationVar(&opts.validFor, "validFor", time.Hour, "How long the authorization stays valid, its validBefore")
+		fs.StringVar(&opts.version, "permitVersion", "", "EIP-712 domain version of the token (default: detected from the token)")
+		fs.StringVar(&opts.file, "out", "transfer-auth.json", "File to write the signed authorization to")
+		fs.StringVar(&opts.relayerEnv, "relayerKeyEnv", "", "Submit the authorization at once from the hex private key in this environment variable, which pays the gas")
+		addRootFlags(fs, "receiver")
+	case "submit":
+		fs.StringVar(&opts.file, "auth", "transfer-auth.json", "Signed authorization file produced by \"transfer-auth sign\"")
+	}
+	addSharedFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s transfer-auth sign -tokenContract 0x... -receiver 0x... -amount 100 [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s transfer-auth submit -auth transfer-auth.json [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nTransfers tokens implementing EIP-3009, such as USDC, with a signature of the owner.\n")
+		fmt.Fprintf(fs.Output(), "Any account can submit the authorization and pay the gas.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runTransferAuth implements the "transfer-auth sign|submit" subcommand
+func runTransferAuth(args []string) {
+	if len(args) == 0 || (args[0] != "sign" && args[0] != "submit") {
+		newTransferAuthFlagSet("sign", &transferAuthOptions{}).Usage()
+		exit(exitInvalid)
+	}
+	var opts transferAuthOptions
+	fs := newTransferAuthFlagSet(args[0], &opts)
+	fs.Parse(args[1:])
+	configure(fs)
+
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	if args[0] == "sign" {
+		if *rpcURLFlag == "" || *tokenContract == "" || *receiverFlag == "" || opts.amount == "" {
+			fmt.Println("Error: Missing required parameters")
+			fs.Usage()
+			exit(exitInvalid)
+		}
+		signTransferAuth(&opts, nf)
+		return
+	}
+	if *rpcURLFlag == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	data, err := os.ReadFile(opts.file)
+	if err != nil {
+		fatalf("Failed to read authorization: %v", err)
+	}
+	p := new(signedTransferAuth)
+	if err := json.Unmarshal(data, p); err != nil {
+		fatalf("Failed to parse authorization: %v", err)
+	}
+	client, chainID := dialRPC()
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	submitTransferAuth(client, chainID, signer, p, nf)
+}
+
+// signTransferAuth signs a transfer authorization off-chain with the owner's key and writes it to a
+// file. With -relayerKeyEnv it submits it right away from that key
+func signTransferAuth(opts *transferAuthOptions, nf numberFormat) {
+	var relayer sender.Signer
+	if opts.relayerEnv != "" {
+		var err error
+		if relayer, err = envKeySigner(opts.relayerEnv); err != nil {
+			exitf(exitInvalid, "Failed to load relayer key: %v", err)
+		}
+	}
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	receiver, err := parseAddress(*receiverFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid receiver: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	typed, ok := signer.(typedDataSigner)
+	if !ok {
+		exitf(exitInvalid, "The selected key source cannot sign EIP-712 authorizations")
+	}
+	token, err := loadToken(client, *tokenContract, *tokenABIFlag)
+	if err == nil {
+		err = token.verify()
+	}
+	if err != nil {
+		fatalf("Failed to load token contract: %v", err)
+	}
+	decimals, err := token.decimals()
+	if err != nil {
+		exitf(exitRPC, "Failed to get token decimals: %v", err)
+	}
+	value, err := parseUnits(opts.amount, decimals)
+	if err != nil {
+		exitf(exitInvalid, "Invalid amount: %v", err)
+	}
+
+	// authorizations take random nonces, so several can be pending at once
+	owner := signer.Address()
+	var nonce common.Hash
+	if _, err := rand.Read(nonce[:]); err != nil {
+		fatalf("Failed to generate nonce: %v", err)
+	}
+	if _, err := transferAuthUsed(client, token.address, owner, nonce); err != nil {
+		fatalf("Token does not support EIP-3009 transfer authorizations: %v", err)
+	}
+	version, domain, err := permitDomain(client, token, chainID, opts.version)
+	if err != nil {
+		fatalf("Failed to build the EIP-712 domain: %v", err)
+	}
+	p := &signedTransferAuth{
+		Token:       token.address,
+		ChainID:     chainID.Uint64(),
+		Version:     version,
+		From:        owner,
+		To:          receiver,
+		Value:       value.String(),
+		ValidBefore: uint64(time.Now().Add(opts.validFor).Unix()),
+		Nonce:       nonce,
+	}
+	infof("Authorizing the transfer of %s base units of %s from %s to %s until %s", nf.format(p.Value), token.symbol(), owner.Hex(), receiver.Hex(), time.Unix(int64(p.ValidBefore), 0).Format(time.RFC3339))
+
+	signature, err := typed.SignTypedData(domain, p.structHash())
+	if err != nil {
+		fatalf("Failed to sign authorization: %v", err)
+	}
+	if signature[64] < 27 {
+		signature[64] += 27
+	}
+	p.V, p.R, p.S = signature[64], common.BytesToHash(signature[:32]), common.BytesToHash(signature[32:64])
+	if err := p.verify(domain); err != nil {
+		fatalf("Failed to verify authorization signature: %v", err)
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		fatalf("Failed to encode authorization: %v", err)
+	}
+	if err := os.WriteFile(opts.file, append(data, '\n'), 0o600); err != nil {
+		fatalf("Failed to write authorization: %v", err)
+	}
+	if relayer == nil {
+		resultf([]interface{}{"file", opts.file}, "Signed transfer authorization written to %s, any account can submit it", opts.file)
+		return
+	}
+	infof("Signed transfer authorization written to %s, submitting it from %s", opts.file, relayer.Address().Hex())
+	submitTransferAuth(client, chainID, relayer, p, nf)
+}
+
+// submitTransferAuth sends transferWithAuthorization for the signed authorization from signer,
+// which pays the gas but need not be named in the authorization
+func submitTransferAuth(client *ethclient.Client, chainID *big.Int, signer sender.Signer, p *signedTransferAuth, nf numberFormat) {
+	value, ok := new(big.Int).SetString(p.Value, 10)
+	if !ok {
+		exitf(exitInvalid, "Invalid authorization value %q", p.Value)
+	}
+	if chainID.Uint64() != p.ChainID {
+		exitf(exitInvalid, "Authorization is for chain ID %d, but the RPC serves chain ID %s", p.ChainID, chainID)
+	}
+	now := time.Now().Unix()
+	if now >= int64(p.ValidBefore) {
+		exitf(exitInvalid, "Authorization expired at %s", time.Unix(int64(p.ValidBefore), 0).Format(time.RFC3339))
+	}
+	if now <= int64(p.ValidAfter) {
+		exitf(exitInvalid, "Authorization is not valid before %s", time.Unix(int64(p.ValidAfter), 0).Format(time.RFC3339))
+	}
+	token, err := loadToken(client, p.Token.Hex(), *tokenABIFlag)
+	if err != nil {
+		fatalf("Failed to load token contract: %v", err)
+	}
+	if used, err := transferAuthUsed(client, token.address, p.From, p.Nonce); err != nil {
+		fatalf("Token does not support EIP-3009 transfer authorizations: %v", err)
+	} else if used {
+		fatalf("Authorization nonce %s of %s was already used, the transfer was submitted or cancelled", p.Nonce.Hex(), p.From.Hex())
+	}
+	_, domain, err := permitDomain(client, token, chainID, p.Version)
+	if err != nil {
+		fatalf("Failed to build the EIP-712 domain: %v", err)
+	}
+	if err := p.verify(domain); err != nil {
+		exitf(exitInvalid, "Invalid authorization signature: %v", err)
+	}
+	if balance, err := token.balanceOf(p.From); err != nil {
+		exitf(exitRPC, "Failed to get token balance: %v", err)
+	} else if balance.Cmp(value) < 0 {
+		exitf(exitFunds, "Insufficient funds: %s holds %s base units of the %s to transfer", p.From.Hex(), balance, value)
+	}
+	infof("Owner's address: %s", p.From.Hex())
+	infof("Receiver address: %s", p.To.Hex())
+	infof("Relayer's address: %s", signer.Address().Hex())
+	infof("Amount: %s base units of %s", nf.format(value.String()), token.symbol())
+
+	transferData, err := eip3009ABI.Pack("transferWithAuthorization", p.From, p.To, value,
+		new(big.Int).SetUint64(p.ValidAfter), new(big.Int).SetUint64(p.ValidBefore), p.Nonce, p.V, p.R, p.S)
+	if err != nil {
+		fatalf("Failed to pack transferWithAuthorization call: %v", err)
+	}
+	tx := newTransaction(client, signer.Address(), chainID, &token.address, new(big.Int), transferData, nf)
+	sendAndFollow(client, signer, chainID, tx, nf)
+}
+
+// transferAuthUsed tells whether authorizer has used or cancelled the authorization nonce
+func transferAuthUsed(client *ethclient.Client, token, authorizer common.Address, nonce common.Hash) (bool, error) {
+	results, err := callABI(client, eip3009ABI, token, "authorizationState", authorizer, nonce)
+	if err != nil {
+		return false, err
+	}
+	return results[0].(bool), nil
+}
+
+// structHash returns the EIP-712 hash of the TransferWithAuthorization message
+func (p *signedTransferAuth) structHash() []byte {
+	value, _ := new(big.Int).SetString(p.Value, 10)
+	if value == nil {
+		return nil
+	}
+	return crypto.Keccak256(
+		transferWithAuthorizationTypeHash,
+		common.LeftPadBytes(p.From.Bytes(), 32),
+		common.LeftPadBytes(p.To.Bytes(), 32),
+		math.U256Bytes(value),
+		math.U256Bytes(new(big.Int).SetUint64(p.ValidAfter)),
+		math.U256Bytes(new(big.Int).SetUint64(p.ValidBefore)),
+		p.Nonce.Bytes(),
+	)
+}
+
+// verify checks that the authorization was signed by its owner
+func (p *signedTransferAuth) verify(domain []byte) error {
+	structHash := p.structHash()
+	if structHash == nil {
+		return errors.New("invalid value")
+	}
+	if p.V != 27 && p.V != 28 {
+		return fmt.Errorf("invalid v %d", p.V)
+	}
+	signature := append(append(p.R.Bytes(), p.S.Bytes()...), p.V-27)
+	pubkey, err := crypto.SigToPub(typedDataHash(domain, structHash), signature)
+	if err != nil {
+		return err
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != p.From {
+		return fmt.Errorf("signed by %s, not by the owner %s", signer.Hex(), p.From.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// checkTransferEvent compares the ERC-20 Transfer events of a mined transfer or transferFrom call
+// with the call itself. A token charging a fee on transfers delivers less than requested, and a
+// contract that forwards to another one may move tokens the call did not name; both are flagged
+// with a warning. Other transactions are left alone
+func checkTransferEvent(client *ethclient.Client, from common.Address, tx *types.Transaction, receipt *types.Receipt, tokens map[common.Address]*tokenUnits, nf numberFormat) {
+	data := tx.Data()
+	if tx.To() == nil || len(data) < 4 {
+		return
+	}
+	erc20 := mustLoadABI(erc20ABIJSON)
+	method, err := erc20.MethodById(data[:4])
+	if err != nil || (method.Name != "transfer" && method.Name != "transferFrom") {
+		return
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return
+	}
+	token := *tx.To()
+	if method.Name == "transferFrom" {
+		from = args[0].(common.Address)
+	}
+	to := args[len(args)-2].(common.Address)
+	want := args[len(args)-1].(*big.Int)
+
+	// received counts what reached the receiver, sent everything that left the sender, fees
+	// paid to a treasury or burnt included
+	event := erc20.Events["Transfer"]
+	received, sent := new(big.Int), new(big.Int)
+	var emitter common.Address
+	for _, log := range receipt.Logs {
+		if len(log.Topics) != 3 || log.Topics[0] != event.ID {
+			continue
+		}
+		values, err := unpackLog(&event, log)
+		if err != nil {
+			continue
+		}
+		src, dst, amount := values[0].(common.Address), values[1].(common.Address), values[2].(*big.Int)
+		if log.Address != token {
+			if src == from && dst == to {
+				emitter = log.Address
+			}
+			continue
+		}
+		if src == from {
+			sent.Add(sent, amount)
+			if dst == to {
+				received.Add(received, amount)
+			}
+		}
+	}
+
+	format := func(amount *big.Int) string {
+		if units := lookupTokenUnits(client, token, tokens); units != nil {
+			return nf.format(formatUnits(amount, units.decimals)) + " " + units.symbol
+		}
+		return nf.format(amount.String()) + " base units"
+	}
+	switch {
+	case received.Sign() == 0 && emitter != (common.Address{}):
+		warnf("The Transfer event to %s was emitted by %s, not by the token contract %s that was called: check which token the receiver got", to.Hex(), emitter.Hex(), token.Hex())
+	case received.Sign() == 0:
+		warnf("Token %s emitted no Transfer event from %s to %s: check the balance of the receiver", token.Hex(), from.Hex(), to.Hex())
+	case received.Cmp(want) < 0:
+		warnf("The receiver got %s of the %s requested: the token takes a fee of %s on transfers", format(received), format(want), format(new(big.Int).Sub(want, received)))
+	case received.Cmp(want) > 0:
+		warnf("The Transfer events move %s to the receiver, more than the %s requested", format(received), format(want))
+	case sent.Cmp(want) > 0:
+		warnf("The receiver got %s, but the token took %s from %s: it charges a fee on top of transfers", format(received), format(sent), from.Hex())
+	default:
+		infof("Transfer event verified: %s from %s to %s", format(want), from.Hex(), to.Hex())
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet/trezor"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+	"github.com/karalabe/hid"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// trezorEthereumSignTxEIP1559 is the message type of EthereumSignTxEIP1559, which
+// the protocol definitions bundled with go-ethereum do not include yet
+const trezorEthereumSignTxEIP1559 = 452
+
+// trezorDevices lists the USB identifiers of Trezor devices (HID and WebUSB firmwares)
+var trezorDevices = []struct {
+	vendorID, productID, usagePage uint16
+}{
+	{0x534c, 0x0001, 0xff00}, // Trezor One with HID firmware
+	{0x1209, 0x53c1, 0xffff}, // Trezor One (>= 1.7), Model T, Safe 3 with WebUSB firmware
+}
+
+// trezorSigner signs EIP-1559 transactions on a Trezor hardware wallet
+type trezorSigner struct {
+	device  hid.Device
+	path    accounts.DerivationPath
+	address common.Address
+}
+
+// loadTrezor opens the first connected Trezor, unlocks it and derives the account at path
+func loadTrezor(path accounts.DerivationPath) (sender.Signer, error) {
+	if !hid.Supported() {
+		return nil, errors.New("USB devices are not supported on this platform")
+	}
+	var found []hid.DeviceInfo
+	for _, id := range trezorDevices {
+		infos, err := hid.Enumerate(id.vendorID, id.productID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enumerate USB devices: %v", err)
+		}
+		for _, info := range infos {
+			// Windows and macOS match on the usage page, Linux on the interface
+			if info.UsagePage == id.usagePage || info.Interface == 0 {
+				found = append(found, info)
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, errors.New("no Trezor found, make sure it is connected and not in use by another application")
+	}
+	for i, info := range found {
+		infof("Found Trezor %d: %s %s (%s)", i, info.Manufacturer, info.Product, info.Path)
+	}
+
+	device, err := found[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Trezor: %v", err)
+	}
+	s := &trezorSigner{device: device, path: path}
+
+	features := new(trezor.Features)
+	if err := s.call(&trezor.Initialize{}, features); err != nil {
+		device.Close()
+		return nil, err
+	}
+	infof("Using Trezor %q (firmware %d.%d.%d)", features.GetLabel(), features.GetMajorVersion(), features.GetMinorVersion(), features.GetPatchVersion())
+
+	// show the address on the device so it can be compared with the output
+	showDisplay := true
+	promptf("Please confirm the address on your Trezor")
+	address := new(trezor.EthereumAddress)
+	if err := s.call(&trezor.EthereumGetAddress{AddressN: path, ShowDisplay: &showDisplay}, address); err != nil {
+		device.Close()
+		return nil, err
+	}
+	if addr := address.GetAddressHex(); addr != "" {
+		s.address = common.HexToAddress(addr)
+	} else {
+		s.address = common.BytesToAddress(address.GetAddressBin())
+	}
+	infof("Using Trezor account %s at %s", s.address.Hex(), path)
+	return s, nil
+}
+
+func (s *trezorSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *trezorSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if tx.Type() != types.DynamicFeeTxType {
+		return nil, fmt.Errorf("Trezor signing of transaction type %d is not supported", tx.Type())
+	}
+
+	// EthereumSignTxEIP1559, encoded by hand (see trezor-firmware messages-ethereum.proto)
+	data := tx.Data()
+	var msg []byte
+	for _, index := range s.path {
+		msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(index))
+	}
+	appendBytes := func(num protowire.Number, b []byte) {
+		msg = protowire.AppendTag(msg, num, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, b)
+	}
+	appendBytes(2, new(big.Int).SetUint64(tx.Nonce()).Bytes())
+	appendBytes(3, tx.GasFeeCap().Bytes())
+	appendBytes(4, tx.GasTipCap().Bytes())
+	appendBytes(5, new(big.Int).SetUint64(tx.Gas()).Bytes())
+	if to := tx.To(); to != nil {
+		appendBytes(6, []byte(to.Hex()))
+	}
+	appendBytes(7, tx.Value().Bytes())
+	chunk := data
+	if len(chunk) > 1024 {
+		chunk = chunk[:1024]
+	}
+	appendBytes(8, chunk)
+	data = data[len(chunk):]
+	msg = protowire.AppendTag(msg, 9, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(len(tx.Data())))
+	msg = protowire.AppendTag(msg, 10, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, chainID.Uint64())
+	for _, entry := range tx.AccessList() {
+		var item []byte
+		item = protowire.AppendTag(item, 1, protowire.BytesType)
+		item = protowire.AppendBytes(item, []byte(entry.Address.Hex()))
+		for _, key := range entry.StorageKeys {
+			item = protowire.AppendTag(item, 2, protowire.BytesType)
+			item = protowire.AppendBytes(item, key.Bytes())
+		}
+		appendBytes(11, item)
+	}
+
+	promptf("Please review and confirm the transaction on your Trezor")
+	kind, reply, err := s.exchange(trezorEthereumSignTxEIP1559, msg)
+	response := new(trezor.EthereumTxRequest)
+	for {
+		if err != nil {
+			return nil, err
+		}
+		if err := s.decode(kind, reply, response); err != nil {
+			return nil, err
+		}
+		// the device asks for the remaining calldata in chunks before signing
+		if response.DataLength == nil || int(*response.DataLength) > len(data) {
+			break
+		}
+		chunk, data = data[:*response.DataLength], data[*response.DataLength:]
+		kind, reply, err = s.exchangeMessage(&trezor.EthereumTxAck{DataChunk: chunk})
+	}
+	if len(response.GetSignatureR()) == 0 || len(response.GetSignatureS()) == 0 {
+		return nil, errors.New("trezor: reply lacks signature")
+	}
+	signature := make([]byte, 65)
+	copy(signature[32-len(response.GetSignatureR()):32], response.GetSignatureR())
+	copy(signature[64-len(response.GetSignatureS()):64], response.GetSignatureS())
+	signature[64] = byte(response.GetSignatureV())
+
+	signer := types.LatestSignerForChainID(chainID)
+	signed, err := tx.WithSignature(signer, signature)
+	if err != nil {
+		return nil, err
+	}
+	if sender, err := types.Sender(signer, signed); err != nil || sender != s.address {
+		return nil, errors.New("trezor: signature does not match the device account")
+	}
+	return signed, nil
+}
+
+// call sends req and decodes the final reply into res
+func (s *trezorSigner) call(req, res proto.Message) error {
+	kind, reply, err := s.exchangeMessage(req)
+	if err != nil {
+		return err
+	}
+	return s.decode(kind, reply, res)
+}
+
+func (s *trezorSigner) decode(kind uint16, reply []byte, res proto.Message) error {
+	if kind != trezor.Type(res) {
+		return fmt.Errorf("trezor: expected reply %s, got %s", trezor.Name(trezor.Type(res)), trezor.Name(kind))
+	}
+	return proto.Unmarshal(reply, res)
+}
+
+func (s *trezorSigner) exchangeMessage(req proto.Message) (uint16, []byte, error) {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	return s.exchange(trezor.Type(req), data)
+}
+
+// exchange sends a raw message and returns the device's final reply, answering
+// button, PIN and passphrase requests along the way
+func (s *trezorSigner) exchange(kind uint16, data []byte) (uint16, []byte, error) {
+	for {
+		reply, replyData, err := s.roundTrip(kind, data)
+		if err != nil {
+			return 0, nil, err
+		}
+		var next proto.Message
+		switch reply {
+		case uint16(trezor.MessageType_MessageType_Failure):
+			failure := new(trezor.Failure)
+			if err := proto.Unmarshal(replyData, failure); err != nil {
+				return 0, nil, err
+			}
+			return 0, nil, errors.New("trezor: " + failure.GetMessage())
+		case uint16(trezor.MessageType_MessageType_ButtonRequest):
+			next = &trezor.ButtonAck{}
+		case uint16(trezor.MessageType_MessageType_PinMatrixRequest):
+			promptf("Enter your PIN using the layout shown on the Trezor:")
+			promptf("  7 8 9\n  4 5 6\n  1 2 3")
+			pin, err := promptPassword("PIN: ")
+			if err != nil {
+				return 0, nil, err
+			}
+			next = &trezor.PinMatrixAck{Pin: &pin}
+		case uint16(trezor.MessageType_MessageType_PassphraseRequest):
+			passphrase, err := promptPassword("Trezor passphrase (empty for none): ")
+			if err != nil {
+				return 0, nil, err
+			}
+			next = &trezor.PassphraseAck{Passphrase: &passphrase}
+		default:
+			return reply, replyData, nil
+		}
+		if data, err = proto.Marshal(next); err != nil {
+			return 0, nil, err
+		}
+		kind = trezor.Type(next)
+	}
+}
+
+// roundTrip writes one framed message in 64 byte reports and reads back the reply
+func (s *trezorSigner) roundTrip(kind uint16, data []byte) (uint16, []byte, error) {
+	payload := make([]byte, 8+len(data))
+	copy(payload, []byte{0x23, 0x23})
+	binary.BigEndian.PutUint16(payload[2:], kind)
+	binary.BigEndian.PutUint32(payload[4:], uint32(len(data)))
+	copy(payload[8:], data)
+
+	chunk := make([]byte, 64)
+	chunk[0] = 0x3f
+	for len(payload) > 0 {
+		n := copy(chunk[1:], payload)
+		clear(chunk[1+n:])
+		payload = payload[n:]
+		if _, err := s.device.Write(chunk); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var reply []byte
+	for {
+		if _, err := io.ReadFull(s.device, chunk); err != nil {
+			return 0, nil, err
+		}
+		if chunk[0] != 0x3f || (reply == nil && (chunk[1] != 0x23 || chunk[2] != 0x23)) {
+			return 0, nil, errors.New("trezor: invalid reply header")
+		}
+		var part []byte
+		if reply == nil {
+			kind = binary.BigEndian.Uint16(chunk[3:5])
+			reply = make([]byte, 0, binary.BigEndian.Uint32(chunk[5:9]))
+			part = chunk[9:]
+		} else {
+			part = chunk[1:]
+		}
+		if left := cap(reply) - len(reply); left > len(part) {
+			reply = append(reply, part...)
+		} else {
+			return kind, append(reply, part[:left]...), nil
+		}
+	}
+}
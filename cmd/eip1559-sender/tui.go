@@ -0,0 +1,534 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+	"golang.org/x/term"
+)
+
+func newTUIFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	addRootFlags(fs, "tokenContract", "token", "tokenList", "tokenABI", "errorABI", "decimals", "explorerURL", "gasLimit", "gasMargin", "gasFloor", "gasCeiling", "maxFeeEth", "maxFeeGwei", "priority", "feeBlocks",
+		"feePercentile", "feeHeadroom", "feeSource", "txType", "nonceSource", "bumpPercent", "private", "relayURL", "historyFile", "dev", "devAccount")
+	addRootFlags(fs, readFlags...)
+	addRootFlags(fs, keyFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s tui -rpcURL https://... -privateKeyEnv SENDER_KEY [-tokenContract 0x...] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nAn interactive terminal screen to send transfers, with the fees of every new block and the\n")
+		fmt.Fprintf(fs.Output(), "transactions sent so far. Tab moves between the fields and the pending transactions, Enter sends,\n")
+		fmt.Fprintf(fs.Output(), "b bumps the fees of the selected pending transaction by -bumpPercent, and Esc or Ctrl-C quits.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// the fields of the TUI form, followed by the pending transactions panel in the focus order
+const (
+	tuiReceiver = iota
+	tuiAmount
+	tuiToken
+	tuiPending
+)
+
+// tuiMessages is the number of log messages the TUI shows
+const tuiMessages = 6
+
+// tuiTx is a transfer sent from the TUI
+type tuiTx struct {
+	tx      *types.Transaction
+	tracker *sender.Tracker // follows every version sent, the first one mined wins
+	summary string
+	status  string
+}
+
+// tui is the state of the tui subcommand. It is only touched by the goroutine running the event
+// loop, except for messages, which the log handler appends to
+type tui struct {
+	client   *ethclient.Client
+	signer   sender.Signer
+	chainID  *big.Int
+	chain    network
+	nf       numberFormat
+	nonces   *sender.NonceManager
+	decimals *tokenDecimals
+
+	fields     [tuiPending]string
+	focus      int
+	selected   int
+	confirming *batchTransfer
+	prompt     string
+	txs        []*tuiTx
+
+	block   uint64
+	baseFee *big.Int
+	legacy  bool
+	tip     *big.Int
+	feeCap  *big.Int
+	balance *big.Int
+
+	mu       sync.Mutex
+	messages []string
+}
+
+// runTUI implements the "tui" subcommand: a form sending transfers through the batch engine,
+// redrawn on every key and every new block
+func runTUI(args []string) {
+	fs := newTUIFlagSet()
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		exitf(exitInvalid, "tui needs an interactive terminal, use the other subcommands from scripts")
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+
+	ui := &tui{
+		client:   client,
+		signer:   signer,
+		chainID:  chainID,
+		chain:    lookupChain(chainID),
+		nf:       nf,
+		nonces:   &sender.NonceManager{Next: sourceNonce},
+		decimals: &tokenDecimals{byToken: map[string]int{}},
+	}
+	ui.fields[tuiToken] = *tokenContract
+	ui.refresh()
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		fatalf("Failed to set up the terminal: %v", err)
+	}
+	level := slog.LevelInfo
+	if *verboseFlag {
+		level = slog.LevelDebug
+	}
+	saved := logger
+	logger = slog.New(&tuiHandler{ui: ui, level: level})
+	// the alternate screen keeps the shell's scrollback intact
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	ui.loop()
+	fmt.Print("\x1b[?25h\x1b[?1049l")
+	term.Restore(int(os.Stdin.Fd()), state)
+	logger = saved
+
+	for _, sent := range ui.txs {
+		resultf([]interface{}{"hash", sent.tx.Hash().Hex(), "nonce", sent.tx.Nonce(), "status", sent.status}, "Nonce %d: %s, %s (%s)", sent.tx.Nonce(), sent.summary, sent.tx.Hash().Hex(), sent.status)
+	}
+}
+
+// loop redraws the screen and handles keys and new blocks until the user quits or -deadline
+// passes
+func (ui *tui) loop() {
+	ctx, cancel := context.WithCancel(opCtx)
+	defer cancel()
+	blocks := sender.WatchBlocks(ctx, ui.client, pollInterval)
+	keys := readKeys()
+	for {
+		ui.draw()
+		select {
+		case key := <-keys:
+			if !ui.handleKey(key) {
+				return
+			}
+		case _, ok := <-blocks:
+			if !ok {
+				return
+			}
+			ui.refresh()
+		}
+	}
+}
+
+// readKeys turns the bytes typed into keys: a printable character, or the name of a control key
+// such as "enter", "tab" or "up"
+func readKeys() <-chan string {
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				keys <- "ctrl-c"
+				return
+			}
+			input := string(buf[:n])
+			switch input {
+			case "\x1b":
+				keys <- "esc"
+				continue
+			case "\x1b[A", "\x1bOA":
+				keys <- "up"
+				continue
+			case "\x1b[B", "\x1bOB":
+				keys <- "down"
+				continue
+			case "\x1b[Z":
+				keys <- "backtab"
+				continue
+			}
+			if strings.HasPrefix(input, "\x1b") {
+				// other escape sequences, such as the left and right arrows
+				continue
+			}
+			// pasted text arrives in one read
+			for _, r := range input {
+				switch r {
+				case '\r', '\n':
+					keys <- "enter"
+				case '\t':
+					keys <- "tab"
+				case 0x7f, '\b':
+					keys <- "backspace"
+				case 0x03:
+					keys <- "ctrl-c"
+				default:
+					if unicode.IsPrint(r) {
+						keys <- string(r)
+					}
+				}
+			}
+		}
+	}()
+	return keys
+}
+
+// handleKey acts on key and reports whether to keep running
+func (ui *tui) handleKey(key string) bool {
+	if ui.confirming != nil {
+		t := *ui.confirming
+		ui.confirming, ui.prompt = nil, ""
+		if key == "y" || key == "Y" {
+			ui.send(t)
+		} else {
+			ui.addMessage("Not sent")
+		}
+		return true
+	}
+	switch {
+	case key == "ctrl-c" || key == "esc" || (key == "q" && ui.focus == tuiPending):
+		return false
+	case key == "tab" || (key == "down" && ui.focus != tuiPending):
+		ui.focus = (ui.focus + 1) % (tuiPending + 1)
+	case key == "backtab" || (key == "up" && ui.focus != tuiPending):
+		ui.focus = (ui.focus + tuiPending) % (tuiPending + 1)
+	case ui.focus == tuiPending:
+		switch key {
+		case "up":
+			ui.selected = max(ui.selected-1, 0)
+		case "down":
+			ui.selected = max(min(ui.selected+1, len(ui.txs)-1), 0)
+		case "b":
+			ui.bump()
+		}
+	case key == "enter":
+		ui.prepare()
+	case key == "backspace":
+		field := ui.fields[ui.focus]
+		_, size := utf8.DecodeLastRuneInString(field)
+		ui.fields[ui.focus] = field[:len(field)-size]
+	case utf8.RuneCountInString(key) == 1:
+		ui.fields[ui.focus] += key
+	}
+	return true
+}
+
+// prepare checks the form and asks for confirmation of the transfer it describes
+func (ui *tui) prepare() {
+	t := batchTransfer{
+		Receiver: strings.TrimSpace(ui.fields[tuiReceiver]),
+		Amount:   json.Number(strings.TrimSpace(ui.fields[tuiAmount])),
+		Token:    strings.TrimSpace(ui.fields[tuiToken]),
+	}
+	if isENSName(t.Receiver) {
+		address, err := resolveENS(ui.client, t.Receiver)
+		if err != nil {
+			ui.addMessage(fmt.Sprintf("Failed to resolve %s: %v", t.Receiver, err))
+			return
+		}
+		t.Receiver = address.Hex()
+	}
+	if err := t.validate(); err != nil {
+		ui.addMessage(err.Error())
+		return
+	}
+	asset := ui.chain.symbol
+	if t.Token != "" {
+		token, err := loadToken(ui.client, t.Token, *tokenABIFlag)
+		if err != nil {
+			ui.addMessage(fmt.Sprintf("Failed to load token: %v", err))
+			return
+		}
+		asset = token.describe()
+	}
+	ui.confirming = &t
+	ui.prompt = fmt.Sprintf("Send %s %s to %s? (y/n)", ui.nf.format(t.Amount.String()), asset, common.HexToAddress(t.Receiver).Hex())
+}
+
+// send signs and broadcasts t at the next nonce with the fees of the latest block
+func (ui *tui) send(t batchTransfer) {
+	if ui.tip == nil {
+		ui.addMessage("No fee estimate yet, try again after the next block")
+		return
+	}
+	from := ui.signer.Address()
+	nonce, err := ui.nonces.Reserve(opCtx, ui.client, from)
+	if err != nil {
+		ui.addMessage(fmt.Sprintf("Failed to get nonce: %v", err))
+		return
+	}
+	tx, err := sendBatchTransfer(opCtx, ui.client, ui.signer, ui.chainID, nonce, ui.legacy, ui.tip, ui.feeCap, &t, ui.decimals)
+	if err != nil {
+		ui.nonces.Fail(from, nonce, err)
+		ui.addMessage(fmt.Sprintf("Failed to send: %v", err))
+		return
+	}
+	summary := fmt.Sprintf("%s %s to %s", ui.nf.format(t.Amount.String()), ui.chain.symbol, common.HexToAddress(t.Receiver).Hex())
+	if t.Token != "" {
+		summary = fmt.Sprintf("%s of token %s to %s", ui.nf.format(t.Amount.String()), common.HexToAddress(t.Token).Hex(), common.HexToAddress(t.Receiver).Hex())
+	}
+	ui.txs = append(ui.txs, &tuiTx{tx: tx, tracker: sender.NewTracker(ui.client, ui.signer.Address(), tx), summary: summary, status: "pending"})
+	ui.selected = len(ui.txs) - 1
+	ui.fields[tuiAmount] = ""
+	ui.addMessage(fmt.Sprintf("Sent nonce %d: %s", tx.Nonce(), tx.Hash().Hex()))
+	if url := explorerTxURL(ui.chainID, tx.Hash().Hex()); url != "" {
+		ui.addMessage("Explorer: " + url)
+	}
+}
+
+// bump re-sends the selected pending transaction with fees raised by -bumpPercent
+func (ui *tui) bump() {
+	if ui.selected < 0 || ui.selected >= len(ui.txs) || ui.txs[ui.selected].status != "pending" {
+		ui.addMessage("Select a pending transaction to bump")
+		return
+	}
+	sent := ui.txs[ui.selected]
+	replacement, err := bumpTx(opCtx, ui.client, ui.signer, ui.chainID, sent.tx, *bumpPercent)
+	if err != nil {
+		ui.addMessage(fmt.Sprintf("Failed to bump nonce %d: %v", sent.tx.Nonce(), err))
+		return
+	}
+	sent.tx = replacement
+	sent.tracker.Add(replacement.Hash())
+	ui.addMessage(fmt.Sprintf("Bumped nonce %d: tip %s gwei, max fee %s gwei, %s", replacement.Nonce(), formatUnits(replacement.GasTipCap(), 9), formatUnits(replacement.GasFeeCap(), 9), replacement.Hash().Hex()))
+}
+
+// refresh reads the latest block, the fees a transfer would pay in it, the balance and the
+// receipts of the pending transactions
+func (ui *tui) refresh() {
+	header, err := ui.client.HeaderByNumber(opCtx, nil)
+	if err != nil {
+		ui.addMessage(fmt.Sprintf("Failed to get header: %v", err))
+		return
+	}
+	ui.block = header.Number.Uint64()
+	if ui.legacy, err = legacyTx(header); err != nil {
+		ui.addMessage(fmt.Sprintf("Invalid transaction type: %v", err))
+		return
+	}
+	ui.baseFee = header.BaseFee
+	if ui.legacy {
+		ui.baseFee = nil
+	}
+	if tip, feeCap, err := suggestFees(opCtx, ui.client, ui.baseFee); err != nil {
+		ui.addMessage(fmt.Sprintf("Failed to determine fees: %v", err))
+	} else {
+		ui.tip, ui.feeCap = tip, feeCap
+	}
+	from := ui.signer.Address()
+	if balance, err := ui.client.BalanceAt(opCtx, from, nil); err == nil {
+		ui.balance = balance
+	}
+
+	for _, sent := range ui.txs {
+		if sent.status != "pending" {
+			continue
+		}
+		receipt, err := sent.tracker.Check(opCtx)
+		if errors.Is(err, sender.ErrNonceUsed) {
+			sent.status = "replaced"
+			ui.addMessage(fmt.Sprintf("Nonce %d was used by another transaction", sent.tx.Nonce()))
+			continue
+		}
+		if receipt == nil {
+			continue
+		}
+		recordReceipt(ui.client, receipt)
+		sent.status = fmt.Sprintf("success (block %d)", receipt.BlockNumber)
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			sent.status = revertedStatus(ui.client, receipt)
+		}
+		ui.addMessage(fmt.Sprintf("Nonce %d: %s, %s", sent.tx.Nonce(), sent.status, receipt.TxHash.Hex()))
+	}
+}
+
+// draw renders the whole screen, cutting lines to the terminal's width
+func (ui *tui) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	gwei := func(v *big.Int) string {
+		if v == nil {
+			return "-"
+		}
+		return ui.nf.format(formatUnits(v, 9)) + " gwei"
+	}
+
+	add(" \x1b[1mEIP-1559 sender\x1b[0m  %s (chain ID %s)", ui.chain.name, ui.chainID)
+	balance := "-"
+	if ui.balance != nil {
+		balance = ui.nf.format(formatUnits(ui.balance, ui.chain.decimals)) + " " + ui.chain.symbol
+	}
+	add(" Account  %s  balance %s", ui.signer.Address().Hex(), balance)
+	if ui.legacy {
+		add(" Block    %d  gas price %s (%s)", ui.block, gwei(ui.feeCap), *priorityFlag)
+	} else {
+		add(" Block    %d  base fee %s  tip %s  max fee %s (%s)", ui.block, gwei(ui.baseFee), gwei(ui.tip), gwei(ui.feeCap), *priorityFlag)
+	}
+	add("")
+	for i, label := range []string{"Receiver", "Amount  ", "Token   "} {
+		value := ui.fields[i]
+		if ui.focus == i {
+			value = "\x1b[7m" + value + " \x1b[0m"
+		}
+		hint := ""
+		if i == tuiToken && ui.fields[i] == "" {
+			hint = "  empty for " + ui.chain.symbol
+		}
+		add(" %s %s%s", label, value, hint)
+	}
+	add("")
+	title := " Transactions"
+	if ui.focus == tuiPending {
+		title = " \x1b[7mTransactions\x1b[0m"
+	}
+	add("%s", title)
+	if len(ui.txs) == 0 {
+		add("   none yet")
+	}
+	for i, sent := range ui.txs {
+		marker := " "
+		if i == ui.selected {
+			marker = ">"
+		}
+		add(" %s nonce %d  %s  tip %s  %s  %s", marker, sent.tx.Nonce(), sent.summary, gwei(sent.tx.GasTipCap()), sent.status, sent.tx.Hash().Hex())
+	}
+	add("")
+	add(" Messages")
+	ui.mu.Lock()
+	for _, message := range ui.messages {
+		add("   %s", message)
+	}
+	ui.mu.Unlock()
+	add("")
+	if ui.prompt != "" {
+		add(" \x1b[1m%s\x1b[0m", ui.prompt)
+	} else {
+		add(" Tab next field  Enter send  b bump selected transaction  Esc quit")
+	}
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	var screen strings.Builder
+	screen.WriteString("\x1b[H\x1b[2J")
+	for i, line := range lines {
+		if i > 0 {
+			screen.WriteString("\r\n")
+		}
+		screen.WriteString(truncateANSI(line, width))
+	}
+	fmt.Print(screen.String())
+}
+
+// truncateANSI cuts line to width visible characters, not counting escape sequences
+func truncateANSI(line string, width int) string {
+	var out strings.Builder
+	visible, escape := 0, false
+	for _, r := range line {
+		switch {
+		case r == '\x1b':
+			escape = true
+		case escape:
+			escape = r < '@' || r > '~' || r == '['
+		case visible >= width:
+			continue
+		default:
+			visible++
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// addMessage adds message to the messages panel, dropping the oldest ones and repeats
+func (ui *tui) addMessage(message string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	if n := len(ui.messages); n > 0 && ui.messages[n-1] == message {
+		return
+	}
+	ui.messages = append(ui.messages, message)
+	if len(ui.messages) > tuiMessages {
+		ui.messages = ui.messages[len(ui.messages)-tuiMessages:]
+	}
+}
+
+// tuiHandler shows log messages in the messages panel of the TUI, as printing them would break
+// up the screen
+type tuiHandler struct {
+	ui    *tui
+	level slog.Level
+}
+
+func (h *tuiHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *tuiHandler) Handle(_ context.Context, r slog.Record) error {
+	message := r.Message
+	if r.Level >= slog.LevelWarn {
+		message = "Warning: " + message
+	}
+	h.ui.addMessage(message)
+	return nil
+}
+
+func (h *tuiHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *tuiHandler) WithGroup(string) slog.Handler      { return h }
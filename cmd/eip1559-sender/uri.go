@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// uriNumber matches the numbers of EIP-681 parameters, such as 1e18 or 2.014e18
+var uriNumber = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?([eE][0-9]+)?$`)
+
+// paymentURI is an EIP-681 payment request: a native coin transfer to target, or an ERC-20
+// transfer of the token at target when receiver is set
+type paymentURI struct {
+	target   string
+	chainID  uint64 // 0 if the request leaves the chain to the wallet
+	receiver string
+	value    *big.Int // wei, or token base units for an ERC-20 transfer
+	gasLimit uint64
+}
+
+// parsePaymentURI parses ethereum:<target>[@<chainID>][/transfer][?<parameters>]. Parameters
+// other than the ones of a transfer are rejected rather than ignored, as they would change what
+// is paid
+func parsePaymentURI(raw string) (*paymentURI, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ethereum" || u.Opaque == "" {
+		return nil, errors.New("expected ethereum:<address>[@<chainID>][/transfer][?<parameters>]")
+	}
+	target, function, _ := strings.Cut(strings.TrimPrefix(u.Opaque, "pay-"), "/")
+	target, chain, hasChain := strings.Cut(target, "@")
+	req := &paymentURI{target: target}
+	if hasChain {
+		id, err := strconv.ParseInt(chain, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid chain ID %q", chain)
+		}
+		req.chainID = uint64(id)
+	}
+	if err := checkURIAddress(target); err != nil {
+		return nil, err
+	}
+	params, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range params {
+		if len(values) > 1 {
+			return nil, fmt.Errorf("parameter %s is given %d times", key, len(values))
+		}
+	}
+	token := false
+	switch function {
+	case "":
+	case "transfer":
+		token = true
+	default:
+		return nil, fmt.Errorf("unsupported function %q, only native coin and ERC-20 transfers can be paid", function)
+	}
+	for key, values := range params {
+		value := values[0]
+		switch {
+		case key == "value" && !token:
+			if req.value, err = parseURINumber(value); err != nil {
+				return nil, fmt.Errorf("invalid value: %v", err)
+			}
+		case key == "address" && token:
+			if err := checkURIAddress(value); err != nil {
+				return nil, err
+			}
+			req.receiver = value
+		case key == "uint256" && token:
+			if req.value, err = parseURINumber(value); err != nil {
+				return nil, fmt.Errorf("invalid uint256: %v", err)
+			}
+		case key == "gas" || key == "gasLimit":
+			limit, err := parseURINumber(value)
+			if err != nil || !limit.IsUint64() {
+				return nil, fmt.Errorf("invalid %s %q", key, value)
+			}
+			req.gasLimit = limit.Uint64()
+		case key == "gasPrice":
+			warnf("Ignoring the gasPrice of the payment request, the fees are estimated as usual")
+		case key == "value" && token:
+			// wallets add value=0 to token transfers
+			if value != "0" {
+				return nil, errors.New("a token transfer cannot also send the native coin")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported parameter %q", key)
+		}
+	}
+	switch {
+	case token && req.receiver == "":
+		return nil, errors.New("the transfer has no address parameter")
+	case token && req.value == nil:
+		return nil, errors.New("the transfer has no uint256 parameter")
+	case req.value == nil:
+		return nil, errors.New("the request has no value parameter, the amount has to be given")
+	}
+	return req, nil
+}
+
+// checkURIAddress rejects a malformed address of a payment request. ENS names are resolved later,
+// as for -receiver
+func checkURIAddress(address string) error {
+	if isENSName(address) {
+		return nil
+	}
+	_, err := parseAddress(address)
+	return err
+}
+
+// parseURINumber parses a positive integer of an EIP-681 request, which may be written with an
+// exponent such as 1.5e18 as long as it has no fractional part left
+func parseURINumber(value string) (*big.Int, error) {
+	if !uriNumber.MatchString(value) {
+		return nil, fmt.Errorf("%q is not a number", value)
+	}
+	r, ok := new(big.Rat).SetString(value)
+	if !ok || !r.IsInt() {
+		return nil, fmt.Errorf("%q is not a whole number of base units", value)
+	}
+	n := r.Num()
+	if n.Sign() <= 0 || n.BitLen() > 256 {
+		return nil, fmt.Errorf("%q is out of range", value)
+	}
+	return n, nil
+}
+
+// applyURI fills in -receiver, -chainID, -tokenContract and the amount from -uri, which cannot be
+// combined with the flags it replaces
+func applyURI() error {
+	if *uriFlag == "" {
+		return nil
+	}
+	if *receiverFlag != "" || *amountFlag != "" || *amountRawFlag != "" || *tokenValueFlag != 0 || *unitFlag != "" || *maxFlag ||
+		*tokenContract != "" || *batchFlag != "" {
+		return errors.New("-uri gives the receiver, token and amount, and cannot be combined with -receiver, -amount, -amountRaw, -tokenValue, -unit, -max, -tokenContract or -batch")
+	}
+	req, err := parsePaymentURI(*uriFlag)
+	if err != nil {
+		return err
+	}
+	if req.chainID != 0 {
+		if *chainIDFlag != 0 && uint64(*chainIDFlag) != req.chainID {
+			return fmt.Errorf("the request is for chain ID %d, not -chainID %d", req.chainID, *chainIDFlag)
+		}
+		for _, n := range networks {
+			if n.key == *networkFlag && n.chainID != req.chainID {
+				return fmt.Errorf("the request is for chain ID %d, not -network %s (chain ID %d)", req.chainID, n.key, n.chainID)
+			}
+		}
+		// dialRPC then refuses an RPC serving another chain
+		*chainIDFlag = int64(req.chainID)
+	}
+	if req.gasLimit != 0 && *gasLimitFlag == 0 {
+		*gasLimitFlag = req.gasLimit
+	}
+	if req.receiver != "" {
+		*tokenContract, *receiverFlag, *amountRawFlag = req.target, req.receiver, req.value.String()
+		infof("Payment request: %s base units of token %s to %s", req.value, req.target, req.receiver)
+	} else {
+		*receiverFlag, *amountFlag, *unitFlag = req.target, req.value.String(), "wei"
+		chain := lookupChain(big.NewInt(*chainIDFlag))
+		infof("Payment request: %s %s (%s wei) to %s", formatUnits(req.value, chain.decimals), chain.symbol, req.value, req.target)
+	}
+	if req.chainID == 0 {
+		warnf("The payment request does not name a chain, paying on the chain of the RPC")
+	}
+	return nil
+}
+
+// formatPaymentURI is the inverse of parsePaymentURI: a request for value wei to target, or for
+// value base units of the token at target when receiver is set. A chainID of 0 is left out
+func formatPaymentURI(target string, chainID uint64, receiver string, value *big.Int) string {
+	uri := "ethereum:" + target
+	if chainID != 0 {
+		uri += "@" + strconv.FormatUint(chainID, 10)
+	}
+	if receiver != "" {
+		return uri + "/transfer?address=" + receiver + "&uint256=" + formatURINumber(value)
+	}
+	return uri + "?value=" + formatURINumber(value)
+}
+
+// formatURINumber writes n with its trailing zeros as an exponent, 1e18 rather than a
+// 19-digit number, which keeps the QR code small
+func formatURINumber(n *big.Int) string {
+	digits := n.String()
+	trimmed := strings.TrimRight(digits, "0")
+	if zeros := len(digits) - len(trimmed); zeros > 1 {
+		return trimmed + "e" + strconv.Itoa(zeros)
+	}
+	return digits
+}
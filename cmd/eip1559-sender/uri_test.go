@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParsePaymentURI(t *testing.T) {
+	const (
+		token    = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+		receiver = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+	)
+	for _, tc := range []struct {
+		uri  string
+		want *paymentURI // nil if the request is refused
+	}{
+		{"ethereum:" + receiver + "?value=2.014e18",
+			&paymentURI{target: receiver, value: mustBig("2014000000000000000")}},
+		{"ethereum:pay-" + receiver + "@1?value=1e18&gas=21000",
+			&paymentURI{target: receiver, chainID: 1, value: mustBig("1000000000000000000"), gasLimit: 21000}},
+		{" ethereum:alice.eth@137?value=5 ",
+			&paymentURI{target: "alice.eth", chainID: 137, value: big.NewInt(5)}},
+		{"ethereum:" + token + "@1/transfer?address=" + receiver + "&uint256=1e6",
+			&paymentURI{target: token, chainID: 1, receiver: receiver, value: big.NewInt(1000000)}},
+		{"ethereum:" + token + "/transfer?address=" + receiver + "&uint256=25&value=0&gasLimit=65000",
+			&paymentURI{target: token, receiver: receiver, value: big.NewInt(25), gasLimit: 65000}},
+		{"ethereum:" + receiver + "?value=1&gasPrice=1e9",
+			&paymentURI{target: receiver, value: big.NewInt(1)}},
+
+		{"bitcoin:" + receiver + "?value=1", nil},
+		{"ethereum:?value=1", nil},
+		{"ethereum:" + receiver, nil},
+		{"ethereum:" + receiver + "@0?value=1", nil},
+		{"ethereum:" + receiver + "@mainnet?value=1", nil},
+		{"ethereum:0x1234?value=1", nil},
+		{"ethereum:" + receiver + "?value=1.5", nil},
+		{"ethereum:" + receiver + "?value=0", nil},
+		{"ethereum:" + receiver + "?value=-1", nil},
+		{"ethereum:" + receiver + "?value=1e78", nil},
+		{"ethereum:" + receiver + "?value=1&value=2", nil},
+		{"ethereum:" + receiver + "?value=1&data=0x00", nil},
+		{"ethereum:" + receiver + "?value=1&gas=1e20", nil},
+		{"ethereum:" + token + "/approve?address=" + receiver + "&uint256=1", nil},
+		{"ethereum:" + token + "/transfer?uint256=1", nil},
+		{"ethereum:" + token + "/transfer?address=" + receiver, nil},
+		{"ethereum:" + token + "/transfer?address=" + receiver + "&uint256=1&value=1", nil},
+	} {
+		got, err := parsePaymentURI(tc.uri)
+		if tc.want == nil {
+			if err == nil {
+				t.Errorf("parsePaymentURI(%q) = %+v, want an error", tc.uri, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePaymentURI(%q) = %v", tc.uri, err)
+			continue
+		}
+		if got.target != tc.want.target || got.chainID != tc.want.chainID || got.receiver != tc.want.receiver ||
+			got.value.Cmp(tc.want.value) != 0 || got.gasLimit != tc.want.gasLimit {
+			t.Errorf("parsePaymentURI(%q) = %+v, want %+v", tc.uri, got, tc.want)
+		}
+	}
+}
+
+func TestFormatPaymentURI(t *testing.T) {
+	const target = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+	for _, tc := range []struct {
+		chainID  uint64
+		receiver string
+		value    *big.Int
+		want     string
+	}{
+		{1, "", mustBig("1500000000000000000"), "ethereum:" + target + "@1?value=15e17"},
+		{0, "", big.NewInt(10), "ethereum:" + target + "?value=10"},
+		{137, "alice.eth", big.NewInt(1000000), "ethereum:" + target + "@137/transfer?address=alice.eth&uint256=1e6"},
+	} {
+		uri := formatPaymentURI(target, tc.chainID, tc.receiver, tc.value)
+		if uri != tc.want {
+			t.Errorf("formatPaymentURI = %q, want %q", uri, tc.want)
+			continue
+		}
+		req, err := parsePaymentURI(uri)
+		if err != nil || req.chainID != tc.chainID || req.receiver != tc.receiver || req.value.Cmp(tc.value) != 0 {
+			t.Errorf("parsePaymentURI(%q) = %+v, %v, want the formatted request back", uri, req, err)
+		}
+	}
+}
+
+func mustBig(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid number " + s)
+	}
+	return n
+}
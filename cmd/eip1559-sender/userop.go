@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// defaultEntryPoint is the ERC-4337 v0.7 EntryPoint, deployed at the same address on all chains
+const defaultEntryPoint = "0x0000000071727De22E5E9d8BAf0edAc6f37da032"
+
+// dummySignature stands in for the owner's signature while the bundler estimates gas. It has the
+// length and shape of a real one, so the account's ECDSA recovery runs instead of reverting
+var dummySignature = common.FromHex("0xfffffffffffffffffffffffffffffff000000000000000000000000000000000" +
+	"7aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" + "1c")
+
+// userOpABI holds the EntryPoint's nonce and deposit lookups and the execute method of
+// SimpleAccount-compatible smart accounts
+var userOpABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(`[
+		{"type":"function","name":"getNonce","stateMutability":"view","inputs":[{"name":"sender","type":"address"},{"name":"key","type":"uint192"}],"outputs":[{"name":"nonce","type":"uint256"}]},
+		{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+		{"type":"function","name":"execute","stateMutability":"nonpayable","inputs":[{"name":"dest","type":"address"},{"name":"value","type":"uint256"},{"name":"func","type":"bytes"}],"outputs":[]}
+	]`))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// userOperation is a v0.7 UserOperation in the JSON-RPC form bundlers accept
+type userOperation struct {
+	Sender               common.Address `json:"sender"`
+	Nonce                *hexutil.Big   `json:"nonce"`
+	CallData             hexutil.Bytes  `json:"callData"`
+	CallGasLimit         *hexutil.Big   `json:"callGasLimit"`
+	VerificationGasLimit *hexutil.Big   `json:"verificationGasLimit"`
+	PreVerificationGas   *hexutil.Big   `json:"preVerificationGas"`
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	// set by a paymaster paying for the gas, see paymaster.go
+	Paymaster                     *common.Address `json:"paymaster,omitempty"`
+	PaymasterVerificationGasLimit *hexutil.Big    `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       *hexutil.Big    `json:"paymasterPostOpGasLimit,omitempty"`
+	PaymasterData                 hexutil.Bytes   `json:"paymasterData,omitempty"`
+	Signature                     hexutil.Bytes   `json:"signature"`
+}
+
+// userOpGas is the result of eth_estimateUserOperationGas
+type userOpGas struct {
+	PreVerificationGas   *hexutil.Big `json:"preVerificationGas"`
+	VerificationGasLimit *hexutil.Big `json:"verificationGasLimit"`
+	CallGasLimit         *hexutil.Big `json:"callGasLimit"`
+	// only estimated for operations with a paymaster
+	PaymasterVerificationGasLimit *hexutil.Big `json:"paymasterVerificationGasLimit"`
+}
+
+// userOpReceipt is the result of eth_getUserOperationReceipt
+type userOpReceipt struct {
+	Success       bool         `json:"success"`
+	Reason        string       `json:"reason"`
+	ActualGasCost *hexutil.Big `json:"actualGasCost"`
+	ActualGasUsed *hexutil.Big `json:"actualGasUsed"`
+	Logs          []struct {
+		Address common.Address `json:"address"`
+		Topics  []common.Hash  `json:"topics"`
+		Data    hexutil.Bytes  `json:"data"`
+	} `json:"logs"`
+	Receipt struct {
+		TransactionHash common.Hash  `json:"transactionHash"`
+		BlockNumber     *hexutil.Big `json:"blockNumber"`
+	} `json:"receipt"`
+}
+
+// maxCost returns the most the UserOperation can be charged: all its gas at maxFeePerGas,
+// the paymaster's included
+func (op *userOperation) maxCost() *big.Int {
+	gas := new(big.Int).Add(op.CallGasLimit.ToInt(), op.VerificationGasLimit.ToInt())
+	gas.Add(gas, op.PreVerificationGas.ToInt())
+	if op.Paymaster != nil {
+		gas.Add(gas, op.PaymasterVerificationGasLimit.ToInt())
+		gas.Add(gas, op.PaymasterPostOpGasLimit.ToInt())
+	}
+	return gas.Mul(gas, op.MaxFeePerGas.ToInt())
+}
+
+// hash returns the hash the account's owner signs, binding the operation to the EntryPoint
+// and chain. Gas limits and fees are packed in pairs of 128-bit values as in PackedUserOperation
+func (op *userOperation) hash(entryPoint common.Address, chainID *big.Int) common.Hash {
+	words := func(high, low *hexutil.Big) common.Hash {
+		var packed common.Hash
+		high.ToInt().FillBytes(packed[:16])
+		low.ToInt().FillBytes(packed[16:])
+		return packed
+	}
+	emptyHash := crypto.Keccak256Hash(nil)
+	inner := crypto.Keccak256(
+		common.LeftPadBytes(op.Sender.Bytes(), 32),
+		common.LeftPadBytes(op.Nonce.ToInt().Bytes(), 32),
+		emptyHash.Bytes(), // initCode
+		crypto.Keccak256(op.CallData),
+		words(op.VerificationGasLimit, op.CallGasLimit).Bytes(),
+		common.LeftPadBytes(op.PreVerificationGas.ToInt().Bytes(), 32),
+		words(op.MaxPriorityFeePerGas, op.MaxFeePerGas).Bytes(),
+		crypto.Keccak256(op.paymasterAndData()),
+	)
+	return crypto.Keccak256Hash(inner, common.LeftPadBytes(entryPoint.Bytes(), 32), common.LeftPadBytes(chainID.Bytes(), 32))
+}
+
+// userOpOptions holds the flags of the userop subcommand
+type userOpOptions struct {
+	account      string
+	bundlerURL   string
+	entryPoint   string
+	paymasterURL string
+	pmContext    string
+}
+
+func newUserOpFlagSet(opts *userOpOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("userop", flag.ExitOnError)
+	fs.StringVar(&opts.account, "account", "", "Smart account (ERC-4337) sending the transfer, owned by the signing key")
+	fs.StringVar(&opts.bundlerURL, "bundlerURL", "", "Bundler RPC URL the UserOperation is estimated and submitted through")
+	fs.StringVar(&opts.entryPoint, "entryPoint", defaultEntryPoint, "EntryPoint contract of the account (v0.7)")
+	fs.StringVar(&opts.paymasterURL, "paymasterURL", "", "ERC-7677 paymaster service paying for the gas, often the bundler URL itself")
+	fs.StringVar(&opts.pmContext, "paymasterContext", "", `JSON object passed to the paymaster service, e.g. {"token": "0x..."} to pay in a token (fields depend on the service)`)
+	addRootFlags(fs, "receiver", "amount", "amountRaw", "unit", "tokenValue", "tokenContract", "token", "tokenList", "tokenABI", "decimals", "data", "maxFeePerGas", "maxPriorityFeePerGas", "maxFeeEth", "maxFeeGwei",
+		"priority", "feeBlocks", "feePercentile", "feeHeadroom", "feeSource", "explorerURL", "fiat", "priceFeed", "priceURL", "wait", "dryRun", "yes", "y")
+	addRootFlags(fs, readFlags...)
+	addRootFlags(fs, keyFlags...)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s userop -account 0x... -bundlerURL https://... -receiver 0x... -amount 0.1 [-tokenContract 0x...] [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nSends the transfer from a deployed smart account as an ERC-4337 UserOperation signed by the account's owner.\n")
+		fmt.Fprintf(fs.Output(), "The account has to implement execute(address,uint256,bytes) like SimpleAccount and pay for its gas, unless -paymasterURL does.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runUserOp implements the "userop" subcommand
+func runUserOp(args []string) {
+	var opts userOpOptions
+	fs := newUserOpFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" || opts.account == "" || opts.bundlerURL == "" || *receiverFlag == "" || (*amountFlag == "" && *amountRawFlag == "" && *dataFlag == "") {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	if *tokenContract != "" && *dataFlag != "" {
+		exitf(exitInvalid, "-data cannot be combined with -tokenContract")
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	account, err := parseAddress(opts.account)
+	if err != nil {
+		exitf(exitInvalid, "Invalid account: %v", err)
+	}
+	entryPoint, err := parseAddress(opts.entryPoint)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -entryPoint: %v", err)
+	}
+	if opts.pmContext != "" && opts.paymasterURL == "" {
+		exitf(exitInvalid, "-paymasterContext requires -paymasterURL")
+	}
+	pmContext, err := parsePaymasterContext(opts.pmContext)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -paymasterContext: %v", err)
+	}
+	client, chainID := dialRPC()
+	if err := resolveAddressFlags(client, chainID); err != nil {
+		fatalf("Failed to resolve address: %v", err)
+	}
+	receiver, err := parseAddress(*receiverFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid receiver: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	owner, ok := signer.(textSigner)
+	if !ok {
+		exitf(exitInvalid, "The selected key source cannot sign UserOperations")
+	}
+	bundler, err := dialUserOpService(opts.bundlerURL)
+	if err != nil {
+		exitf(exitRPC, "Failed to connect to the bundler: %v", err)
+	}
+	defer bundler.Close()
+	var paymaster *rpc.Client
+	if opts.paymasterURL != "" {
+		if paymaster, err = dialUserOpService(opts.paymasterURL); err != nil {
+			exitf(exitRPC, "Failed to connect to the paymaster: %v", err)
+		}
+		defer paymaster.Close()
+	}
+
+	if code, err := client.CodeAt(opCtx, account, nil); err != nil {
+		exitf(exitRPC, "Failed to get the code of the account: %v", err)
+	} else if len(code) == 0 {
+		fatalf("No smart account deployed at %s, deploy it first", account.Hex())
+	}
+	infof("Smart account: %s, owner: %s", account.Hex(), signer.Address().Hex())
+
+	op, value, err := buildUserOp(client, chainID, account, entryPoint, receiver, nf)
+	if err != nil {
+		fatalf("Failed to build UserOperation: %v", err)
+	}
+	op.Signature = dummySignature
+	var stub *paymasterFields
+	if paymaster != nil {
+		// the stub data stands in for the paymaster's signature while the gas is estimated
+		if stub, err = requestPaymaster(paymaster, "pm_getPaymasterStubData", op, entryPoint, chainID, pmContext); err != nil {
+			exitf(exitRPC, "Failed to get paymaster stub data: %v", err)
+		}
+	}
+	var gas userOpGas
+	if err := bundler.CallContext(opCtx, &gas, "eth_estimateUserOperationGas", op, entryPoint); err != nil {
+		fatalf("Failed to estimate UserOperation gas: %s", describeCallError(err))
+	}
+	if gas.CallGasLimit == nil || gas.VerificationGasLimit == nil || gas.PreVerificationGas == nil {
+		fatalf("The bundler returned an incomplete gas estimate")
+	}
+	op.CallGasLimit, op.VerificationGasLimit, op.PreVerificationGas = gas.CallGasLimit, gas.VerificationGasLimit, gas.PreVerificationGas
+	if op.Paymaster != nil && gas.PaymasterVerificationGasLimit != nil {
+		op.PaymasterVerificationGasLimit = gas.PaymasterVerificationGasLimit
+	}
+	infof("UserOperation gas: %s call, %s verification, %s pre-verification", nf.format(op.CallGasLimit.ToInt().String()),
+		nf.format(op.VerificationGasLimit.ToInt().String()), nf.format(op.PreVerificationGas.ToInt().String()))
+
+	maxCost := op.maxCost()
+	gasTotal := new(big.Int).Div(maxCost, op.MaxFeePerGas.ToInt())
+	// the gas of an operation is capped like that of a transaction, with no L1 fee of its own
+	if err := checkFeeCap(nil, chainID, types.NewTx(&types.DynamicFeeTx{Gas: gasTotal.Uint64(), GasFeeCap: op.MaxFeePerGas.ToInt()})); err != nil {
+		fatalf("%v", err)
+	}
+	charged := maxCost
+	if op.Paymaster != nil {
+		charged = new(big.Int)
+	}
+	if err := checkUserOpFunds(client, account, entryPoint, value, charged); err != nil {
+		fatalf("%v", err)
+	}
+	if *dryRunFlag {
+		resultf([]interface{}{"gas", gasTotal.Uint64()}, "Dry run: the bundler accepted the UserOperation for estimation, not submitting it")
+		return
+	}
+	if err := confirmUserOp(client, chainID, op, stub, receiver, value, maxCost, nf); err != nil {
+		fatalf("%v", err)
+	}
+	if stub != nil && !stub.IsFinal {
+		// the paymaster signs over the final gas limits
+		if _, err := requestPaymaster(paymaster, "pm_getPaymasterData", op, entryPoint, chainID, pmContext); err != nil {
+			exitf(exitRPC, "Failed to get paymaster data: %v", err)
+		}
+	}
+	hash := op.hash(entryPoint, chainID)
+	debugf("UserOperation hash: %s", hash.Hex())
+
+	signature, err := owner.SignText(hash.Bytes())
+	if err != nil {
+		fatalf("Failed to sign UserOperation: %v", err)
+	}
+	if signature[64] < 27 {
+		signature[64] += 27
+	}
+	op.Signature = signature
+	var submitted common.Hash
+	if err := bundler.CallContext(opCtx, &submitted, "eth_sendUserOperation", op, entryPoint); err != nil {
+		exitf(exitRejected, "Failed to submit UserOperation: %s", describeCallError(err))
+	}
+	if submitted != hash {
+		warnf("The bundler reports the UserOperation hash %s, expected %s", submitted.Hex(), hash.Hex())
+	}
+	resultf([]interface{}{"userOpHash", submitted.Hex()}, "UserOperation submitted: %s", submitted.Hex())
+	if !*waitFlag {
+		return
+	}
+
+	infof("Waiting for the UserOperation to be included...")
+	receipt, err := waitUserOp(opCtx, client, bundler, submitted)
+	if err != nil {
+		exitf(exitRPC, "Failed to get the UserOperation receipt: %v", err)
+	}
+	chain := lookupChain(chainID)
+	infof("Included in transaction %s in block %s", receipt.Receipt.TransactionHash.Hex(), receipt.Receipt.BlockNumber.ToInt())
+	if url := explorerTxURL(chainID, receipt.Receipt.TransactionHash.Hex()); url != "" {
+		infof("Explorer: %s", url)
+	}
+	if receipt.ActualGasCost != nil {
+		cost := receipt.ActualGasCost.ToInt()
+		infof("UserOperation fee: %s %s%s", nf.format(formatUnits(cost, chain.decimals)), chain.symbol, fiatAmount(client, chainID, cost, chain.decimals, nf))
+	}
+	events := receiptEventABIs()
+	tokens := map[common.Address]*tokenUnits{}
+	for _, l := range receipt.Logs {
+		log := &types.Log{Address: l.Address, Topics: l.Topics, Data: l.Data}
+		infof("Event: %s", describeLog(client, log, events, tokens, nf))
+	}
+	if !receipt.Success {
+		if receipt.Reason != "" {
+			infof("Revert reason: %s", receipt.Reason)
+		}
+		exitf(exitReverted, "UserOperation reverted")
+	}
+	resultf([]interface{}{"userOpHash", submitted.Hex(), "txHash", receipt.Receipt.TransactionHash.Hex()}, "UserOperation succeeded")
+}
+
+// dialUserOpService connects to the JSON-RPC endpoint of a bundler or paymaster through the
+// proxy. The -header values are meant for the node and not sent
+func dialUserOpService(url string) (*rpc.Client, error) {
+	transport, err := proxyTransport()
+	if err != nil {
+		return nil, err
+	}
+	return rpc.DialOptions(opCtx, url, rpc.WithHTTPClient(&http.Client{Transport: transport, Timeout: *rpcTimeout}))
+}
+
+// buildUserOp returns the unsigned operation calling execute on the account for the transfer
+// of the flags, with fees set and gas limits left for the bundler to estimate, and the native
+// amount it sends
+func buildUserOp(client *ethclient.Client, chainID *big.Int, account, entryPoint, receiver common.Address, nf numberFormat) (*userOperation, *big.Int, error) {
+	dest, value, data := receiver, new(big.Int), []byte(nil)
+	if *tokenContract != "" {
+		token, err := loadToken(client, *tokenContract, *tokenABIFlag)
+		if err != nil {
+			return nil, nil, err
+		}
+		if data, err = token.transferData(account, receiver, *amountFlag, nf); err != nil {
+			return nil, nil, err
+		}
+		dest = token.address
+	} else {
+		value = nativeAmount(chainID, nf)
+		var err error
+		if data, err = callData(); err != nil {
+			return nil, nil, fmt.Errorf("invalid -data: %v", err)
+		}
+	}
+	callData, err := userOpABI.Pack("execute", dest, value, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, err := callABI(client, userOpABI, entryPoint, "getNonce", account, new(big.Int))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get the account nonce from the EntryPoint: %v", err)
+	}
+	nonce, ok := results[0].(*big.Int)
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected getNonce() type %T", results[0])
+	}
+	header, err := client.HeaderByNumber(opCtx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get the latest block: %v", err)
+	}
+	tip, feeCap, err := suggestFees(opCtx, client, header.BaseFee)
+	if err != nil {
+		return nil, nil, err
+	}
+	zero := (*hexutil.Big)(new(big.Int))
+	return &userOperation{
+		Sender:               account,
+		Nonce:                (*hexutil.Big)(nonce),
+		CallData:             callData,
+		CallGasLimit:         zero,
+		VerificationGasLimit: zero,
+		PreVerificationGas:   zero,
+		MaxFeePerGas:         (*hexutil.Big)(feeCap),
+		MaxPriorityFeePerGas: (*hexutil.Big)(tip),
+	}, value, nil
+}
+
+// checkUserOpFunds verifies that the account's balance and EntryPoint deposit cover the native
+// amount sent and the most the operation can be charged, nothing if a paymaster pays
+func checkUserOpFunds(client *ethclient.Client, account, entryPoint common.Address, value, maxCost *big.Int) error {
+	balance, err := client.BalanceAt(opCtx, account, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get the account balance: %v", err)
+	}
+	results, err := callABI(client, userOpABI, entryPoint, "balanceOf", account)
+	if err != nil {
+		return fmt.Errorf("failed to get the account's EntryPoint deposit: %v", err)
+	}
+	deposit, ok := results[0].(*big.Int)
+	if !ok {
+		return fmt.Errorf("unexpected balanceOf() type %T", results[0])
+	}
+	need := new(big.Int).Add(value, maxCost)
+	if have := new(big.Int).Add(balance, deposit); have.Cmp(need) < 0 {
+		return fmt.Errorf("insufficient funds: the account holds %s Wei and a deposit of %s Wei, but the transfer and gas need up to %s Wei", balance, deposit, need)
+	}
+	return nil
+}
+
+// confirmUserOp shows what the operation does and asks to go ahead, as confirmTx does for
+// transactions
+func confirmUserOp(client *ethclient.Client, chainID *big.Int, op *userOperation, stub *paymasterFields, receiver common.Address, value, maxCost *big.Int, nf numberFormat) error {
+	chain := lookupChain(chainID)
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "\nChain:    %s (chain ID %s)\n", chain.name, chainID)
+	fmt.Fprintf(&summary, "Account:  %s\n", op.Sender.Hex())
+	fmt.Fprintf(&summary, "To:       %s\n", receiver.Hex())
+	if *tokenContract != "" {
+		if *amountRawFlag != "" {
+			fmt.Fprintf(&summary, "Amount:   %s base units of %s\n", nf.format(*amountRawFlag), *tokenContract)
+		} else {
+			fmt.Fprintf(&summary, "Amount:   %s tokens of %s\n", nf.format(*amountFlag), *tokenContract)
+		}
+	} else {
+		fmt.Fprintf(&summary, "Amount:   %s %s%s\n", nf.format(formatUnits(value, chain.decimals)), chain.symbol, fiatAmount(client, chainID, value, chain.decimals, nf))
+	}
+	fmt.Fprintf(&summary, "Nonce:    %s\n", op.Nonce.ToInt())
+	fmt.Fprintf(&summary, "Max fee:  %s %s (at %s Wei per gas)%s\n", nf.format(formatUnits(maxCost, chain.decimals)), chain.symbol, nf.format(op.MaxFeePerGas.ToInt().String()), fiatAmount(client, chainID, maxCost, chain.decimals, nf))
+	if stub != nil {
+		sponsor := ""
+		if stub.Sponsor != nil && stub.Sponsor.Name != "" {
+			sponsor = " (" + stub.Sponsor.Name + ")"
+		}
+		fmt.Fprintf(&summary, "Gas paid: by the paymaster %s%s\n", op.Paymaster.Hex(), sponsor)
+	}
+	return confirm(summary.String())
+}
+
+// waitUserOp polls the bundler for the receipt of the operation on every new block
+func waitUserOp(ctx context.Context, client *ethclient.Client, bundler *rpc.Client, hash common.Hash) (*userOpReceipt, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks := sender.WatchBlocks(ctx, client, pollInterval)
+	for range blocks {
+		var raw json.RawMessage
+		if err := bundler.CallContext(ctx, &raw, "eth_getUserOperationReceipt", hash); err != nil {
+			warnf("Failed to get the UserOperation receipt: %v", err)
+			continue
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+		receipt := new(userOpReceipt)
+		if err := json.Unmarshal(raw, receipt); err != nil {
+			return nil, fmt.Errorf("unexpected receipt: %v", err)
+		}
+		return receipt, nil
+	}
+	return nil, ctx.Err()
+}
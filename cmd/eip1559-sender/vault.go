@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// loadVaultKey reads a hex private key from a HashiCorp Vault KV secret (version 1 or 2).
+// The token and namespace are taken from VAULT_TOKEN and VAULT_NAMESPACE
+func loadVaultKey(addr, path, field string) (sender.Signer, error) {
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if addr == "" {
+		return nil, errors.New("no Vault address given, use -vaultAddr or VAULT_ADDR")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		return nil, errors.New("VAULT_TOKEN is not set")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault secret: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []string                   `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to decode Vault response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read Vault secret %s: %s %s", path, resp.Status, strings.Join(body.Errors, "; "))
+	}
+
+	// KV version 2 nests the secret under data.data
+	data := body.Data
+	if nested, ok := data["data"]; ok {
+		if err := json.Unmarshal(nested, &data); err != nil {
+			return nil, fmt.Errorf("failed to decode Vault secret: %v", err)
+		}
+	}
+	var hexKey string
+	if raw, ok := data[field]; !ok {
+		return nil, fmt.Errorf("Vault secret %s has no field %q", path, field)
+	} else if err := json.Unmarshal(raw, &hexKey); err != nil {
+		return nil, fmt.Errorf("Vault secret field %q is not a string", field)
+	}
+	raw := []byte(hexKey)
+	key, err := parseHexKey(raw)
+	wipe(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key from Vault: %v", err)
+	}
+	return newKeySigner(key)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// verifyOptions holds the flags of the verify subcommand
+type verifyOptions struct {
+	rawTx   string
+	from    string
+	chainID string
+}
+
+func newVerifyFlagSet(opts *verifyOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.StringVar(&opts.rawTx, "rawTx", "", "Signed raw transaction as 0x-prefixed hex, or path to a file containing it or written by -signTo")
+	fs.StringVar(&opts.from, "expectedFrom", "", "Address the signature must recover to")
+	fs.StringVar(&opts.chainID, "expectedChainID", "", "Chain ID the transaction must be signed for")
+	addRootFlags(fs, "force", "v", "quiet", "logFormat")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s verify -rawTx 0x02f8...|tx.json -expectedFrom 0x... -expectedChainID 1 [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nChecks, without an RPC, that a signed transaction recovers to the expected sender and is bound to the expected chain.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runVerify implements the "verify" subcommand, the check of a signed transaction before it is
+// carried to the machine that broadcasts it
+func runVerify(args []string) {
+	var opts verifyOptions
+	fs := newVerifyFlagSet(&opts)
+	fs.Parse(args)
+	if err := setupLogging(); err != nil {
+		exitf(exitInvalid, "Invalid logging options: %v", err)
+	}
+
+	if opts.rawTx == "" || opts.from == "" || opts.chainID == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	expectedFrom, err := parseAddress(opts.from)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -expectedFrom: %v", err)
+	}
+	expectedChainID, ok := new(big.Int).SetString(opts.chainID, 10)
+	if !ok || expectedChainID.Sign() <= 0 {
+		exitf(exitInvalid, "Invalid -expectedChainID %q", opts.chainID)
+	}
+	tx, err := decodeRawTx(opts.rawTx)
+	if err != nil {
+		exitf(exitInvalid, "Invalid raw transaction: %v", err)
+	}
+
+	// a transaction without a chain ID could be replayed on any chain, so it matches none
+	if !tx.Protected() {
+		fatalf("Transaction %s is not replay protected, it is not bound to chain ID %s", tx.Hash().Hex(), expectedChainID)
+	}
+	if tx.ChainId().Cmp(expectedChainID) != 0 {
+		fatalf("Transaction %s is signed for chain ID %s, not %s", tx.Hash().Hex(), tx.ChainId(), expectedChainID)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		fatalf("Transaction %s has an invalid signature: %v", tx.Hash().Hex(), err)
+	}
+	if from != expectedFrom {
+		fatalf("Transaction %s is signed by %s, not by %s", tx.Hash().Hex(), from.Hex(), expectedFrom.Hex())
+	}
+	resultf([]interface{}{"hash", tx.Hash().Hex(), "from", from.Hex(), "chainId", tx.ChainId().String(), "nonce", tx.Nonce(), "valid", true},
+		"Valid transaction %s by %s for chain ID %s, nonce %d", tx.Hash().Hex(), from.Hex(), tx.ChainId(), tx.Nonce())
+}
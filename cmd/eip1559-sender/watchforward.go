@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gmh5225/EIP1559-sender/pkg/sender"
+)
+
+// forwardOptions holds the flags of the watch-forward subcommand
+type forwardOptions struct {
+	to        string
+	minAmount string
+}
+
+func newWatchForwardFlagSet(opts *forwardOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("watch-forward", flag.ExitOnError)
+	fs.StringVar(&opts.to, "to", "", "Address receiving everything that arrives at the signer's address")
+	fs.StringVar(&opts.minAmount, "minAmount", "", "Wait until at least this much can be forwarded, in whole coins or tokens (default: forward any amount)")
+	// each forward waits for its receipt before the next, and the command runs until interrupted
+	skipped := []string{"nonce", "nonceSource", "rescue", "exportUnsigned", "qr", "signTo", "expiresIn", "bumpAfter", "maxBumps", "validFor", "preflight", "preflightTxs", "explorerAPI", "explorerAPIKey", "from", "accessList", "simulate", "tenderlyProject", "tenderlyKey", "trace", "deadline", "wait"}
+	for _, name := range sharedFlags {
+		if !slices.Contains(skipped, name) {
+			addRootFlags(fs, name)
+		}
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s watch-forward -to 0x... -privateKeyEnv HOT_KEY -yes [options]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "\nWatches new blocks and forwards what arrives at the signer's address to -to: ETH less the maximum gas cost, or with -tokenContract the whole token balance. Runs until interrupted.\n")
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runWatchForward implements the "watch-forward" subcommand, a hot wallet sweeper: the sweep of
+// a single account, repeated on every new block whose balance leaves something to forward
+func runWatchForward(args []string) {
+	var opts forwardOptions
+	fs := newWatchForwardFlagSet(&opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" || opts.to == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	// nobody may be around to answer the confirmation prompt when funds arrive
+	if !*yesFlag && !*dryRunFlag {
+		exitf(exitInvalid, "watch-forward sends unattended and requires -yes")
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, chainID := dialRPC()
+	to, err := parseAddress(opts.to)
+	if err != nil {
+		exitf(exitInvalid, "Invalid -to address: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	from := signer.Address()
+	if from == to {
+		exitf(exitInvalid, "-to is the signer's own address")
+	}
+
+	var token *erc20Token
+	decimals, symbol := lookupChain(chainID).decimals, lookupChain(chainID).symbol
+	if *tokenContract != "" {
+		if token, err = loadToken(client, *tokenContract, *tokenABIFlag); err != nil {
+			fatalf("Failed to load token contract: %v", err)
+		}
+		if decimals, err = token.decimals(); err != nil {
+			fatalf("Failed to get token decimals: %v", err)
+		}
+		symbol = token.symbol()
+		infof("Token: %s", token.describe())
+	}
+	minAmount := big.NewInt(1)
+	if opts.minAmount != "" {
+		if minAmount, err = parseUnits(opts.minAmount, decimals); err != nil {
+			exitf(exitInvalid, "Invalid -minAmount: %v", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	infof("Forwarding %s arriving at %s to %s until interrupted", symbol, from.Hex(), to.Hex())
+	forwarded, count := new(big.Int), 0
+	var last *big.Int
+	for range sender.WatchBlocks(ctx, client, pollInterval) {
+		var balance *big.Int
+		if token != nil {
+			balance, err = token.balanceOf(from)
+		} else {
+			balance, err = client.PendingBalanceAt(ctx, from)
+		}
+		if err != nil {
+			// a hot wallet sweeper should outlast a node that is briefly unavailable
+			warnf("Failed to get balance: %v", err)
+			continue
+		}
+		changed := last == nil || balance.Cmp(last) != 0
+		if last != nil && balance.Cmp(last) > 0 {
+			infof("Received %s %s", nf.format(formatUnits(new(big.Int).Sub(balance, last), decimals)), symbol)
+		}
+		last = balance
+		// a dry run would simulate the same forward on every block
+		if balance.Sign() == 0 || (*dryRunFlag && !changed) {
+			continue
+		}
+
+		header, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			warnf("Failed to get header: %v", err)
+			continue
+		}
+		legacy, err := legacyTx(header)
+		if err != nil {
+			exitf(exitInvalid, "Invalid transaction type: %v", err)
+		}
+		baseFee := header.BaseFee
+		if legacy {
+			baseFee = nil
+		}
+		tip, feeCap, err := suggestFees(ctx, client, baseFee)
+		if err != nil {
+			warnf("Failed to determine fees: %v", err)
+			continue
+		}
+		// the nonce is looked up afresh, the account may also send from elsewhere
+		forward := &sweptAccount{signer: signer, nonces: &sender.NonceManager{}}
+		if err := forward.prepare(client, chainID, token, to, legacy, tip, feeCap); err != nil {
+			if changed {
+				warnf("Not forwarding %s %s: %v", nf.format(formatUnits(balance, decimals)), symbol, err)
+			}
+			continue
+		}
+		if forward.amount.Sign() == 0 || forward.amount.Cmp(minAmount) < 0 {
+			if changed {
+				debugf("%s %s left to forward is below -minAmount or the gas cost", nf.format(formatUnits(forward.amount, decimals)), symbol)
+			}
+			continue
+		}
+		forward.send(client, chainID)
+		amount := nf.format(formatUnits(forward.amount, decimals))
+		switch forward.status {
+		case "simulated":
+			resultf([]interface{}{"amount", formatUnits(forward.amount, decimals), "to", to.Hex(), "status", forward.status},
+				"Would forward %s %s to %s", amount, symbol, to.Hex())
+			continue
+		case "sent":
+		default:
+			warnf("Failed to forward %s %s: %s", amount, symbol, forward.status)
+			continue
+		}
+
+		receipt, err := sender.WaitReceipt(ctx, client, forward.hash, *confirmations, pollInterval)
+		if err != nil {
+			if ctx.Err() != nil {
+				warnf("Interrupted while waiting for forward %s", forward.hash.Hex())
+				break
+			}
+			warnf("Failed to wait for forward %s: %v", forward.hash.Hex(), err)
+			continue
+		}
+		recordReceipt(client, receipt)
+		status := "success"
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			status = "reverted"
+		} else {
+			forwarded.Add(forwarded, forward.amount)
+			count++
+		}
+		resultf([]interface{}{"amount", formatUnits(forward.amount, decimals), "to", to.Hex(), "hash", forward.hash.Hex(), "block", receipt.BlockNumber.Uint64(), "status", status},
+			"Forwarded %s %s to %s: %s (%s in block %d)", amount, symbol, to.Hex(), forward.hash.Hex(), status, receipt.BlockNumber)
+	}
+	resultf([]interface{}{"to", to.Hex(), "total", formatUnits(forwarded, decimals), "forwards", count},
+		"Stopped after forwarding %s %s to %s in %d transactions", nf.format(formatUnits(forwarded, decimals)), symbol, to.Hex(), count)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// webhookEvent is the JSON payload POSTed to -webhook about the outcome of a transaction
+type webhookEvent struct {
+	// Status is confirmed, reverted, replaced or expired (cancelled by -validFor)
+	Status            string `json:"status"`
+	Hash              string `json:"hash"`
+	ChainID           string `json:"chainId"`
+	From              string `json:"from"`
+	Nonce             uint64 `json:"nonce"`
+	Block             uint64 `json:"block,omitempty"`
+	GasUsed           uint64 `json:"gasUsed,omitempty"`
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	// ReplacedBy is the hash of the transaction that took the nonce, if known
+	ReplacedBy string `json:"replacedBy,omitempty"`
+	// Job is the ID of the daemon job the transaction was sent for
+	Job string `json:"job,omitempty"`
+	// Memo is the -memo, batch row memo or job memo the transaction was sent with
+	Memo string `json:"memo,omitempty"`
+}
+
+// receiptEvent describes the mined transaction of receipt, sent by from with nonce
+func receiptEvent(chainID *big.Int, from common.Address, nonce uint64, receipt *types.Receipt) webhookEvent {
+	event := webhookEvent{
+		Status:  "confirmed",
+		Hash:    receipt.TxHash.Hex(),
+		ChainID: chainID.String(),
+		From:    from.Hex(),
+		Nonce:   nonce,
+		Block:   receipt.BlockNumber.Uint64(),
+		GasUsed: receipt.GasUsed,
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		event.Status = "reverted"
+	}
+	if receipt.EffectiveGasPrice != nil {
+		event.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+	}
+	return event
+}
+
+// replacedEvent describes tx, which will not be mined as another transaction took its nonce
+func replacedEvent(chainID *big.Int, from common.Address, tx *types.Transaction, replacedBy common.Hash) webhookEvent {
+	event := webhookEvent{Status: "replaced", Hash: tx.Hash().Hex(), ChainID: chainID.String(), From: from.Hex(), Nonce: tx.Nonce()}
+	if replacedBy != (common.Hash{}) {
+		event.ReplacedBy = replacedBy.Hex()
+	}
+	return event
+}
+
+// notifyWebhook POSTs event to -webhook, retrying failed deliveries up to -webhookRetries times
+// with exponential backoff. Failures are only logged, the transaction is sent either way
+func notifyWebhook(event webhookEvent) {
+	if memo := takeMemo(event.Hash); event.Memo == "" {
+		event.Memo = memo
+	}
+	// a server or daemon may reload the webhook while it runs
+	configMu.RLock()
+	url, retries := *webhookFlag, *webhookRetries
+	configMu.RUnlock()
+	if url == "" {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		warnf("Failed to encode webhook payload: %v", err)
+		return
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err := postWebhook(client, url, body)
+		if err == nil {
+			debugf("Webhook notified: %s %s", event.Status, event.Hash)
+			return
+		}
+		if attempt >= retries {
+			warnf("Failed to notify webhook about %s: %v", event.Hash, err)
+			return
+		}
+		debugf("Webhook delivery failed, retrying in %s: %v", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postWebhook makes a single delivery attempt
+func postWebhook(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
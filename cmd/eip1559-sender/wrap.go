@@ -0,0 +1,110 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// wethABIJSON holds the deposit and withdraw methods of WETH9 and its clones (WPOL, WBNB, WXDAI)
+//
+//go:embed weth.abi.json
+var wethABIJSON string
+
+var wethABI = mustLoadABI(wethABIJSON)
+
+// wrapOptions holds the flags of the wrap and unwrap subcommands
+type wrapOptions struct {
+	amount   string
+	contract string
+}
+
+func newWrapFlagSet(action string, opts *wrapOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	fs.StringVar(&opts.amount, "amount", "", "Amount in whole coins, e.g. 1.5")
+	fs.StringVar(&opts.contract, "wethContract", "", "Wrapped native coin contract (default: the canonical one of the chain)")
+	addSharedFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s -amount 1.0 [options]\n", os.Args[0], action)
+		if action == "wrap" {
+			fmt.Fprintf(fs.Output(), "\nWraps the native coin by calling deposit() on the chain's WETH contract.\n")
+		} else {
+			fmt.Fprintf(fs.Output(), "\nUnwraps WETH back into the native coin by calling withdraw() on the chain's WETH contract.\n")
+		}
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runWrap implements the "wrap" and "unwrap" subcommands
+func runWrap(action string, args []string) {
+	var opts wrapOptions
+	fs := newWrapFlagSet(action, &opts)
+	fs.Parse(args)
+	configure(fs)
+
+	if *rpcURLFlag == "" || opts.amount == "" {
+		fmt.Println("Error: Missing required parameters")
+		fs.Usage()
+		exit(exitInvalid)
+	}
+	if opts.contract != "" && !common.IsHexAddress(opts.contract) {
+		exitf(exitInvalid, "Invalid -wethContract address %q", opts.contract)
+	}
+	nf, err := lookupLocale(*localeFlag)
+	if err != nil {
+		exitf(exitInvalid, "Invalid locale: %v", err)
+	}
+	client, chainID := dialRPC()
+	chain := lookupChain(chainID)
+	value, err := parseUnits(opts.amount, chain.decimals)
+	if err != nil {
+		exitf(exitInvalid, "Invalid amount: %v", err)
+	}
+	contract := opts.contract
+	if contract == "" {
+		if contract = chain.wrapped; contract == "" {
+			exitf(exitInvalid, "No canonical WETH contract is known for chain ID %s, pass -wethContract", chainID)
+		}
+	}
+	weth := &erc20Token{client: client, address: common.HexToAddress(contract), abi: wethABI}
+	if err := weth.verify(); err != nil {
+		fatalf("Failed to load WETH contract: %v", err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		exitf(keyExitCode(err), "Failed to load signing key: %v", err)
+	}
+	from := signer.Address()
+	infof("Sender's address: %s", from.Hex())
+	infof("WETH contract: %s", weth.describe())
+
+	// wrapping sends the coins along with deposit(), whose funds newTransaction checks; unwrapping
+	// burns WETH the sender has to hold
+	var data []byte
+	txValue := new(big.Int)
+	if action == "wrap" {
+		infof("Wrapping %s %s into %s", nf.format(formatUnits(value, chain.decimals)), chain.symbol, weth.symbol())
+		data, err = wethABI.Pack("deposit")
+		txValue = value
+	} else {
+		if balance, err := weth.balanceOf(from); err != nil {
+			exitf(exitRPC, "Failed to get WETH balance: %v", err)
+		} else if balance.Cmp(value) < 0 {
+			exitf(exitFunds, "Insufficient funds: %s holds %s %s, less than the %s to unwrap", from.Hex(),
+				nf.format(formatUnits(balance, chain.decimals)), weth.symbol(), nf.format(formatUnits(value, chain.decimals)))
+		}
+		infof("Unwrapping %s %s into %s", nf.format(formatUnits(value, chain.decimals)), weth.symbol(), chain.symbol)
+		data, err = wethABI.Pack("withdraw", value)
+	}
+	if err != nil {
+		fatalf("Failed to pack WETH call: %v", err)
+	}
+	tx := newTransaction(client, from, chainID, &weth.address, txValue, data, nf)
+	sendAndFollow(client, signer, chainID, tx, nf)
+}
@@ -0,0 +1,97 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Builder fills in the nonce, fees and gas limit of new transactions
+type Builder struct {
+	Client *Client
+	Fees   FeeOptions
+	// TxType defaults to TxAuto
+	TxType TxType
+	// GasLimit is used as given, 0 to estimate it
+	GasLimit uint64
+	// Nonces allocates the nonces, a private NonceManager if nil
+	Nonces *NonceManager
+}
+
+// Build returns an unsigned EIP-1559 transaction, or a legacy one where needed, from from. A nil
+// to deploys data as a contract. It fails if the balance of from cannot pay for it
+func (b *Builder) Build(ctx context.Context, from common.Address, to *common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	ctx, span := startSpan(ctx, "sender.Build", attribute.String("eth.from", from.Hex()))
+	tx, err := b.build(ctx, from, to, value, data)
+	if err == nil {
+		span.SetAttributes(txAttributes(tx)...)
+	}
+	endSpan(span, err)
+	return tx, err
+}
+
+func (b *Builder) build(ctx context.Context, from common.Address, to *common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	if b.Nonces == nil {
+		b.Nonces = &NonceManager{}
+	}
+	client := b.Client.Client
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get header: %w", err)
+	}
+	legacy, err := UseLegacy(header, b.TxType)
+	if err != nil {
+		return nil, err
+	}
+	baseFee := header.BaseFee
+	if legacy {
+		baseFee = nil
+	}
+	tip, feeCap, err := SuggestFees(ctx, client, baseFee, b.Fees)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine fees: %w", err)
+	}
+
+	// check the value first, estimating gas with an unaffordable value fails with a vaguer error
+	balance, err := client.PendingBalanceAt(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	if err := CheckFunds(balance, value, 0, feeCap); err != nil {
+		return nil, fmt.Errorf("insufficient funds: %w", err)
+	}
+	gas := b.GasLimit
+	if gas == 0 {
+		estimateCtx, span := startSpan(ctx, "sender.EstimateGas")
+		gas, err = client.EstimateGas(estimateCtx, ethereum.CallMsg{From: from, To: to, Value: value, Data: data})
+		span.SetAttributes(attribute.Int64("eth.gas", int64(gas)))
+		endSpan(span, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to estimate gas: %w", err)
+		}
+	}
+	if err := CheckFunds(balance, value, gas, feeCap); err != nil {
+		return nil, fmt.Errorf("insufficient funds: %w", err)
+	}
+
+	// the nonce comes last, nothing can fail after it is reserved
+	nonce, err := b.Nonces.Reserve(ctx, client, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	return MakeTx(legacy, &types.DynamicFeeTx{
+		ChainID:   b.Client.ChainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        to,
+		Value:     value,
+		Data:      data,
+	}), nil
+}
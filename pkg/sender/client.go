@@ -0,0 +1,33 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Client is an RPC connection together with the chain ID its transactions are signed for
+type Client struct {
+	*ethclient.Client
+	ChainID *big.Int
+}
+
+// Dial connects to rawURL with the given options, such as rpc.WithHTTPClient. A nil chainID is
+// asked from the node
+func Dial(ctx context.Context, rawURL string, chainID *big.Int, options ...rpc.ClientOption) (*Client, error) {
+	conn, err := rpc.DialOptions(ctx, rawURL, options...)
+	if err != nil {
+		return nil, err
+	}
+	client := ethclient.NewClient(conn)
+	if chainID == nil {
+		if chainID, err = client.ChainID(ctx); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to get chain ID: %w", err)
+		}
+	}
+	return &Client{Client: client, ChainID: chainID}, nil
+}
@@ -0,0 +1,32 @@
+// Package sender builds, signs and sends EIP-1559 transactions, the core of the eip1559-sender
+// command for use from other Go programs.
+//
+// A Builder fills in the nonce, fees and gas limit of a transaction, a Signer signs it and
+// WaitReceipt follows it until it is mined:
+//
+//	client, err := sender.Dial(ctx, "https://...", nil)
+//	if err != nil {
+//		return err
+//	}
+//	signer := sender.NewKeySigner(key)
+//	builder := &sender.Builder{Client: client, Fees: sender.DefaultFees}
+//	tx, err := builder.Build(ctx, signer.Address(), &to, value, nil)
+//	if err != nil {
+//		return err
+//	}
+//	signed, err := sender.SignTx(ctx, signer, tx, client.ChainID)
+//	if err != nil {
+//		return err
+//	}
+//	if err := sender.Broadcast(ctx, client.Client, signed); err != nil {
+//		return err
+//	}
+//	receipt, err := sender.WaitReceipt(ctx, client.Client, signed.Hash(), 1, 2*time.Second)
+//
+// Every function reports failures as errors and honours the cancellation of its context.
+//
+// Building, signing, broadcasting and waiting are recorded as OpenTelemetry spans, children of the
+// span of the context, with the nonce lookup, fee suggestion and gas estimation of Build as spans
+// of their own. They go to the global tracer provider and are dropped unless the program sets one
+// up with otel.SetTracerProvider.
+package sender
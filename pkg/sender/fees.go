@@ -0,0 +1,281 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/holiman/uint256"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FeeOptions tunes the fee estimator
+type FeeOptions struct {
+	// Tip and FeeCap are used as given, nil to estimate them
+	Tip, FeeCap *big.Int
+	// Blocks is the number of recent blocks whose fee history sets the tip, 0 for the node's suggestion
+	Blocks uint64
+	// Percentile of the tips paid in each of those blocks
+	Percentile float64
+	// Headroom is the number of full blocks of base fee growth (12.5% each) the fee cap must survive
+	Headroom uint
+	// FallbackTip suggests a tip when the node serves neither fee history nor
+	// eth_maxPriorityFeePerGas, such as from an external gas API. nil goes on to eth_gasPrice
+	FallbackTip func(ctx context.Context) (*big.Int, error)
+	// OnFallback, if set, is told about every tip source that failed before one succeeded
+	OnFallback func(source string, err error)
+}
+
+// DefaultFees are the estimator settings of the standard priority
+var DefaultFees = FeeOptions{Blocks: 20, Percentile: 50, Headroom: 6}
+
+// SuggestFees returns the tip and fee cap for a new transaction, unless fixed by opts. The tip
+// comes from the first of these that answers: the fee history, the node's suggestion,
+// opts.FallbackTip and the part of eth_gasPrice above the base fee. The fee cap covers
+// opts.Headroom blocks of base fee growth. Without a base fee both are the gas price of a
+// legacy transaction
+func SuggestFees(ctx context.Context, client *ethclient.Client, baseFee *big.Int, opts FeeOptions) (*big.Int, *big.Int, error) {
+	ctx, span := startSpan(ctx, "sender.SuggestFees")
+	tip, feeCap, err := suggestFees(ctx, client, baseFee, opts)
+	if err == nil {
+		span.SetAttributes(attribute.String("eth.max_priority_fee_per_gas", tip.String()), attribute.String("eth.max_fee_per_gas", feeCap.String()))
+	}
+	endSpan(span, err)
+	return tip, feeCap, err
+}
+
+func suggestFees(ctx context.Context, client *ethclient.Client, baseFee *big.Int, opts FeeOptions) (*big.Int, *big.Int, error) {
+	tip, feeCap := opts.Tip, opts.FeeCap
+	if baseFee == nil {
+		// legacy transactions pay a single gas price, the fee cap if given
+		if tip != nil {
+			return nil, nil, errors.New("a tip does not apply to legacy transactions, the fee cap sets the gas price")
+		}
+		if feeCap == nil {
+			var err error
+			if feeCap, err = client.SuggestGasPrice(ctx); err != nil {
+				return nil, nil, fmt.Errorf("failed to get suggested gas price: %w", err)
+			}
+		}
+		return feeCap, feeCap, nil
+	}
+	if opts.Percentile < 0 || opts.Percentile > 100 {
+		return nil, nil, fmt.Errorf("fee percentile %v is not between 0 and 100", opts.Percentile)
+	}
+
+	if tip == nil || feeCap == nil {
+		suggested, nextBaseFee, err := suggestTip(ctx, client, baseFee, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if tip == nil {
+			tip = suggested
+			// a suggested tip must not push the fee over an explicit cap
+			if feeCap != nil && tip.Cmp(feeCap) > 0 {
+				tip = new(big.Int).Set(feeCap)
+			}
+		}
+		if feeCap == nil {
+			if nextBaseFee.Cmp(baseFee) < 0 {
+				nextBaseFee = baseFee
+			}
+			feeCap = new(big.Int).Add(BaseFeeHeadroom(nextBaseFee, opts.Headroom), tip)
+		}
+	}
+	if feeCap.Cmp(tip) < 0 {
+		return nil, nil, fmt.Errorf("maxFeePerGas %s Wei is below maxPriorityFeePerGas %s Wei", feeCap, tip)
+	}
+	if feeCap.Cmp(baseFee) < 0 {
+		return nil, nil, fmt.Errorf("maxFeePerGas %s Wei is below the current base fee of %s Wei", feeCap, baseFee)
+	}
+	return tip, feeCap, nil
+}
+
+// suggestTip returns the tip of the first tip source that answers, with the base fee to expect.
+// Some nodes and chains lack the fee history or eth_maxPriorityFeePerGas, and a transfer should
+// not fail for want of a suggestion that eth_gasPrice also gives
+func suggestTip(ctx context.Context, client *ethclient.Client, baseFee *big.Int, opts FeeOptions) (*big.Int, *big.Int, error) {
+	var failures []string
+	failed := func(source string, err error) {
+		failures = append(failures, fmt.Sprintf("%s: %v", source, err))
+		trace.SpanFromContext(ctx).AddEvent("fee source failed", trace.WithAttributes(attribute.String("source", source), attribute.String("error", err.Error())))
+		if opts.OnFallback != nil {
+			opts.OnFallback(source, err)
+		}
+	}
+	if opts.Blocks > 0 {
+		tip, nextBaseFee, err := feeHistoryEstimate(ctx, client, opts)
+		if err == nil {
+			return tip, nextBaseFee, nil
+		}
+		failed("eth_feeHistory", err)
+	}
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err == nil {
+		return tip, baseFee, nil
+	}
+	failed("eth_maxPriorityFeePerGas", err)
+	if opts.FallbackTip != nil {
+		if tip, err = opts.FallbackTip(ctx); err == nil {
+			return tip, baseFee, nil
+		}
+		failed("gas API", err)
+	}
+	// the gas price a node suggests is the base fee plus the tip it would pay
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err == nil {
+		if tip = new(big.Int).Sub(gasPrice, baseFee); tip.Sign() < 0 {
+			tip.SetInt64(0)
+		}
+		return tip, baseFee, nil
+	}
+	failed("eth_gasPrice", err)
+	return nil, nil, fmt.Errorf("failed to suggest maxPriorityFeePerGas: %s", strings.Join(failures, "; "))
+}
+
+// feeHistoryEstimate returns the median over the last opts.Blocks non-empty blocks of their
+// opts.Percentile tip, together with the base fee of the next block
+func feeHistoryEstimate(ctx context.Context, client *ethclient.Client, opts FeeOptions) (*big.Int, *big.Int, error) {
+	if opts.Blocks == 0 {
+		return nil, nil, errors.New("fee history disabled")
+	}
+	history, err := client.FeeHistory(ctx, opts.Blocks, nil, []float64{opts.Percentile})
+	if err != nil {
+		return nil, nil, err
+	}
+	var tips []*big.Int
+	for i, reward := range history.Reward {
+		// empty blocks report a zero tip that says nothing about the market
+		if i < len(history.GasUsedRatio) && history.GasUsedRatio[i] > 0 && len(reward) > 0 {
+			tips = append(tips, reward[0])
+		}
+	}
+	if len(tips) == 0 || len(history.BaseFee) == 0 {
+		return nil, nil, fmt.Errorf("no transactions in the last %d blocks", opts.Blocks)
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+	return tips[len(tips)/2], history.BaseFee[len(history.BaseFee)-1], nil
+}
+
+// BaseFeeHeadroom returns the base fee after blocks consecutive full blocks, each raising it by 12.5%
+func BaseFeeHeadroom(baseFee *big.Int, blocks uint) *big.Int {
+	fee := new(big.Int).Set(baseFee)
+	for i := uint(0); i < blocks; i++ {
+		fee.Mul(fee, big.NewInt(9))
+		fee.Add(fee, big.NewInt(7))
+		fee.Div(fee, big.NewInt(8))
+	}
+	return fee
+}
+
+// TxType selects the type of the transactions a Builder creates
+type TxType string
+
+const (
+	// TxAuto builds EIP-1559 transactions, or legacy ones on chains without a base fee
+	TxAuto TxType = "auto"
+	// TxDynamic always builds EIP-1559 transactions
+	TxDynamic TxType = "dynamic"
+	// TxLegacy always builds legacy (type 0) transactions
+	TxLegacy TxType = "legacy"
+)
+
+// UseLegacy reports whether to build a legacy transaction of typ on top of header
+func UseLegacy(header *types.Header, typ TxType) (bool, error) {
+	switch typ {
+	case TxAuto, "":
+		return header.BaseFee == nil, nil
+	case TxLegacy:
+		return true, nil
+	case TxDynamic:
+		if header.BaseFee == nil {
+			return false, errors.New("the chain has no base fee and does not accept EIP-1559 transactions")
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown transaction type %q, expected auto, dynamic or legacy", typ)
+}
+
+// MakeTx returns inner as a transaction, or as a legacy one paying its fee cap as the gas price
+func MakeTx(legacy bool, inner *types.DynamicFeeTx) *types.Transaction {
+	if legacy {
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    inner.Nonce,
+			GasPrice: inner.GasFeeCap,
+			Gas:      inner.Gas,
+			To:       inner.To,
+			Value:    inner.Value,
+			Data:     inner.Data,
+		})
+	}
+	return types.NewTx(inner)
+}
+
+// WithFees returns a copy of tx with a new tip and fee cap, keeping its type and payload. A
+// non-nil blobFeeCap replaces the blob fee cap of a blob transaction
+func WithFees(tx *types.Transaction, chainID, tip, feeCap, blobFeeCap *big.Int) *types.Transaction {
+	switch tx.Type() {
+	case types.BlobTxType:
+		if blobFeeCap == nil {
+			blobFeeCap = tx.BlobGasFeeCap()
+		}
+		return types.NewTx(&types.BlobTx{
+			ChainID:    uint256.MustFromBig(chainID),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  uint256.MustFromBig(tip),
+			GasFeeCap:  uint256.MustFromBig(feeCap),
+			Gas:        tx.Gas(),
+			To:         *tx.To(),
+			Value:      uint256.MustFromBig(tx.Value()),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+			BlobFeeCap: uint256.MustFromBig(blobFeeCap),
+			BlobHashes: tx.BlobHashes(),
+			Sidecar:    tx.BlobTxSidecar(),
+		})
+	case types.SetCodeTxType:
+		return types.NewTx(&types.SetCodeTx{
+			ChainID:    uint256.MustFromBig(chainID),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  uint256.MustFromBig(tip),
+			GasFeeCap:  uint256.MustFromBig(feeCap),
+			Gas:        tx.Gas(),
+			To:         *tx.To(),
+			Value:      uint256.MustFromBig(tx.Value()),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+			AuthList:   tx.SetCodeAuthorizations(),
+		})
+	}
+	return MakeTx(tx.Type() == types.LegacyTxType, &types.DynamicFeeTx{
+		ChainID:    chainID,
+		Nonce:      tx.Nonce(),
+		GasTipCap:  tip,
+		GasFeeCap:  feeCap,
+		Gas:        tx.Gas(),
+		To:         tx.To(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	})
+}
+
+// CheckFunds verifies that balance covers value plus gas at feeCap, reporting the shortfall otherwise
+func CheckFunds(balance, value *big.Int, gas uint64, feeCap *big.Int) error {
+	maxCost := new(big.Int).Mul(new(big.Int).SetUint64(gas), feeCap)
+	need := new(big.Int).Add(value, maxCost)
+	if balance.Cmp(need) >= 0 {
+		return nil
+	}
+	short := new(big.Int).Sub(need, balance)
+	if gas == 0 {
+		return fmt.Errorf("need %s more Wei to send %s Wei from a balance of %s Wei", short, value, balance)
+	}
+	return fmt.Errorf("need %s more Wei to cover %s Wei plus at most %s Wei of gas from a balance of %s Wei", short, value, maxCost, balance)
+}
@@ -0,0 +1,180 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// NonceSource selects the account state a nonce is read from
+type NonceSource string
+
+const (
+	// PendingNonce counts the account's transactions waiting in the node's pool
+	PendingNonce NonceSource = "pending"
+	// LatestNonce ignores pending transactions, reusing the nonce of the first one to replace it
+	LatestNonce NonceSource = "latest"
+)
+
+// AccountNonce returns the nonce of from in the state selected by source
+func AccountNonce(ctx context.Context, client *ethclient.Client, from common.Address, source NonceSource) (uint64, error) {
+	switch source {
+	case PendingNonce, "":
+		return client.PendingNonceAt(ctx, from)
+	case LatestNonce:
+		return client.NonceAt(ctx, from, nil)
+	}
+	return 0, fmt.Errorf("unknown nonce source %q, expected pending or latest", source)
+}
+
+// NonceManager hands out nonces per sender within one process, so transactions built back to
+// back do not race the node's view of the pending nonce. The zero value is ready to use
+type NonceManager struct {
+	// Next returns the first nonce of an account, its pending nonce if nil
+	Next func(ctx context.Context, client *ethclient.Client, from common.Address) (uint64, error)
+
+	mu       sync.Mutex
+	next     map[common.Address]uint64
+	released map[common.Address][]uint64
+	stale    map[common.Address]bool
+}
+
+// Reserve returns a nonce for a new transaction of from: the lowest released one, or the one
+// after the last reserved. The first reservation asks Next
+func (m *NonceManager) Reserve(ctx context.Context, client *ethclient.Client, from common.Address) (uint64, error) {
+	ctx, span := startSpan(ctx, "sender.ReserveNonce", attribute.String("eth.from", from.Hex()))
+	nonce, source, err := m.reserve(ctx, client, from)
+	span.SetAttributes(attribute.Int64("eth.nonce", int64(nonce)), attribute.String("sender.nonce_source", source))
+	endSpan(span, err)
+	return nonce, err
+}
+
+// reserve is Reserve, also telling whether the nonce was released, allocated after the last one
+// or asked from the node
+func (m *NonceManager) reserve(ctx context.Context, client *ethclient.Client, from common.Address) (uint64, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stale[from] {
+		if err := m.resync(ctx, client, from); err != nil {
+			return 0, "node", err
+		}
+	}
+	if released := m.released[from]; len(released) > 0 {
+		m.released[from] = released[1:]
+		return released[0], "released", nil
+	}
+	source := "allocated"
+	nonce, ok := m.next[from]
+	if !ok {
+		source = "node"
+		var err error
+		if nonce, err = m.nodeNonce(ctx, client, from); err != nil {
+			return 0, source, err
+		}
+	}
+	if m.next == nil {
+		m.next = map[common.Address]uint64{}
+	}
+	m.next[from] = nonce + 1
+	return nonce, source, nil
+}
+
+// nodeNonce asks Next, or the node's pending nonce, for the first nonce of from
+func (m *NonceManager) nodeNonce(ctx context.Context, client *ethclient.Client, from common.Address) (uint64, error) {
+	if m.Next != nil {
+		return m.Next(ctx, client, from)
+	}
+	return client.PendingNonceAt(ctx, from)
+}
+
+// resync moves the next nonce of from up to the node's, keeping it past every nonce reserved so
+// far, and drops the released nonces the node has seen used since
+func (m *NonceManager) resync(ctx context.Context, client *ethclient.Client, from common.Address) error {
+	node, err := m.nodeNonce(ctx, client, from)
+	if err != nil {
+		return err
+	}
+	delete(m.stale, from)
+	if next, ok := m.next[from]; !ok || node > next {
+		if m.next == nil {
+			m.next = map[common.Address]uint64{}
+		}
+		m.next[from] = node
+	}
+	var released []uint64
+	for _, nonce := range m.released[from] {
+		if nonce >= node {
+			released = append(released, nonce)
+		}
+	}
+	if len(released) == 0 {
+		delete(m.released, from)
+	} else {
+		m.released[from] = released
+	}
+	return nil
+}
+
+// IsNonceError tells whether err, returned by the node for a sent transaction, shows that its
+// nonce is taken: mined already or held in the pool by another transaction
+func IsNonceError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrNonceUsed) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, reason := range []string{"nonce too low", "already known", "known transaction", "replacement transaction underpriced"} {
+		if strings.Contains(msg, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resync makes the next reservation of from ask Next again and move past the nonces taken
+// outside the manager. Nonces reserved and not yet released are never handed out again
+func (m *NonceManager) Resync(from common.Address) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stale == nil {
+		m.stale = map[common.Address]bool{}
+	}
+	m.stale[from] = true
+}
+
+// Fail hands back the nonce of a transaction that failed with err. It is released unless err
+// shows the nonce is taken, in which case it is dropped and the account resynced, rather than
+// handing out a dead nonce again
+func (m *NonceManager) Fail(from common.Address, nonce uint64, err error) {
+	if IsNonceError(err) {
+		m.Resync(from)
+		return
+	}
+	m.Release(from, nonce)
+}
+
+// Release returns a reserved nonce whose transaction was never sent, to be handed out again
+// before any new one so that no gap is left behind
+func (m *NonceManager) Release(from common.Address, nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.next[from] == nonce+1 {
+		m.next[from] = nonce
+		return
+	}
+	if m.released == nil {
+		m.released = map[common.Address][]uint64{}
+	}
+	released := append(m.released[from], nonce)
+	sort.Slice(released, func(i, j int) bool { return released[i] < released[j] })
+	m.released[from] = released
+}
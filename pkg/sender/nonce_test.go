@@ -0,0 +1,133 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var testFrom = common.HexToAddress("0x1")
+
+// testNonces returns a manager whose Next answers *pending, the node's pending nonce
+func testNonces(pending *uint64) *NonceManager {
+	return &NonceManager{Next: func(context.Context, *ethclient.Client, common.Address) (uint64, error) {
+		return *pending, nil
+	}}
+}
+
+// reserveNonces reserves n nonces of testFrom
+func reserveNonces(t *testing.T, m *NonceManager, n int) []uint64 {
+	t.Helper()
+	var got []uint64
+	for range n {
+		nonce, err := m.Reserve(context.Background(), nil, testFrom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, nonce)
+	}
+	return got
+}
+
+func TestNonceManagerRelease(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		release []uint64 // after reserving 5, 6 and 7
+		want    []uint64 // the next three reservations
+	}{
+		{"none", nil, []uint64{8, 9, 10}},
+		{"last", []uint64{7}, []uint64{7, 8, 9}},
+		{"gap", []uint64{6}, []uint64{6, 8, 9}},
+		{"gaps lowest first", []uint64{6, 5}, []uint64{5, 6, 8}},
+		{"all from the top", []uint64{7, 6, 5}, []uint64{5, 6, 7}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pending := uint64(5)
+			m := testNonces(&pending)
+			if got := reserveNonces(t, m, 3); !slices.Equal(got, []uint64{5, 6, 7}) {
+				t.Fatalf("reserved %v, want [5 6 7]", got)
+			}
+			for _, nonce := range tc.release {
+				m.Release(testFrom, nonce)
+			}
+			if got := reserveNonces(t, m, 3); !slices.Equal(got, tc.want) {
+				t.Errorf("reserved %v after releasing %v, want %v", got, tc.release, tc.want)
+			}
+		})
+	}
+}
+
+func TestNonceManagerFail(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		release []uint64 // released unused after reserving 5, 6 and 7
+		err     error    // the send of nonce 5 failed with
+		pending uint64   // the node's pending nonce by then
+		want    []uint64
+	}{
+		{"unused", nil, errors.New("insufficient funds for gas * price + value"), 5, []uint64{5, 8}},
+		// 6 and 7 are still in flight, so they are not handed out again
+		{"nonce too low", nil, errors.New("nonce too low: next nonce 6, tx nonce 5"), 6, []uint64{8, 9}},
+		{"already known", nil, errors.New("already known"), 8, []uint64{8, 9}},
+		{"gap kept", []uint64{6}, errors.New("nonce too low"), 6, []uint64{6, 8}},
+		{"node ahead", nil, errors.New("nonce too low"), 12, []uint64{12, 13}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pending := uint64(5)
+			m := testNonces(&pending)
+			reserveNonces(t, m, 3)
+			for _, nonce := range tc.release {
+				m.Release(testFrom, nonce)
+			}
+			pending = tc.pending
+			m.Fail(testFrom, 5, tc.err)
+			if got := reserveNonces(t, m, 2); !slices.Equal(got, tc.want) {
+				t.Errorf("reserved %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNonceManagerResync(t *testing.T) {
+	pending := uint64(5)
+	m := testNonces(&pending)
+	reserveNonces(t, m, 3)
+	m.Release(testFrom, 5)
+	// 5 and 6 were used by transactions sent elsewhere
+	pending = 7
+	m.Resync(testFrom)
+	if got := reserveNonces(t, m, 2); !slices.Equal(got, []uint64{8, 9}) {
+		t.Errorf("reserved %v after resync, want [8 9]", got)
+	}
+
+	// an account never reserved from starts at the node's nonce
+	other := &NonceManager{Next: m.Next}
+	other.Resync(testFrom)
+	if got := reserveNonces(t, other, 1); !slices.Equal(got, []uint64{7}) {
+		t.Errorf("reserved %v, want [7]", got)
+	}
+}
+
+func TestIsNonceError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("nonce too low"), true},
+		{errors.New("Nonce too low: address 0x1, tx: 5 state: 6"), true},
+		{errors.New("already known"), true},
+		{errors.New("replacement transaction underpriced"), true},
+		{errors.Join(errors.New("nonce 5 was"), ErrNonceUsed), true},
+		{errors.New("insufficient funds for gas * price + value"), false},
+		{errors.New("max fee per gas less than block base fee"), false},
+		{nil, false},
+	} {
+		if got := IsNonceError(tc.err); got != tc.want {
+			t.Errorf("IsNonceError(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WaitReceipt waits for the receipt of hash until it has the given number of confirmations,
+// checking on every new block over WebSocket connections and every interval otherwise
+func WaitReceipt(ctx context.Context, client *ethclient.Client, hash common.Hash, confirmations uint64, interval time.Duration) (*types.Receipt, error) {
+	ctx, span := startSpan(ctx, "sender.WaitReceipt", attribute.String("eth.tx_hash", hash.Hex()), attribute.Int64("eth.confirmations", int64(confirmations)))
+	receipt, err := waitReceipt(ctx, client, hash, confirmations, interval)
+	if err == nil {
+		span.SetAttributes(receiptAttributes(receipt)...)
+	}
+	endSpan(span, err)
+	return receipt, err
+}
+
+func waitReceipt(ctx context.Context, client *ethclient.Client, hash common.Hash, confirmations uint64, interval time.Duration) (*types.Receipt, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks := WatchBlocks(ctx, client, interval)
+	for {
+		receipt, err := lookupReceipt(ctx, client, hash)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			if confirmations <= 1 {
+				return receipt, nil
+			}
+			head, err := client.BlockNumber(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// the receipt is re-fetched every round so a reorg moves it to its new block
+			if head+1 >= receipt.BlockNumber.Uint64()+confirmations {
+				return receipt, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-blocks:
+		}
+	}
+}
+
+// receiptAttributes describe the outcome of a mined transaction on a span
+func receiptAttributes(receipt *types.Receipt) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("eth.tx_hash", receipt.TxHash.Hex()),
+		attribute.Int64("eth.block", receipt.BlockNumber.Int64()),
+		attribute.Int64("eth.status", int64(receipt.Status)),
+		attribute.Int64("eth.gas_used", int64(receipt.GasUsed)),
+	}
+}
+
+// lookupReceipt returns the receipt of hash, or nil if it is not mined yet
+func lookupReceipt(ctx context.Context, client *ethclient.Client, hash common.Hash) (*types.Receipt, error) {
+	receipt, err := client.TransactionReceipt(ctx, hash)
+	// freshly started geth nodes report missing receipts as "indexing in progress"
+	if errors.Is(err, ethereum.NotFound) || (err != nil && strings.Contains(err.Error(), "indexing is in progress")) {
+		return nil, nil
+	}
+	return receipt, err
+}
+
+// WatchBlocks signals on the returned channel whenever a new block may have arrived: on each
+// header of a newHeads subscription over WebSocket and IPC connections, and every interval over
+// HTTP or once the subscription fails. Signals are dropped while one is pending, and the
+// channel is closed when ctx is done
+func WatchBlocks(ctx context.Context, client *ethclient.Client, interval time.Duration) <-chan struct{} {
+	blocks := make(chan struct{}, 1)
+	signal := func() {
+		select {
+		case blocks <- struct{}{}:
+		default:
+		}
+	}
+	go func() {
+		defer close(blocks)
+		headers := make(chan *types.Header, 16)
+		if sub, err := client.SubscribeNewHead(ctx, headers); err == nil {
+			defer sub.Unsubscribe()
+		subscribed:
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-headers:
+					signal()
+				case <-sub.Err():
+					// the subscription ended, fall back to polling
+					break subscribed
+				}
+			}
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				signal()
+			}
+		}
+	}()
+	return blocks
+}
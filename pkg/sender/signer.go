@@ -0,0 +1,44 @@
+package sender
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Signer signs transactions on behalf of a single account
+type Signer interface {
+	Address() common.Address
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// KeySigner signs with a private key held in memory
+type KeySigner struct {
+	Key *ecdsa.PrivateKey
+}
+
+// NewKeySigner returns a signer for the account of key
+func NewKeySigner(key *ecdsa.PrivateKey) *KeySigner {
+	return &KeySigner{Key: key}
+}
+
+func (s *KeySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.Key.PublicKey)
+}
+
+func (s *KeySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.Key)
+}
+
+// Zero overwrites the private key in memory, after which s can no longer sign. Copies made by
+// the caller, such as the hex string the key was parsed from, are not reached
+func (s *KeySigner) Zero() {
+	words := s.Key.D.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	s.Key.D.SetInt64(0)
+}
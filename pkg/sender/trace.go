@@ -0,0 +1,64 @@
+package sender
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the OpenTelemetry tracer of the package. Its spans go to the global
+// tracer provider, which drops them until the program installs one with otel.SetTracerProvider
+const TracerName = "github.com/gmh5225/EIP1559-sender/pkg/sender"
+
+// startSpan starts a span of the package as a child of the span of ctx, if any
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, recording err as the reason it failed
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// txAttributes describe tx on a span
+func txAttributes(tx *types.Transaction) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("eth.tx_hash", tx.Hash().Hex()),
+		attribute.Int64("eth.nonce", int64(tx.Nonce())),
+		attribute.Int64("eth.gas", int64(tx.Gas())),
+	}
+	if tx.To() != nil {
+		attrs = append(attrs, attribute.String("eth.to", tx.To().Hex()))
+	}
+	return attrs
+}
+
+// SignTx signs tx with signer for chainID, in a span of ctx. Signing with a hardware wallet or a
+// remote key service can take longer than the rest of the send
+func SignTx(ctx context.Context, signer Signer, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	_, span := startSpan(ctx, "sender.SignTx", attribute.String("eth.from", signer.Address().Hex()), attribute.Int64("eth.nonce", int64(tx.Nonce())))
+	signed, err := signer.SignTx(tx, chainID)
+	if err == nil {
+		span.SetAttributes(attribute.String("eth.tx_hash", signed.Hash().Hex()))
+	}
+	endSpan(span, err)
+	return signed, err
+}
+
+// Broadcast sends the signed tx with eth_sendRawTransaction, in a span of ctx
+func Broadcast(ctx context.Context, client *ethclient.Client, tx *types.Transaction) error {
+	ctx, span := startSpan(ctx, "sender.Broadcast", txAttributes(tx)...)
+	err := client.SendTransaction(ctx, tx)
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,134 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrNonceUsed is returned by a Tracker whose nonce was mined in a transaction it does not follow
+var ErrNonceUsed = errors.New("used by another transaction")
+
+// Tracker follows every version of one transaction, the original and the replacements sent with
+// the same nonce to raise its fees, and reports the one that got mined. It is safe for
+// concurrent use, so replacements can be added while another goroutine waits
+type Tracker struct {
+	client *ethclient.Client
+	from   common.Address
+	nonce  uint64
+
+	mu     sync.Mutex
+	hashes []common.Hash
+}
+
+// NewTracker returns a Tracker following tx, sent by from
+func NewTracker(client *ethclient.Client, from common.Address, tx *types.Transaction) *Tracker {
+	return &Tracker{client: client, from: from, nonce: tx.Nonce(), hashes: []common.Hash{tx.Hash()}}
+}
+
+// ResumeTracker returns a Tracker following hashes, the versions of the transaction from sent at
+// nonce in the order they were sent, such as those an earlier run of the program was following
+func ResumeTracker(client *ethclient.Client, from common.Address, nonce uint64, hashes []common.Hash) *Tracker {
+	return &Tracker{client: client, from: from, nonce: nonce, hashes: slices.Clone(hashes)}
+}
+
+// Add follows one more version of the transaction, which must have been sent with the same nonce
+func (t *Tracker) Add(hash common.Hash) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !slices.Contains(t.hashes, hash) {
+		t.hashes = append(t.hashes, hash)
+	}
+}
+
+// Hashes returns the hashes of every version followed, in the order they were added
+func (t *Tracker) Hashes() []common.Hash {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.hashes)
+}
+
+// Check looks up the receipt of every version once. It returns the receipt of the version that
+// was mined, whose TxHash is the canonical hash, nil if none is mined yet, or ErrNonceUsed
+func (t *Tracker) Check(ctx context.Context) (*types.Receipt, error) {
+	hashes := t.Hashes()
+	if receipt, err := t.lookup(ctx, hashes); receipt != nil || err != nil {
+		return receipt, err
+	}
+	mined, err := t.client.NonceAt(ctx, t.from, nil)
+	if err != nil {
+		return nil, err
+	}
+	if mined <= t.nonce {
+		return nil, nil
+	}
+	// one of the versions, or a replacement added meanwhile, may have been mined since its
+	// receipt was looked up
+	if receipt, err := t.lookup(ctx, t.Hashes()); receipt != nil || err != nil {
+		return receipt, err
+	}
+	return nil, fmt.Errorf("nonce %d was %w", t.nonce, ErrNonceUsed)
+}
+
+// lookup returns the first receipt found for hashes, or nil if there is none
+func (t *Tracker) lookup(ctx context.Context, hashes []common.Hash) (*types.Receipt, error) {
+	for _, hash := range hashes {
+		receipt, err := lookupReceipt(ctx, t.client, hash)
+		if receipt != nil || err != nil {
+			return receipt, err
+		}
+	}
+	return nil, nil
+}
+
+// Wait waits until one of the versions is mined and has the given number of confirmations,
+// checking on every new block like WaitReceipt. Every version is looked up again each round,
+// so a reorg that gets another version mined is followed to the new one
+func (t *Tracker) Wait(ctx context.Context, confirmations uint64, interval time.Duration) (*types.Receipt, error) {
+	ctx, span := startSpan(ctx, "sender.Tracker.Wait", attribute.String("eth.from", t.from.Hex()), attribute.Int64("eth.nonce", int64(t.nonce)),
+		attribute.Int64("eth.confirmations", int64(confirmations)))
+	receipt, err := t.wait(ctx, confirmations, interval)
+	span.SetAttributes(attribute.Int("sender.versions", len(t.Hashes())))
+	if err == nil {
+		span.SetAttributes(receiptAttributes(receipt)...)
+	}
+	endSpan(span, err)
+	return receipt, err
+}
+
+func (t *Tracker) wait(ctx context.Context, confirmations uint64, interval time.Duration) (*types.Receipt, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks := WatchBlocks(ctx, t.client, interval)
+	for {
+		receipt, err := t.Check(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			if confirmations <= 1 {
+				return receipt, nil
+			}
+			head, err := t.client.BlockNumber(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if head+1 >= receipt.BlockNumber.Uint64()+confirmations {
+				return receipt, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-blocks:
+		}
+	}
+}
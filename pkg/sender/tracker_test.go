@@ -0,0 +1,264 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var (
+	testOriginal    = common.HexToHash("0x01")
+	testReplacement = common.HexToHash("0x02")
+	testOther       = common.HexToHash("0x03")
+)
+
+// fakeNode answers the JSON-RPC calls of a Tracker from receipts, the mined nonce of testFrom
+// and the head block number
+type fakeNode struct {
+	mu       sync.Mutex
+	receipts map[common.Hash]*types.Receipt
+	nonce    uint64
+	head     uint64
+	onNonce  func(n *fakeNode) // called, with mu held, before the mined nonce is answered
+}
+
+// mine gives hash a receipt in block and advances the mined nonce past nonce
+func (n *fakeNode) mine(hash common.Hash, block, nonce uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.mineLocked(hash, block, nonce)
+}
+
+func (n *fakeNode) mineLocked(hash common.Hash, block, nonce uint64) {
+	n.receipts[hash] = &types.Receipt{
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      hash,
+		BlockHash:   common.BigToHash(new(big.Int).SetUint64(block)),
+		BlockNumber: new(big.Int).SetUint64(block),
+		Logs:        []*types.Log{},
+	}
+	n.nonce = max(n.nonce, nonce+1)
+	n.head = max(n.head, block)
+}
+
+// reorg drops the receipt of hash, leaving its nonce mined
+func (n *fakeNode) reorg(hash common.Hash) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.receipts, hash)
+}
+
+func (n *fakeNode) setHead(head uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.head = head
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n.mu.Lock()
+	var result any
+	switch req.Method {
+	case "eth_getTransactionReceipt":
+		var hash common.Hash
+		json.Unmarshal(req.Params[0], &hash)
+		if receipt, ok := n.receipts[hash]; ok {
+			result = receipt
+		}
+	case "eth_getTransactionCount":
+		if n.onNonce != nil {
+			n.onNonce(n)
+		}
+		result = hexutil.Uint64(n.nonce)
+	case "eth_blockNumber":
+		result = hexutil.Uint64(n.head)
+	default:
+		n.mu.Unlock()
+		http.Error(w, "unexpected method "+req.Method, http.StatusBadRequest)
+		return
+	}
+	n.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+// testTracker returns a Tracker of nonce 5 following hashes on a fresh fake node
+func testTracker(t *testing.T, hashes ...common.Hash) (*Tracker, *fakeNode) {
+	t.Helper()
+	node := &fakeNode{receipts: map[common.Hash]*types.Receipt{}, nonce: 5, head: 100}
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return ResumeTracker(client, testFrom, 5, hashes), node
+}
+
+func TestTrackerCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		follow  []common.Hash
+		mine    common.Hash // mined with nonce 5 if set
+		onNonce func(n *fakeNode)
+		want    common.Hash // the receipt returned, zero for none
+		wantErr error
+	}{
+		{name: "pending", follow: []common.Hash{testOriginal}},
+		{name: "original mined", follow: []common.Hash{testOriginal, testReplacement}, mine: testOriginal, want: testOriginal},
+		{name: "replacement mined", follow: []common.Hash{testOriginal, testReplacement}, mine: testReplacement, want: testReplacement},
+		{name: "nonce used elsewhere", follow: []common.Hash{testOriginal, testReplacement}, mine: testOther, wantErr: ErrNonceUsed},
+		{
+			// the replacement is mined after its receipt was looked up, but before the nonce was
+			name:    "mined between lookups",
+			follow:  []common.Hash{testOriginal, testReplacement},
+			onNonce: func(n *fakeNode) { n.mineLocked(testReplacement, 101, 5) },
+			want:    testReplacement,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker, node := testTracker(t, tc.follow...)
+			if tc.mine != (common.Hash{}) {
+				node.mine(tc.mine, 101, 5)
+			}
+			node.onNonce = tc.onNonce
+			receipt, err := tracker.Check(context.Background())
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Check error = %v, want %v", err, tc.wantErr)
+			}
+			if got := receiptHash(receipt); got != tc.want {
+				t.Errorf("Check = receipt of %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrackerAdd(t *testing.T) {
+	tracker, node := testTracker(t, testOriginal)
+	tracker.Add(testReplacement)
+	tracker.Add(testOriginal)
+	if got := tracker.Hashes(); len(got) != 2 || got[0] != testOriginal || got[1] != testReplacement {
+		t.Fatalf("Hashes = %v, want the original and the replacement once each", got)
+	}
+	node.mine(testReplacement, 101, 5)
+	receipt, err := tracker.Check(context.Background())
+	if err != nil || receiptHash(receipt) != testReplacement {
+		t.Errorf("Check = %v, %v, want the receipt of the added replacement", receiptHash(receipt), err)
+	}
+}
+
+func TestTrackerWait(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		confirmations uint64
+		// change runs while Wait is waiting on the original
+		change func(tracker *Tracker, node *fakeNode)
+		want   common.Hash
+	}{
+		{
+			name: "replacement added while waiting",
+			change: func(tracker *Tracker, node *fakeNode) {
+				tracker.Add(testReplacement)
+				node.mine(testReplacement, 101, 5)
+			},
+			want: testReplacement,
+		},
+		{
+			name:          "confirmations",
+			confirmations: 3,
+			change: func(tracker *Tracker, node *fakeNode) {
+				node.mine(testOriginal, 101, 5)
+				time.Sleep(20 * time.Millisecond)
+				node.setHead(103)
+			},
+			want: testOriginal,
+		},
+		{
+			// a reorg drops the original before it is confirmed and mines the replacement
+			name:          "reorg to the replacement",
+			confirmations: 3,
+			change: func(tracker *Tracker, node *fakeNode) {
+				tracker.Add(testReplacement)
+				node.mine(testOriginal, 101, 5)
+				time.Sleep(20 * time.Millisecond)
+				node.reorg(testOriginal)
+				node.mine(testReplacement, 102, 5)
+				time.Sleep(20 * time.Millisecond)
+				node.setHead(104)
+			},
+			want: testReplacement,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker, node := testTracker(t, testOriginal)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			type result struct {
+				receipt *types.Receipt
+				err     error
+			}
+			done := make(chan result, 1)
+			go func() {
+				receipt, err := tracker.Wait(ctx, tc.confirmations, time.Millisecond)
+				done <- result{receipt, err}
+			}()
+			time.Sleep(20 * time.Millisecond)
+			select {
+			case r := <-done:
+				t.Fatalf("Wait returned %s, %v before anything was mined", receiptHash(r.receipt), r.err)
+			default:
+			}
+			tc.change(tracker, node)
+			r := <-done
+			if r.err != nil || receiptHash(r.receipt) != tc.want {
+				t.Errorf("Wait = receipt of %s, %v, want %s", receiptHash(r.receipt), r.err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrackerWaitNonceUsed(t *testing.T) {
+	tracker, node := testTracker(t, testOriginal)
+	node.mine(testOther, 101, 5)
+	_, err := tracker.Wait(context.Background(), 1, time.Millisecond)
+	if !errors.Is(err, ErrNonceUsed) {
+		t.Errorf("Wait error = %v, want ErrNonceUsed", err)
+	}
+}
+
+func TestTrackerWaitCanceled(t *testing.T) {
+	tracker, _ := testTracker(t, testOriginal)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tracker.Wait(ctx, 1, time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait error = %v, want the context's", err)
+	}
+}
+
+// receiptHash returns the TxHash of receipt, or the zero hash for none
+func receiptHash(receipt *types.Receipt) common.Hash {
+	if receipt == nil {
+		return common.Hash{}
+	}
+	return receipt.TxHash
+}
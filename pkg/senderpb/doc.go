@@ -0,0 +1,5 @@
+// Package senderpb holds the gRPC interface of the eip1559-sender server, generated from
+// sender.proto.
+package senderpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative sender.proto
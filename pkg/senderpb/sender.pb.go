@@ -0,0 +1,460 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: sender.proto
+
+package senderpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type TransactionStatus_State int32
+
+const (
+	TransactionStatus_STATE_UNSPECIFIED TransactionStatus_State = 0
+	// Waiting in the node's pool
+	TransactionStatus_PENDING TransactionStatus_State = 1
+	// Mined with fewer confirmations than requested
+	TransactionStatus_INCLUDED TransactionStatus_State = 2
+	// Mined successfully with the requested confirmations, final
+	TransactionStatus_CONFIRMED TransactionStatus_State = 3
+	// Mined but reverted, final
+	TransactionStatus_FAILED TransactionStatus_State = 4
+	// Gone from the node's pool without being mined, final
+	TransactionStatus_DROPPED TransactionStatus_State = 5
+)
+
+// Enum value maps for TransactionStatus_State.
+var (
+	TransactionStatus_State_name = map[int32]string{
+		0: "STATE_UNSPECIFIED",
+		1: "PENDING",
+		2: "INCLUDED",
+		3: "CONFIRMED",
+		4: "FAILED",
+		5: "DROPPED",
+	}
+	TransactionStatus_State_value = map[string]int32{
+		"STATE_UNSPECIFIED": 0,
+		"PENDING":           1,
+		"INCLUDED":          2,
+		"CONFIRMED":         3,
+		"FAILED":            4,
+		"DROPPED":           5,
+	}
+)
+
+func (x TransactionStatus_State) Enum() *TransactionStatus_State {
+	p := new(TransactionStatus_State)
+	*p = x
+	return p
+}
+
+func (x TransactionStatus_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TransactionStatus_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_sender_proto_enumTypes[0].Descriptor()
+}
+
+func (TransactionStatus_State) Type() protoreflect.EnumType {
+	return &file_sender_proto_enumTypes[0]
+}
+
+func (x TransactionStatus_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TransactionStatus_State.Descriptor instead.
+func (TransactionStatus_State) EnumDescriptor() ([]byte, []int) {
+	return file_sender_proto_rawDescGZIP(), []int{3, 0}
+}
+
+type SendRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Receiver of the native coin, or of the tokens with token_contract
+	To string `protobuf:"bytes,1,opt,name=to,proto3" json:"to,omitempty"`
+	// Amount as a decimal integer in the smallest unit: Wei, or the token's base units
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	// ERC-20 contract to transfer value of, empty for the native coin
+	TokenContract string `protobuf:"bytes,3,opt,name=token_contract,json=tokenContract,proto3" json:"token_contract,omitempty"`
+	// Calldata of a native coin transaction
+	Data []byte `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	// Gas limit, 0 to estimate it
+	GasLimit      uint64 `protobuf:"varint,5,opt,name=gas_limit,json=gasLimit,proto3" json:"gas_limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendRequest) Reset() {
+	*x = SendRequest{}
+	mi := &file_sender_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendRequest) ProtoMessage() {}
+
+func (x *SendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sender_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendRequest.ProtoReflect.Descriptor instead.
+func (*SendRequest) Descriptor() ([]byte, []int) {
+	return file_sender_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SendRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *SendRequest) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *SendRequest) GetTokenContract() string {
+	if x != nil {
+		return x.TokenContract
+	}
+	return ""
+}
+
+func (x *SendRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *SendRequest) GetGasLimit() uint64 {
+	if x != nil {
+		return x.GasLimit
+	}
+	return 0
+}
+
+type SendResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          string                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	From          string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	Nonce         uint64                 `protobuf:"varint,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendResponse) Reset() {
+	*x = SendResponse{}
+	mi := &file_sender_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendResponse) ProtoMessage() {}
+
+func (x *SendResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sender_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendResponse.ProtoReflect.Descriptor instead.
+func (*SendResponse) Descriptor() ([]byte, []int) {
+	return file_sender_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SendResponse) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *SendResponse) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *SendResponse) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+type WatchTransactionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Hash  string                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	// Confirmations after which the transaction counts as confirmed, the server's -confirmations if 0
+	Confirmations uint64 `protobuf:"varint,2,opt,name=confirmations,proto3" json:"confirmations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchTransactionRequest) Reset() {
+	*x = WatchTransactionRequest{}
+	mi := &file_sender_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchTransactionRequest) ProtoMessage() {}
+
+func (x *WatchTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sender_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchTransactionRequest.ProtoReflect.Descriptor instead.
+func (*WatchTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_sender_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WatchTransactionRequest) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *WatchTransactionRequest) GetConfirmations() uint64 {
+	if x != nil {
+		return x.Confirmations
+	}
+	return 0
+}
+
+type TransactionStatus struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	Hash          string                  `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	State         TransactionStatus_State `protobuf:"varint,2,opt,name=state,proto3,enum=eip1559sender.v1.TransactionStatus_State" json:"state,omitempty"`
+	BlockNumber   uint64                  `protobuf:"varint,3,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	Confirmations uint64                  `protobuf:"varint,4,opt,name=confirmations,proto3" json:"confirmations,omitempty"`
+	GasUsed       uint64                  `protobuf:"varint,5,opt,name=gas_used,json=gasUsed,proto3" json:"gas_used,omitempty"`
+	// Price paid per gas in Wei, as a decimal integer
+	EffectiveGasPrice string `protobuf:"bytes,6,opt,name=effective_gas_price,json=effectiveGasPrice,proto3" json:"effective_gas_price,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *TransactionStatus) Reset() {
+	*x = TransactionStatus{}
+	mi := &file_sender_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionStatus) ProtoMessage() {}
+
+func (x *TransactionStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_sender_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionStatus.ProtoReflect.Descriptor instead.
+func (*TransactionStatus) Descriptor() ([]byte, []int) {
+	return file_sender_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TransactionStatus) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *TransactionStatus) GetState() TransactionStatus_State {
+	if x != nil {
+		return x.State
+	}
+	return TransactionStatus_STATE_UNSPECIFIED
+}
+
+func (x *TransactionStatus) GetBlockNumber() uint64 {
+	if x != nil {
+		return x.BlockNumber
+	}
+	return 0
+}
+
+func (x *TransactionStatus) GetConfirmations() uint64 {
+	if x != nil {
+		return x.Confirmations
+	}
+	return 0
+}
+
+func (x *TransactionStatus) GetGasUsed() uint64 {
+	if x != nil {
+		return x.GasUsed
+	}
+	return 0
+}
+
+func (x *TransactionStatus) GetEffectiveGasPrice() string {
+	if x != nil {
+		return x.EffectiveGasPrice
+	}
+	return ""
+}
+
+var File_sender_proto protoreflect.FileDescriptor
+
+const file_sender_proto_rawDesc = "" +
+	"\n" +
+	"\fsender.proto\x12\x10eip1559sender.v1\"\x8b\x01\n" +
+	"\vSendRequest\x12\x0e\n" +
+	"\x02to\x18\x01 \x01(\tR\x02to\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\x12%\n" +
+	"\x0etoken_contract\x18\x03 \x01(\tR\rtokenContract\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\fR\x04data\x12\x1b\n" +
+	"\tgas_limit\x18\x05 \x01(\x04R\bgasLimit\"L\n" +
+	"\fSendResponse\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\tR\x04from\x12\x14\n" +
+	"\x05nonce\x18\x03 \x01(\x04R\x05nonce\"S\n" +
+	"\x17WatchTransactionRequest\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\x12$\n" +
+	"\rconfirmations\x18\x02 \x01(\x04R\rconfirmations\"\xdf\x02\n" +
+	"\x11TransactionStatus\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\x12?\n" +
+	"\x05state\x18\x02 \x01(\x0e2).eip1559sender.v1.TransactionStatus.StateR\x05state\x12!\n" +
+	"\fblock_number\x18\x03 \x01(\x04R\vblockNumber\x12$\n" +
+	"\rconfirmations\x18\x04 \x01(\x04R\rconfirmations\x12\x19\n" +
+	"\bgas_used\x18\x05 \x01(\x04R\agasUsed\x12.\n" +
+	"\x13effective_gas_price\x18\x06 \x01(\tR\x11effectiveGasPrice\"a\n" +
+	"\x05State\x12\x15\n" +
+	"\x11STATE_UNSPECIFIED\x10\x00\x12\v\n" +
+	"\aPENDING\x10\x01\x12\f\n" +
+	"\bINCLUDED\x10\x02\x12\r\n" +
+	"\tCONFIRMED\x10\x03\x12\n" +
+	"\n" +
+	"\x06FAILED\x10\x04\x12\v\n" +
+	"\aDROPPED\x10\x052\xb5\x01\n" +
+	"\x06Sender\x12E\n" +
+	"\x04Send\x12\x1d.eip1559sender.v1.SendRequest\x1a\x1e.eip1559sender.v1.SendResponse\x12d\n" +
+	"\x10WatchTransaction\x12).eip1559sender.v1.WatchTransactionRequest\x1a#.eip1559sender.v1.TransactionStatus0\x01B0Z.github.com/gmh5225/EIP1559-sender/pkg/senderpbb\x06proto3"
+
+var (
+	file_sender_proto_rawDescOnce sync.Once
+	file_sender_proto_rawDescData []byte
+)
+
+func file_sender_proto_rawDescGZIP() []byte {
+	file_sender_proto_rawDescOnce.Do(func() {
+		file_sender_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sender_proto_rawDesc), len(file_sender_proto_rawDesc)))
+	})
+	return file_sender_proto_rawDescData
+}
+
+var file_sender_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_sender_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_sender_proto_goTypes = []any{
+	(TransactionStatus_State)(0),    // 0: eip1559sender.v1.TransactionStatus.State
+	(*SendRequest)(nil),             // 1: eip1559sender.v1.SendRequest
+	(*SendResponse)(nil),            // 2: eip1559sender.v1.SendResponse
+	(*WatchTransactionRequest)(nil), // 3: eip1559sender.v1.WatchTransactionRequest
+	(*TransactionStatus)(nil),       // 4: eip1559sender.v1.TransactionStatus
+}
+var file_sender_proto_depIdxs = []int32{
+	0, // 0: eip1559sender.v1.TransactionStatus.state:type_name -> eip1559sender.v1.TransactionStatus.State
+	1, // 1: eip1559sender.v1.Sender.Send:input_type -> eip1559sender.v1.SendRequest
+	3, // 2: eip1559sender.v1.Sender.WatchTransaction:input_type -> eip1559sender.v1.WatchTransactionRequest
+	2, // 3: eip1559sender.v1.Sender.Send:output_type -> eip1559sender.v1.SendResponse
+	4, // 4: eip1559sender.v1.Sender.WatchTransaction:output_type -> eip1559sender.v1.TransactionStatus
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_sender_proto_init() }
+func file_sender_proto_init() {
+	if File_sender_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sender_proto_rawDesc), len(file_sender_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sender_proto_goTypes,
+		DependencyIndexes: file_sender_proto_depIdxs,
+		EnumInfos:         file_sender_proto_enumTypes,
+		MessageInfos:      file_sender_proto_msgTypes,
+	}.Build()
+	File_sender_proto = out.File
+	file_sender_proto_goTypes = nil
+	file_sender_proto_depIdxs = nil
+}
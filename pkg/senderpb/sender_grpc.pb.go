@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sender.proto
+
+package senderpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Sender_Send_FullMethodName             = "/eip1559sender.v1.Sender/Send"
+	Sender_WatchTransaction_FullMethodName = "/eip1559sender.v1.Sender/WatchTransaction"
+)
+
+// SenderClient is the client API for Sender service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Sender sends transactions from the server's account and reports their progress
+type SenderClient interface {
+	// Send builds, signs and broadcasts a transfer, returning once the node accepted it
+	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error)
+	// WatchTransaction streams the status of a transaction every time it changes, until it is
+	// confirmed, failed or dropped
+	WatchTransaction(ctx context.Context, in *WatchTransactionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransactionStatus], error)
+}
+
+type senderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSenderClient(cc grpc.ClientConnInterface) SenderClient {
+	return &senderClient{cc}
+}
+
+func (c *senderClient) Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendResponse)
+	err := c.cc.Invoke(ctx, Sender_Send_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *senderClient) WatchTransaction(ctx context.Context, in *WatchTransactionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransactionStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Sender_ServiceDesc.Streams[0], Sender_WatchTransaction_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchTransactionRequest, TransactionStatus]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Sender_WatchTransactionClient = grpc.ServerStreamingClient[TransactionStatus]
+
+// SenderServer is the server API for Sender service.
+// All implementations must embed UnimplementedSenderServer
+// for forward compatibility.
+//
+// Sender sends transactions from the server's account and reports their progress
+type SenderServer interface {
+	// Send builds, signs and broadcasts a transfer, returning once the node accepted it
+	Send(context.Context, *SendRequest) (*SendResponse, error)
+	// WatchTransaction streams the status of a transaction every time it changes, until it is
+	// confirmed, failed or dropped
+	WatchTransaction(*WatchTransactionRequest, grpc.ServerStreamingServer[TransactionStatus]) error
+	mustEmbedUnimplementedSenderServer()
+}
+
+// UnimplementedSenderServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSenderServer struct{}
+
+func (UnimplementedSenderServer) Send(context.Context, *SendRequest) (*SendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedSenderServer) WatchTransaction(*WatchTransactionRequest, grpc.ServerStreamingServer[TransactionStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTransaction not implemented")
+}
+func (UnimplementedSenderServer) mustEmbedUnimplementedSenderServer() {}
+func (UnimplementedSenderServer) testEmbeddedByValue()                {}
+
+// UnsafeSenderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SenderServer will
+// result in compilation errors.
+type UnsafeSenderServer interface {
+	mustEmbedUnimplementedSenderServer()
+}
+
+func RegisterSenderServer(s grpc.ServiceRegistrar, srv SenderServer) {
+	// If the following call pancis, it indicates UnimplementedSenderServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Sender_ServiceDesc, srv)
+}
+
+func _Sender_Send_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SenderServer).Send(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sender_Send_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SenderServer).Send(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sender_WatchTransaction_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTransactionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SenderServer).WatchTransaction(m, &grpc.GenericServerStream[WatchTransactionRequest, TransactionStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Sender_WatchTransactionServer = grpc.ServerStreamingServer[TransactionStatus]
+
+// Sender_ServiceDesc is the grpc.ServiceDesc for Sender service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sender_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "eip1559sender.v1.Sender",
+	HandlerType: (*SenderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Send",
+			Handler:    _Sender_Send_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTransaction",
+			Handler:       _Sender_WatchTransaction_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sender.proto",
+}